@@ -0,0 +1,179 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/audit"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/ets"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+type session interface {
+	Inbound() <-chan cemi.Message
+	Close()
+}
+
+var (
+	projectPath = flag.String("project", "", "ETS project or group address export")
+	gatewayAddr = flag.String("gateway", "", "KNXnet/IP gateway to open a tunnel to")
+	routerAddr  = flag.String("router", "", "KNXnet/IP multicast address to listen on")
+	replayPath  = flag.String("replay", "", "recording to audit instead of a live session")
+	recordPath  = flag.String("record", "", "file to record the live session to")
+	duration    = flag.Duration("duration", 0, "duration of the live session, until interrupted if 0")
+	asJSON      = flag.Bool("json", false, "write the report as JSON")
+	verbose     = flag.Bool("verbose", false, "log the activity of the KNX components")
+)
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr,
+		"Usage: %s -project <file> (-gateway <addr> | -router <addr> | -replay <file>)\n",
+		os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = printUsage
+	flag.Parse()
+
+	sources := 0
+	for _, source := range []string{*gatewayAddr, *routerAddr, *replayPath} {
+		if source != "" {
+			sources++
+		}
+	}
+
+	if *projectPath == "" || sources != 1 {
+		printUsage()
+		os.Exit(2)
+	}
+
+	if *verbose {
+		util.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+
+	project, err := ets.LoadProject(*projectPath)
+	if err != nil {
+		log.Fatalf("Failed to read project: %v", err)
+	}
+
+	auditor := audit.New(project)
+
+	if *replayPath != "" {
+		err = replay(auditor, *replayPath)
+	} else {
+		err = listen(auditor)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report := auditor.Report()
+
+	if *asJSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteReport(os.Stdout)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !report.Clean() {
+		os.Exit(1)
+	}
+}
+
+func replay(auditor *audit.Audit, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	return audit.ReadRecording(file, func(_ time.Time, msg cemi.Message) error {
+		auditor.Record(msg)
+		return nil
+	})
+}
+
+func connect() (session, error) {
+	if *gatewayAddr != "" {
+		return knx.NewTunnel(*gatewayAddr, knxnet.TunnelLayerData, knx.DefaultTunnelConfig)
+	}
+
+	return knx.NewRouter(*routerAddr, knx.DefaultRouterConfig)
+}
+
+func listen(auditor *audit.Audit) error {
+	sess, err := connect()
+	if err != nil {
+		return err
+	}
+
+	defer sess.Close()
+
+	var recorder *audit.Recorder
+	if *recordPath != "" {
+		file, err := os.Create(*recordPath)
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		recorder = audit.NewRecorder(file, nil)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	var timeout <-chan time.Time
+	if *duration > 0 {
+		timeout = time.After(*duration)
+	}
+
+	fmt.Fprintln(os.Stderr, "Listening, press Ctrl+C to stop")
+
+	for {
+		select {
+		case msg, open := <-sess.Inbound():
+			if !open {
+				return errors.New("Connection closed")
+			}
+
+			// System broadcasts are audited like any other frame.
+			if sb, ok := msg.(*knx.SystemBroadcast); ok {
+				msg = sb.Message
+			}
+
+			auditor.Record(msg)
+
+			if recorder != nil {
+				if err := recorder.Record(msg); err != nil {
+					return err
+				}
+			}
+
+		case <-timeout:
+			return nil
+
+		case <-interrupt:
+			return nil
+		}
+	}
+}
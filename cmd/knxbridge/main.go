@@ -142,6 +142,11 @@ func (br *bridge) serve() error {
 				return errors.New("Router channel closed")
 			}
 
+			// System broadcasts are forwarded like any other frame.
+			if sb, ok := msg.(*knx.SystemBroadcast); ok {
+				msg = sb.Message
+			}
+
 			if ind, ok := msg.(*cemi.LDataInd); ok {
 				util.Log(br, "%+v", ind)
 				if err := br.tunnel.Send(&cemi.LDataReq{LData: ind.LData}); err != nil {
@@ -0,0 +1,368 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Package anomaly watches bus traffic for behaviour that indicates misconfigured or failing
+// devices, such as telegram storms or fighting senders.
+package anomaly
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// ProtectedRange is a range of group addresses which only the given devices may write to.
+type ProtectedRange struct {
+	First   cemi.GroupAddr
+	Last    cemi.GroupAddr
+	Allowed []cemi.IndividualAddr
+}
+
+// contains determines whether the group address is inside the range.
+func (rng ProtectedRange) contains(addr cemi.GroupAddr) bool {
+	return addr >= rng.First && addr <= rng.Last
+}
+
+// allows determines whether the device may write to the range.
+func (rng ProtectedRange) allows(source cemi.IndividualAddr) bool {
+	for _, allowed := range rng.Allowed {
+		if allowed == source {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Config allows you to configure the detector.
+type Config struct {
+	// A storm is reported when a device sends more than StormThreshold telegrams within
+	// StormWindow.
+	StormWindow    time.Duration
+	StormThreshold int
+
+	// CollisionWindow is the time within which writes of different values by different devices
+	// to the same group address are considered a collision.
+	CollisionWindow time.Duration
+
+	// Protected contains the group address ranges which only certain devices may write to.
+	Protected []ProtectedRange
+
+	// A device is reported when at least RepeatRatio of its last RepeatTelegrams telegrams have
+	// been repeated.
+	RepeatTelegrams int
+	RepeatRatio     float64
+
+	// GroupPriorities are the priorities that are expected for group telegrams. System priority
+	// is reserved for management.
+	GroupPriorities []cemi.Priority
+
+	// UnusualHopCounts are the hop counts that are reported.
+	UnusualHopCounts []uint8
+
+	// Cooldown suppresses repeated reports of the same anomaly.
+	Cooldown time.Duration
+
+	// Clock provides the time at which telegrams are seen.
+	Clock util.Clock
+}
+
+// DefaultConfig is a good default configuration for a detector.
+var DefaultConfig = Config{
+	StormWindow:      time.Second,
+	StormThreshold:   20,
+	CollisionWindow:  2 * time.Second,
+	RepeatTelegrams:  20,
+	RepeatRatio:      0.3,
+	GroupPriorities:  []cemi.Priority{cemi.PrioNormal, cemi.PrioUrgent, cemi.PrioLow},
+	UnusualHopCounts: []uint8{0, 7},
+	Cooldown:         time.Minute,
+	Clock:            util.RealClock,
+}
+
+// checkConfig makes sure that the configuration is actually usable.
+func checkConfig(config Config) Config {
+	if config.StormWindow <= 0 {
+		config.StormWindow = DefaultConfig.StormWindow
+	}
+
+	if config.StormThreshold <= 0 {
+		config.StormThreshold = DefaultConfig.StormThreshold
+	}
+
+	if config.CollisionWindow <= 0 {
+		config.CollisionWindow = DefaultConfig.CollisionWindow
+	}
+
+	if config.RepeatTelegrams <= 0 {
+		config.RepeatTelegrams = DefaultConfig.RepeatTelegrams
+	}
+
+	if config.RepeatRatio <= 0 {
+		config.RepeatRatio = DefaultConfig.RepeatRatio
+	}
+
+	if config.GroupPriorities == nil {
+		config.GroupPriorities = DefaultConfig.GroupPriorities
+	}
+
+	if config.UnusualHopCounts == nil {
+		config.UnusualHopCounts = DefaultConfig.UnusualHopCounts
+	}
+
+	if config.Cooldown < 0 {
+		config.Cooldown = DefaultConfig.Cooldown
+	}
+
+	if config.Clock == nil {
+		config.Clock = DefaultConfig.Clock
+	}
+
+	return config
+}
+
+// sourceState is what the detector knows about a device.
+type sourceState struct {
+	// sent contains the times of the telegrams within the storm window.
+	sent []time.Time
+
+	// repeats contains the repeat flags of the latest telegrams.
+	repeats []bool
+}
+
+// lastWrite is the latest write to a group address.
+type lastWrite struct {
+	source cemi.IndividualAddr
+	value  []byte
+	time   time.Time
+}
+
+// reportKey identifies an anomaly for the cooldown.
+type reportKey struct {
+	kind   string
+	source cemi.IndividualAddr
+	dest   uint16
+}
+
+// A Detector inspects frames and reports anomalies. It is safe for concurrent use.
+type Detector struct {
+	config Config
+
+	mu       sync.Mutex
+	sources  map[cemi.IndividualAddr]*sourceState
+	writes   map[cemi.GroupAddr]lastWrite
+	reported map[reportKey]time.Time
+}
+
+// NewDetector creates a detector.
+func NewDetector(config Config) *Detector {
+	return &Detector{
+		config:   checkConfig(config),
+		sources:  make(map[cemi.IndividualAddr]*sourceState),
+		writes:   make(map[cemi.GroupAddr]lastWrite),
+		reported: make(map[reportKey]time.Time),
+	}
+}
+
+// Inspect examines the message and returns the anomalies it reveals. L_Data.ind and L_Data.con
+// frames as well as L_Busmon.ind frames are inspected; other messages are ignored.
+func (det *Detector) Inspect(msg cemi.Message) []Event {
+	var ldata cemi.LData
+
+	switch msg := msg.(type) {
+	case *cemi.LDataInd:
+		ldata = msg.LData
+
+	case *cemi.LDataCon:
+		if msg.Control1&cemi.Control1HasError != 0 {
+			return nil
+		}
+
+		ldata = msg.LData
+
+	case *cemi.LBusmonInd:
+		frame, ok, err := msg.Frame()
+		if err != nil {
+			util.Log(det, "Failed to parse bus monitor frame: %v", err)
+			return nil
+		}
+
+		if !ok {
+			return nil
+		}
+
+		ldata = frame
+
+	default:
+		return nil
+	}
+
+	return det.inspect(ldata, det.config.Clock.Now())
+}
+
+// Serve inspects the messages until the inbound channel is closed and reports the anomalies
+// through the events channel, which is closed afterwards.
+func (det *Detector) Serve(inbound <-chan cemi.Message, events chan<- Event) {
+	defer close(events)
+
+	for msg := range inbound {
+		for _, event := range det.Inspect(msg) {
+			events <- event
+		}
+	}
+}
+
+// report determines whether an anomaly should be reported, given the cooldown. The caller must
+// hold the lock.
+func (det *Detector) report(key reportKey, now time.Time) bool {
+	if last, ok := det.reported[key]; ok && now.Sub(last) < det.config.Cooldown {
+		return false
+	}
+
+	det.reported[key] = now
+	return true
+}
+
+// inspect examines the frame.
+func (det *Detector) inspect(ldata cemi.LData, now time.Time) []Event {
+	det.mu.Lock()
+	defer det.mu.Unlock()
+
+	header := Header{Time: now, Source: ldata.Source}
+	group := ldata.Control2.IsGroupAddr()
+
+	var events []Event
+
+	state, ok := det.sources[ldata.Source]
+	if !ok {
+		state = &sourceState{}
+		det.sources[ldata.Source] = state
+	}
+
+	// Storms
+	start := 0
+	for start < len(state.sent) && now.Sub(state.sent[start]) >= det.config.StormWindow {
+		start++
+	}
+
+	state.sent = append(state.sent[start:], now)
+
+	if len(state.sent) > det.config.StormThreshold &&
+		det.report(reportKey{"storm", ldata.Source, 0}, now) {
+		events = append(events, &Storm{
+			Header:    header,
+			Telegrams: len(state.sent),
+			Window:    det.config.StormWindow,
+		})
+	}
+
+	// Repeats
+	state.repeats = append(state.repeats, ldata.Control1&cemi.Control1NoRepeat == 0)
+	if len(state.repeats) > det.config.RepeatTelegrams {
+		state.repeats = state.repeats[1:]
+	}
+
+	if len(state.repeats) == det.config.RepeatTelegrams {
+		repeated := 0
+		for _, repeat := range state.repeats {
+			if repeat {
+				repeated++
+			}
+		}
+
+		if float64(repeated) >= det.config.RepeatRatio*float64(len(state.repeats)) &&
+			det.report(reportKey{"repeats", ldata.Source, 0}, now) {
+			events = append(events, &Repeats{
+				Header:    header,
+				Telegrams: len(state.repeats),
+				Repeated:  repeated,
+			})
+		}
+	}
+
+	// Priority
+	prio := ldata.Control1.Priority()
+	if group && !containsPriority(det.config.GroupPriorities, prio) &&
+		det.report(reportKey{"priority", ldata.Source, ldata.Destination}, now) {
+		events = append(events, &Priority{
+			Header:      header,
+			Destination: ldata.Destination,
+			Group:       group,
+			Priority:    prio,
+		})
+	}
+
+	// Hop count
+	if hops := ldata.Control2.Hops(); containsHops(det.config.UnusualHopCounts, hops) &&
+		det.report(reportKey{"hops", ldata.Source, ldata.Destination}, now) {
+		events = append(events, &HopCount{
+			Header:      header,
+			Destination: ldata.Destination,
+			Group:       group,
+			Hops:        hops,
+		})
+	}
+
+	app, ok := ldata.Data.(*cemi.AppData)
+	if !group || !ok || app.Command != cemi.GroupValueWrite {
+		return events
+	}
+
+	dest := cemi.GroupAddr(ldata.Destination)
+
+	// Protected ranges
+	for _, rng := range det.config.Protected {
+		if rng.contains(dest) && !rng.allows(ldata.Source) &&
+			det.report(reportKey{"protected", ldata.Source, ldata.Destination}, now) {
+			events = append(events, &ProtectedWrite{Header: header, Destination: dest, Range: rng})
+			break
+		}
+	}
+
+	// Collisions
+	last, ok := det.writes[dest]
+	if ok && last.source != ldata.Source && now.Sub(last.time) < det.config.CollisionWindow &&
+		!bytes.Equal(last.value, app.Data) &&
+		det.report(reportKey{"collision", ldata.Source, ldata.Destination}, now) {
+		events = append(events, &Collision{
+			Header:      header,
+			Destination: dest,
+			Value:       append([]byte(nil), app.Data...),
+			Other:       last.source,
+			OtherValue:  last.value,
+		})
+	}
+
+	det.writes[dest] = lastWrite{
+		source: ldata.Source,
+		value:  append([]byte(nil), app.Data...),
+		time:   now,
+	}
+
+	return events
+}
+
+// containsPriority determines whether the priority is in the list.
+func containsPriority(prios []cemi.Priority, prio cemi.Priority) bool {
+	for _, other := range prios {
+		if other == prio {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsHops determines whether the hop count is in the list.
+func containsHops(counts []uint8, hops uint8) bool {
+	for _, other := range counts {
+		if other == hops {
+			return true
+		}
+	}
+
+	return false
+}
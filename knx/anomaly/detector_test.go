@@ -0,0 +1,268 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package anomaly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// frame describes a telegram for the tests.
+type frame struct {
+	src      cemi.IndividualAddr
+	dest     cemi.GroupAddr
+	value    byte
+	repeated bool
+	prio     cemi.Priority
+	hops     uint8
+}
+
+// ind creates a L_Data.ind message which writes the value to the group address.
+func (f frame) ind() *cemi.LDataInd {
+	control1 := cemi.Control1StdFrame | cemi.Control1NoSysBroadcast | cemi.Control1Prio(f.prio)
+	if !f.repeated {
+		control1 |= cemi.Control1NoRepeat
+	}
+
+	return &cemi.LDataInd{LData: cemi.LData{
+		Control1:    control1,
+		Control2:    cemi.Control2GroupAddr | cemi.Control2Hops(f.hops),
+		Source:      f.src,
+		Destination: uint16(f.dest),
+		Data:        &cemi.AppData{Command: cemi.GroupValueWrite, Data: []byte{f.value}},
+	}}
+}
+
+var (
+	sensor  = cemi.NewIndividualAddr3(1, 1, 5)
+	switch1 = cemi.NewIndividualAddr3(1, 1, 6)
+	ga      = cemi.NewGroupAddr3(1, 2, 3)
+)
+
+func newTestDetector(config Config) (*Detector, *util.FakeClock) {
+	clock := util.NewFakeClock(time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC))
+	config.Clock = clock
+
+	return NewDetector(config), clock
+}
+
+func normal(src cemi.IndividualAddr, value byte) frame {
+	return frame{src: src, dest: ga, value: value, prio: cemi.PrioLow, hops: 6}
+}
+
+func TestDetector(t *testing.T) {
+	t.Run("Quiet", func(t *testing.T) {
+		det, clock := newTestDetector(DefaultConfig)
+
+		for i := 0; i < 100; i++ {
+			if events := det.Inspect(normal(sensor, 1).ind()); len(events) > 0 {
+				t.Fatalf("Expected no events, got %v", events)
+			}
+
+			clock.Advance(time.Second)
+		}
+	})
+
+	t.Run("Storm", func(t *testing.T) {
+		config := DefaultConfig
+		config.StormThreshold = 5
+		det, clock := newTestDetector(config)
+
+		var storms []*Storm
+		for i := 0; i < 20; i++ {
+			for _, event := range det.Inspect(normal(sensor, 1).ind()) {
+				if storm, ok := event.(*Storm); ok {
+					storms = append(storms, storm)
+				}
+			}
+
+			clock.Advance(100 * time.Millisecond)
+		}
+
+		// The cooldown suppresses further reports.
+		if len(storms) != 1 {
+			t.Fatalf("Expected 1 storm, got %v", len(storms))
+		}
+
+		if storms[0].Source != sensor || storms[0].Telegrams != 6 {
+			t.Fatalf("Unexpected storm %v", storms[0])
+		}
+	})
+
+	t.Run("Collision", func(t *testing.T) {
+		det, clock := newTestDetector(DefaultConfig)
+
+		det.Inspect(normal(sensor, 1).ind())
+		clock.Advance(500 * time.Millisecond)
+		events := det.Inspect(normal(switch1, 0).ind())
+
+		if len(events) != 1 {
+			t.Fatalf("Expected 1 event, got %v", events)
+		}
+
+		collision, ok := events[0].(*Collision)
+		if !ok {
+			t.Fatalf("Expected collision, got %v", events[0])
+		}
+
+		if collision.Source != switch1 || collision.Other != sensor ||
+			collision.Destination != ga || collision.Value[0] != 0 || collision.OtherValue[0] != 1 {
+			t.Fatalf("Unexpected collision %v", collision)
+		}
+	})
+
+	t.Run("NoCollision", func(t *testing.T) {
+		det, clock := newTestDetector(DefaultConfig)
+
+		// Same value
+		det.Inspect(normal(sensor, 1).ind())
+		if events := det.Inspect(normal(switch1, 1).ind()); len(events) > 0 {
+			t.Fatalf("Expected no events, got %v", events)
+		}
+
+		// Outside of the window
+		clock.Advance(time.Minute)
+		if events := det.Inspect(normal(sensor, 0).ind()); len(events) > 0 {
+			t.Fatalf("Expected no events, got %v", events)
+		}
+	})
+
+	t.Run("ProtectedWrite", func(t *testing.T) {
+		config := DefaultConfig
+		config.Protected = []ProtectedRange{{
+			First:   cemi.NewGroupAddr3(1, 2, 0),
+			Last:    cemi.NewGroupAddr3(1, 2, 255),
+			Allowed: []cemi.IndividualAddr{sensor},
+		}}
+		det, clock := newTestDetector(config)
+
+		if events := det.Inspect(normal(sensor, 1).ind()); len(events) > 0 {
+			t.Fatalf("Expected no events, got %v", events)
+		}
+
+		clock.Advance(time.Minute)
+
+		events := det.Inspect(normal(switch1, 1).ind())
+		if len(events) != 1 {
+			t.Fatalf("Expected 1 event, got %v", events)
+		}
+
+		if write, ok := events[0].(*ProtectedWrite); !ok || write.Source != switch1 {
+			t.Fatalf("Unexpected event %v", events[0])
+		}
+	})
+
+	t.Run("Repeats", func(t *testing.T) {
+		config := DefaultConfig
+		config.RepeatTelegrams = 10
+		config.RepeatRatio = 0.5
+		det, clock := newTestDetector(config)
+
+		var reports []*Repeats
+		for i := 0; i < 10; i++ {
+			f := normal(sensor, 1)
+			f.repeated = i%2 == 0
+
+			for _, event := range det.Inspect(f.ind()) {
+				if report, ok := event.(*Repeats); ok {
+					reports = append(reports, report)
+				}
+			}
+
+			clock.Advance(time.Second)
+		}
+
+		if len(reports) != 1 {
+			t.Fatalf("Expected 1 report, got %v", len(reports))
+		}
+
+		if reports[0].Repeated != 5 || reports[0].Ratio() != 0.5 {
+			t.Fatalf("Unexpected report %v", reports[0])
+		}
+	})
+
+	t.Run("Priority", func(t *testing.T) {
+		det, _ := newTestDetector(DefaultConfig)
+
+		f := normal(sensor, 1)
+		f.prio = cemi.PrioSystem
+
+		events := det.Inspect(f.ind())
+		if len(events) != 1 {
+			t.Fatalf("Expected 1 event, got %v", events)
+		}
+
+		if prio, ok := events[0].(*Priority); !ok || prio.Priority != cemi.PrioSystem || !prio.Group {
+			t.Fatalf("Unexpected event %v", events[0])
+		}
+	})
+
+	t.Run("HopCount", func(t *testing.T) {
+		det, _ := newTestDetector(DefaultConfig)
+
+		f := normal(sensor, 1)
+		f.hops = 7
+
+		events := det.Inspect(f.ind())
+		if len(events) != 1 {
+			t.Fatalf("Expected 1 event, got %v", events)
+		}
+
+		if hops, ok := events[0].(*HopCount); !ok || hops.Hops != 7 {
+			t.Fatalf("Unexpected event %v", events[0])
+		}
+	})
+
+	t.Run("BusMonitor", func(t *testing.T) {
+		det, _ := newTestDetector(DefaultConfig)
+
+		// 1.1.5 -> 0/0/1 with hop count 7
+		raw := []byte{0xBC, 0x11, 0x05, 0x00, 0x01, 0xF1, 0x00, 0x81}
+		var checksum byte = 0xFF
+		for _, b := range raw {
+			checksum ^= b
+		}
+
+		msg := cemi.LBusmonInd(append([]byte{0}, append(raw, checksum)...))
+
+		events := det.Inspect(&msg)
+		if len(events) != 1 {
+			t.Fatalf("Expected 1 event, got %v", events)
+		}
+
+		if hops, ok := events[0].(*HopCount); !ok || hops.Source != sensor {
+			t.Fatalf("Unexpected event %v", events[0])
+		}
+	})
+
+	t.Run("Serve", func(t *testing.T) {
+		det, _ := newTestDetector(DefaultConfig)
+
+		inbound := make(chan cemi.Message, 2)
+		events := make(chan Event, 2)
+
+		f := normal(sensor, 1)
+		f.hops = 0
+		inbound <- f.ind()
+		close(inbound)
+
+		det.Serve(inbound, events)
+
+		event, ok := <-events
+		if !ok {
+			t.Fatal("Expected event")
+		}
+
+		if head := event.Head(); head.Source != sensor {
+			t.Fatalf("Expected %v, got %v", sensor, head.Source)
+		}
+
+		if _, ok := <-events; ok {
+			t.Fatal("Expected closed channel")
+		}
+	})
+}
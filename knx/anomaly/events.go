@@ -0,0 +1,133 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package anomaly
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// Header contains the information which all events share.
+type Header struct {
+	Time time.Time
+
+	// Source is the device whose telegram has revealed the anomaly.
+	Source cemi.IndividualAddr
+}
+
+// Head returns the header, which allows handling events without knowing their type.
+func (header Header) Head() Header {
+	return header
+}
+
+// An Event is a suspicious observation. Use a type switch in order to access the details.
+type Event interface {
+	Head() Header
+	String() string
+}
+
+// Storm reports a device which sends an unusual number of telegrams.
+type Storm struct {
+	Header
+	Telegrams int
+	Window    time.Duration
+}
+
+// String describes the event.
+func (event *Storm) String() string {
+	return fmt.Sprintf("Device %v has sent %d telegrams within %v",
+		event.Source, event.Telegrams, event.Window)
+}
+
+// Collision reports that two devices have written different values to the same group address
+// within a short time. This usually means that the devices are not supposed to share the address
+// or that they fight each other.
+type Collision struct {
+	Header
+	Destination cemi.GroupAddr
+	Value       []byte
+
+	// Other is the device that has written the other value.
+	Other      cemi.IndividualAddr
+	OtherValue []byte
+}
+
+// String describes the event.
+func (event *Collision) String() string {
+	return fmt.Sprintf("Devices %v and %v have written %x and %x to %v",
+		event.Other, event.Source, event.OtherValue, event.Value, event.Destination)
+}
+
+// ProtectedWrite reports that a device has written to a protected group address range, although
+// it is not among the allowed sources.
+type ProtectedWrite struct {
+	Header
+	Destination cemi.GroupAddr
+	Range       ProtectedRange
+}
+
+// String describes the event.
+func (event *ProtectedWrite) String() string {
+	return fmt.Sprintf("Device %v is not allowed to write to %v", event.Source, event.Destination)
+}
+
+// Repeats reports a device whose telegrams are often repeated. Telegrams are repeated when they
+// have not been acknowledged, which points to missing receivers or a faulty bus.
+type Repeats struct {
+	Header
+	Telegrams int
+	Repeated  int
+}
+
+// Ratio returns the share of repeated telegrams.
+func (event *Repeats) Ratio() float64 {
+	return float64(event.Repeated) / float64(event.Telegrams)
+}
+
+// String describes the event.
+func (event *Repeats) String() string {
+	return fmt.Sprintf("Device %v had to repeat %d of %d telegrams",
+		event.Source, event.Repeated, event.Telegrams)
+}
+
+// Priority reports a telegram with a priority that is unusual for its kind.
+type Priority struct {
+	Header
+	Destination uint16
+	Group       bool
+	Priority    cemi.Priority
+}
+
+// String describes the event.
+func (event *Priority) String() string {
+	return fmt.Sprintf("Device %v has sent a telegram to %s with priority %d",
+		event.Source, formatDestination(event.Destination, event.Group), event.Priority)
+}
+
+// HopCount reports a telegram with an unusual hop count. A hop count of 7 prevents couplers from
+// ever discarding the telegram, whereas a hop count of 0 means that the telegram has passed more
+// couplers than any regular topology contains.
+type HopCount struct {
+	Header
+	Destination uint16
+	Group       bool
+	Hops        uint8
+}
+
+// String describes the event.
+func (event *HopCount) String() string {
+	return fmt.Sprintf("Device %v has sent a telegram to %s with hop count %d",
+		event.Source, formatDestination(event.Destination, event.Group), event.Hops)
+}
+
+// formatDestination formats the destination of a frame.
+func formatDestination(dest uint16, group bool) string {
+	if group {
+		return cemi.GroupAddr(dest).String()
+	}
+
+	return cemi.IndividualAddr(dest).String()
+}
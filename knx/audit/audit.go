@@ -0,0 +1,344 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Package audit compares an ETS project with the traffic that is observed on the bus, in order to
+// find group addresses, datapoint types and devices which do not match the project.
+package audit
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/ets"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// payloadBits maps the main numbers of datapoint types to the size of their values in bits.
+var payloadBits = map[int]int{
+	1: 1, 2: 2, 3: 4, 4: 8, 5: 8, 6: 8, 7: 16, 8: 16, 9: 16, 10: 24, 11: 24, 12: 32, 13: 32,
+	14: 32, 15: 32, 16: 112, 17: 8, 18: 8, 19: 64, 20: 8, 21: 8, 22: 16, 23: 2, 25: 8, 26: 8,
+	27: 32, 29: 64, 232: 24, 235: 48, 238: 8, 242: 48, 249: 48, 251: 48,
+}
+
+// PayloadSize determines the number of bytes which follow the APCI in telegrams that carry a value
+// of the datapoint type with the given main number. Values of up to 6 bits are embedded in the
+// APCI, which gives a size of 0. The second result is false for unknown datapoint types.
+func PayloadSize(main int) (int, bool) {
+	bits, ok := payloadBits[main]
+	if !ok {
+		return 0, false
+	}
+
+	if bits <= 6 {
+		return 0, true
+	}
+
+	return bits / 8, true
+}
+
+// payloadSize determines the number of bytes which follow the APCI.
+func payloadSize(app *cemi.AppData) int {
+	if len(app.Data) <= 1 {
+		return 0
+	}
+
+	return len(app.Data) - 1
+}
+
+// usage counts the telegrams and senders of something.
+type usage struct {
+	telegrams int
+	sources   map[cemi.IndividualAddr]struct{}
+}
+
+// count accounts for a telegram from the source.
+func (u *usage) count(source cemi.IndividualAddr) {
+	if u.sources == nil {
+		u.sources = make(map[cemi.IndividualAddr]struct{})
+	}
+
+	u.telegrams++
+	u.sources[source] = struct{}{}
+}
+
+// sortedSources returns the senders in ascending order.
+func (u *usage) sortedSources() []cemi.IndividualAddr {
+	sources := make([]cemi.IndividualAddr, 0, len(u.sources))
+	for source := range u.sources {
+		sources = append(sources, source)
+	}
+
+	sort.Sort(sourceSlice(sources))
+	return sources
+}
+
+// sourceSlice sorts individual addresses in ascending order.
+type sourceSlice []cemi.IndividualAddr
+
+func (addrs sourceSlice) Len() int           { return len(addrs) }
+func (addrs sourceSlice) Less(i, j int) bool { return addrs[i] < addrs[j] }
+func (addrs sourceSlice) Swap(i, j int)      { addrs[i], addrs[j] = addrs[j], addrs[i] }
+
+// groupUsage records how a group address is used.
+type groupUsage struct {
+	usage
+
+	// sizes records the telegrams which carry a value by their payload size.
+	sizes map[int]*usage
+}
+
+// UnknownGroup is a group address which has been used on the bus, but is missing from the
+// project.
+type UnknownGroup struct {
+	Address   cemi.GroupAddr
+	Telegrams int
+	Sources   []cemi.IndividualAddr
+}
+
+// LengthMismatch reports telegrams whose payload size does not match the datapoint type of their
+// group address. Each observed size is reported separately.
+type LengthMismatch struct {
+	ets.GroupAddress
+	Expected  int
+	Observed  int
+	Telegrams int
+	Sources   []cemi.IndividualAddr
+}
+
+// UnknownDevice is an individual address which has sent telegrams, but is missing from the
+// project's topology.
+type UnknownDevice struct {
+	Address   cemi.IndividualAddr
+	Telegrams int
+}
+
+// unknownGroupSlice sorts unknown group addresses by address.
+type unknownGroupSlice []UnknownGroup
+
+func (groups unknownGroupSlice) Len() int           { return len(groups) }
+func (groups unknownGroupSlice) Less(i, j int) bool { return groups[i].Address < groups[j].Address }
+func (groups unknownGroupSlice) Swap(i, j int)      { groups[i], groups[j] = groups[j], groups[i] }
+
+// unknownDeviceSlice sorts unknown devices by individual address.
+type unknownDeviceSlice []UnknownDevice
+
+func (devices unknownDeviceSlice) Len() int { return len(devices) }
+
+func (devices unknownDeviceSlice) Less(i, j int) bool {
+	return devices[i].Address < devices[j].Address
+}
+
+func (devices unknownDeviceSlice) Swap(i, j int) { devices[i], devices[j] = devices[j], devices[i] }
+
+// Report is the result of an audit. All lists are ordered by address.
+type Report struct {
+	Project   string
+	Telegrams int
+
+	// Invalid is the number of bus monitor frames that could not be parsed.
+	Invalid int
+
+	UnknownGroups    []UnknownGroup
+	UnusedGroups     []ets.GroupAddress
+	LengthMismatches []LengthMismatch
+
+	// UnknownDevices is only filled if the project contains a topology, which is not the case for
+	// exports of the group addresses.
+	UnknownDevices  []UnknownDevice
+	TopologyChecked bool
+}
+
+// Clean determines whether the audit has not found any problems.
+func (report *Report) Clean() bool {
+	return len(report.UnknownGroups) == 0 && len(report.UnusedGroups) == 0 &&
+		len(report.LengthMismatches) == 0 && len(report.UnknownDevices) == 0
+}
+
+// An Audit accumulates the observed traffic and compares it with the project. It is safe for
+// concurrent use.
+type Audit struct {
+	project *ets.Project
+
+	mu        sync.Mutex
+	telegrams int
+	invalid   int
+	groups    map[cemi.GroupAddr]*groupUsage
+	devices   map[cemi.IndividualAddr]int
+}
+
+// New creates an audit of the project.
+func New(project *ets.Project) *Audit {
+	return &Audit{
+		project: project,
+		groups:  make(map[cemi.GroupAddr]*groupUsage),
+		devices: make(map[cemi.IndividualAddr]int),
+	}
+}
+
+// Record accounts for the message. L_Data.ind and L_Data.con frames as well as L_Busmon.ind frames
+// are understood; other messages are ignored.
+func (audit *Audit) Record(msg cemi.Message) {
+	var ldata cemi.LData
+
+	switch msg := msg.(type) {
+	case *cemi.LDataInd:
+		ldata = msg.LData
+
+	case *cemi.LDataCon:
+		// Frames that could not be sent did not appear on the bus.
+		if msg.Control1&cemi.Control1HasError != 0 {
+			return
+		}
+
+		ldata = msg.LData
+
+	case *cemi.LBusmonInd:
+		frame, ok, err := msg.Frame()
+		if err != nil {
+			util.Log(audit, "Failed to parse bus monitor frame: %v", err)
+
+			audit.mu.Lock()
+			audit.invalid++
+			audit.mu.Unlock()
+
+			return
+		}
+
+		if !ok {
+			return
+		}
+
+		ldata = frame
+
+	default:
+		return
+	}
+
+	audit.record(ldata)
+}
+
+// record accounts for the frame.
+func (audit *Audit) record(ldata cemi.LData) {
+	audit.mu.Lock()
+	defer audit.mu.Unlock()
+
+	audit.telegrams++
+	audit.devices[ldata.Source]++
+
+	app, ok := ldata.Data.(*cemi.AppData)
+	if !ok || !ldata.Control2.IsGroupAddr() || !app.Command.IsGroupCommand() {
+		return
+	}
+
+	addr := cemi.GroupAddr(ldata.Destination)
+
+	group, ok := audit.groups[addr]
+	if !ok {
+		group = &groupUsage{sizes: make(map[int]*usage)}
+		audit.groups[addr] = group
+	}
+
+	group.count(ldata.Source)
+
+	// Only writes and responses carry a value.
+	if app.Command == cemi.GroupValueRead {
+		return
+	}
+
+	size := payloadSize(app)
+
+	sizeUsage, ok := group.sizes[size]
+	if !ok {
+		sizeUsage = &usage{}
+		group.sizes[size] = sizeUsage
+	}
+
+	sizeUsage.count(ldata.Source)
+}
+
+// Serve records the messages until the channel is closed.
+func (audit *Audit) Serve(inbound <-chan cemi.Message) {
+	for msg := range inbound {
+		audit.Record(msg)
+	}
+}
+
+// Report compares the traffic that has been recorded so far with the project.
+func (audit *Audit) Report() *Report {
+	audit.mu.Lock()
+	defer audit.mu.Unlock()
+
+	report := &Report{
+		Project:         audit.project.Name,
+		Telegrams:       audit.telegrams,
+		Invalid:         audit.invalid,
+		TopologyChecked: len(audit.project.Devices) > 0,
+	}
+
+	for _, ga := range audit.project.GroupAddresses {
+		group, ok := audit.groups[ga.Address]
+		if !ok {
+			report.UnusedGroups = append(report.UnusedGroups, ga)
+			continue
+		}
+
+		main, ok := ga.DatapointMain()
+		if !ok {
+			continue
+		}
+
+		expected, ok := PayloadSize(main)
+		if !ok {
+			continue
+		}
+
+		sizes := make([]int, 0, len(group.sizes))
+		for size := range group.sizes {
+			sizes = append(sizes, size)
+		}
+
+		sort.Ints(sizes)
+
+		for _, size := range sizes {
+			if size == expected {
+				continue
+			}
+
+			report.LengthMismatches = append(report.LengthMismatches, LengthMismatch{
+				GroupAddress: ga,
+				Expected:     expected,
+				Observed:     size,
+				Telegrams:    group.sizes[size].telegrams,
+				Sources:      group.sizes[size].sortedSources(),
+			})
+		}
+	}
+
+	for addr, group := range audit.groups {
+		if _, ok := audit.project.GroupAddress(addr); !ok {
+			report.UnknownGroups = append(report.UnknownGroups, UnknownGroup{
+				Address:   addr,
+				Telegrams: group.telegrams,
+				Sources:   group.sortedSources(),
+			})
+		}
+	}
+
+	sort.Sort(unknownGroupSlice(report.UnknownGroups))
+
+	if report.TopologyChecked {
+		for addr, telegrams := range audit.devices {
+			if _, ok := audit.project.Device(addr); !ok {
+				report.UnknownDevices = append(report.UnknownDevices, UnknownDevice{
+					Address:   addr,
+					Telegrams: telegrams,
+				})
+			}
+		}
+
+		sort.Sort(unknownDeviceSlice(report.UnknownDevices))
+	}
+
+	return report
+}
@@ -0,0 +1,211 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/ets"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+var (
+	actuator = cemi.NewIndividualAddr3(1, 1, 5)
+	button   = cemi.NewIndividualAddr3(1, 1, 6)
+	stranger = cemi.NewIndividualAddr3(1, 1, 99)
+
+	gaSwitch     = cemi.NewGroupAddr3(1, 1, 0)
+	gaBrightness = cemi.NewGroupAddr3(1, 1, 1)
+	gaScene      = cemi.NewGroupAddr3(1, 1, 2)
+	gaUnknown    = cemi.NewGroupAddr3(7, 7, 7)
+)
+
+func makeProject() *ets.Project {
+	return &ets.Project{
+		Name: "Home",
+		GroupAddresses: []ets.GroupAddress{
+			{Address: gaSwitch, Name: "Switch", DatapointType: "DPST-1-1"},
+			{Address: gaBrightness, Name: "Brightness", DatapointType: "DPST-5-1"},
+			{Address: gaScene, Name: "Scene", DatapointType: "DPST-17-1"},
+		},
+		Devices: []ets.ProjectDevice{
+			{Address: actuator, Name: "Actuator"},
+			{Address: button, Name: "Button"},
+		},
+	}
+}
+
+func makeGroupInd(
+	src cemi.IndividualAddr,
+	dest cemi.GroupAddr,
+	command cemi.APCI,
+	data ...byte,
+) *cemi.LDataInd {
+	return &cemi.LDataInd{LData: cemi.LData{
+		Control1:    cemi.Control1StdFrame | cemi.Control1NoRepeat | cemi.Control1NoSysBroadcast,
+		Control2:    cemi.Control2GroupAddr | cemi.Control2Hops(6),
+		Source:      src,
+		Destination: uint16(dest),
+		Data:        &cemi.AppData{Command: command, Data: data},
+	}}
+}
+
+func TestPayloadSize(t *testing.T) {
+	for main, expected := range map[int]int{1: 0, 3: 0, 5: 1, 9: 2, 10: 3, 14: 4, 16: 14} {
+		if size, ok := PayloadSize(main); !ok || size != expected {
+			t.Fatalf("Expected %v for %v, got %v", expected, main, size)
+		}
+	}
+
+	if _, ok := PayloadSize(999); ok {
+		t.Fatal("Expected unknown datapoint type")
+	}
+}
+
+func TestAudit(t *testing.T) {
+	audit := New(makeProject())
+
+	audit.Record(makeGroupInd(button, gaSwitch, cemi.GroupValueWrite, 1))
+	audit.Record(makeGroupInd(actuator, gaSwitch, cemi.GroupValueResponse, 1))
+	audit.Record(makeGroupInd(button, gaBrightness, cemi.GroupValueWrite, 0, 128))
+	audit.Record(makeGroupInd(button, gaBrightness, cemi.GroupValueRead, 0))
+
+	// Brightness as 2-byte float
+	audit.Record(makeGroupInd(stranger, gaBrightness, cemi.GroupValueWrite, 0, 0x0C, 0x1A))
+	audit.Record(makeGroupInd(stranger, gaUnknown, cemi.GroupValueWrite, 1))
+
+	report := audit.Report()
+
+	if report.Telegrams != 6 || report.Project != "Home" || !report.TopologyChecked {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+
+	expectedUnknown := []UnknownGroup{
+		{Address: gaUnknown, Telegrams: 1, Sources: []cemi.IndividualAddr{stranger}},
+	}
+	if !reflect.DeepEqual(report.UnknownGroups, expectedUnknown) {
+		t.Fatalf("Expected %+v, got %+v", expectedUnknown, report.UnknownGroups)
+	}
+
+	if len(report.UnusedGroups) != 1 || report.UnusedGroups[0].Address != gaScene {
+		t.Fatalf("Expected %v to be unused, got %+v", gaScene, report.UnusedGroups)
+	}
+
+	if len(report.LengthMismatches) != 1 {
+		t.Fatalf("Expected 1 length mismatch, got %+v", report.LengthMismatches)
+	}
+
+	mismatch := report.LengthMismatches[0]
+	if mismatch.Address != gaBrightness || mismatch.Expected != 1 || mismatch.Observed != 2 ||
+		!reflect.DeepEqual(mismatch.Sources, []cemi.IndividualAddr{stranger}) {
+		t.Fatalf("Unexpected mismatch %+v", mismatch)
+	}
+
+	expectedDevices := []UnknownDevice{{Address: stranger, Telegrams: 2}}
+	if !reflect.DeepEqual(report.UnknownDevices, expectedDevices) {
+		t.Fatalf("Expected %+v, got %+v", expectedDevices, report.UnknownDevices)
+	}
+
+	if report.Clean() {
+		t.Fatal("Expected findings")
+	}
+
+	t.Run("WithoutTopology", func(t *testing.T) {
+		project := makeProject()
+		project.Devices = nil
+
+		audit := New(project)
+		audit.Record(makeGroupInd(stranger, gaSwitch, cemi.GroupValueWrite, 1))
+
+		report := audit.Report()
+		if report.TopologyChecked || len(report.UnknownDevices) > 0 {
+			t.Fatalf("Unexpected report %+v", report)
+		}
+	})
+
+	t.Run("WriteJSON", func(t *testing.T) {
+		var buffer bytes.Buffer
+		if err := report.WriteJSON(&buffer); err != nil {
+			t.Fatal(err)
+		}
+
+		var doc jsonReport
+		if err := json.Unmarshal(buffer.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(doc.LengthMismatches) != 1 || doc.LengthMismatches[0].Address != "1/1/1" ||
+			doc.UnknownDevices[0].Address != "1.1.99" {
+			t.Fatalf("Unexpected JSON %s", buffer.String())
+		}
+	})
+
+	t.Run("WriteReport", func(t *testing.T) {
+		var buffer bytes.Buffer
+		if err := report.WriteReport(&buffer); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, expected := range []string{"7/7/7", "1/1/2", "Brightness", "1.1.99"} {
+			if !strings.Contains(buffer.String(), expected) {
+				t.Fatalf("Expected %q in report:\n%s", expected, buffer.String())
+			}
+		}
+	})
+}
+
+func TestRecording(t *testing.T) {
+	clock := util.NewFakeClock(time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	var buffer bytes.Buffer
+	rec := NewRecorder(&buffer, clock)
+
+	messages := []cemi.Message{
+		makeGroupInd(button, gaSwitch, cemi.GroupValueWrite, 1),
+		makeGroupInd(button, gaBrightness, cemi.GroupValueWrite, 0, 128),
+	}
+
+	for _, msg := range messages {
+		if err := rec.Record(msg); err != nil {
+			t.Fatal(err)
+		}
+
+		clock.Advance(time.Second)
+	}
+
+	buffer.WriteString("# Comment\n\n")
+
+	var times []time.Time
+	var replayed []cemi.Message
+
+	err := ReadRecording(&buffer, func(at time.Time, msg cemi.Message) error {
+		times = append(times, at)
+		replayed = append(replayed, msg)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(replayed, messages) {
+		t.Fatalf("Expected %+v, got %+v", messages, replayed)
+	}
+
+	if len(times) != 2 || times[1].Sub(times[0]) != time.Second {
+		t.Fatalf("Unexpected times %v", times)
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		err := ReadRecording(strings.NewReader("2020-01-01T12:00:00Z xyz\n"), nil)
+		if err == nil || !strings.HasPrefix(err.Error(), "Line 1:") {
+			t.Fatalf("Expected error for line 1, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package audit
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// A Recorder writes messages to a recording, so that they can be audited later. A recording is a
+// text file with one message per line, consisting of the time at which it has been received in
+// RFC 3339 format and the hex-encoded CEMI frame, separated by a space. Empty lines and lines
+// starting with '#' are ignored.
+type Recorder struct {
+	clock util.Clock
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewRecorder creates a recorder which writes to w. The clock provides the time at which the
+// messages are received; nil means util.RealClock.
+func NewRecorder(w io.Writer, clock util.Clock) *Recorder {
+	if clock == nil {
+		clock = util.RealClock
+	}
+
+	return &Recorder{clock: clock, w: w}
+}
+
+// Record appends the message to the recording.
+func (rec *Recorder) Record(msg cemi.Message) error {
+	data, err := cemi.AppendPack(nil, msg)
+	if err != nil {
+		return err
+	}
+
+	line := rec.clock.Now().Format(time.RFC3339Nano) + " " + hex.EncodeToString(data) + "\n"
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	_, err = io.WriteString(rec.w, line)
+	return err
+}
+
+// ReadRecording reads the messages of a recording and passes them to fn, along with the time at
+// which they have been received. It stops at the first error, including those returned by fn.
+func ReadRecording(r io.Reader, fn func(at time.Time, msg cemi.Message) error) error {
+	scanner := bufio.NewScanner(r)
+
+	for num := 1; scanner.Scan(); num++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return fmt.Errorf("Line %d: Expected time and frame", num)
+		}
+
+		at, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return fmt.Errorf("Line %d: %v", num, err)
+		}
+
+		data, err := hex.DecodeString(fields[1])
+		if err != nil {
+			return fmt.Errorf("Line %d: %v", num, err)
+		}
+
+		var msg cemi.Message
+		if _, err := cemi.Unpack(data, &msg); err != nil {
+			return fmt.Errorf("Line %d: %v", num, err)
+		}
+
+		if err := fn(at, msg); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
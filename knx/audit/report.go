@@ -0,0 +1,187 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// jsonGroup is the JSON representation of a group address of the project.
+type jsonGroup struct {
+	Address       string `json:"address"`
+	Name          string `json:"name"`
+	DatapointType string `json:"datapointType"`
+}
+
+// jsonUnknownGroup is the JSON representation of UnknownGroup.
+type jsonUnknownGroup struct {
+	Address   string   `json:"address"`
+	Telegrams int      `json:"telegrams"`
+	Sources   []string `json:"sources"`
+}
+
+// jsonLengthMismatch is the JSON representation of LengthMismatch.
+type jsonLengthMismatch struct {
+	jsonGroup
+	Expected  int      `json:"expected"`
+	Observed  int      `json:"observed"`
+	Telegrams int      `json:"telegrams"`
+	Sources   []string `json:"sources"`
+}
+
+// jsonUnknownDevice is the JSON representation of UnknownDevice.
+type jsonUnknownDevice struct {
+	Address   string `json:"address"`
+	Telegrams int    `json:"telegrams"`
+}
+
+// jsonReport is the JSON representation of Report.
+type jsonReport struct {
+	Project          string               `json:"project"`
+	Telegrams        int                  `json:"telegrams"`
+	Invalid          int                  `json:"invalidFrames"`
+	UnknownGroups    []jsonUnknownGroup   `json:"unknownGroups"`
+	UnusedGroups     []jsonGroup          `json:"unusedGroups"`
+	LengthMismatches []jsonLengthMismatch `json:"lengthMismatches"`
+	UnknownDevices   []jsonUnknownDevice  `json:"unknownDevices"`
+	TopologyChecked  bool                 `json:"topologyChecked"`
+}
+
+// formatSources converts the senders to their usual notation.
+func formatSources(sources []cemi.IndividualAddr) []string {
+	formatted := make([]string, len(sources))
+	for i, source := range sources {
+		formatted[i] = source.String()
+	}
+
+	return formatted
+}
+
+// WriteJSON exports the report as JSON. Addresses are given in their usual notation.
+func (report *Report) WriteJSON(w io.Writer) error {
+	doc := jsonReport{
+		Project:          report.Project,
+		Telegrams:        report.Telegrams,
+		Invalid:          report.Invalid,
+		UnknownGroups:    []jsonUnknownGroup{},
+		UnusedGroups:     []jsonGroup{},
+		LengthMismatches: []jsonLengthMismatch{},
+		UnknownDevices:   []jsonUnknownDevice{},
+		TopologyChecked:  report.TopologyChecked,
+	}
+
+	for _, group := range report.UnknownGroups {
+		doc.UnknownGroups = append(doc.UnknownGroups, jsonUnknownGroup{
+			Address:   group.Address.String(),
+			Telegrams: group.Telegrams,
+			Sources:   formatSources(group.Sources),
+		})
+	}
+
+	for _, ga := range report.UnusedGroups {
+		doc.UnusedGroups = append(doc.UnusedGroups, jsonGroup{
+			Address:       ga.Address.String(),
+			Name:          ga.Name,
+			DatapointType: ga.DatapointType,
+		})
+	}
+
+	for _, mismatch := range report.LengthMismatches {
+		doc.LengthMismatches = append(doc.LengthMismatches, jsonLengthMismatch{
+			jsonGroup: jsonGroup{
+				Address:       mismatch.Address.String(),
+				Name:          mismatch.Name,
+				DatapointType: mismatch.DatapointType,
+			},
+			Expected:  mismatch.Expected,
+			Observed:  mismatch.Observed,
+			Telegrams: mismatch.Telegrams,
+			Sources:   formatSources(mismatch.Sources),
+		})
+	}
+
+	for _, dev := range report.UnknownDevices {
+		doc.UnknownDevices = append(doc.UnknownDevices, jsonUnknownDevice{
+			Address:   dev.Address.String(),
+			Telegrams: dev.Telegrams,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(doc)
+}
+
+// WriteReport writes the findings as text, with one table per kind of finding.
+func (report *Report) WriteReport(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(table, "Audit of project %q: %d telegrams", report.Project, report.Telegrams)
+	if report.Invalid > 0 {
+		fmt.Fprintf(table, ", %d invalid frames", report.Invalid)
+	}
+
+	fmt.Fprintln(table)
+
+	if report.Clean() {
+		fmt.Fprintln(table, "No findings")
+	}
+
+	if len(report.UnknownGroups) > 0 {
+		fmt.Fprintln(table, "\nGroup addresses on the bus, but not in the project:")
+		fmt.Fprintln(table, "Address\tTelegrams\tSources")
+
+		for _, group := range report.UnknownGroups {
+			fmt.Fprintf(table, "%v\t%d\t%s\n", group.Address, group.Telegrams,
+				strings.Join(formatSources(group.Sources), ", "))
+		}
+	}
+
+	if len(report.UnusedGroups) > 0 {
+		fmt.Fprintln(table, "\nGroup addresses in the project, but not on the bus:")
+		fmt.Fprintln(table, "Address\tName\tDatapoint type")
+
+		for _, ga := range report.UnusedGroups {
+			fmt.Fprintf(table, "%v\t%s\t%s\n", ga.Address, ga.Name, ga.DatapointType)
+		}
+	}
+
+	if len(report.LengthMismatches) > 0 {
+		fmt.Fprintln(table, "\nPayload sizes which do not match the datapoint type:")
+		fmt.Fprintln(table, "Address\tName\tDatapoint type\tExpected\tObserved\tTelegrams\tSources")
+
+		for _, mismatch := range report.LengthMismatches {
+			fmt.Fprintf(table, "%v\t%s\t%s\t%d\t%d\t%d\t%s\n",
+				mismatch.Address,
+				mismatch.Name,
+				mismatch.DatapointType,
+				mismatch.Expected,
+				mismatch.Observed,
+				mismatch.Telegrams,
+				strings.Join(formatSources(mismatch.Sources), ", "))
+		}
+	}
+
+	if len(report.UnknownDevices) > 0 {
+		fmt.Fprintln(table, "\nDevices on the bus, but not in the topology:")
+		fmt.Fprintln(table, "Address\tTelegrams")
+
+		for _, dev := range report.UnknownDevices {
+			fmt.Fprintf(table, "%v\t%d\n", dev.Address, dev.Telegrams)
+		}
+	}
+
+	if !report.TopologyChecked {
+		fmt.Fprintln(table, "\nThe project does not contain a topology, devices have not been checked.")
+	}
+
+	return table.Flush()
+}
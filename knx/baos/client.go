@@ -0,0 +1,296 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package baos
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/dpt"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// Config allows you to configure the client's behavior.
+type Config struct {
+	// ResponseTimeout specifies how long to wait for the response to a request.
+	ResponseTimeout time.Duration
+
+	// Clock is used for response timeouts. Tests may use a util.FakeClock in order to control the
+	// passage of time.
+	Clock util.Clock
+}
+
+// DefaultConfig is a good default configuration for a Client.
+var DefaultConfig = Config{
+	ResponseTimeout: 2 * time.Second,
+	Clock:           util.RealClock,
+}
+
+// checkConfig makes sure that the configuration is actually usable.
+func checkConfig(config Config) Config {
+	if config.ResponseTimeout <= 0 {
+		config.ResponseTimeout = DefaultConfig.ResponseTimeout
+	}
+
+	if config.Clock == nil {
+		config.Clock = DefaultConfig.Clock
+	}
+
+	return config
+}
+
+// These are errors that can occur when using a Client.
+var (
+	ErrResponseTimeout = errors.New("Response timeout reached")
+	ErrClosed          = errors.New("Client has been closed")
+)
+
+// inboundBuffer is the number of datapoint events which are buffered for the inbound channel.
+const inboundBuffer = 64
+
+// A Client communicates with the ObjectServer of a BAOS device. Requests are processed one at a
+// time. Changes of datapoint values are delivered through the inbound channel, similar to the
+// events of a knx.GroupClient.
+type Client struct {
+	transport Transport
+	config    Config
+
+	// Serializes requests, so that responses can be attributed to their request.
+	requestMu sync.Mutex
+	responses chan *Message
+
+	inbound chan DatapointEvent
+}
+
+// NewClient creates a new client that communicates through the given transport. The client takes
+// ownership of the transport and closes it when it is closed.
+func NewClient(transport Transport, config Config) *Client {
+	client := &Client{
+		transport: transport,
+		config:    checkConfig(config),
+		responses: make(chan *Message, 1),
+		inbound:   make(chan DatapointEvent, inboundBuffer),
+	}
+
+	go client.serve()
+
+	return client
+}
+
+// serve dispatches the incoming messages.
+func (client *Client) serve() {
+	util.Log(client, "Started worker")
+	defer util.Log(client, "Worker exited")
+
+	defer close(client.inbound)
+	defer close(client.responses)
+
+	for data := range client.transport.Inbound() {
+		msg := &Message{}
+		if _, err := msg.Unpack(data); err != nil {
+			util.Log(client, "Error during Unpack: %v", err)
+			continue
+		}
+
+		switch msg.Subservice {
+		case DatapointValueInd:
+			events, err := unpackValues(msg.Data, msg.Count)
+			if err != nil {
+				util.Log(client, "Invalid indication: %v", err)
+				continue
+			}
+
+			// Responses are dispatched by this goroutine, too. Therefore it must not wait for
+			// somebody to read the events.
+			for _, event := range events {
+				select {
+				case client.inbound <- event:
+				default:
+					util.Log(client, "Dropped value indication of datapoint %d", event.ID)
+				}
+			}
+
+		case ServerItemInd:
+			// Server item changes are not of interest.
+
+		default:
+			// Replace a response that nobody waits for.
+			select {
+			case <-client.responses:
+			default:
+			}
+
+			client.responses <- msg
+		}
+	}
+}
+
+// request sends the request and waits for the response.
+func (client *Client) request(req *Message) (*Message, error) {
+	client.requestMu.Lock()
+	defer client.requestMu.Unlock()
+
+	// Discard responses to previous requests that have timed out.
+	select {
+	case _, open := <-client.responses:
+		if !open {
+			return nil, ErrClosed
+		}
+
+	default:
+	}
+
+	buffer := make([]byte, req.Size())
+	req.Pack(buffer)
+
+	if err := client.transport.Send(buffer); err != nil {
+		return nil, err
+	}
+
+	timeout := client.config.Clock.NewTimer(client.config.ResponseTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case <-timeout.C():
+			return nil, ErrResponseTimeout
+
+		case res, open := <-client.responses:
+			if !open {
+				return nil, ErrClosed
+			}
+
+			if res.Subservice != req.Subservice.response() {
+				util.Log(client, "Ignored unexpected response %#x", uint8(res.Subservice))
+				continue
+			}
+
+			if err := res.err(); err != nil {
+				return nil, err
+			}
+
+			return res, nil
+		}
+	}
+}
+
+// GetServerItem retrieves count server items, starting with the given one.
+func (client *Client) GetServerItem(start ItemID, count uint16) ([]ServerItem, error) {
+	res, err := client.request(&Message{
+		Subservice: GetServerItemReq,
+		Start:      uint16(start),
+		Count:      count,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return unpackServerItems(res.Data, res.Count)
+}
+
+// GetDatapointDescription retrieves the descriptions of count datapoints, starting with the given
+// one.
+func (client *Client) GetDatapointDescription(
+	start, count uint16,
+) ([]DatapointDescription, error) {
+	res, err := client.request(&Message{
+		Subservice: GetDatapointDescriptionReq,
+		Start:      start,
+		Count:      count,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return unpackDescriptions(res.Data, res.Count)
+}
+
+// GetDatapointValue retrieves the values of up to count datapoints, starting with the given one.
+// The filter determines which values are included.
+func (client *Client) GetDatapointValue(
+	start, count uint16,
+	filter Filter,
+) ([]DatapointEvent, error) {
+	res, err := client.request(&Message{
+		Subservice: GetDatapointValueReq,
+		Start:      start,
+		Count:      count,
+		Data:       []byte{byte(filter)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return unpackValues(res.Data, res.Count)
+}
+
+// SetDatapointValue applies the given commands to their datapoints.
+func (client *Client) SetDatapointValue(cmds ...DatapointCommand) error {
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	_, err := client.request(&Message{
+		Subservice: SetDatapointValueReq,
+		Start:      cmds[0].ID,
+		Count:      uint16(len(cmds)),
+		Data:       appendCommands(nil, cmds),
+	})
+
+	return err
+}
+
+// GetParameterByte retrieves count parameter bytes, starting with the given one.
+func (client *Client) GetParameterByte(start, count uint16) ([]byte, error) {
+	res, err := client.request(&Message{
+		Subservice: GetParameterByteReq,
+		Start:      start,
+		Count:      count,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.Data) < int(res.Count) {
+		return nil, ErrItemLength
+	}
+
+	return append([]byte(nil), res.Data[:res.Count]...), nil
+}
+
+// Send sets the value of the datapoint and sends it to the bus.
+func (client *Client) Send(id uint16, value dpt.DatapointValue) error {
+	return client.SetDatapointValue(DatapointCommand{
+		ID:      id,
+		Command: CommandSetAndSend,
+		Data:    EncodeValue(value),
+	})
+}
+
+// Read retrieves the current value of the datapoint and unpacks it into the given value.
+func (client *Client) Read(id uint16, value dpt.DatapointValue) error {
+	events, err := client.GetDatapointValue(id, 1, FilterAll)
+	if err != nil {
+		return err
+	}
+
+	if len(events) == 0 || events[0].ID != id {
+		return ErrNoItemFound
+	}
+
+	return events[0].Decode(value)
+}
+
+// Inbound returns the channel on which changes of datapoint values are delivered. It is closed when
+// the transport has been closed. If the channel is not drained, events are dropped once its buffer
+// is full.
+func (client *Client) Inbound() <-chan DatapointEvent {
+	return client.inbound
+}
+
+// Close shuts the transport down.
+func (client *Client) Close() {
+	client.transport.Close()
+}
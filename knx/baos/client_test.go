@@ -0,0 +1,252 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package baos
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/dpt"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// fakeServer simulates the ObjectServer of a BAOS device with two datapoints.
+type fakeServer struct {
+	transport Transport
+	values    map[uint16][]byte
+	commands  chan DatapointCommand
+}
+
+func newFakeServer(transport Transport) *fakeServer {
+	server := &fakeServer{
+		transport: transport,
+		values: map[uint16][]byte{
+			1: {1},
+			2: {0x0C, 0x1A},
+		},
+		commands: make(chan DatapointCommand, 4),
+	}
+
+	go server.serve()
+
+	return server
+}
+
+func (server *fakeServer) respond(msg *Message) {
+	buffer := make([]byte, msg.Size())
+	msg.Pack(buffer)
+	server.transport.Send(buffer)
+}
+
+func (server *fakeServer) serve() {
+	for data := range server.transport.Inbound() {
+		var req Message
+		if _, err := req.Unpack(data); err != nil {
+			continue
+		}
+
+		res := &Message{Subservice: req.Subservice.response(), Start: req.Start, Count: req.Count}
+
+		switch req.Subservice {
+		case GetServerItemReq:
+			res.Data = []byte{0, byte(ItemFirmwareVersion), 1, 0x20}
+
+		case GetDatapointDescriptionReq:
+			res.Data = []byte{0, 1, byte(ValueType1Bit), byte(ConfigCommunication), 1}
+
+		case GetDatapointValueReq:
+			value, ok := server.values[req.Start]
+			if !ok {
+				res.Count = 0
+				res.Data = []byte{byte(ErrBadID)}
+				break
+			}
+
+			res.Data = append([]byte{0, byte(req.Start), 1 << 4, byte(len(value))}, value...)
+
+		case SetDatapointValueReq:
+			cmd := DatapointCommand{
+				ID:      uint16(req.Data[0])<<8 | uint16(req.Data[1]),
+				Command: Command(req.Data[2]),
+				Data:    req.Data[4 : 4+int(req.Data[3])],
+			}
+
+			server.values[cmd.ID] = cmd.Data
+			server.commands <- cmd
+
+			res.Count = 0
+			res.Data = []byte{0}
+
+		case GetParameterByteReq:
+			res.Data = []byte{0xAA, 0xBB}[:req.Count]
+
+		default:
+			res.Count = 0
+			res.Data = []byte{byte(ErrServiceNotSupported)}
+		}
+
+		server.respond(res)
+	}
+}
+
+func newTestClient(config Config) (*Client, *fakeServer) {
+	clientConn, serverConn := net.Pipe()
+
+	server := newFakeServer(NewIPTransport(serverConn))
+	client := NewClient(NewIPTransport(clientConn), config)
+
+	return client, server
+}
+
+func TestClient(t *testing.T) {
+	client, server := newTestClient(DefaultConfig)
+	defer server.transport.Close()
+	defer client.Close()
+
+	t.Run("GetServerItem", func(t *testing.T) {
+		items, err := client.GetServerItem(ItemFirmwareVersion, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(items) != 1 || items[0].ID != ItemFirmwareVersion ||
+			!bytes.Equal(items[0].Data, []byte{0x20}) {
+			t.Errorf("Unexpected items: %+v", items)
+		}
+	})
+
+	t.Run("GetDatapointDescription", func(t *testing.T) {
+		descs, err := client.GetDatapointDescription(1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(descs) != 1 || descs[0].ValueType != ValueType1Bit || descs[0].DPT != 1 {
+			t.Errorf("Unexpected descriptions: %+v", descs)
+		}
+	})
+
+	t.Run("Read", func(t *testing.T) {
+		var temp dpt.DPT_9001
+		if err := client.Read(2, &temp); err != nil {
+			t.Fatal(err)
+		}
+
+		if temp != 21 {
+			t.Errorf("Unexpected temperature: %v", temp)
+		}
+
+		var on dpt.DPT_1001
+		if err := client.Read(1, &on); err != nil {
+			t.Fatal(err)
+		}
+
+		if !on {
+			t.Error("Unexpected switch state")
+		}
+
+		if err := client.Read(3, &on); err != ErrBadID {
+			t.Fatalf("Expected %v, got %v", ErrBadID, err)
+		}
+	})
+
+	t.Run("Send", func(t *testing.T) {
+		temp := dpt.DPT_9001(21)
+		if err := client.Send(2, &temp); err != nil {
+			t.Fatal(err)
+		}
+
+		cmd := <-server.commands
+		if cmd.ID != 2 || cmd.Command != CommandSetAndSend ||
+			!bytes.Equal(cmd.Data, []byte{0x0C, 0x1A}) {
+			t.Errorf("Unexpected command: %+v", cmd)
+		}
+	})
+
+	t.Run("GetParameterByte", func(t *testing.T) {
+		params, err := client.GetParameterByte(0, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(params, []byte{0xAA, 0xBB}) {
+			t.Errorf("Unexpected parameter bytes: %v", params)
+		}
+	})
+
+	t.Run("Indication", func(t *testing.T) {
+		server.respond(&Message{
+			Subservice: DatapointValueInd,
+			Start:      1,
+			Count:      2,
+			Data:       []byte{0, 1, 1 << 4, 1, 0, 0, 2, 1<<4 | 1<<3, 2, 0x0C, 0x1A},
+		})
+
+		for _, id := range []uint16{1, 2} {
+			select {
+			case event := <-client.Inbound():
+				if event.ID != id || !event.State.Valid() {
+					t.Errorf("Unexpected event: %+v", event)
+				}
+
+			case <-time.After(time.Second):
+				t.Fatal("No event received")
+			}
+		}
+	})
+}
+
+func TestClient_UnreadIndications(t *testing.T) {
+	client, server := newTestClient(DefaultConfig)
+	defer server.transport.Close()
+	defer client.Close()
+
+	// Nobody reads the indications.
+	for i := 0; i < inboundBuffer+1; i++ {
+		server.respond(&Message{
+			Subservice: DatapointValueInd,
+			Start:      1,
+			Count:      1,
+			Data:       []byte{0, 1, 1 << 4, 1, 0},
+		})
+	}
+
+	if _, err := client.GetParameterByte(0, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(client.Inbound()) != inboundBuffer {
+		t.Errorf("Expected %d buffered events, got %d", inboundBuffer, len(client.Inbound()))
+	}
+}
+
+func TestClient_Timeout(t *testing.T) {
+	clientConn, deviceConn := net.Pipe()
+	defer deviceConn.Close()
+
+	// The device receives requests, but never responds.
+	go func() {
+		for range NewIPTransport(deviceConn).Inbound() {
+		}
+	}()
+
+	clock := util.NewFakeClock(time.Unix(0, 0))
+
+	config := DefaultConfig
+	config.Clock = clock
+
+	client := NewClient(NewIPTransport(clientConn), config)
+	defer client.Close()
+
+	go func() {
+		clock.BlockUntil(1)
+		clock.Advance(config.ResponseTimeout)
+	}()
+
+	if _, err := client.GetServerItem(ItemHardwareType, 1); err != ErrResponseTimeout {
+		t.Fatalf("Expected %v, got %v", ErrResponseTimeout, err)
+	}
+}
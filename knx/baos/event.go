@@ -0,0 +1,44 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package baos
+
+import (
+	"github.com/vapourismo/knx-go/knx/dpt"
+)
+
+// A DatapointEvent carries the value of a datapoint, either because it has been requested or
+// because it has changed.
+type DatapointEvent struct {
+	ID    uint16
+	State DatapointState
+	Data  []byte
+}
+
+// Decode unpacks the value into the given datapoint type.
+func (event DatapointEvent) Decode(value dpt.DatapointValue) error {
+	return DecodeValue(event.Data, value)
+}
+
+// EncodeValue packs a datapoint value in the form that the ObjectServer expects. Unlike in group
+// telegrams, values larger than 6 bits are not preceded by an empty byte.
+func EncodeValue(value dpt.DatapointValue) []byte {
+	data := value.Pack()
+	if len(data) > 1 {
+		return data[1:]
+	}
+
+	return data
+}
+
+// DecodeValue unpacks a value which has been received from the ObjectServer into the given datapoint
+// type. A single byte might be a small value or a 1-byte value, therefore both are tried.
+func DecodeValue(data []byte, value dpt.DatapointValue) error {
+	if len(data) == 1 {
+		if err := value.Unpack(data); err == nil {
+			return nil
+		}
+	}
+
+	return value.Unpack(append([]byte{0}, data...))
+}
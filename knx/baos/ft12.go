@@ -0,0 +1,283 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package baos
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// These are the bytes that delimit FT1.2 frames.
+const (
+	ft12Ack        = 0xE5
+	ft12FixedStart = 0x10
+	ft12VarStart   = 0x68
+	ft12End        = 0x16
+)
+
+// These are the control fields of FT1.2 frames.
+const (
+	// ft12Reset resets the link.
+	ft12Reset = 0x40
+
+	// ft12Data sends user data. The frame count bit alternates between frames.
+	ft12Data = 0x53
+
+	// ft12FrameCount is the frame count bit.
+	ft12FrameCount = 0x20
+)
+
+// FT12Config allows you to configure the behavior of an FT1.2 transport.
+type FT12Config struct {
+	// AckTimeout specifies how long to wait for the acknowledgement of a frame.
+	AckTimeout time.Duration
+
+	// Retries is the number of times a frame is repeated when it has not been acknowledged.
+	Retries int
+
+	// Clock is used for acknowledgement timeouts. Tests may use a util.FakeClock in order to
+	// control the passage of time.
+	Clock util.Clock
+}
+
+// DefaultFT12Config is a good default configuration for an FT1.2 transport.
+var DefaultFT12Config = FT12Config{
+	AckTimeout: 500 * time.Millisecond,
+	Retries:    3,
+	Clock:      util.RealClock,
+}
+
+// checkFT12Config makes sure that the configuration is actually usable.
+func checkFT12Config(config FT12Config) FT12Config {
+	if config.AckTimeout <= 0 {
+		config.AckTimeout = DefaultFT12Config.AckTimeout
+	}
+
+	if config.Retries < 0 {
+		config.Retries = DefaultFT12Config.Retries
+	}
+
+	if config.Clock == nil {
+		config.Clock = DefaultFT12Config.Clock
+	}
+
+	return config
+}
+
+// ErrNoAck is returned when the device does not acknowledge a frame.
+var ErrNoAck = errors.New("Frame has not been acknowledged")
+
+// FT12Transport transports ObjectServer messages in FT1.2 frames, usually over a serial port with
+// 19200 baud, 8 data bits, even parity and 1 stop bit. Opening the port is up to the caller.
+type FT12Transport struct {
+	port   io.ReadWriteCloser
+	config FT12Config
+
+	// Serializes sending, since only one frame may await its acknowledgement.
+	sendMu     sync.Mutex
+	frameCount bool
+
+	writeMu sync.Mutex
+	acks    chan struct{}
+	inbound chan []byte
+}
+
+// NewFT12Transport creates a transport on top of the given port and resets the link. The transport
+// takes ownership of the port and closes it when it is closed.
+func NewFT12Transport(port io.ReadWriteCloser, config FT12Config) (*FT12Transport, error) {
+	transport := &FT12Transport{
+		port:       port,
+		config:     checkFT12Config(config),
+		frameCount: true,
+		acks:       make(chan struct{}, 1),
+		inbound:    make(chan []byte),
+	}
+
+	go transport.serve()
+
+	if err := transport.transmit([]byte{ft12FixedStart, ft12Reset, ft12Reset, ft12End}); err != nil {
+		port.Close()
+		return nil, err
+	}
+
+	return transport, nil
+}
+
+// write writes the bytes to the port.
+func (transport *FT12Transport) write(data []byte) error {
+	transport.writeMu.Lock()
+	defer transport.writeMu.Unlock()
+
+	_, err := transport.port.Write(data)
+	return err
+}
+
+// transmit writes the frame and waits for its acknowledgement. The caller must hold the send lock,
+// unless no other goroutine can send yet.
+func (transport *FT12Transport) transmit(frame []byte) error {
+	for attempt := 0; attempt <= transport.config.Retries; attempt++ {
+		// Forget about acknowledgements that arrived too late.
+		select {
+		case <-transport.acks:
+		default:
+		}
+
+		if err := transport.write(frame); err != nil {
+			return err
+		}
+
+		timeout := transport.config.Clock.NewTimer(transport.config.AckTimeout)
+
+		select {
+		case <-transport.acks:
+			timeout.Stop()
+			return nil
+
+		case <-timeout.C():
+			util.Log(transport, "Frame has not been acknowledged in time")
+		}
+	}
+
+	return ErrNoAck
+}
+
+// Send transmits an ObjectServer message and waits until it has been acknowledged.
+func (transport *FT12Transport) Send(msg []byte) error {
+	transport.sendMu.Lock()
+	defer transport.sendMu.Unlock()
+
+	control := byte(ft12Data)
+	if transport.frameCount {
+		control |= ft12FrameCount
+	}
+
+	frame := make([]byte, 0, len(msg)+7)
+	frame = append(frame, ft12VarStart, byte(len(msg)+1), byte(len(msg)+1), ft12VarStart, control)
+	frame = append(frame, msg...)
+	frame = append(frame, ft12Checksum(frame[4:]), ft12End)
+
+	if err := transport.transmit(frame); err != nil {
+		return err
+	}
+
+	transport.frameCount = !transport.frameCount
+
+	return nil
+}
+
+// ft12Checksum calculates the checksum of the control field and the user data.
+func ft12Checksum(data []byte) byte {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+
+	return sum
+}
+
+// readVarFrame reads the remainder of a variable length frame, after the start byte. It returns the
+// control field and the user data.
+func readVarFrame(reader *bufio.Reader) (byte, []byte, error) {
+	var header [3]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return 0, nil, err
+	}
+
+	if header[0] != header[1] || header[2] != ft12VarStart || header[0] == 0 {
+		return 0, nil, errors.New("Invalid FT1.2 frame header")
+	}
+
+	body := make([]byte, int(header[0])+2)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return 0, nil, err
+	}
+
+	payload := body[:header[0]]
+	if body[len(body)-2] != ft12Checksum(payload) || body[len(body)-1] != ft12End {
+		return 0, nil, errors.New("Invalid FT1.2 frame checksum")
+	}
+
+	return payload[0], payload[1:], nil
+}
+
+// serve receives the incoming frames.
+func (transport *FT12Transport) serve() {
+	util.Log(transport, "Started worker")
+	defer util.Log(transport, "Worker exited")
+
+	defer close(transport.inbound)
+
+	reader := bufio.NewReader(transport.port)
+
+	// The frame count bit of the last frame that has been received. Repeated frames carry the same
+	// bit and must only be delivered once.
+	lastFrameCount := -1
+
+	for {
+		start, err := reader.ReadByte()
+		if err != nil {
+			util.Log(transport, "Error while reading: %v", err)
+			return
+		}
+
+		switch start {
+		case ft12Ack:
+			select {
+			case transport.acks <- struct{}{}:
+			default:
+			}
+
+		case ft12FixedStart:
+			var frame [3]byte
+			if _, err := io.ReadFull(reader, frame[:]); err != nil {
+				util.Log(transport, "Error while reading: %v", err)
+				return
+			}
+
+			if frame[0] == frame[1] && frame[2] == ft12End && frame[0]&0x0F == 0 {
+				lastFrameCount = -1
+				transport.write([]byte{ft12Ack})
+			}
+
+		case ft12VarStart:
+			control, data, err := readVarFrame(reader)
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				util.Log(transport, "Error while reading: %v", err)
+				return
+			} else if err != nil {
+				util.Log(transport, "Dropped frame: %v", err)
+				continue
+			}
+
+			transport.write([]byte{ft12Ack})
+
+			frameCount := int(control & ft12FrameCount)
+			if frameCount == lastFrameCount {
+				util.Log(transport, "Dropped repeated frame")
+				continue
+			}
+
+			lastFrameCount = frameCount
+			transport.inbound <- data
+
+		default:
+			util.Log(transport, "Skipped unexpected byte %#x", start)
+		}
+	}
+}
+
+// Inbound returns the channel on which received messages are delivered.
+func (transport *FT12Transport) Inbound() <-chan []byte {
+	return transport.inbound
+}
+
+// Close shuts the underlying port down.
+func (transport *FT12Transport) Close() error {
+	return transport.port.Close()
+}
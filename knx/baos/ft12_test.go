@@ -0,0 +1,94 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package baos
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// expectBytes reads from the port and compares the result.
+func expectBytes(t *testing.T, port io.Reader, expected []byte) {
+	buffer := make([]byte, len(expected))
+	if _, err := io.ReadFull(port, buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buffer, expected) {
+		t.Fatalf("Unexpected bytes: %x != %x", buffer, expected)
+	}
+}
+
+func TestFT12Transport(t *testing.T) {
+	port, device := net.Pipe()
+	defer device.Close()
+
+	created := make(chan *FT12Transport)
+	go func() {
+		transport, err := NewFT12Transport(port, DefaultFT12Config)
+		if err != nil {
+			t.Error(err)
+		}
+
+		created <- transport
+	}()
+
+	// The link is reset first.
+	expectBytes(t, device, []byte{0x10, 0x40, 0x40, 0x16})
+	device.Write([]byte{0xE5})
+
+	transport := <-created
+	if transport == nil {
+		t.FailNow()
+	}
+
+	defer transport.Close()
+
+	t.Run("Send", func(t *testing.T) {
+		for _, control := range []byte{0x73, 0x53} {
+			sent := make(chan error)
+			go func() { sent <- transport.Send([]byte{0xF0, 0x01}) }()
+
+			sum := control + 0xF0 + 0x01
+			expectBytes(t, device, []byte{0x68, 3, 3, 0x68, control, 0xF0, 0x01, sum, 0x16})
+			device.Write([]byte{0xE5})
+
+			if err := <-sent; err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	t.Run("Receive", func(t *testing.T) {
+		frame := []byte{0x68, 3, 3, 0x68, 0xF3, 0xF0, 0x81, 0, 0x16}
+		frame[7] = ft12Checksum(frame[4:7])
+
+		go device.Write(frame)
+		expectBytes(t, device, []byte{0xE5})
+
+		select {
+		case msg := <-transport.Inbound():
+			if !bytes.Equal(msg, []byte{0xF0, 0x81}) {
+				t.Errorf("Unexpected message: %x", msg)
+			}
+
+		case <-time.After(time.Second):
+			t.Fatal("No message received")
+		}
+
+		// The frame is repeated, as if the acknowledgement had been lost.
+		go device.Write(frame)
+		expectBytes(t, device, []byte{0xE5})
+
+		select {
+		case msg := <-transport.Inbound():
+			t.Fatalf("Repeated frame has been delivered: %x", msg)
+
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
@@ -0,0 +1,396 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Package baos provides a client for the ObjectServer protocol of KNX BAOS devices.
+package baos
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// mainService is the first byte of every ObjectServer message.
+const mainService = 0xF0
+
+// Subservice identifies an ObjectServer service.
+type Subservice uint8
+
+// These are supported subservices.
+const (
+	GetServerItemReq           Subservice = 0x01
+	GetDatapointDescriptionReq Subservice = 0x03
+	GetDatapointValueReq       Subservice = 0x05
+	SetDatapointValueReq       Subservice = 0x06
+	GetParameterByteReq        Subservice = 0x07
+
+	GetServerItemRes           Subservice = 0x81
+	GetDatapointDescriptionRes Subservice = 0x83
+	GetDatapointValueRes       Subservice = 0x85
+	SetDatapointValueRes       Subservice = 0x86
+	GetParameterByteRes        Subservice = 0x87
+
+	DatapointValueInd Subservice = 0xC1
+	ServerItemInd     Subservice = 0xC2
+)
+
+// response returns the subservice which responds to the request subservice.
+func (sub Subservice) response() Subservice {
+	return sub | 0x80
+}
+
+// ErrorCode is an error which is reported by an ObjectServer.
+type ErrorCode uint8
+
+// These are known error codes.
+const (
+	ErrInternal            ErrorCode = 0x01
+	ErrNoItemFound         ErrorCode = 0x02
+	ErrBufferTooSmall      ErrorCode = 0x03
+	ErrItemNotWriteable    ErrorCode = 0x04
+	ErrServiceNotSupported ErrorCode = 0x05
+	ErrBadServiceParameter ErrorCode = 0x06
+	ErrBadID               ErrorCode = 0x07
+	ErrBadCommand          ErrorCode = 0x08
+	ErrBadLength           ErrorCode = 0x09
+	ErrInconsistent        ErrorCode = 0x0A
+	ErrServerBusy          ErrorCode = 0x0B
+)
+
+// Error implements the error interface.
+func (code ErrorCode) Error() string {
+	switch code {
+	case ErrInternal:
+		return "Internal error"
+
+	case ErrNoItemFound:
+		return "No item found"
+
+	case ErrBufferTooSmall:
+		return "Buffer is too small"
+
+	case ErrItemNotWriteable:
+		return "Item is not writeable"
+
+	case ErrServiceNotSupported:
+		return "Service is not supported"
+
+	case ErrBadServiceParameter:
+		return "Bad service parameter"
+
+	case ErrBadID:
+		return "Bad ID"
+
+	case ErrBadCommand:
+		return "Bad command or value"
+
+	case ErrBadLength:
+		return "Bad length"
+
+	case ErrInconsistent:
+		return "Message is inconsistent"
+
+	case ErrServerBusy:
+		return "Object server is busy"
+
+	default:
+		return fmt.Sprintf("Unknown error code %#x", uint8(code))
+	}
+}
+
+// These are errors that might occur while parsing ObjectServer messages.
+var (
+	ErrMainService = errors.New("Message does not belong to the ObjectServer protocol")
+	ErrItemLength  = errors.New("Item exceeds the message")
+)
+
+// A Message is an ObjectServer message. Requests and responses address a range of items, starting
+// at Start. The items themselves are encoded in Data.
+type Message struct {
+	Subservice Subservice
+	Start      uint16
+	Count      uint16
+	Data       []byte
+}
+
+// Size returns the packed size.
+func (msg *Message) Size() uint {
+	return 6 + uint(len(msg.Data))
+}
+
+// Pack assembles the message in the given buffer.
+func (msg *Message) Pack(buffer []byte) {
+	util.PackSome(
+		buffer, uint8(mainService), uint8(msg.Subservice), msg.Start, msg.Count, msg.Data,
+	)
+}
+
+// Unpack parses the given data in order to initialize the structure.
+func (msg *Message) Unpack(data []byte) (uint, error) {
+	if len(data) < 6 {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	if data[0] != mainService {
+		return 0, ErrMainService
+	}
+
+	msg.Subservice = Subservice(data[1])
+	msg.Start = uint16(data[2])<<8 | uint16(data[3])
+	msg.Count = uint16(data[4])<<8 | uint16(data[5])
+	msg.Data = data[6:]
+
+	return uint(len(data)), nil
+}
+
+// err extracts the error of a response. Responses that fail carry no items and an error code.
+func (msg *Message) err() error {
+	if msg.Count == 0 && len(msg.Data) > 0 && msg.Data[0] != 0 {
+		return ErrorCode(msg.Data[0])
+	}
+
+	return nil
+}
+
+// ItemID identifies a server item.
+type ItemID uint16
+
+// These are known server items.
+const (
+	ItemHardwareType       ItemID = 1
+	ItemHardwareVersion    ItemID = 2
+	ItemFirmwareVersion    ItemID = 3
+	ItemManufacturer       ItemID = 4
+	ItemAppManufacturer    ItemID = 5
+	ItemApplicationID      ItemID = 6
+	ItemApplicationVersion ItemID = 7
+	ItemSerialNumber       ItemID = 8
+	ItemTimeSinceReset     ItemID = 9
+	ItemBusConnected       ItemID = 10
+	ItemMaxBufferSize      ItemID = 11
+	ItemProgrammingMode    ItemID = 15
+	ItemProtocolVersion    ItemID = 16
+)
+
+// A ServerItem is a property of the ObjectServer itself.
+type ServerItem struct {
+	ID   ItemID
+	Data []byte
+}
+
+// unpackServerItems parses the server items in a GetServerItem response.
+func unpackServerItems(data []byte, count uint16) ([]ServerItem, error) {
+	items := make([]ServerItem, 0, count)
+
+	for i := uint16(0); i < count; i++ {
+		if len(data) < 3 {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		length := int(data[2])
+		if len(data) < 3+length {
+			return nil, ErrItemLength
+		}
+
+		items = append(items, ServerItem{
+			ID:   ItemID(uint16(data[0])<<8 | uint16(data[1])),
+			Data: append([]byte(nil), data[3:3+length]...),
+		})
+
+		data = data[3+length:]
+	}
+
+	return items, nil
+}
+
+// ValueType determines the size of a datapoint value.
+type ValueType uint8
+
+// These are the possible value types.
+const (
+	ValueType1Bit   ValueType = 0
+	ValueType2Bit   ValueType = 1
+	ValueType3Bit   ValueType = 2
+	ValueType4Bit   ValueType = 3
+	ValueType5Bit   ValueType = 4
+	ValueType6Bit   ValueType = 5
+	ValueType7Bit   ValueType = 6
+	ValueType1Byte  ValueType = 7
+	ValueType2Byte  ValueType = 8
+	ValueType3Byte  ValueType = 9
+	ValueType4Byte  ValueType = 10
+	ValueType6Byte  ValueType = 11
+	ValueType8Byte  ValueType = 12
+	ValueType10Byte ValueType = 13
+	ValueType14Byte ValueType = 14
+)
+
+// Size returns the number of bytes that a value of this type occupies.
+func (vt ValueType) Size() int {
+	switch {
+	case vt <= ValueType1Byte:
+		return 1
+
+	case vt <= ValueType4Byte:
+		return int(vt-ValueType1Byte) + 1
+
+	case vt == ValueType6Byte:
+		return 6
+
+	case vt == ValueType8Byte:
+		return 8
+
+	case vt == ValueType10Byte:
+		return 10
+
+	default:
+		return 14
+	}
+}
+
+// ConfigFlags describe how a datapoint has been configured.
+type ConfigFlags uint8
+
+// These are the configuration flags of a datapoint.
+const (
+	ConfigCommunication ConfigFlags = 1 << 2
+	ConfigRead          ConfigFlags = 1 << 3
+	ConfigWrite         ConfigFlags = 1 << 4
+	ConfigReadOnInit    ConfigFlags = 1 << 5
+	ConfigTransmit      ConfigFlags = 1 << 6
+	ConfigUpdate        ConfigFlags = 1 << 7
+)
+
+// A DatapointDescription describes a datapoint of the ObjectServer.
+type DatapointDescription struct {
+	ID        uint16
+	ValueType ValueType
+	Flags     ConfigFlags
+
+	// DPT is the main number of the datapoint type, e.g. 9 for DPT 9.001. It is 0 if the datapoint
+	// type is unknown.
+	DPT uint8
+}
+
+// unpackDescriptions parses the descriptions in a GetDatapointDescription response.
+func unpackDescriptions(data []byte, count uint16) ([]DatapointDescription, error) {
+	if len(data) < 5*int(count) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	descs := make([]DatapointDescription, count)
+	for i := range descs {
+		desc := data[5*i:]
+		descs[i] = DatapointDescription{
+			ID:        uint16(desc[0])<<8 | uint16(desc[1]),
+			ValueType: ValueType(desc[2]),
+			Flags:     ConfigFlags(desc[3]),
+			DPT:       desc[4],
+		}
+	}
+
+	return descs, nil
+}
+
+// Filter selects the datapoint values that are retrieved.
+type Filter uint8
+
+// These are the possible filters.
+const (
+	FilterAll     Filter = 0
+	FilterValid   Filter = 1
+	FilterUpdated Filter = 2
+)
+
+// TransmissionState indicates the progress of sending a datapoint value to the bus.
+type TransmissionState uint8
+
+// These are the possible transmission states.
+const (
+	TransmissionIdleOk    TransmissionState = 0
+	TransmissionIdleError TransmissionState = 1
+	TransmissionRunning   TransmissionState = 2
+	TransmissionRequested TransmissionState = 3
+)
+
+// DatapointState describes the state of a datapoint value.
+type DatapointState uint8
+
+// Valid determines whether the datapoint has received a value.
+func (state DatapointState) Valid() bool {
+	return state&(1<<4) != 0
+}
+
+// Updated determines whether the value has changed since it has been read last.
+func (state DatapointState) Updated() bool {
+	return state&(1<<3) != 0
+}
+
+// ReadRequested determines whether a read request has been received for the datapoint.
+func (state DatapointState) ReadRequested() bool {
+	return state&(1<<2) != 0
+}
+
+// Transmission returns the transmission state.
+func (state DatapointState) Transmission() TransmissionState {
+	return TransmissionState(state & 3)
+}
+
+// unpackValues parses the datapoint values in a GetDatapointValue response or a DatapointValue
+// indication.
+func unpackValues(data []byte, count uint16) ([]DatapointEvent, error) {
+	events := make([]DatapointEvent, 0, count)
+
+	for i := uint16(0); i < count; i++ {
+		if len(data) < 4 {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		length := int(data[3])
+		if len(data) < 4+length {
+			return nil, ErrItemLength
+		}
+
+		events = append(events, DatapointEvent{
+			ID:    uint16(data[0])<<8 | uint16(data[1]),
+			State: DatapointState(data[2]),
+			Data:  append([]byte(nil), data[4:4+length]...),
+		})
+
+		data = data[4+length:]
+	}
+
+	return events, nil
+}
+
+// Command determines what happens to a datapoint when its value is set.
+type Command uint8
+
+// These are the possible commands.
+const (
+	CommandNone            Command = 0x00
+	CommandSet             Command = 0x01
+	CommandSend            Command = 0x02
+	CommandSetAndSend      Command = 0x03
+	CommandRead            Command = 0x04
+	CommandClearTransState Command = 0x05
+)
+
+// A DatapointCommand manipulates a datapoint.
+type DatapointCommand struct {
+	ID      uint16
+	Command Command
+	Data    []byte
+}
+
+// appendCommands appends the commands to dst.
+func appendCommands(dst []byte, cmds []DatapointCommand) []byte {
+	for _, cmd := range cmds {
+		dst = append(dst, byte(cmd.ID>>8), byte(cmd.ID), byte(cmd.Command), byte(len(cmd.Data)))
+		dst = append(dst, cmd.Data...)
+	}
+
+	return dst
+}
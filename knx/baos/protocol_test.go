@@ -0,0 +1,44 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package baos
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/vapourismo/knx-go/knx/dpt"
+)
+
+func TestValueType_Size(t *testing.T) {
+	sizes := map[ValueType]int{
+		ValueType1Bit:   1,
+		ValueType1Byte:  1,
+		ValueType2Byte:  2,
+		ValueType4Byte:  4,
+		ValueType14Byte: 14,
+	}
+
+	for vt, size := range sizes {
+		if vt.Size() != size {
+			t.Errorf("Unexpected size of value type %d: %d != %d", vt, vt.Size(), size)
+		}
+	}
+}
+
+func TestEncodeValue(t *testing.T) {
+	on := dpt.DPT_1001(true)
+	if data := EncodeValue(&on); !bytes.Equal(data, []byte{1}) {
+		t.Errorf("Unexpected encoding: %v", data)
+	}
+
+	percent := dpt.DPT_5004(200)
+	if data := EncodeValue(&percent); !bytes.Equal(data, []byte{200}) {
+		t.Errorf("Unexpected encoding: %v", data)
+	}
+
+	var result dpt.DPT_5004
+	if err := DecodeValue([]byte{200}, &result); err != nil || result != percent {
+		t.Errorf("Unexpected decoding: %v, %v", result, err)
+	}
+}
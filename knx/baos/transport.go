@@ -0,0 +1,144 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package baos
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// A Transport exchanges ObjectServer messages with a BAOS device.
+type Transport interface {
+	// Send transmits an ObjectServer message.
+	Send(msg []byte) error
+
+	// Inbound returns the channel on which received messages are delivered. It is closed when the
+	// transport fails or has been closed.
+	Inbound() <-chan []byte
+
+	// Close shuts the transport down.
+	Close() error
+}
+
+// These are properties of the KNXnet/IP header used by BAOS devices.
+const (
+	ipHeaderLength     = 6
+	ipProtocolVersion  = 0x20
+	ipService          = 0xF080
+	ipConnHeaderLength = 4
+)
+
+// These are errors that might occur on a KNXnet/IP transport.
+var (
+	ErrIPHeader     = errors.New("Invalid KNXnet/IP header")
+	ErrIPConnHeader = errors.New("Invalid connection header")
+)
+
+// IPTransport transports ObjectServer messages in KNXnet/IP frames over a stream, usually a TCP
+// connection to port 12004 of the BAOS device.
+type IPTransport struct {
+	conn    io.ReadWriteCloser
+	sendMu  sync.Mutex
+	inbound chan []byte
+}
+
+// DialIP connects to the BAOS device at the given address.
+func DialIP(address string) (*IPTransport, error) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewIPTransport(conn), nil
+}
+
+// NewIPTransport creates a transport on top of the given stream. The transport takes ownership of
+// the stream and closes it when it is closed.
+func NewIPTransport(conn io.ReadWriteCloser) *IPTransport {
+	transport := &IPTransport{
+		conn:    conn,
+		inbound: make(chan []byte),
+	}
+
+	go transport.serve()
+
+	return transport
+}
+
+// Send transmits an ObjectServer message.
+func (transport *IPTransport) Send(msg []byte) error {
+	size := ipHeaderLength + ipConnHeaderLength + len(msg)
+
+	frame := make([]byte, 0, size)
+	frame = append(frame, ipHeaderLength, ipProtocolVersion)
+	frame = util.AppendUint16(frame, ipService)
+	frame = util.AppendUint16(frame, uint16(size))
+	frame = append(frame, ipConnHeaderLength, 0, 0, 0)
+	frame = append(frame, msg...)
+
+	transport.sendMu.Lock()
+	defer transport.sendMu.Unlock()
+
+	_, err := transport.conn.Write(frame)
+	return err
+}
+
+// readFrame reads the next ObjectServer message from the stream.
+func (transport *IPTransport) readFrame() ([]byte, error) {
+	var header [ipHeaderLength + ipConnHeaderLength]byte
+	if _, err := io.ReadFull(transport.conn, header[:]); err != nil {
+		return nil, err
+	}
+
+	service := uint16(header[2])<<8 | uint16(header[3])
+	size := int(header[4])<<8 | int(header[5])
+
+	if header[0] != ipHeaderLength || header[1] != ipProtocolVersion || service != ipService ||
+		size < len(header) {
+		return nil, ErrIPHeader
+	}
+
+	if header[6] != ipConnHeaderLength {
+		return nil, ErrIPConnHeader
+	}
+
+	msg := make([]byte, size-len(header))
+	if _, err := io.ReadFull(transport.conn, msg); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// serve receives the incoming messages.
+func (transport *IPTransport) serve() {
+	util.Log(transport, "Started worker")
+	defer util.Log(transport, "Worker exited")
+
+	defer close(transport.inbound)
+
+	for {
+		msg, err := transport.readFrame()
+		if err != nil {
+			util.Log(transport, "Error while reading frame: %v", err)
+			return
+		}
+
+		transport.inbound <- msg
+	}
+}
+
+// Inbound returns the channel on which received messages are delivered.
+func (transport *IPTransport) Inbound() <-chan []byte {
+	return transport.inbound
+}
+
+// Close shuts the underlying stream down.
+func (transport *IPTransport) Close() error {
+	return transport.conn.Close()
+}
@@ -91,6 +91,16 @@ func (info Info) Pack(buffer []byte) {
 	copy(buffer[1:], info[:buffer[0]])
 }
 
+// AppendPack appends the packed info structure to dst.
+func (info Info) AppendPack(dst []byte) ([]byte, error) {
+	if len(info) > 255 {
+		info = info[:255]
+	}
+
+	dst = append(dst, byte(len(info)))
+	return append(dst, info...), nil
+}
+
 // Unpack initializes the structure by parsing the given data.
 func (info *Info) Unpack(data []byte) (n uint, err error) {
 	var length uint8
@@ -138,6 +148,11 @@ func (body *UnsupportedMessage) Pack(buffer []byte) {
 	copy(buffer, body.Data)
 }
 
+// AppendPack appends the message body to dst.
+func (body *UnsupportedMessage) AppendPack(dst []byte) ([]byte, error) {
+	return append(dst, body.Data...), nil
+}
+
 // Unpack initializes the structure by parsing the given data.
 func (body *UnsupportedMessage) Unpack(data []byte) (uint, error) {
 	if len(body.Data) < len(data) {
@@ -152,44 +167,47 @@ type messageUnpackable interface {
 	Message
 }
 
-// Unpack a message from a CEMI-encoded frame.
-func Unpack(data []byte, message *Message) (n uint, err error) {
-	var code MessageCode
-
-	// Read header.
-	n, err = util.Unpack(data, (*uint8)(&code))
-	if err != nil {
-		return
-	}
-
-	var body messageUnpackable
-
-	// Decide which message is appropriate.
+// newMessage allocates the message structure for the given message code.
+func newMessage(code MessageCode) messageUnpackable {
 	switch code {
 	case LBusmonIndCode:
-		body = &LBusmonInd{}
+		return &LBusmonInd{}
 
 	case LDataReqCode:
-		body = &LDataReq{}
+		return &LDataReq{}
 
 	case LDataConCode:
-		body = &LDataCon{}
+		return &LDataCon{}
 
 	case LDataIndCode:
-		body = &LDataInd{}
+		return &LDataInd{}
 
 	case LRawReqCode:
-		body = &LRawReq{}
+		return &LRawReq{}
 
 	case LRawConCode:
-		body = &LRawCon{}
+		return &LRawCon{}
 
 	case LRawIndCode:
-		body = &LRawInd{}
+		return &LRawInd{}
 
 	default:
-		body = &UnsupportedMessage{Code: code}
+		return &UnsupportedMessage{Code: code}
 	}
+}
+
+// Unpack a message from a CEMI-encoded frame.
+func Unpack(data []byte, message *Message) (n uint, err error) {
+	var code MessageCode
+
+	// Read header.
+	n, err = util.Unpack(data, (*uint8)(&code))
+	if err != nil {
+		return
+	}
+
+	// Decide which message is appropriate.
+	body := newMessage(code)
 
 	// Parse the message.
 	m, err := body.Unpack(data[n:])
@@ -210,3 +228,19 @@ func Size(message Message) uint {
 func Pack(buffer []byte, message Message) {
 	util.PackSome(buffer, uint8(message.MessageCode()), message)
 }
+
+// AppendPack appends a CEMI-encoded frame containing the given message to dst. Unlike Pack, it
+// does not panic when the message is incomplete, but returns an error instead. In that case, dst
+// is returned unaltered.
+func AppendPack(dst []byte, message Message) ([]byte, error) {
+	if message == nil {
+		return dst, util.ErrNilPackable
+	}
+
+	result, err := util.AppendPack(append(dst, uint8(message.MessageCode())), message)
+	if err != nil {
+		return dst, err
+	}
+
+	return result, nil
+}
@@ -6,6 +6,7 @@ package cemi
 import (
 	"bytes"
 	"math/rand"
+	"reflect"
 	"testing"
 )
 
@@ -61,3 +62,154 @@ func TestUnpack(t *testing.T) {
 		}
 	}
 }
+
+func makeRandFrame() []byte {
+	switch rand.Int() % 4 {
+	case 0:
+		codes := []MessageCode{LRawReqCode, LRawConCode, LRawIndCode, LBusmonIndCode, 0x42}
+		code := codes[rand.Int()%len(codes)]
+
+		return append([]byte{byte(code)}, makeRandBuffer(1+rand.Int()%32)...)
+
+	case 1:
+		ldataCodes := []MessageCode{LDataReqCode, LDataConCode, LDataIndCode}
+		code := ldataCodes[rand.Int()%3]
+
+		return append([]byte{byte(code)}, makeRandLData()...)
+
+	default:
+		n := 1 + rand.Int()%16
+
+		return bytes.Join([][]byte{
+			[]byte{byte(LDataIndCode), 0},
+			makeRandBuffer(6),
+			[]byte{byte(n), byte(rand.Int() & 0x7F)},
+			makeRandBuffer(n),
+		}, nil)
+	}
+}
+
+func TestDecoder_Unpack(t *testing.T) {
+	var dec Decoder
+
+	for i := 0; i < 1000; i++ {
+		data := makeRandFrame()
+
+		var expected Message
+		expectedNum, expectedErr := Unpack(data, &expected)
+
+		var msg Message
+		num, err := dec.Unpack(data, &msg)
+
+		if err != expectedErr || num != expectedNum {
+			t.Fatal("Unexpected result:", num, err, expectedNum, expectedErr, data)
+		}
+
+		if !reflect.DeepEqual(msg, expected) {
+			t.Fatalf("Unexpected message: %+v != %+v", msg, expected)
+		}
+	}
+}
+
+func TestDecoder_UnpackInto(t *testing.T) {
+	t.Run("Frames", func(t *testing.T) {
+		var dec Decoder
+		var msg Message
+
+		for i := 0; i < 1000; i++ {
+			data := makeRandFrame()
+
+			var expected Message
+			expectedNum, expectedErr := Unpack(data, &expected)
+
+			num, err := dec.UnpackInto(data, &msg)
+
+			if err != expectedErr || num != expectedNum {
+				t.Fatal("Unexpected result:", num, err, expectedNum, expectedErr, data)
+			}
+
+			if err == nil && !reflect.DeepEqual(msg, expected) {
+				t.Fatalf("Unexpected message: %+v != %+v", msg, expected)
+			}
+		}
+	})
+
+	t.Run("Reuse", func(t *testing.T) {
+		var dec Decoder
+
+		frame := func(value byte) []byte {
+			return []byte{byte(LDataIndCode), 0, 0xBC, 0xE0, 0x11, 0x05, 0x0A, 0x03, 2, 0, 0x80, value}
+		}
+
+		var retained Message
+		if _, err := dec.UnpackInto(frame(1), &retained); err != nil {
+			t.Fatal(err)
+		}
+
+		var msg Message
+		allocs := testing.AllocsPerRun(100, func() {
+			if _, err := dec.UnpackInto(frame(2), &msg); err != nil {
+				t.Fatal(err)
+			}
+		})
+
+		// Only the frame literal and the first decoding allocate.
+		if allocs > 1 {
+			t.Errorf("Expected no allocations for decoding, got %v", allocs)
+		}
+
+		// Messages provided by the caller are independent of each other.
+		if data := retained.(*LDataInd).Data.(*AppData).Data; data[1] != 1 {
+			t.Errorf("Retained message has been altered: %v", data)
+		}
+
+		if data := msg.(*LDataInd).Data.(*AppData).Data; data[1] != 2 {
+			t.Errorf("Unexpected data: %v", data)
+		}
+	})
+}
+
+func TestAppendPack(t *testing.T) {
+	t.Run("Frames", func(t *testing.T) {
+		var buffer []byte
+
+		for i := 0; i < 100; i++ {
+			var msg Message
+			if _, err := Unpack(makeRandFrame(), &msg); err != nil {
+				t.Fatal(err)
+			}
+
+			expected := make([]byte, Size(msg))
+			Pack(expected, msg)
+
+			var err error
+			buffer, err = AppendPack(append(buffer[:0], 0xFF), msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if buffer[0] != 0xFF || !bytes.Equal(buffer[1:], expected) {
+				t.Fatal("Unexpected result:", buffer[1:], expected)
+			}
+		}
+	})
+
+	t.Run("NoTransportUnit", func(t *testing.T) {
+		dst := []byte{1, 2, 3}
+
+		result, err := AppendPack(dst, &LDataReq{})
+		if err != ErrNoTransportUnit {
+			t.Fatal("Should not succeed")
+		}
+
+		if !bytes.Equal(result, dst) {
+			t.Error("Buffer has been altered:", result)
+		}
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		if _, err := AppendPack(nil, nil); err == nil {
+			t.Fatal("Should not succeed")
+		}
+	})
+}
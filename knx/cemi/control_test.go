@@ -0,0 +1,26 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package cemi
+
+import "testing"
+
+func TestControlField2_Hops(t *testing.T) {
+	for hops := uint8(0); hops <= 7; hops++ {
+		for _, flags := range []ControlField2{0, Control2GroupAddr, Control2LTEFrame | 0x0B} {
+			ctrl2 := Control2Hops(hops) | flags
+			if ctrl2.Hops() != hops {
+				t.Errorf("Expected %d hops, got %d for %#02x", hops, ctrl2.Hops(), uint8(ctrl2))
+			}
+		}
+	}
+
+	// The usual control field of a group telegram has the hop count 6.
+	if hops := ControlField2(0xE0).Hops(); hops != 6 {
+		t.Errorf("Expected 6 hops, got %d", hops)
+	}
+
+	if hops := Control2Hops(9).Hops(); hops != 7 {
+		t.Errorf("Expected 7 hops, got %d", hops)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package cemi
+
+import "io"
+
+// A Decoder parses CEMI-encoded frames while reusing memory. Once the structures involved have
+// grown large enough, decoding does not allocate memory anymore.
+//
+// Unpack decodes into structures owned by the decoder. The message it produces is only valid until
+// the next call to Unpack; copy it if you need to retain it. UnpackInto decodes into a message
+// provided by the caller instead. A Decoder must not be used by multiple goroutines at the same
+// time.
+type Decoder struct {
+	ldataReq    LDataReq
+	ldataCon    LDataCon
+	ldataInd    LDataInd
+	lrawReq     LRawReq
+	lrawCon     LRawCon
+	lrawInd     LRawInd
+	busmonInd   LBusmonInd
+	unsupported UnsupportedMessage
+}
+
+// Unpack a message from a CEMI-encoded frame. It behaves like the package-level Unpack, except
+// that the message points into the structures owned by the decoder.
+func (dec *Decoder) Unpack(data []byte, message *Message) (uint, error) {
+	if len(data) < 1 {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	var result Message
+
+	switch code := MessageCode(data[0]); code {
+	case LBusmonIndCode:
+		result = &dec.busmonInd
+
+	case LDataReqCode:
+		result = &dec.ldataReq
+
+	case LDataConCode:
+		result = &dec.ldataCon
+
+	case LDataIndCode:
+		result = &dec.ldataInd
+
+	case LRawReqCode:
+		result = &dec.lrawReq
+
+	case LRawConCode:
+		result = &dec.lrawCon
+
+	case LRawIndCode:
+		result = &dec.lrawInd
+
+	default:
+		dec.unsupported.Code = code
+		result = &dec.unsupported
+	}
+
+	n, err := dec.UnpackInto(data, &result)
+	if err == nil {
+		*message = result
+	}
+
+	return n, err
+}
+
+// UnpackInto parses a CEMI-encoded frame into the message provided by the caller. If the message
+// has the type that the frame encodes, its memory is reused, including its transport unit.
+// Otherwise, a new message is stored in its place. Decoding repeatedly into the same message does
+// not allocate memory, once it has grown large enough.
+//
+// The decoder does not retain the message. If an error occurs, the message may be altered
+// partially.
+func (dec *Decoder) UnpackInto(data []byte, message *Message) (uint, error) {
+	if len(data) < 1 {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	code := MessageCode(data[0])
+	body := data[1:]
+
+	if *message == nil || (*message).MessageCode() != code {
+		*message = newMessage(code)
+	}
+
+	var (
+		n   uint
+		err error
+	)
+
+	switch msg := (*message).(type) {
+	case *LBusmonInd:
+		*msg = append((*msg)[:0], body...)
+		n = uint(len(body))
+
+	case *LDataReq:
+		n, err = msg.unpackReusing(body)
+
+	case *LDataCon:
+		n, err = msg.unpackReusing(body)
+
+	case *LDataInd:
+		n, err = msg.unpackReusing(body)
+
+	case *LRawReq:
+		msg.LRaw = append(msg.LRaw[:0], body...)
+		n = uint(len(body))
+
+	case *LRawCon:
+		msg.LRaw = append(msg.LRaw[:0], body...)
+		n = uint(len(body))
+
+	case *LRawInd:
+		msg.LRaw = append(msg.LRaw[:0], body...)
+		n = uint(len(body))
+
+	case *UnsupportedMessage:
+		msg.Data = append(msg.Data[:0], body...)
+		n = uint(len(body))
+
+	default:
+		// Messages of other types cannot be reused.
+		fresh := newMessage(code)
+		n, err = fresh.Unpack(body)
+		*message = fresh
+	}
+
+	return 1 + n, err
+}
@@ -3,6 +3,17 @@
 
 package cemi
 
+import "errors"
+
+// These are errors that can occur when decoding the raw frame of a L_Busmon.ind message.
+var (
+	ErrRawFrameLength = errors.New("Raw frame is too short")
+	ErrRawChecksum    = errors.New("Raw frame has an invalid checksum")
+)
+
+// rawStdFrame indicates a standard frame in the control field of a raw frame.
+const rawStdFrame = 1 << 7
+
 // A LBusmonInd represents a L_Busmon.ind message.
 type LBusmonInd []byte
 
@@ -21,6 +32,11 @@ func (lbm LBusmonInd) Pack(buffer []byte) {
 	copy(buffer, lbm)
 }
 
+// AppendPack appends the message body to dst.
+func (lbm LBusmonInd) AppendPack(dst []byte) ([]byte, error) {
+	return append(dst, lbm...), nil
+}
+
 // Unpack initializes the structure by parsing the given data.
 func (lbm *LBusmonInd) Unpack(data []byte) (n uint, err error) {
 	target := []byte(*lbm)
@@ -34,3 +50,72 @@ func (lbm *LBusmonInd) Unpack(data []byte) (n uint, err error) {
 
 	return
 }
+
+// Frame decodes the raw TP1 frame which follows the additional information. The result has the
+// same structure as the frames of L_Data messages. The second result is false for acknowledgement
+// frames, which consist of a single byte.
+func (lbm LBusmonInd) Frame() (LData, bool, error) {
+	if len(lbm) < 1 || len(lbm) < 1+int(lbm[0]) {
+		return LData{}, false, ErrRawFrameLength
+	}
+
+	var info Info
+	if lbm[0] > 0 {
+		info = append(info, lbm[1:1+int(lbm[0])]...)
+	}
+
+	raw := []byte(lbm[1+int(lbm[0]):])
+
+	switch len(raw) {
+	case 0:
+		return LData{}, false, ErrRawFrameLength
+
+	case 1:
+		return LData{}, false, nil
+	}
+
+	// The checksum byte makes the parity of every bit position odd.
+	var checksum byte
+	for _, b := range raw {
+		checksum ^= b
+	}
+
+	if checksum != 0xFF {
+		return LData{}, false, ErrRawChecksum
+	}
+
+	// Standard frames carry the length in the lower bits of the network layer control field,
+	// whereas extended frames have an extended control field and a dedicated length byte.
+	var ctrl2, length byte
+	var addrs, tpdu []byte
+
+	if raw[0]&rawStdFrame != 0 {
+		if len(raw) < 8 || len(raw) < 8+int(raw[5]&15) {
+			return LData{}, false, ErrRawFrameLength
+		}
+
+		ctrl2, length = raw[5]&0xF0, raw[5]&15
+		addrs, tpdu = raw[1:5], raw[6:7+int(length)]
+	} else {
+		if len(raw) < 9 || len(raw) < 9+int(raw[6]) {
+			return LData{}, false, ErrRawFrameLength
+		}
+
+		ctrl2, length = raw[1], raw[6]
+		addrs, tpdu = raw[2:6], raw[7:8+int(length)]
+	}
+
+	ldata := LData{
+		Info:        info,
+		Control1:    ControlField1(raw[0]),
+		Control2:    ControlField2(ctrl2),
+		Source:      IndividualAddr(uint16(addrs[0])<<8 | uint16(addrs[1])),
+		Destination: uint16(addrs[2])<<8 | uint16(addrs[3]),
+	}
+
+	if _, err := unpackTransportUnit(append([]byte{length}, tpdu...), &ldata.Data, false); err != nil {
+		return LData{}, false, err
+	}
+
+	return ldata, true, nil
+}
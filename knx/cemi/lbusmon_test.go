@@ -0,0 +1,90 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package cemi
+
+import (
+	"bytes"
+	"testing"
+)
+
+// makeRawFrame appends the checksum to the raw frame and wraps it in a L_Busmon.ind message
+// without additional information.
+func makeRawFrame(raw ...byte) LBusmonInd {
+	var checksum byte = 0xFF
+	for _, b := range raw {
+		checksum ^= b
+	}
+
+	return append(LBusmonInd{0}, append(raw, checksum)...)
+}
+
+func TestLBusmonInd_Frame(t *testing.T) {
+	t.Run("Standard", func(t *testing.T) {
+		// Priority low, not repeated, 1.1.5 -> 0/0/1, group, hop count 6, GroupValueWrite 1
+		frame := makeRawFrame(0xBC, 0x11, 0x05, 0x00, 0x01, 0xE1, 0x00, 0x81)
+
+		ldata, ok, err := frame.Frame()
+		if err != nil || !ok {
+			t.Fatalf("Expected frame, got %v, %v", ok, err)
+		}
+
+		if ldata.Source != 0x1105 || ldata.Destination != 0x0001 {
+			t.Fatalf("Expected 1.1.5 -> 0/0/1, got %v -> %v", ldata.Source, ldata.Destination)
+		}
+
+		if !ldata.Control2.IsGroupAddr() {
+			t.Fatal("Expected group address")
+		}
+
+		if hops := ldata.Control2.Hops(); hops != 6 {
+			t.Fatalf("Expected 6 hops, got %v", hops)
+		}
+
+		if prio := ldata.Control1.Priority(); prio != PrioLow {
+			t.Fatalf("Expected %v, got %v", PrioLow, prio)
+		}
+
+		app, ok := ldata.Data.(*AppData)
+		if !ok || app.Command != GroupValueWrite || !bytes.Equal(app.Data, []byte{1}) {
+			t.Fatalf("Unexpected transport unit %+v", ldata.Data)
+		}
+	})
+
+	t.Run("Extended", func(t *testing.T) {
+		frame := makeRawFrame(0x3C, 0xE0, 0x11, 0x05, 0x00, 0x01, 0x01, 0x00, 0x81)
+
+		ldata, ok, err := frame.Frame()
+		if err != nil || !ok {
+			t.Fatalf("Expected frame, got %v, %v", ok, err)
+		}
+
+		if ldata.Control2.Hops() != 6 || ldata.Destination != 0x0001 {
+			t.Fatalf("Unexpected frame %+v", ldata)
+		}
+	})
+
+	t.Run("Acknowledgement", func(t *testing.T) {
+		_, ok, err := LBusmonInd{0, 0xCC}.Frame()
+		if err != nil || ok {
+			t.Fatalf("Expected acknowledgement, got %v, %v", ok, err)
+		}
+	})
+
+	t.Run("Checksum", func(t *testing.T) {
+		frame := makeRawFrame(0xBC, 0x11, 0x05, 0x00, 0x01, 0xE1, 0x00, 0x81)
+		frame[len(frame)-1] ^= 1
+
+		if _, _, err := frame.Frame(); err != ErrRawChecksum {
+			t.Fatalf("Expected %v, got %v", ErrRawChecksum, err)
+		}
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		frame := makeRawFrame(0xBC, 0x11, 0x05, 0x00, 0x01, 0xE3, 0x00, 0x81)
+
+		if _, _, err := frame.Frame(); err != ErrRawFrameLength {
+			t.Fatalf("Expected %v, got %v", ErrRawFrameLength, err)
+		}
+	})
+}
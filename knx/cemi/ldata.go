@@ -3,7 +3,15 @@
 
 package cemi
 
-import "github.com/vapourismo/knx-go/knx/util"
+import (
+	"errors"
+	"io"
+
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// ErrNoTransportUnit is returned when packing a frame without a transport unit.
+var ErrNoTransportUnit = errors.New("Frame has no transport unit")
 
 // A LData is a link-layer data frame. L_Data.req, L_Data.con and L_Data.ind share this structure.
 type LData struct {
@@ -28,12 +36,38 @@ func (ldata *LData) Unpack(data []byte) (n uint, err error) {
 		return
 	}
 
-	m, err := unpackTransportUnit(data[n:], &ldata.Data)
+	m, err := unpackTransportUnit(data[n:], &ldata.Data, false)
 	n += m
 
 	return
 }
 
+// unpackReusing is like Unpack, but it reuses the memory of the structure, including its transport
+// unit.
+func (ldata *LData) unpackReusing(data []byte) (uint, error) {
+	if len(data) < 1 || len(data) < 7+int(data[0]) {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	infoLength := int(data[0])
+	if infoLength > 0 {
+		ldata.Info = append(ldata.Info[:0], data[1:1+infoLength]...)
+	} else {
+		ldata.Info = nil
+	}
+
+	header := data[1+infoLength:]
+	ldata.Control1 = ControlField1(header[0])
+	ldata.Control2 = ControlField2(header[1])
+	ldata.Source = IndividualAddr(uint16(header[2])<<8 | uint16(header[3]))
+	ldata.Destination = uint16(header[4])<<8 | uint16(header[5])
+
+	n := uint(7 + infoLength)
+
+	m, err := unpackTransportUnit(data[n:], &ldata.Data, true)
+	return n + m, err
+}
+
 // Size returns the packed size.
 func (ldata *LData) Size() uint {
 	return ldata.Info.Size() + 6 + ldata.Data.Size()
@@ -52,6 +86,20 @@ func (ldata *LData) Pack(buffer []byte) {
 	)
 }
 
+// AppendPack appends the message body to dst. It fails if the frame has no transport unit.
+func (ldata *LData) AppendPack(dst []byte) ([]byte, error) {
+	if ldata.Data == nil {
+		return dst, ErrNoTransportUnit
+	}
+
+	dst, _ = ldata.Info.AppendPack(dst)
+	dst = append(dst, uint8(ldata.Control1), uint8(ldata.Control2))
+	dst = util.AppendUint16(dst, uint16(ldata.Source))
+	dst = util.AppendUint16(dst, ldata.Destination)
+
+	return util.AppendPack(dst, ldata.Data)
+}
+
 // A LDataReq represents a L_Data.req message body.
 type LDataReq struct {
 	LData
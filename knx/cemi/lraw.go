@@ -16,6 +16,11 @@ func (lraw LRaw) Pack(buffer []byte) {
 	copy(buffer, lraw)
 }
 
+// AppendPack appends the message body to dst.
+func (lraw LRaw) AppendPack(dst []byte) ([]byte, error) {
+	return append(dst, lraw...), nil
+}
+
 // Unpack initializes the structure by parsing the given data.
 func (lraw *LRaw) Unpack(data []byte) (n uint, err error) {
 	target := []byte(*lraw)
@@ -57,5 +62,5 @@ type LRawInd struct {
 
 // MessageCode returns the message code for L_Raw.ind.
 func (LRawInd) MessageCode() MessageCode {
-	return LRawConCode
+	return LRawIndCode
 }
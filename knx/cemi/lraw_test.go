@@ -0,0 +1,37 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package cemi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLRaw_MessageCode(t *testing.T) {
+	messages := []Message{
+		&LRawReq{LRaw{1, 2, 3}},
+		&LRawCon{LRaw{1, 2, 3}},
+		&LRawInd{LRaw{1, 2, 3}},
+	}
+
+	codes := []MessageCode{LRawReqCode, LRawConCode, LRawIndCode}
+
+	for i, msg := range messages {
+		frame := make([]byte, Size(msg))
+		Pack(frame, msg)
+
+		if MessageCode(frame[0]) != codes[i] {
+			t.Errorf("Expected %v, got %v", codes[i], MessageCode(frame[0]))
+		}
+
+		var result Message
+		if _, err := Unpack(frame, &result); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(result, msg) {
+			t.Errorf("Expected %+v, got %+v", msg, result)
+		}
+	}
+}
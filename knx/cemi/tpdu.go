@@ -82,6 +82,35 @@ func (app *AppData) Pack(buffer []byte) {
 	buffer[2] |= byte(app.Command&3) << 6
 }
 
+// AppendPack appends the transport data unit including its leading length byte to dst.
+func (app *AppData) AppendPack(dst []byte) ([]byte, error) {
+	dataLength := len(app.Data)
+
+	if dataLength > 255 {
+		dataLength = 255
+	} else if dataLength < 1 {
+		dataLength = 1
+	}
+
+	tpci := byte(app.Command>>2) & 3
+	if app.Numbered {
+		tpci |= 1<<6 | (app.SeqNumber&15)<<2
+	}
+
+	apci := byte(app.Command&3) << 6
+	if len(app.Data) > 0 {
+		apci |= app.Data[0] & 63
+	}
+
+	dst = append(dst, byte(dataLength), tpci, apci)
+
+	if len(app.Data) > 1 {
+		dst = append(dst, app.Data[1:dataLength]...)
+	}
+
+	return dst, nil
+}
+
 // A ControlData encodes control information in a transport unit.
 type ControlData struct {
 	Numbered  bool
@@ -104,25 +133,39 @@ func (control *ControlData) Pack(buffer []byte) {
 	}
 }
 
+// AppendPack appends the transport data unit including its leading length byte to dst.
+func (control *ControlData) AppendPack(dst []byte) ([]byte, error) {
+	tpci := 1<<7 | (control.Command & 3)
+	if control.Numbered {
+		tpci |= 1<<6 | (control.SeqNumber&15)<<2
+	}
+
+	return append(dst, 0, tpci), nil
+}
+
 // A TransportUnit is responsible to transport data.
 type TransportUnit interface {
 	util.Packable
 }
 
 // unpackTransportUnit parses the given data in order to extract the transport unit that it encodes.
-func unpackTransportUnit(data []byte, unit *TransportUnit) (uint, error) {
+// If reuse is true and the unit already has the type that the data encodes, its memory is reused.
+func unpackTransportUnit(data []byte, unit *TransportUnit, reuse bool) (uint, error) {
 	if len(data) < 2 {
 		return 0, io.ErrUnexpectedEOF
 	}
 
 	// Does unit contain control information?
 	if (data[1] & (1 << 7)) == 1<<7 {
-		control := &ControlData{
-			Numbered:  (data[1] & (1 << 6)) == 1<<6,
-			SeqNumber: (data[1] >> 2) & 15,
-			Command:   data[1] & 3,
+		control, ok := (*unit).(*ControlData)
+		if !reuse || !ok {
+			control = &ControlData{}
 		}
 
+		control.Numbered = (data[1] & (1 << 6)) == 1<<6
+		control.SeqNumber = (data[1] >> 2) & 15
+		control.Command = data[1] & 3
+
 		*unit = control
 
 		return 2, nil
@@ -134,14 +177,26 @@ func unpackTransportUnit(data []byte, unit *TransportUnit) (uint, error) {
 		return 0, io.ErrUnexpectedEOF
 	}
 
-	app := &AppData{
-		Numbered:  (data[1] & (1 << 6)) == 1<<6,
-		SeqNumber: (data[1] >> 2) & 15,
-		Command:   APCI((data[1]&3)<<2 | data[2]>>6),
-		Data:      make([]byte, dataLength),
+	app, ok := (*unit).(*AppData)
+	if !reuse || !ok {
+		app = &AppData{}
+	}
+
+	app.Numbered = (data[1] & (1 << 6)) == 1<<6
+	app.SeqNumber = (data[1] >> 2) & 15
+	app.Command = APCI((data[1]&3)<<2 | data[2]>>6)
+
+	if cap(app.Data) < dataLength {
+		app.Data = make([]byte, dataLength)
+	} else {
+		app.Data = app.Data[:dataLength]
+	}
+
+	// The frame may be shorter than its length byte claims. Missing bytes are zero.
+	for i := copy(app.Data, data[2:]); i < dataLength; i++ {
+		app.Data[i] = 0
 	}
 
-	copy(app.Data, data[2:])
 	app.Data[0] &= 63
 
 	*unit = app
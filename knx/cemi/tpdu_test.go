@@ -111,7 +111,7 @@ func TestUnpackTransportUnit(t *testing.T) {
 			data[1] |= 1 << 7
 
 			var unit TransportUnit
-			num, err := unpackTransportUnit(data, &unit)
+			num, err := unpackTransportUnit(data, &unit, false)
 
 			if err != nil {
 				t.Error("Unexpected error:", err, data)
@@ -152,7 +152,7 @@ func TestUnpackTransportUnit(t *testing.T) {
 			data[1] &= ^(byte(1) << 7)
 
 			var unit TransportUnit
-			num, err := unpackTransportUnit(data, &unit)
+			num, err := unpackTransportUnit(data, &unit, false)
 
 			if err != nil {
 				t.Error("Unexpected error:", err, data)
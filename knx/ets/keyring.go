@@ -0,0 +1,235 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Package ets reads files that have been exported from the ETS, the engineering tool of KNX.
+package ets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// These are the parameters of the key derivation that protects a keyring.
+const (
+	keyringSalt       = "1.keyring.ets.knx.org"
+	keyringIterations = 65536
+)
+
+// ErrKeyLength is returned when an encrypted key in a keyring is not 16 bytes long.
+var ErrKeyLength = errors.New("Encrypted key must be 16 bytes long")
+
+// KeyringDevice is a secure device of a keyring.
+type KeyringDevice struct {
+	Address cemi.IndividualAddr
+
+	// ToolKey protects the point-to-point communication with the device. It is nil if the device
+	// does not have one.
+	ToolKey []byte
+
+	// SequenceNumber is the last sequence number that the ETS has used towards the device.
+	SequenceNumber uint64
+
+	SerialNumber string
+}
+
+// Keyring contains the keys of a secured project, as exported by the ETS (.knxkeys).
+//
+// The signature of the keyring is not verified. A wrong password results in wrong keys, which
+// then show up as authentication failures.
+type Keyring struct {
+	Project string
+	Created string
+
+	// BackboneKey protects KNXnet/IP secure routing. It is nil if the backbone is not secured.
+	BackboneKey      []byte
+	MulticastAddress string
+
+	Groups  map[cemi.GroupAddr][]byte
+	Devices map[cemi.IndividualAddr]KeyringDevice
+}
+
+// xmlKeyring is the XML representation of a keyring.
+type xmlKeyring struct {
+	XMLName  xml.Name `xml:"Keyring"`
+	Project  string   `xml:"Project,attr"`
+	Created  string   `xml:"Created,attr"`
+	Backbone *struct {
+		MulticastAddress string `xml:"MulticastAddress,attr"`
+		Key              string `xml:"Key,attr"`
+	} `xml:"Backbone"`
+	Groups []struct {
+		Address string `xml:"Address,attr"`
+		Key     string `xml:"Key,attr"`
+	} `xml:"GroupAddresses>Group"`
+	Devices []struct {
+		IndividualAddress string `xml:"IndividualAddress,attr"`
+		ToolKey           string `xml:"ToolKey,attr"`
+		SequenceNumber    string `xml:"SequenceNumber,attr"`
+		SerialNumber      string `xml:"SerialNumber,attr"`
+	} `xml:"Devices>Device"`
+}
+
+// pbkdf2 derives a key of the given length from the password as specified in RFC 8018, using
+// HMAC-SHA256 as pseudorandom function.
+func pbkdf2(password, salt []byte, iterations, length int) []byte {
+	mac := hmac.New(sha256.New, password)
+	key := make([]byte, 0, length+sha256.Size)
+
+	for i := 1; len(key) < length; i++ {
+		mac.Reset()
+		mac.Write(salt)
+		mac.Write([]byte{byte(i >> 24), byte(i >> 16), byte(i >> 8), byte(i)})
+		u := mac.Sum(nil)
+
+		block := append([]byte(nil), u...)
+		for n := 1; n < iterations; n++ {
+			mac.Reset()
+			mac.Write(u)
+			u = mac.Sum(u[:0])
+
+			for j := range block {
+				block[j] ^= u[j]
+			}
+		}
+
+		key = append(key, block...)
+	}
+
+	return key[:length]
+}
+
+// keyringCipher derives the cipher and IV which protect the keys of the keyring.
+func keyringCipher(password, created string) (cipher.Block, []byte, error) {
+	key := pbkdf2([]byte(password), []byte(keyringSalt), keyringIterations, 16)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	iv := sha256.Sum256([]byte(created))
+
+	return block, iv[:16], nil
+}
+
+// decryptKey decodes and decrypts a key of the keyring.
+func decryptKey(block cipher.Block, iv []byte, encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key) != 16 {
+		return nil, ErrKeyLength
+	}
+
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(key, key)
+
+	return key, nil
+}
+
+// ReadKeyring parses a keyring and decrypts its keys using the password that has been chosen when
+// it was exported.
+func ReadKeyring(r io.Reader, password string) (*Keyring, error) {
+	var doc xmlKeyring
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	block, iv, err := keyringCipher(password, doc.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	keyring := &Keyring{
+		Project: doc.Project,
+		Created: doc.Created,
+		Groups:  make(map[cemi.GroupAddr][]byte, len(doc.Groups)),
+		Devices: make(map[cemi.IndividualAddr]KeyringDevice, len(doc.Devices)),
+	}
+
+	if doc.Backbone != nil {
+		keyring.MulticastAddress = doc.Backbone.MulticastAddress
+
+		if doc.Backbone.Key != "" {
+			if keyring.BackboneKey, err = decryptKey(block, iv, doc.Backbone.Key); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, group := range doc.Groups {
+		addr, err := cemi.NewGroupAddrString(group.Address)
+		if err != nil {
+			return nil, err
+		}
+
+		if keyring.Groups[addr], err = decryptKey(block, iv, group.Key); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, device := range doc.Devices {
+		addr, err := cemi.NewIndividualAddrString(device.IndividualAddress)
+		if err != nil {
+			return nil, err
+		}
+
+		info := KeyringDevice{Address: addr, SerialNumber: device.SerialNumber}
+
+		if device.ToolKey != "" {
+			if info.ToolKey, err = decryptKey(block, iv, device.ToolKey); err != nil {
+				return nil, err
+			}
+		}
+
+		if device.SequenceNumber != "" {
+			if info.SequenceNumber, err = strconv.ParseUint(device.SequenceNumber, 10, 64); err != nil {
+				return nil, err
+			}
+		}
+
+		keyring.Devices[addr] = info
+	}
+
+	return keyring, nil
+}
+
+// LoadKeyring reads the keyring file at the given path.
+func LoadKeyring(path, password string) (*Keyring, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return ReadKeyring(file, password)
+}
+
+// GroupKey returns the key of the secure group address. Together with ToolKey, it makes the keyring
+// usable as a secure.KeyStore.
+func (keyring *Keyring) GroupKey(addr cemi.GroupAddr) ([]byte, bool) {
+	key, ok := keyring.Groups[addr]
+	return key, ok
+}
+
+// ToolKey returns the tool key of the device.
+func (keyring *Keyring) ToolKey(addr cemi.IndividualAddr) ([]byte, bool) {
+	device, ok := keyring.Devices[addr]
+	if !ok || device.ToolKey == nil {
+		return nil, false
+	}
+
+	return device.ToolKey, true
+}
@@ -0,0 +1,111 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package ets
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/secure"
+)
+
+var _ secure.KeyStore = (*Keyring)(nil)
+
+func TestPBKDF2(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iterations     int
+		expected       string
+	}{
+		{"password", "salt", 1,
+			"120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"password", "salt", 2,
+			"ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{"password", "salt", 4096,
+			"c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096,
+			"348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1c635518c7dac47e9"},
+		{"passwd", "salt", 1,
+			"55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+				"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+	}
+
+	for _, test := range tests {
+		expected, _ := hex.DecodeString(test.expected)
+
+		key := pbkdf2([]byte(test.password), []byte(test.salt), test.iterations, len(expected))
+		if !bytes.Equal(key, expected) {
+			t.Errorf("Expected %x, got %x", expected, key)
+		}
+	}
+}
+
+// testKeyring has been encrypted with the password "secret" independently of this package. The
+// group key is "0123456789abcdef", the tool key and the backbone key are "fedcba9876543210".
+const testKeyring = `<?xml version="1.0" encoding="utf-8"?>
+<Keyring Project="Test" CreatedBy="ETS" Created="2020-06-16T08:58:58" Signature="" xmlns="http://knx.org/xml/keyring/1">
+  <Backbone MulticastAddress="224.0.23.12" Latency="1000" Key="mFqoGRX2oevj0bFc0Eer8A==" />
+  <GroupAddresses>
+    <Group Address="2307" Key="JbMPQnW0MqxVJbkkUE/N6w==" />
+  </GroupAddresses>
+  <Devices>
+    <Device IndividualAddress="1.1.9" ToolKey="mFqoGRX2oevj0bFc0Eer8A==" SequenceNumber="42" SerialNumber="00FA01020304" />
+    <Device IndividualAddress="1.1.10" />
+  </Devices>
+</Keyring>`
+
+func TestReadKeyring(t *testing.T) {
+	groupKey := []byte("0123456789abcdef")
+	toolKey := []byte("fedcba9876543210")
+
+	keyring, err := ReadKeyring(strings.NewReader(testKeyring), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if keyring.Project != "Test" || keyring.MulticastAddress != "224.0.23.12" ||
+		!bytes.Equal(keyring.BackboneKey, toolKey) {
+		t.Errorf("Unexpected keyring: %+v", keyring)
+	}
+
+	if key, ok := keyring.GroupKey(cemi.NewGroupAddr3(1, 1, 3)); !ok || !bytes.Equal(key, groupKey) {
+		t.Errorf("Unexpected group key: %x", key)
+	}
+
+	key, ok := keyring.ToolKey(cemi.NewIndividualAddr3(1, 1, 9))
+	if !ok || !bytes.Equal(key, toolKey) {
+		t.Errorf("Unexpected tool key: %x", key)
+	}
+
+	if _, ok := keyring.ToolKey(cemi.NewIndividualAddr3(1, 1, 10)); ok {
+		t.Error("Device without tool key has a tool key")
+	}
+
+	if device := keyring.Devices[cemi.NewIndividualAddr3(1, 1, 9)]; device.SequenceNumber != 42 ||
+		device.SerialNumber != "00FA01020304" {
+		t.Errorf("Unexpected device: %+v", device)
+	}
+
+	t.Run("WrongPassword", func(t *testing.T) {
+		other, err := ReadKeyring(strings.NewReader(testKeyring), "wrong")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if key, _ := other.GroupKey(cemi.NewGroupAddr3(1, 1, 3)); bytes.Equal(key, groupKey) {
+			t.Error("Key has been decrypted with the wrong password")
+		}
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		doc := strings.Replace(testKeyring, `Address="2307" Key="`, `Address="2307" Key="AAAA`, 1)
+
+		if _, err := ReadKeyring(strings.NewReader(doc), "secret"); err == nil {
+			t.Fatal("Should not succeed")
+		}
+	})
+}
@@ -0,0 +1,558 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package ets
+
+import (
+	"archive/zip"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// These are errors that can occur when reading product data.
+var (
+	ErrNoApplication = errors.New("Product data does not contain an application program")
+	ErrMaskVersion   = errors.New("Invalid mask version")
+)
+
+// Segment is an absolute code segment of an application program.
+type Segment struct {
+	ID      string
+	Address uint16
+	Size    int
+
+	// Data is the initial content of the segment. It may be shorter than the segment.
+	Data []byte
+}
+
+// ParameterKind determines how the value of a parameter is encoded.
+type ParameterKind uint8
+
+// These are the supported kinds of parameter types.
+const (
+	// ParameterNone is a type without value, e.g. a separator in the parameter dialog.
+	ParameterNone ParameterKind = iota
+
+	// ParameterNumber is an integer, including enumerations.
+	ParameterNumber
+
+	// ParameterText is a string.
+	ParameterText
+
+	// ParameterOther is a type which is not interpreted, e.g. a floating point number or an IP
+	// address.
+	ParameterOther
+)
+
+// ParameterType describes the values of a parameter.
+type ParameterType struct {
+	ID        string
+	Name      string
+	Kind      ParameterKind
+	SizeInBit int
+
+	// Enumeration maps the texts of enumerated values to their values.
+	Enumeration map[string]int64
+}
+
+// MemoryLocation is the location of a value inside a code segment.
+type MemoryLocation struct {
+	Segment   string
+	Offset    int
+	BitOffset int
+}
+
+// Parameter is a parameter of an application program.
+type Parameter struct {
+	ID     string
+	Name   string
+	Text   string
+	TypeID string
+
+	// Value is the default value.
+	Value string
+
+	// Memory is the location of the parameter, or nil if it is not stored in memory.
+	Memory *MemoryLocation
+}
+
+// ComObject is a communication object, as the group objects are called in the ETS.
+type ComObject struct {
+	ID            string
+	Number        int
+	Name          string
+	Text          string
+	FunctionText  string
+	ObjectSize    string
+	DatapointType string
+
+	Communication bool
+	Read          bool
+	Write         bool
+	Transmit      bool
+	Update        bool
+	ReadOnInit    bool
+}
+
+// TableLocation is the location of a table inside a code segment.
+type TableLocation struct {
+	Segment    string
+	Offset     int
+	MaxEntries int
+}
+
+// LoadStep is a step of a load procedure, e.g. LdCtrlWriteProp. The attributes are kept as they
+// are, since their meaning depends on the step.
+type LoadStep struct {
+	Name  string
+	Attrs map[string]string
+}
+
+// Attr returns the attribute with the given name.
+func (step LoadStep) Attr(name string) string {
+	return step.Attrs[name]
+}
+
+// Int parses the attribute with the given name as an integer. Missing attributes are 0.
+func (step LoadStep) Int(name string) (int64, error) {
+	value, ok := step.Attrs[name]
+	if !ok || value == "" {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(value, 0, 64)
+}
+
+// Application is an application program from the product database.
+type Application struct {
+	ID          string
+	Name        string
+	Number      uint16
+	Version     uint8
+	MaskVersion uint16
+
+	Segments       []Segment
+	ParameterTypes map[string]*ParameterType
+	Parameters     map[string]*Parameter
+
+	// ParameterRefs maps the IDs of parameter references to the IDs of their parameters.
+	ParameterRefs map[string]string
+
+	ComObjects       []ComObject
+	AddressTable     *TableLocation
+	AssociationTable *TableLocation
+	ComObjectTable   *TableLocation
+	LoadProcedure    []LoadStep
+}
+
+// Segment finds the code segment with the given ID.
+func (app *Application) Segment(id string) (*Segment, bool) {
+	for i := range app.Segments {
+		if app.Segments[i].ID == id {
+			return &app.Segments[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// Parameter finds the parameter with the given ID or the ID of a reference to it.
+func (app *Application) Parameter(id string) (*Parameter, bool) {
+	if ref, ok := app.ParameterRefs[id]; ok {
+		id = ref
+	}
+
+	param, ok := app.Parameters[id]
+	return param, ok
+}
+
+// xmlMemory is the XML representation of a memory location.
+type xmlMemory struct {
+	CodeSegment string `xml:"CodeSegment,attr"`
+	Offset      int    `xml:"Offset,attr"`
+	BitOffset   int    `xml:"BitOffset,attr"`
+}
+
+// xmlParameter is the XML representation of a parameter.
+type xmlParameter struct {
+	ID            string     `xml:"Id,attr"`
+	Name          string     `xml:"Name,attr"`
+	Text          string     `xml:"Text,attr"`
+	ParameterType string     `xml:"ParameterType,attr"`
+	Value         string     `xml:"Value,attr"`
+	Offset        int        `xml:"Offset,attr"`
+	BitOffset     int        `xml:"BitOffset,attr"`
+	Memory        *xmlMemory `xml:"Memory"`
+}
+
+// xmlTable is the XML representation of a table location.
+type xmlTable struct {
+	CodeSegment string `xml:"CodeSegment,attr"`
+	Offset      int    `xml:"Offset,attr"`
+	MaxEntries  int    `xml:"MaxEntries,attr"`
+}
+
+// location converts the table location.
+func (table *xmlTable) location() *TableLocation {
+	if table == nil {
+		return nil
+	}
+
+	return &TableLocation{
+		Segment:    table.CodeSegment,
+		Offset:     table.Offset,
+		MaxEntries: table.MaxEntries,
+	}
+}
+
+// xmlComObjectTable is the XML representation of the communication object table.
+type xmlComObjectTable struct {
+	xmlTable
+
+	ComObjects []xmlComObject `xml:"ComObject"`
+}
+
+// xmlComObject is the XML representation of a communication object.
+type xmlComObject struct {
+	ID                string `xml:"Id,attr"`
+	Number            int    `xml:"Number,attr"`
+	Name              string `xml:"Name,attr"`
+	Text              string `xml:"Text,attr"`
+	FunctionText      string `xml:"FunctionText,attr"`
+	ObjectSize        string `xml:"ObjectSize,attr"`
+	DatapointType     string `xml:"DatapointType,attr"`
+	CommunicationFlag string `xml:"CommunicationFlag,attr"`
+	ReadFlag          string `xml:"ReadFlag,attr"`
+	WriteFlag         string `xml:"WriteFlag,attr"`
+	TransmitFlag      string `xml:"TransmitFlag,attr"`
+	UpdateFlag        string `xml:"UpdateFlag,attr"`
+	ReadOnInitFlag    string `xml:"ReadOnInitFlag,attr"`
+}
+
+// xmlLoadProcedure is the XML representation of a load procedure. The steps are collected token
+// by token, because their attributes differ from step to step.
+type xmlLoadProcedure struct {
+	Steps []LoadStep
+}
+
+// UnmarshalXML implements the xml.Unmarshaler interface.
+func (proc *xmlLoadProcedure) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	depth := 0
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch elem := token.(type) {
+		case xml.StartElement:
+			// Only the direct children of the load procedure are steps.
+			if depth == 0 {
+				attrs := make(map[string]string, len(elem.Attr))
+				for _, attr := range elem.Attr {
+					attrs[attr.Name.Local] = attr.Value
+				}
+
+				proc.Steps = append(proc.Steps, LoadStep{Name: elem.Name.Local, Attrs: attrs})
+			}
+
+			depth++
+
+		case xml.EndElement:
+			if depth == 0 {
+				return nil
+			}
+
+			depth--
+		}
+	}
+}
+
+// xmlApplication is the XML representation of an application program.
+type xmlApplication struct {
+	ID                 string `xml:"Id,attr"`
+	Name               string `xml:"Name,attr"`
+	ApplicationNumber  uint16 `xml:"ApplicationNumber,attr"`
+	ApplicationVersion uint8  `xml:"ApplicationVersion,attr"`
+	MaskVersion        string `xml:"MaskVersion,attr"`
+
+	Segments []struct {
+		ID      string `xml:"Id,attr"`
+		Address uint16 `xml:"Address,attr"`
+		Size    int    `xml:"Size,attr"`
+		Data    string `xml:"Data"`
+	} `xml:"Static>Code>AbsoluteSegment"`
+
+	ParameterTypes []struct {
+		ID         string `xml:"Id,attr"`
+		Name       string `xml:"Name,attr"`
+		TypeNumber *struct {
+			SizeInBit int `xml:"SizeInBit,attr"`
+		} `xml:"TypeNumber"`
+		TypeRestriction *struct {
+			SizeInBit    int `xml:"SizeInBit,attr"`
+			Enumerations []struct {
+				Text  string `xml:"Text,attr"`
+				Value int64  `xml:"Value,attr"`
+			} `xml:"Enumeration"`
+		} `xml:"TypeRestriction"`
+		TypeText *struct {
+			SizeInBit int `xml:"SizeInBit,attr"`
+		} `xml:"TypeText"`
+		TypeNone *struct{} `xml:"TypeNone"`
+	} `xml:"Static>ParameterTypes>ParameterType"`
+
+	Parameters []xmlParameter `xml:"Static>Parameters>Parameter"`
+	Unions     []struct {
+		Memory     *xmlMemory     `xml:"Memory"`
+		Parameters []xmlParameter `xml:"Parameter"`
+	} `xml:"Static>Parameters>Union"`
+
+	ParameterRefs []struct {
+		ID    string `xml:"Id,attr"`
+		RefID string `xml:"RefId,attr"`
+	} `xml:"Static>ParameterRefs>ParameterRef"`
+
+	AddressTable     *xmlTable          `xml:"Static>AddressTable"`
+	AssociationTable *xmlTable          `xml:"Static>AssociationTable"`
+	ComObjectTable   *xmlComObjectTable `xml:"Static>ComObjectTable"`
+
+	LoadProcedures []xmlLoadProcedure `xml:"Static>LoadProcedures>LoadProcedure"`
+}
+
+// xmlProduct is the XML representation of a product database file.
+type xmlProduct struct {
+	Applications []xmlApplication `xml:"ManufacturerData>Manufacturer>ApplicationPrograms>ApplicationProgram"`
+}
+
+// parseMaskVersion parses a mask version reference, e.g. "MV-07B0".
+func parseMaskVersion(ref string) (uint16, error) {
+	mask, err := strconv.ParseUint(strings.TrimPrefix(ref, "MV-"), 16, 16)
+	if err != nil {
+		return 0, ErrMaskVersion
+	}
+
+	return uint16(mask), nil
+}
+
+// convertParameter converts the XML parameter. The union's memory location, if any, is used as the
+// base of the parameter's location.
+func convertParameter(param *xmlParameter, union *xmlMemory) *Parameter {
+	converted := &Parameter{
+		ID:     param.ID,
+		Name:   param.Name,
+		Text:   param.Text,
+		TypeID: param.ParameterType,
+		Value:  param.Value,
+	}
+
+	if param.Memory != nil {
+		converted.Memory = &MemoryLocation{
+			Segment:   param.Memory.CodeSegment,
+			Offset:    param.Memory.Offset,
+			BitOffset: param.Memory.BitOffset,
+		}
+	} else if union != nil {
+		converted.Memory = &MemoryLocation{
+			Segment:   union.CodeSegment,
+			Offset:    union.Offset + param.Offset,
+			BitOffset: union.BitOffset + param.BitOffset,
+		}
+	}
+
+	return converted
+}
+
+// convert converts the XML representation of the application program.
+func (doc *xmlApplication) convert() (*Application, error) {
+	mask, err := parseMaskVersion(doc.MaskVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	app := &Application{
+		ID:               doc.ID,
+		Name:             doc.Name,
+		Number:           doc.ApplicationNumber,
+		Version:          doc.ApplicationVersion,
+		MaskVersion:      mask,
+		ParameterTypes:   make(map[string]*ParameterType, len(doc.ParameterTypes)),
+		Parameters:       make(map[string]*Parameter, len(doc.Parameters)),
+		ParameterRefs:    make(map[string]string, len(doc.ParameterRefs)),
+		AddressTable:     doc.AddressTable.location(),
+		AssociationTable: doc.AssociationTable.location(),
+	}
+
+	if doc.ComObjectTable != nil {
+		app.ComObjectTable = doc.ComObjectTable.location()
+	}
+
+	for _, seg := range doc.Segments {
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(seg.Data))
+		if err != nil {
+			return nil, err
+		}
+
+		app.Segments = append(app.Segments, Segment{
+			ID:      seg.ID,
+			Address: seg.Address,
+			Size:    seg.Size,
+			Data:    data,
+		})
+	}
+
+	for _, typ := range doc.ParameterTypes {
+		converted := &ParameterType{ID: typ.ID, Name: typ.Name, Kind: ParameterOther}
+
+		switch {
+		case typ.TypeNumber != nil:
+			converted.Kind = ParameterNumber
+			converted.SizeInBit = typ.TypeNumber.SizeInBit
+
+		case typ.TypeRestriction != nil:
+			converted.Kind = ParameterNumber
+			converted.SizeInBit = typ.TypeRestriction.SizeInBit
+			converted.Enumeration = make(map[string]int64)
+
+			for _, enum := range typ.TypeRestriction.Enumerations {
+				converted.Enumeration[enum.Text] = enum.Value
+			}
+
+		case typ.TypeText != nil:
+			converted.Kind = ParameterText
+			converted.SizeInBit = typ.TypeText.SizeInBit
+
+		case typ.TypeNone != nil:
+			converted.Kind = ParameterNone
+		}
+
+		app.ParameterTypes[typ.ID] = converted
+	}
+
+	for i := range doc.Parameters {
+		param := convertParameter(&doc.Parameters[i], nil)
+		app.Parameters[param.ID] = param
+	}
+
+	for _, union := range doc.Unions {
+		for i := range union.Parameters {
+			param := convertParameter(&union.Parameters[i], union.Memory)
+			app.Parameters[param.ID] = param
+		}
+	}
+
+	for _, ref := range doc.ParameterRefs {
+		app.ParameterRefs[ref.ID] = ref.RefID
+	}
+
+	var objects []xmlComObject
+	if doc.ComObjectTable != nil {
+		objects = doc.ComObjectTable.ComObjects
+	}
+
+	for _, obj := range objects {
+		app.ComObjects = append(app.ComObjects, ComObject{
+			ID:            obj.ID,
+			Number:        obj.Number,
+			Name:          obj.Name,
+			Text:          obj.Text,
+			FunctionText:  obj.FunctionText,
+			ObjectSize:    obj.ObjectSize,
+			DatapointType: obj.DatapointType,
+			Communication: obj.CommunicationFlag == "Enabled",
+			Read:          obj.ReadFlag == "Enabled",
+			Write:         obj.WriteFlag == "Enabled",
+			Transmit:      obj.TransmitFlag == "Enabled",
+			Update:        obj.UpdateFlag == "Enabled",
+			ReadOnInit:    obj.ReadOnInitFlag == "Enabled",
+		})
+	}
+
+	for _, proc := range doc.LoadProcedures {
+		app.LoadProcedure = append(app.LoadProcedure, proc.Steps...)
+	}
+
+	return app, nil
+}
+
+// ReadApplications parses an XML file of the product database and returns the application programs
+// it contains.
+func ReadApplications(r io.Reader) ([]*Application, error) {
+	var doc xmlProduct
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	apps := make([]*Application, 0, len(doc.Applications))
+	for i := range doc.Applications {
+		app, err := doc.Applications[i].convert()
+		if err != nil {
+			return nil, err
+		}
+
+		apps = append(apps, app)
+	}
+
+	return apps, nil
+}
+
+// readArchive reads the application programs of the archive.
+func readArchive(archive *zip.Reader) ([]*Application, error) {
+	var apps []*Application
+
+	for _, file := range archive.File {
+		// Application programs are stored as M-XXXX/M-XXXX_A-*.xml.
+		if !strings.Contains(path.Base(file.Name), "_A-") || path.Ext(file.Name) != ".xml" {
+			continue
+		}
+
+		content, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		fileApps, err := ReadApplications(content)
+		content.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		apps = append(apps, fileApps...)
+	}
+
+	if len(apps) == 0 {
+		return nil, ErrNoApplication
+	}
+
+	return apps, nil
+}
+
+// ReadProduct reads the application programs of a product database archive (.knxprod). Archives
+// which are protected with a password are not supported.
+func ReadProduct(r io.ReaderAt, size int64) ([]*Application, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return readArchive(archive)
+}
+
+// OpenProduct reads the application programs of the product database archive at the given path.
+func OpenProduct(name string) ([]*Application, error) {
+	archive, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer archive.Close()
+
+	return readArchive(&archive.Reader)
+}
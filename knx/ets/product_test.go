@@ -0,0 +1,216 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package ets
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testApplication = `<?xml version="1.0" encoding="utf-8"?>
+<KNX xmlns="http://knx.org/xml/project/20">
+  <ManufacturerData>
+    <Manufacturer RefId="M-00FA">
+      <ApplicationPrograms>
+        <ApplicationProgram Id="M-00FA_A-0001-10-0000" Name="Switch" ApplicationNumber="1"
+            ApplicationVersion="16" MaskVersion="MV-07B0">
+          <Static>
+            <Code>
+              <AbsoluteSegment Id="M-00FA_A-0001-10-0000_AS-4000" Address="16384" Size="64">
+                <Data>AQID</Data>
+              </AbsoluteSegment>
+            </Code>
+            <ParameterTypes>
+              <ParameterType Id="M-00FA_A-0001-10-0000_PT-Delay" Name="Delay">
+                <TypeNumber SizeInBit="16" Type="unsignedInt" minInclusive="0" maxInclusive="600" />
+              </ParameterType>
+              <ParameterType Id="M-00FA_A-0001-10-0000_PT-Mode" Name="Mode">
+                <TypeRestriction Base="Value" SizeInBit="2">
+                  <Enumeration Text="Off" Value="0" Id="M-00FA_A-0001-10-0000_PT-Mode_EN-0" />
+                  <Enumeration Text="On" Value="1" Id="M-00FA_A-0001-10-0000_PT-Mode_EN-1" />
+                </TypeRestriction>
+              </ParameterType>
+              <ParameterType Id="M-00FA_A-0001-10-0000_PT-Name" Name="Name">
+                <TypeText SizeInBit="64" />
+              </ParameterType>
+              <ParameterType Id="M-00FA_A-0001-10-0000_PT-Separator" Name="Separator">
+                <TypeNone />
+              </ParameterType>
+            </ParameterTypes>
+            <Parameters>
+              <Parameter Id="M-00FA_A-0001-10-0000_P-1" Name="Delay" Text="Delay in seconds"
+                  ParameterType="M-00FA_A-0001-10-0000_PT-Delay" Value="30">
+                <Memory CodeSegment="M-00FA_A-0001-10-0000_AS-4000" Offset="4" BitOffset="0" />
+              </Parameter>
+              <Union SizeInBit="8">
+                <Memory CodeSegment="M-00FA_A-0001-10-0000_AS-4000" Offset="6" BitOffset="0" />
+                <Parameter Id="M-00FA_A-0001-10-0000_P-2" Name="Mode" Text="Mode"
+                    ParameterType="M-00FA_A-0001-10-0000_PT-Mode" Offset="0" BitOffset="2"
+                    Value="1" />
+              </Union>
+              <Parameter Id="M-00FA_A-0001-10-0000_P-3" Name="Heading" Text="General"
+                  ParameterType="M-00FA_A-0001-10-0000_PT-Separator" Value="" />
+            </Parameters>
+            <ParameterRefs>
+              <ParameterRef Id="M-00FA_A-0001-10-0000_P-1_R-1" RefId="M-00FA_A-0001-10-0000_P-1" />
+            </ParameterRefs>
+            <ComObjectTable CodeSegment="M-00FA_A-0001-10-0000_AS-4000" Offset="32">
+              <ComObject Id="M-00FA_A-0001-10-0000_O-1" Name="Switch" Text="Switch"
+                  FunctionText="On/Off" Number="1" ObjectSize="1 Bit" DatapointType="DPST-1-1"
+                  CommunicationFlag="Enabled" ReadFlag="Disabled" WriteFlag="Enabled"
+                  TransmitFlag="Disabled" UpdateFlag="Disabled" ReadOnInitFlag="Disabled" />
+            </ComObjectTable>
+            <AddressTable CodeSegment="M-00FA_A-0001-10-0000_AS-4000" Offset="8" MaxEntries="4" />
+            <AssociationTable CodeSegment="M-00FA_A-0001-10-0000_AS-4000" Offset="18"
+                MaxEntries="3" />
+            <LoadProcedures>
+              <LoadProcedure MergeId="2">
+                <LdCtrlUnload LsmIdx="1" />
+                <LdCtrlWriteProp ObjIdx="0" PropId="13" InlineData="00FA" />
+                <LdCtrlDelay MilliSeconds="500" />
+              </LoadProcedure>
+            </LoadProcedures>
+          </Static>
+        </ApplicationProgram>
+      </ApplicationPrograms>
+    </Manufacturer>
+  </ManufacturerData>
+</KNX>`
+
+func checkApplication(t *testing.T, apps []*Application) {
+	if len(apps) != 1 {
+		t.Fatalf("Expected 1 application, got %d", len(apps))
+	}
+
+	app := apps[0]
+	if app.Name != "Switch" || app.Number != 1 || app.Version != 16 || app.MaskVersion != 0x07B0 {
+		t.Errorf("Unexpected application: %+v", app)
+	}
+
+	seg, ok := app.Segment("M-00FA_A-0001-10-0000_AS-4000")
+	if !ok || seg.Address != 0x4000 || seg.Size != 64 || !bytes.Equal(seg.Data, []byte{1, 2, 3}) {
+		t.Errorf("Unexpected segment: %+v", seg)
+	}
+
+	delay, ok := app.Parameter("M-00FA_A-0001-10-0000_P-1_R-1")
+	if !ok || delay.Value != "30" || delay.Memory == nil || delay.Memory.Offset != 4 {
+		t.Errorf("Unexpected parameter: %+v", delay)
+	}
+
+	mode, ok := app.Parameter("M-00FA_A-0001-10-0000_P-2")
+	if !ok || mode.Memory == nil || mode.Memory.Offset != 6 || mode.Memory.BitOffset != 2 {
+		t.Errorf("Unexpected union parameter: %+v", mode)
+	}
+
+	if heading, ok := app.Parameter("M-00FA_A-0001-10-0000_P-3"); !ok || heading.Memory != nil {
+		t.Errorf("Unexpected parameter: %+v", heading)
+	}
+
+	modeType := app.ParameterTypes[mode.TypeID]
+	if modeType.Kind != ParameterNumber || modeType.SizeInBit != 2 ||
+		modeType.Enumeration["On"] != 1 {
+		t.Errorf("Unexpected parameter type: %+v", modeType)
+	}
+
+	if typ := app.ParameterTypes["M-00FA_A-0001-10-0000_PT-Name"]; typ.Kind != ParameterText {
+		t.Errorf("Unexpected parameter type: %+v", typ)
+	}
+
+	expectedObjects := []ComObject{{
+		ID:            "M-00FA_A-0001-10-0000_O-1",
+		Number:        1,
+		Name:          "Switch",
+		Text:          "Switch",
+		FunctionText:  "On/Off",
+		ObjectSize:    "1 Bit",
+		DatapointType: "DPST-1-1",
+		Communication: true,
+		Write:         true,
+	}}
+	if !reflect.DeepEqual(app.ComObjects, expectedObjects) {
+		t.Errorf("Unexpected communication objects: %+v", app.ComObjects)
+	}
+
+	if app.AddressTable == nil || app.AddressTable.Offset != 8 || app.AddressTable.MaxEntries != 4 {
+		t.Errorf("Unexpected address table: %+v", app.AddressTable)
+	}
+
+	if app.ComObjectTable == nil || app.ComObjectTable.Offset != 32 {
+		t.Errorf("Unexpected communication object table: %+v", app.ComObjectTable)
+	}
+
+	if len(app.LoadProcedure) != 3 {
+		t.Fatalf("Expected 3 load procedure steps, got %d", len(app.LoadProcedure))
+	}
+
+	step := app.LoadProcedure[1]
+	if step.Name != "LdCtrlWriteProp" || step.Attr("InlineData") != "00FA" {
+		t.Errorf("Unexpected step: %+v", step)
+	}
+
+	if value, err := app.LoadProcedure[2].Int("MilliSeconds"); err != nil || value != 500 {
+		t.Errorf("Unexpected delay: %d (%v)", value, err)
+	}
+}
+
+func TestReadApplications(t *testing.T) {
+	apps, err := ReadApplications(strings.NewReader(testApplication))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkApplication(t, apps)
+
+	invalid := strings.Replace(testApplication, "MV-07B0", "MV-XYZ", 1)
+	if _, err := ReadApplications(strings.NewReader(invalid)); err != ErrMaskVersion {
+		t.Fatalf("Expected %v, got %v", ErrMaskVersion, err)
+	}
+}
+
+func TestReadProduct(t *testing.T) {
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+
+	for name, content := range map[string]string{
+		"knx_master.xml":                      "<KNX />",
+		"M-00FA/Catalog.xml":                  "<KNX />",
+		"M-00FA/M-00FA_A-0001-10-0000.xml":    testApplication,
+		"M-00FA/M-00FA_A-0001-10-0000.mtxml":  "<KNX />",
+		"M-00FA/M-00FA_H-0001-1_HP-0001.xml":  "<KNX />",
+		"M-00FA/Baggages/M-00FA_A-0001-1.png": "",
+	} {
+		file, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file.Write([]byte(content))
+	}
+
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	apps, err := ReadProduct(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkApplication(t, apps)
+
+	t.Run("Empty", func(t *testing.T) {
+		var buffer bytes.Buffer
+		if err := zip.NewWriter(&buffer).Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := ReadProduct(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+		if err != ErrNoApplication {
+			t.Fatalf("Expected %v, got %v", ErrNoApplication, err)
+		}
+	})
+}
@@ -0,0 +1,428 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package ets
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// These are errors that can occur when reading projects.
+var (
+	ErrNoProject        = errors.New("Archive does not contain a project")
+	ErrProtectedProject = errors.New("Projects which are protected with a password are not supported")
+	ErrCSVHeader        = errors.New("CSV file lacks the address column")
+)
+
+// GroupAddress is a group address of a project.
+type GroupAddress struct {
+	Address cemi.GroupAddr
+	Name    string
+
+	// DatapointType references the datapoint type, e.g. "DPST-1-1" or "DPT-9". It is empty if
+	// none has been assigned.
+	DatapointType string
+}
+
+// DatapointMain returns the main number of the datapoint type, e.g. 1 for "DPST-1-1". The second
+// result is false if no datapoint type has been assigned.
+func (ga GroupAddress) DatapointMain() (int, bool) {
+	// Multiple types are separated by spaces. They share the main number.
+	fields := strings.Fields(ga.DatapointType)
+	if len(fields) == 0 {
+		return 0, false
+	}
+
+	parts := strings.Split(fields[0], "-")
+	if len(parts) < 2 || (parts[0] != "DPT" && parts[0] != "DPST") {
+		return 0, false
+	}
+
+	main, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, false
+	}
+
+	return main, true
+}
+
+// ProjectDevice is a device of the project's topology.
+type ProjectDevice struct {
+	Address cemi.IndividualAddr
+	Name    string
+}
+
+// Project contains the group addresses and the topology of an ETS project. Exports of the group
+// addresses alone result in projects without devices.
+type Project struct {
+	Name           string
+	GroupAddresses []GroupAddress
+	Devices        []ProjectDevice
+}
+
+// GroupAddress finds the group address in the project.
+func (project *Project) GroupAddress(addr cemi.GroupAddr) (GroupAddress, bool) {
+	i := sort.Search(len(project.GroupAddresses), func(i int) bool {
+		return project.GroupAddresses[i].Address >= addr
+	})
+
+	if i < len(project.GroupAddresses) && project.GroupAddresses[i].Address == addr {
+		return project.GroupAddresses[i], true
+	}
+
+	return GroupAddress{}, false
+}
+
+// Device finds the device with the individual address in the project.
+func (project *Project) Device(addr cemi.IndividualAddr) (ProjectDevice, bool) {
+	i := sort.Search(len(project.Devices), func(i int) bool {
+		return project.Devices[i].Address >= addr
+	})
+
+	if i < len(project.Devices) && project.Devices[i].Address == addr {
+		return project.Devices[i], true
+	}
+
+	return ProjectDevice{}, false
+}
+
+// sort orders the group addresses and devices, which the lookups rely on.
+func (project *Project) sort() {
+	sort.Stable(groupAddressSlice(project.GroupAddresses))
+	sort.Stable(deviceSlice(project.Devices))
+}
+
+// groupAddressSlice sorts group addresses of a project by address.
+type groupAddressSlice []GroupAddress
+
+func (addrs groupAddressSlice) Len() int           { return len(addrs) }
+func (addrs groupAddressSlice) Less(i, j int) bool { return addrs[i].Address < addrs[j].Address }
+func (addrs groupAddressSlice) Swap(i, j int)      { addrs[i], addrs[j] = addrs[j], addrs[i] }
+
+// deviceSlice sorts devices of a project by individual address.
+type deviceSlice []ProjectDevice
+
+func (devices deviceSlice) Len() int           { return len(devices) }
+func (devices deviceSlice) Less(i, j int) bool { return devices[i].Address < devices[j].Address }
+func (devices deviceSlice) Swap(i, j int)      { devices[i], devices[j] = devices[j], devices[i] }
+
+// parseGroupAddr parses a group address in any of the notations used by the ETS. Unlike
+// cemi.NewGroupAddrString, it rejects incomplete addresses such as "1/-/-", which denote ranges.
+func parseGroupAddr(addr string) (cemi.GroupAddr, bool) {
+	addr = strings.TrimSpace(addr)
+	if addr == "" || strings.Contains(addr, "-") {
+		return 0, false
+	}
+
+	parsed, err := cemi.NewGroupAddrString(addr)
+	return parsed, err == nil
+}
+
+// attr finds the value of an XML attribute.
+func attr(elem xml.StartElement, name string) (string, bool) {
+	for _, attr := range elem.Attr {
+		if attr.Name.Local == name {
+			return attr.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// readProjectXML adds the contents of the XML file to the project. The file may be the project
+// data of a project archive or an XML export of the group addresses.
+func readProjectXML(r io.Reader, project *Project) error {
+	decoder := xml.NewDecoder(r)
+
+	var area, line uint64
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		elem, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch elem.Name.Local {
+		case "ProjectInformation":
+			project.Name, _ = attr(elem, "Name")
+
+		case "Area":
+			value, _ := attr(elem, "Address")
+			area, _ = strconv.ParseUint(value, 10, 4)
+
+		case "Line":
+			value, _ := attr(elem, "Address")
+			line, _ = strconv.ParseUint(value, 10, 4)
+
+		case "DeviceInstance":
+			// Devices without address have not been assigned to a line yet.
+			value, ok := attr(elem, "Address")
+			if !ok {
+				continue
+			}
+
+			device, err := strconv.ParseUint(value, 10, 8)
+			if err != nil {
+				return err
+			}
+
+			name, _ := attr(elem, "Name")
+			project.Devices = append(project.Devices, ProjectDevice{
+				Address: cemi.NewIndividualAddr3(uint8(area), uint8(line), uint8(device)),
+				Name:    name,
+			})
+
+		case "GroupAddress":
+			// Project data stores the address as a number, exports use the 3-level notation.
+			value, _ := attr(elem, "Address")
+			addr, ok := parseGroupAddr(value)
+			if !ok {
+				return errors.New("Invalid group address " + strconv.Quote(value))
+			}
+
+			dpt, ok := attr(elem, "DatapointType")
+			if !ok {
+				dpt, _ = attr(elem, "DPTs")
+			}
+
+			name, _ := attr(elem, "Name")
+			project.GroupAddresses = append(project.GroupAddresses, GroupAddress{
+				Address:       addr,
+				Name:          name,
+				DatapointType: dpt,
+			})
+		}
+	}
+}
+
+// ReadProjectXML reads the project data of an unpacked project archive (0.xml) or an XML export of
+// the group addresses.
+func ReadProjectXML(r io.Reader) (*Project, error) {
+	project := &Project{}
+	if err := readProjectXML(r, project); err != nil {
+		return nil, err
+	}
+
+	project.sort()
+
+	return project, nil
+}
+
+// ReadGroupAddressCSV reads a CSV export of the group addresses. The columns are identified by the
+// header line, of which only "Address" is required. Commas, semicolons and tabs are accepted as
+// separators.
+func ReadGroupAddressCSV(r io.Reader) (*Project, error) {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Some versions of the ETS prepend a byte order mark.
+	content = bytes.TrimPrefix(content, []byte("\xEF\xBB\xBF"))
+
+	header := content
+	if end := bytes.IndexByte(content, '\n'); end >= 0 {
+		header = content[:end]
+	}
+
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	switch {
+	case bytes.IndexByte(header, '\t') >= 0:
+		reader.Comma = '\t'
+
+	case bytes.IndexByte(header, ';') >= 0:
+		reader.Comma = ';'
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{"Address": -1, "Group name": -1, "DatapointType": -1}
+	if len(records) > 0 {
+		for i, name := range records[0] {
+			if _, ok := columns[strings.TrimSpace(name)]; ok {
+				columns[strings.TrimSpace(name)] = i
+			}
+		}
+	}
+
+	if columns["Address"] < 0 {
+		return nil, ErrCSVHeader
+	}
+
+	field := func(record []string, column string) string {
+		if i := columns[column]; i >= 0 && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+
+		return ""
+	}
+
+	project := &Project{}
+
+	for _, record := range records[1:] {
+		// Main and middle groups appear with incomplete addresses.
+		addr, ok := parseGroupAddr(field(record, "Address"))
+		if !ok {
+			continue
+		}
+
+		project.GroupAddresses = append(project.GroupAddresses, GroupAddress{
+			Address:       addr,
+			Name:          field(record, "Group name"),
+			DatapointType: field(record, "DatapointType"),
+		})
+	}
+
+	project.sort()
+
+	return project, nil
+}
+
+// readProjectArchive reads the project data of the archive.
+func readProjectArchive(archive *zip.Reader) (*Project, error) {
+	project := &Project{}
+	found := false
+
+	for _, file := range archive.File {
+		dir, base := path.Dir(file.Name), path.Base(file.Name)
+
+		switch {
+		// Project data is stored as P-XXXX/0.xml, its name and other details as
+		// P-XXXX/project.xml.
+		case strings.HasPrefix(dir, "P-") && (base == "0.xml" || base == "project.xml"):
+			content, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+
+			err = readProjectXML(content, project)
+			content.Close()
+
+			if err != nil {
+				return nil, err
+			}
+
+			found = found || base == "0.xml"
+
+		// Protected projects are stored as P-XXXX.zip, whose entries are encrypted.
+		case strings.HasPrefix(base, "P-") && path.Ext(base) == ".zip":
+			nested, err := readNestedProject(file)
+			if err == zip.ErrAlgorithm {
+				return nil, ErrProtectedProject
+			} else if err != nil {
+				return nil, err
+			}
+
+			project.GroupAddresses = append(project.GroupAddresses, nested.GroupAddresses...)
+			project.Devices = append(project.Devices, nested.Devices...)
+			if nested.Name != "" {
+				project.Name = nested.Name
+			}
+
+			found = true
+		}
+	}
+
+	if !found {
+		return nil, ErrNoProject
+	}
+
+	project.sort()
+
+	return project, nil
+}
+
+// readNestedProject reads the project from an archive inside the project archive.
+func readNestedProject(file *zip.File) (*Project, error) {
+	content, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(content)
+	content.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	return readProjectArchive(archive)
+}
+
+// ReadProject reads a project archive (.knxproj). Archives which are protected with a password are
+// not supported.
+func ReadProject(r io.ReaderAt, size int64) (*Project, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return readProjectArchive(archive)
+}
+
+// LoadProject reads the project archive, XML or CSV export at the given path. The format is
+// determined by the file extension.
+func LoadProject(name string) (*Project, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".knxproj":
+		archive, err := zip.OpenReader(name)
+		if err != nil {
+			return nil, err
+		}
+
+		defer archive.Close()
+
+		return readProjectArchive(&archive.Reader)
+
+	case ".csv":
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+
+		defer file.Close()
+
+		return ReadGroupAddressCSV(file)
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return ReadProjectXML(file)
+}
@@ -0,0 +1,219 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package ets
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+const testProject = `<?xml version="1.0" encoding="utf-8"?>
+<KNX xmlns="http://knx.org/xml/project/20">
+  <Project Id="P-0123">
+    <Installations>
+      <Installation Name="" InstallationId="0">
+        <Topology>
+          <Area Id="P-0123-0_A-1" Name="Building" Address="1">
+            <Line Id="P-0123-0_L-1" Name="Ground floor" Address="1">
+              <DeviceInstance Id="P-0123-0_DI-1" Name="Switch actuator" Address="5" />
+              <DeviceInstance Id="P-0123-0_DI-2" Name="Push button" Address="6" />
+            </Line>
+            <Line Id="P-0123-0_L-2" Name="First floor" Address="2">
+              <Segment Id="P-0123-0_S-1">
+                <DeviceInstance Id="P-0123-0_DI-3" Name="Thermostat" Address="1" />
+              </Segment>
+            </Line>
+          </Area>
+          <UnassignedDevices>
+            <DeviceInstance Id="P-0123-0_DI-4" Name="Spare" />
+          </UnassignedDevices>
+        </Topology>
+        <GroupAddresses>
+          <GroupRanges>
+            <GroupRange Id="P-0123-0_GR-1" RangeStart="2048" RangeEnd="4095" Name="Lighting">
+              <GroupRange Id="P-0123-0_GR-2" RangeStart="2304" RangeEnd="2559" Name="Kitchen">
+                <GroupAddress Id="P-0123-0_GA-2" Address="2305" Name="Brightness"
+                    DatapointType="DPST-5-1" />
+                <GroupAddress Id="P-0123-0_GA-1" Address="2304" Name="Switch"
+                    DatapointType="DPST-1-1" />
+              </GroupRange>
+            </GroupRange>
+          </GroupRanges>
+        </GroupAddresses>
+      </Installation>
+    </Installations>
+  </Project>
+</KNX>`
+
+const testExport = `<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<GroupAddress-Export xmlns="http://knx.org/xml/ga-export/01">
+  <GroupRange Name="Lighting" RangeStart="2048" RangeEnd="4095">
+    <GroupRange Name="Kitchen" RangeStart="2304" RangeEnd="2559">
+      <GroupAddress Name="Switch" Address="1/1/0" DPTs="DPST-1-1" />
+      <GroupAddress Name="Brightness" Address="1/1/1" DPTs="DPST-5-1" />
+    </GroupRange>
+  </GroupRange>
+</GroupAddress-Export>`
+
+const testCSV = "\"Group name\";\"Address\";\"Central\";\"Unfiltered\";\"Description\";" +
+	"\"DatapointType\";\"Security\"\n" +
+	"\"Lighting\";\"1/-/-\";\"\";\"\";\"\";\"\";\"Auto\"\n" +
+	"\"Kitchen\";\"1/1/-\";\"\";\"\";\"\";\"\";\"Auto\"\n" +
+	"\"Switch\";\"1/1/0\";\"\";\"\";\"\";\"DPST-1-1\";\"Auto\"\n" +
+	"\"Brightness\";\"1/1/1\";\"\";\"\";\"\";\"DPST-5-1\";\"Auto\"\n"
+
+var testGroupAddresses = []GroupAddress{
+	{Address: cemi.NewGroupAddr3(1, 1, 0), Name: "Switch", DatapointType: "DPST-1-1"},
+	{Address: cemi.NewGroupAddr3(1, 1, 1), Name: "Brightness", DatapointType: "DPST-5-1"},
+}
+
+func checkProject(t *testing.T, project *Project, devices bool) {
+	if !reflect.DeepEqual(project.GroupAddresses, testGroupAddresses) {
+		t.Fatalf("Expected %+v, got %+v", testGroupAddresses, project.GroupAddresses)
+	}
+
+	if main, ok := project.GroupAddresses[1].DatapointMain(); !ok || main != 5 {
+		t.Fatalf("Expected 5, got %v", main)
+	}
+
+	if _, ok := project.GroupAddress(cemi.NewGroupAddr3(1, 1, 1)); !ok {
+		t.Fatal("Expected group address to be found")
+	}
+
+	if _, ok := project.GroupAddress(cemi.NewGroupAddr3(1, 1, 2)); ok {
+		t.Fatal("Expected group address not to be found")
+	}
+
+	if !devices {
+		return
+	}
+
+	expected := []ProjectDevice{
+		{Address: cemi.NewIndividualAddr3(1, 1, 5), Name: "Switch actuator"},
+		{Address: cemi.NewIndividualAddr3(1, 1, 6), Name: "Push button"},
+		{Address: cemi.NewIndividualAddr3(1, 2, 1), Name: "Thermostat"},
+	}
+
+	if !reflect.DeepEqual(project.Devices, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, project.Devices)
+	}
+
+	if dev, ok := project.Device(cemi.NewIndividualAddr3(1, 2, 1)); !ok || dev.Name != "Thermostat" {
+		t.Fatalf("Unexpected device %+v", dev)
+	}
+}
+
+func TestReadProjectXML(t *testing.T) {
+	t.Run("Project", func(t *testing.T) {
+		project, err := ReadProjectXML(strings.NewReader(testProject))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkProject(t, project, true)
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		project, err := ReadProjectXML(strings.NewReader(testExport))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkProject(t, project, false)
+	})
+}
+
+func TestReadGroupAddressCSV(t *testing.T) {
+	project, err := ReadGroupAddressCSV(strings.NewReader("\xEF\xBB\xBF" + testCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkProject(t, project, false)
+
+	t.Run("Comma", func(t *testing.T) {
+		project, err := ReadGroupAddressCSV(strings.NewReader(strings.Replace(testCSV, ";", ",", -1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkProject(t, project, false)
+	})
+
+	t.Run("Header", func(t *testing.T) {
+		_, err := ReadGroupAddressCSV(strings.NewReader("Name;Value\nSwitch;1\n"))
+		if err != ErrCSVHeader {
+			t.Fatalf("Expected %v, got %v", ErrCSVHeader, err)
+		}
+	})
+}
+
+func makeArchive(t *testing.T, files map[string]string) []byte {
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+
+	for name, content := range files {
+		file, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file.Write([]byte(content))
+	}
+
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+func TestReadProject(t *testing.T) {
+	data := makeArchive(t, map[string]string{
+		"knx_master.xml":     "<KNX />",
+		"P-0123/project.xml": `<KNX><Project><ProjectInformation Name="Home" /></Project></KNX>`,
+		"P-0123/0.xml":       testProject,
+	})
+
+	project, err := ReadProject(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkProject(t, project, true)
+
+	if project.Name != "Home" {
+		t.Fatalf("Expected Home, got %v", project.Name)
+	}
+
+	t.Run("Nested", func(t *testing.T) {
+		nested := makeArchive(t, map[string]string{"P-0123/0.xml": testProject})
+		data := makeArchive(t, map[string]string{
+			"knx_master.xml":   "<KNX />",
+			"P-0123.signature": "",
+			"P-0123.zip":       string(nested),
+		})
+
+		project, err := ReadProject(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkProject(t, project, true)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		data := makeArchive(t, map[string]string{"knx_master.xml": "<KNX />"})
+
+		_, err := ReadProject(bytes.NewReader(data), int64(len(data)))
+		if err != ErrNoProject {
+			t.Fatalf("Expected %v, got %v", ErrNoProject, err)
+		}
+	})
+}
@@ -0,0 +1,357 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// A GroupConn is a GroupClient that owns a connection which must be closed eventually. Its inbound
+// channel is closed when the connection is lost.
+type GroupConn interface {
+	GroupClient
+	Close()
+}
+
+// A GroupDialer establishes a connection for group communication.
+type GroupDialer func() (GroupConn, error)
+
+// TunnelDialer creates a GroupDialer that connects to a KNXnet/IP gateway using a GroupTunnel.
+func TunnelDialer(gatewayAddr string, config TunnelConfig) GroupDialer {
+	return func() (GroupConn, error) {
+		tunnel, err := NewGroupTunnel(gatewayAddr, config)
+		if err != nil {
+			return nil, err
+		}
+
+		return &tunnel, nil
+	}
+}
+
+// RouterDialer creates a GroupDialer that joins a KNXnet/IP routing multicast group using a
+// GroupRouter.
+func RouterDialer(multicastAddress string, config RouterConfig) GroupDialer {
+	return func() (GroupConn, error) {
+		router, err := NewGroupRouter(multicastAddress, config)
+		if err != nil {
+			return nil, err
+		}
+
+		return &router, nil
+	}
+}
+
+// FailoverConfig allows you to configure the failover client's behavior.
+type FailoverConfig struct {
+	// RetryInterval is the time to wait before trying all gateways again, after none of them could
+	// be connected.
+	RetryInterval time.Duration
+
+	// FailbackInterval specifies how often the more preferred gateways are probed while the client
+	// is connected to a less preferred one.
+	FailbackInterval time.Duration
+
+	// Clock is used for all intervals. Tests may use a util.FakeClock in order to control the
+	// passage of time.
+	Clock util.Clock
+}
+
+// DefaultFailoverConfig is a good default configuration for a GroupFailover client.
+var DefaultFailoverConfig = FailoverConfig{
+	RetryInterval:    5 * time.Second,
+	FailbackInterval: time.Minute,
+	Clock:            util.RealClock,
+}
+
+// checkFailoverConfig makes sure that the configuration is actually usable.
+func checkFailoverConfig(config FailoverConfig) FailoverConfig {
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = DefaultFailoverConfig.RetryInterval
+	}
+
+	if config.FailbackInterval <= 0 {
+		config.FailbackInterval = DefaultFailoverConfig.FailbackInterval
+	}
+
+	if config.Clock == nil {
+		config.Clock = DefaultFailoverConfig.Clock
+	}
+
+	return config
+}
+
+// These are errors that can occur when using a GroupFailover.
+var (
+	ErrNoDialers    = errors.New("No dialers have been given")
+	ErrNotConnected = errors.New("No connection is available")
+)
+
+// A GroupFailover is a group client for installations that are reachable through several gateways
+// or routers. It keeps one connection at a time, using the first dialer that succeeds. When the
+// connection is lost, e.g. because of a failed heartbeat, the next dialers are tried in order.
+// While a less preferred connection is in use, the more preferred dialers are probed periodically
+// in order to fail back. Probing happens in the background, so it does not hold up inbound events.
+//
+// The inbound channel persists across connection changes. Events that arrive while switching
+// between connections may be lost.
+type GroupFailover struct {
+	dialers []GroupDialer
+	config  FailoverConfig
+
+	mu     sync.Mutex
+	active GroupConn
+	index  int
+
+	inbound   chan GroupEvent
+	failbacks chan failback
+	done      chan struct{}
+	once      sync.Once
+	wait      sync.WaitGroup
+}
+
+// failback is a connection to a preferred gateway that has been established by a probe.
+type failback struct {
+	conn  GroupConn
+	index int
+}
+
+// NewGroupFailover connects using the first of the given dialers that succeeds. The dialers are
+// ordered by preference. If none of them succeeds, the error of the last dialer is returned.
+func NewGroupFailover(dialers []GroupDialer, config FailoverConfig) (*GroupFailover, error) {
+	if len(dialers) == 0 {
+		return nil, ErrNoDialers
+	}
+
+	failover := &GroupFailover{
+		dialers:   dialers,
+		config:    checkFailoverConfig(config),
+		index:     -1,
+		inbound:   make(chan GroupEvent),
+		failbacks: make(chan failback),
+		done:      make(chan struct{}),
+	}
+
+	if err := failover.dial(failover.order(0, len(dialers))); err != nil {
+		return nil, err
+	}
+
+	failover.wait.Add(1)
+	go failover.serve()
+
+	return failover, nil
+}
+
+// order lists count dialer indices, starting at the given index and wrapping around.
+func (failover *GroupFailover) order(start, count int) []int {
+	indices := make([]int, count)
+	for i := range indices {
+		indices[i] = (start + i) % len(failover.dialers)
+	}
+
+	return indices
+}
+
+// current returns the active connection and the index of its dialer.
+func (failover *GroupFailover) current() (GroupConn, int) {
+	failover.mu.Lock()
+	defer failover.mu.Unlock()
+
+	return failover.active, failover.index
+}
+
+// replace makes the given connection the active one and closes the previous one.
+func (failover *GroupFailover) replace(conn GroupConn, index int) {
+	failover.mu.Lock()
+	previous := failover.active
+	failover.active = conn
+	failover.index = index
+	failover.mu.Unlock()
+
+	if previous != nil {
+		discard(previous)
+	}
+}
+
+// discard closes the connection.
+func discard(conn GroupConn) {
+	conn.Close()
+
+	// Make sure that nothing blocks while trying to deliver to the abandoned inbound channel.
+	go func() {
+		for range conn.Inbound() {
+		}
+	}()
+}
+
+// connect tries the dialers with the given indices in order and returns the first connection that
+// can be established, along with the index of its dialer.
+func (failover *GroupFailover) connect(indices []int) (conn GroupConn, index int, err error) {
+	for _, index = range indices {
+		conn, err = failover.dialers[index]()
+		if err == nil {
+			util.Log(failover, "Connected using dialer %d", index)
+			return conn, index, nil
+		}
+
+		util.Log(failover, "Dialer %d failed: %v", index, err)
+	}
+
+	return nil, -1, err
+}
+
+// dial tries the dialers with the given indices in order. The first connection that can be
+// established replaces the active connection.
+func (failover *GroupFailover) dial(indices []int) error {
+	conn, index, err := failover.connect(indices)
+	if err != nil {
+		return err
+	}
+
+	failover.replace(conn, index)
+	return nil
+}
+
+// probe tries the dialers with the given indices in the background, so that relaying inbound
+// events continues while dialing takes its time. A connection that can be established is handed
+// over to the worker.
+func (failover *GroupFailover) probe(indices []int) {
+	conn, index, err := failover.connect(indices)
+	if err != nil {
+		conn = nil
+	}
+
+	select {
+	case failover.failbacks <- failback{conn: conn, index: index}:
+
+	case <-failover.done:
+		if conn != nil {
+			discard(conn)
+		}
+	}
+}
+
+// acceptFailback makes the connection established by a probe the active one, unless an equally or
+// more preferred connection has become active in the meantime.
+func (failover *GroupFailover) acceptFailback(result failback) {
+	if result.conn == nil {
+		return
+	}
+
+	if _, index := failover.current(); index >= 0 && index <= result.index {
+		discard(result.conn)
+		return
+	}
+
+	failover.replace(result.conn, result.index)
+}
+
+// serve relays inbound events and switches connections when necessary.
+func (failover *GroupFailover) serve() {
+	util.Log(failover, "Started worker")
+	defer util.Log(failover, "Worker exited")
+
+	defer close(failover.inbound)
+	defer failover.wait.Done()
+
+	ticker := failover.config.Clock.NewTicker(failover.config.FailbackInterval)
+	defer ticker.Stop()
+
+	// probing indicates that a probe of the preferred dialers is running.
+	probing := false
+
+	for {
+		conn, index := failover.current()
+
+		// Without a connection, we wait a bit before trying all dialers again.
+		if conn == nil {
+			retry := failover.config.Clock.NewTimer(failover.config.RetryInterval)
+
+			select {
+			case <-failover.done:
+				retry.Stop()
+				return
+
+			case result := <-failover.failbacks:
+				retry.Stop()
+				probing = false
+				failover.acceptFailback(result)
+
+			case <-retry.C():
+				failover.dial(failover.order(0, len(failover.dialers)))
+			}
+
+			continue
+		}
+
+		select {
+		case <-failover.done:
+			return
+
+		// Probe the preferred dialers.
+		case <-ticker.C():
+			if index > 0 && !probing {
+				probing = true
+				go failover.probe(failover.order(0, index))
+			}
+
+		case result := <-failover.failbacks:
+			probing = false
+			failover.acceptFailback(result)
+
+		case event, open := <-conn.Inbound():
+			if !open {
+				util.Log(failover, "Lost connection of dialer %d", index)
+
+				failover.replace(nil, -1)
+				failover.dial(failover.order(index+1, len(failover.dialers)))
+
+				continue
+			}
+
+			select {
+			case <-failover.done:
+				return
+
+			case failover.inbound <- event:
+			}
+		}
+	}
+}
+
+// Active returns the index of the dialer whose connection is in use, or -1 if there is none.
+func (failover *GroupFailover) Active() int {
+	_, index := failover.current()
+	return index
+}
+
+// Send transmits a group event using the active connection. If there is none, ErrNotConnected is
+// returned.
+func (failover *GroupFailover) Send(event GroupEvent) error {
+	conn, _ := failover.current()
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	return conn.Send(event)
+}
+
+// Inbound returns the channel on which group communication can be received. It is closed when the
+// client is closed.
+func (failover *GroupFailover) Inbound() <-chan GroupEvent {
+	return failover.inbound
+}
+
+// Close terminates the active connection and waits for the worker to exit.
+func (failover *GroupFailover) Close() {
+	failover.once.Do(func() {
+		close(failover.done)
+		failover.wait.Wait()
+
+		failover.replace(nil, -1)
+	})
+}
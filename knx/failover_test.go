@@ -0,0 +1,359 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+	"github.com/vapourismo/knx-go/knx/knxtest"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// waitUntil polls the condition until it is satisfied or a second has passed.
+func waitUntil(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(time.Second)
+
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition has not been satisfied in time")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// expectGroupEvent waits for the given event to arrive on the inbound channel.
+func expectGroupEvent(t *testing.T, inbound <-chan GroupEvent, event GroupEvent) {
+	select {
+	case received := <-inbound:
+		if received.Command != event.Command || received.Destination != event.Destination ||
+			!bytes.Equal(received.Data, event.Data) {
+			t.Fatalf("Unexpected event: %+v", received)
+		}
+
+	case <-time.After(time.Second):
+		t.Fatal("No event received")
+	}
+}
+
+// busGateways provides dialers for routers on a virtual bus, which can be made unavailable.
+type busGateways struct {
+	bus *knxnet.Bus
+
+	mu        sync.Mutex
+	available []bool
+	sockets   []*knxnet.BusSocket
+}
+
+func newBusGateways(bus *knxnet.Bus, available ...bool) *busGateways {
+	return &busGateways{
+		bus:       bus,
+		available: available,
+		sockets:   make([]*knxnet.BusSocket, len(available)),
+	}
+}
+
+func (gws *busGateways) setAvailable(index int, available bool) {
+	gws.mu.Lock()
+	defer gws.mu.Unlock()
+
+	gws.available[index] = available
+}
+
+// drop closes the socket of the gateway connection, as if it had been lost.
+func (gws *busGateways) drop(index int) {
+	gws.mu.Lock()
+	defer gws.mu.Unlock()
+
+	gws.sockets[index].Close()
+}
+
+func (gws *busGateways) dialers() []GroupDialer {
+	dialers := make([]GroupDialer, len(gws.available))
+
+	for i := range dialers {
+		index := i
+
+		dialers[i] = func() (GroupConn, error) {
+			gws.mu.Lock()
+			defer gws.mu.Unlock()
+
+			if !gws.available[index] {
+				return nil, errors.New("Gateway is not available")
+			}
+
+			gws.sockets[index] = gws.bus.Join(knxnet.BusLink{})
+			router := NewGroupRouterWithSocket(gws.sockets[index], DefaultRouterConfig)
+
+			return &router, nil
+		}
+	}
+
+	return dialers
+}
+
+func TestGroupFailover(t *testing.T) {
+	t.Run("NoDialers", func(t *testing.T) {
+		if _, err := NewGroupFailover(nil, DefaultFailoverConfig); err != ErrNoDialers {
+			t.Fatal("Should not succeed")
+		}
+	})
+
+	t.Run("Unavailable", func(t *testing.T) {
+		gws := newBusGateways(knxnet.NewBus(1), false, false)
+
+		if _, err := NewGroupFailover(gws.dialers(), DefaultFailoverConfig); err == nil {
+			t.Fatal("Should not succeed")
+		}
+	})
+
+	t.Run("Refused", func(t *testing.T) {
+		busy, err := knxtest.NewGateway()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer busy.Close()
+
+		busy.SetConnStatus(knxnet.ErrNoMoreConnections)
+
+		gateway, err := knxtest.NewGateway()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer gateway.Close()
+
+		config := DefaultTunnelConfig
+		config.ResponseTimeout = 200 * time.Millisecond
+
+		failover, err := NewGroupFailover([]GroupDialer{
+			TunnelDialer(busy.Addr(), config),
+			TunnelDialer(gateway.Addr(), config),
+		}, DefaultFailoverConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer failover.Close()
+
+		if active := failover.Active(); active != 1 {
+			t.Fatalf("Expected dialer 1 to be active, got %d", active)
+		}
+	})
+
+	t.Run("Switch", func(t *testing.T) {
+		bus := knxnet.NewBus(1)
+
+		peer := NewGroupRouterWithSocket(bus.Join(knxnet.BusLink{}), DefaultRouterConfig)
+		defer peer.Close()
+
+		clock := util.NewFakeClock(time.Unix(0, 0))
+
+		config := DefaultFailoverConfig
+		config.Clock = clock
+
+		gws := newBusGateways(bus, false, true)
+
+		failover, err := NewGroupFailover(gws.dialers(), config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer failover.Close()
+
+		if active := failover.Active(); active != 1 {
+			t.Fatalf("Expected dialer 1 to be active, got %d", active)
+		}
+
+		event := GroupEvent{
+			Command:     GroupWrite,
+			Destination: cemi.NewGroupAddr3(1, 2, 3),
+			Data:        []byte{1},
+		}
+
+		if err := peer.Send(event); err != nil {
+			t.Fatal(err)
+		}
+
+		expectGroupEvent(t, failover.Inbound(), event)
+
+		if err := failover.Send(event); err != nil {
+			t.Fatal(err)
+		}
+
+		expectGroupEvent(t, peer.Inbound(), event)
+
+		// The preferred gateway recovers.
+		gws.setAvailable(0, true)
+
+		clock.BlockUntil(1)
+		clock.Advance(config.FailbackInterval)
+
+		waitUntil(t, func() bool { return failover.Active() == 0 })
+
+		// The preferred gateway fails again.
+		gws.drop(0)
+
+		waitUntil(t, func() bool { return failover.Active() == 1 })
+
+		if err := peer.Send(event); err != nil {
+			t.Fatal(err)
+		}
+
+		expectGroupEvent(t, failover.Inbound(), event)
+	})
+
+	t.Run("SlowFailback", func(t *testing.T) {
+		bus := knxnet.NewBus(1)
+
+		peer := NewGroupRouterWithSocket(bus.Join(knxnet.BusLink{}), DefaultRouterConfig)
+		defer peer.Close()
+
+		clock := util.NewFakeClock(time.Unix(0, 0))
+
+		config := DefaultFailoverConfig
+		config.Clock = clock
+
+		gws := newBusGateways(bus, false, true)
+		dialers := gws.dialers()
+
+		// The preferred gateway takes its time to answer the probes.
+		probing := make(chan struct{})
+		release := make(chan struct{})
+		preferred := dialers[0]
+		first := true
+
+		dialers[0] = func() (GroupConn, error) {
+			if first {
+				first = false
+				return preferred()
+			}
+
+			probing <- struct{}{}
+			<-release
+
+			return preferred()
+		}
+
+		failover, err := NewGroupFailover(dialers, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer failover.Close()
+
+		gws.setAvailable(0, true)
+
+		clock.BlockUntil(1)
+		clock.Advance(config.FailbackInterval)
+		<-probing
+
+		// Events are relayed while the probe is running.
+		event := GroupEvent{
+			Command:     GroupWrite,
+			Destination: cemi.NewGroupAddr3(1, 2, 3),
+			Data:        []byte{1},
+		}
+
+		if err := peer.Send(event); err != nil {
+			t.Fatal(err)
+		}
+
+		expectGroupEvent(t, failover.Inbound(), event)
+
+		if active := failover.Active(); active != 1 {
+			t.Fatalf("Expected dialer 1 to be active, got %d", active)
+		}
+
+		close(release)
+
+		waitUntil(t, func() bool { return failover.Active() == 0 })
+	})
+
+	t.Run("CloseWhileProbing", func(t *testing.T) {
+		clock := util.NewFakeClock(time.Unix(0, 0))
+
+		config := DefaultFailoverConfig
+		config.Clock = clock
+
+		gws := newBusGateways(knxnet.NewBus(1), true)
+
+		probing := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+
+		first := true
+		unavailable := func() (GroupConn, error) {
+			if first {
+				first = false
+			} else {
+				probing <- struct{}{}
+				<-release
+			}
+
+			return nil, errors.New("Gateway is not available")
+		}
+
+		failover, err := NewGroupFailover([]GroupDialer{unavailable, gws.dialers()[0]}, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock.BlockUntil(1)
+		clock.Advance(config.FailbackInterval)
+		<-probing
+
+		closed := make(chan struct{})
+		go func() {
+			failover.Close()
+			close(closed)
+		}()
+
+		select {
+		case <-closed:
+		case <-time.After(time.Second):
+			t.Fatal("Close is blocked by the probe")
+		}
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		clock := util.NewFakeClock(time.Unix(0, 0))
+
+		config := DefaultFailoverConfig
+		config.Clock = clock
+
+		gws := newBusGateways(knxnet.NewBus(1), true)
+
+		failover, err := NewGroupFailover(gws.dialers(), config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer failover.Close()
+
+		gws.setAvailable(0, false)
+		gws.drop(0)
+
+		waitUntil(t, func() bool { return failover.Active() == -1 })
+
+		if err := failover.Send(GroupEvent{}); err != ErrNotConnected {
+			t.Fatalf("Expected %v, got %v", ErrNotConnected, err)
+		}
+
+		// Wait for the failback ticker and the retry timer.
+		gws.setAvailable(0, true)
+
+		clock.BlockUntil(2)
+		clock.Advance(config.RetryInterval)
+
+		waitUntil(t, func() bool { return failover.Active() == 0 })
+	})
+}
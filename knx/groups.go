@@ -40,6 +40,10 @@ type GroupEvent struct {
 	Source      cemi.IndividualAddr
 	Destination cemi.GroupAddr
 	Data        []byte
+
+	// Secure indicates that the event has been transmitted using KNX Data Secure. Only clients that
+	// support it set this flag or honour it when sending.
+	Secure bool
 }
 
 // A GroupClient is a KNX client which supports group communication.
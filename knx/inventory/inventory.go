@@ -0,0 +1,274 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Package inventory passively analyses bus traffic in order to find out which devices exist and
+// which group addresses they use.
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// Line identifies a line by its area and line number, which are the upper byte of the individual
+// addresses of the devices on it.
+type Line uint8
+
+// LineOf determines the line of the individual address.
+func LineOf(addr cemi.IndividualAddr) Line {
+	return Line(addr >> 8)
+}
+
+// String generates a string representation, e.g. "1.1".
+func (line Line) String() string {
+	return fmt.Sprintf("%d.%d", uint8(line)>>4, uint8(line)&15)
+}
+
+// Counters keep track of how many telegrams have been seen, and when.
+type Counters struct {
+	Telegrams int
+	Repeats   int
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// count accounts for a telegram.
+func (counters *Counters) count(at time.Time, repeated bool) {
+	if counters.Telegrams == 0 {
+		counters.FirstSeen = at
+	}
+
+	counters.Telegrams++
+	counters.LastSeen = at
+
+	if repeated {
+		counters.Repeats++
+	}
+}
+
+// RepeatRatio is the share of repeated telegrams. A high ratio indicates that the telegrams are
+// not acknowledged, e.g. because of wiring problems or missing receivers.
+func (counters Counters) RepeatRatio() float64 {
+	if counters.Telegrams == 0 {
+		return 0
+	}
+
+	return float64(counters.Repeats) / float64(counters.Telegrams)
+}
+
+// GroupActivity describes how a device uses a group address.
+type GroupActivity struct {
+	Counters
+	Reads     int
+	Responses int
+	Writes    int
+}
+
+// Device is an individual address that has been seen as the source of telegrams.
+type Device struct {
+	Address cemi.IndividualAddr
+	Counters
+
+	// Groups contains the activity of the device per group address.
+	Groups map[cemi.GroupAddr]*GroupActivity
+}
+
+// copy creates a deep copy of the device.
+func (dev *Device) copy() Device {
+	copied := *dev
+	copied.Groups = make(map[cemi.GroupAddr]*GroupActivity, len(dev.Groups))
+
+	for addr, activity := range dev.Groups {
+		activityCopy := *activity
+		copied.Groups[addr] = &activityCopy
+	}
+
+	return copied
+}
+
+// Inventory records the devices and group addresses that appear in the traffic it is fed with.
+// Devices are only recorded once they send, since a telegram to an individual address does not
+// prove that the device exists.
+type Inventory struct {
+	clock util.Clock
+
+	mu      sync.Mutex
+	devices map[cemi.IndividualAddr]*Device
+	invalid int
+}
+
+// NewInventory creates an empty inventory. The clock provides the time at which telegrams are
+// seen; nil means util.RealClock.
+func NewInventory(clock util.Clock) *Inventory {
+	if clock == nil {
+		clock = util.RealClock
+	}
+
+	return &Inventory{
+		clock:   clock,
+		devices: make(map[cemi.IndividualAddr]*Device),
+	}
+}
+
+// Record accounts for the message. L_Data.ind and L_Data.con frames from group or tunnel
+// connections as well as L_Busmon.ind frames from bus monitor connections are understood; other
+// messages are ignored. Repeated telegrams are recognized by the repeat flag of the frame.
+func (inv *Inventory) Record(msg cemi.Message) {
+	var ldata cemi.LData
+
+	switch msg := msg.(type) {
+	case *cemi.LDataInd:
+		ldata = msg.LData
+
+	case *cemi.LDataCon:
+		// Frames that could not be sent did not appear on the bus.
+		if msg.Control1&cemi.Control1HasError != 0 {
+			return
+		}
+
+		ldata = msg.LData
+
+	case *cemi.LBusmonInd:
+		parsed, ok, err := msg.Frame()
+		if err != nil {
+			util.Log(inv, "Failed to parse bus monitor frame: %v", err)
+
+			inv.mu.Lock()
+			inv.invalid++
+			inv.mu.Unlock()
+
+			return
+		}
+
+		if !ok {
+			return
+		}
+
+		ldata = parsed
+
+	default:
+		return
+	}
+
+	inv.record(ldata, inv.clock.Now())
+}
+
+// record accounts for the frame.
+func (inv *Inventory) record(ldata cemi.LData, at time.Time) {
+	repeated := ldata.Control1&cemi.Control1NoRepeat == 0
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	dev, ok := inv.devices[ldata.Source]
+	if !ok {
+		dev = &Device{Address: ldata.Source, Groups: make(map[cemi.GroupAddr]*GroupActivity)}
+		inv.devices[ldata.Source] = dev
+	}
+
+	dev.count(at, repeated)
+
+	app, ok := ldata.Data.(*cemi.AppData)
+	if !ok || !ldata.Control2.IsGroupAddr() || !app.Command.IsGroupCommand() {
+		return
+	}
+
+	group := cemi.GroupAddr(ldata.Destination)
+
+	activity, ok := dev.Groups[group]
+	if !ok {
+		activity = &GroupActivity{}
+		dev.Groups[group] = activity
+	}
+
+	activity.count(at, repeated)
+
+	switch app.Command {
+	case cemi.GroupValueRead:
+		activity.Reads++
+
+	case cemi.GroupValueResponse:
+		activity.Responses++
+
+	case cemi.GroupValueWrite:
+		activity.Writes++
+	}
+}
+
+// Serve records the messages until the channel is closed.
+func (inv *Inventory) Serve(inbound <-chan cemi.Message) {
+	for msg := range inbound {
+		inv.Record(msg)
+	}
+}
+
+// Invalid returns the number of bus monitor frames that could not be parsed.
+func (inv *Inventory) Invalid() int {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	return inv.invalid
+}
+
+// Devices returns a snapshot of the devices, ordered by individual address.
+func (inv *Inventory) Devices() []Device {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	devices := make([]Device, 0, len(inv.devices))
+	for _, dev := range inv.devices {
+		devices = append(devices, dev.copy())
+	}
+
+	sort.Sort(byAddress(devices))
+	return devices
+}
+
+// byAddress sorts devices by their individual address.
+type byAddress []Device
+
+func (devices byAddress) Len() int           { return len(devices) }
+func (devices byAddress) Less(i, j int) bool { return devices[i].Address < devices[j].Address }
+func (devices byAddress) Swap(i, j int)      { devices[i], devices[j] = devices[j], devices[i] }
+
+// byTelegrams sorts devices by the number of telegrams they have sent, in descending order.
+type byTelegrams []Device
+
+func (devices byTelegrams) Len() int { return len(devices) }
+
+func (devices byTelegrams) Less(i, j int) bool {
+	return devices[i].Telegrams > devices[j].Telegrams
+}
+
+func (devices byTelegrams) Swap(i, j int) { devices[i], devices[j] = devices[j], devices[i] }
+
+// Lines returns the individual addresses that have been seen on each line, in ascending order.
+func (inv *Inventory) Lines() map[Line][]cemi.IndividualAddr {
+	lines := make(map[Line][]cemi.IndividualAddr)
+
+	for _, dev := range inv.Devices() {
+		line := LineOf(dev.Address)
+		lines[line] = append(lines[line], dev.Address)
+	}
+
+	return lines
+}
+
+// TopTalkers returns the n devices which have sent the most telegrams. A negative n returns all
+// devices.
+func (inv *Inventory) TopTalkers(n int) []Device {
+	devices := inv.Devices()
+
+	sort.Stable(byTelegrams(devices))
+
+	if n >= 0 && n < len(devices) {
+		devices = devices[:n]
+	}
+
+	return devices
+}
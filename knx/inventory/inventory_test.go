@@ -0,0 +1,166 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// makeRaw appends the checksum to the raw frame and wraps it into a L_Busmon.ind message.
+func makeRaw(frame ...byte) *cemi.LBusmonInd {
+	var checksum byte = 0xFF
+	for _, b := range frame {
+		checksum ^= b
+	}
+
+	msg := cemi.LBusmonInd(append([]byte{0}, append(frame, checksum)...))
+	return &msg
+}
+
+func makeGroupInd(
+	src cemi.IndividualAddr,
+	dest cemi.GroupAddr,
+	command cemi.APCI,
+	repeated bool,
+) *cemi.LDataInd {
+	control1 := cemi.Control1StdFrame | cemi.Control1NoSysBroadcast
+	if !repeated {
+		control1 |= cemi.Control1NoRepeat
+	}
+
+	return &cemi.LDataInd{LData: cemi.LData{
+		Control1:    control1,
+		Control2:    cemi.Control2GroupAddr | cemi.Control2Hops(6),
+		Source:      src,
+		Destination: uint16(dest),
+		Data:        &cemi.AppData{Command: command, Data: []byte{1}},
+	}}
+}
+
+func TestInventory(t *testing.T) {
+	clock := util.NewFakeClock(time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC))
+	inv := NewInventory(clock)
+
+	sensor := cemi.NewIndividualAddr3(1, 1, 5)
+	actuator := cemi.NewIndividualAddr3(1, 2, 7)
+	ga1 := cemi.NewGroupAddr3(1, 2, 3)
+	ga2 := cemi.NewGroupAddr3(1, 2, 4)
+
+	inv.Record(makeGroupInd(sensor, ga1, cemi.GroupValueWrite, false))
+	clock.Advance(time.Minute)
+	inv.Record(makeGroupInd(sensor, ga1, cemi.GroupValueWrite, true))
+	inv.Record(makeGroupInd(actuator, ga2, cemi.GroupValueRead, false))
+
+	// Group write of 1.1.5 to 1/2/3 and its repetition, as seen by a bus monitor.
+	clock.Advance(time.Minute)
+	inv.Record(makeRaw(0xBC, 0x11, 0x05, 0x0A, 0x03, 0xE1, 0x00, 0x81))
+	inv.Record(makeRaw(0x9C, 0x11, 0x05, 0x0A, 0x03, 0xE1, 0x00, 0x81))
+	inv.Record(&cemi.LBusmonInd{0, 0xCC})
+
+	// Group response of 1.2.7 to 1/2/4 in an extended frame.
+	inv.Record(makeRaw(0x3C, 0xE0, 0x12, 0x07, 0x0A, 0x04, 0x01, 0x00, 0x41))
+
+	// Broken frames are counted, but not recorded.
+	inv.Record(&cemi.LBusmonInd{0, 0xBC, 0x11, 0x06, 0x0A, 0x03, 0xE1, 0x00, 0x81, 0x00})
+	inv.Record(&cemi.LBusmonInd{0, 0xBC, 0x11})
+
+	if inv.Invalid() != 2 {
+		t.Errorf("Expected 2 invalid frames, got %d", inv.Invalid())
+	}
+
+	devices := inv.Devices()
+	if len(devices) != 2 {
+		t.Fatalf("Expected 2 devices, got %d", len(devices))
+	}
+
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	expectedSensor := Device{
+		Address: sensor,
+		Counters: Counters{
+			Telegrams: 4,
+			Repeats:   2,
+			FirstSeen: start,
+			LastSeen:  start.Add(2 * time.Minute),
+		},
+		Groups: map[cemi.GroupAddr]*GroupActivity{
+			ga1: {Counters: Counters{
+				Telegrams: 4,
+				Repeats:   2,
+				FirstSeen: start,
+				LastSeen:  start.Add(2 * time.Minute),
+			}, Writes: 4},
+		},
+	}
+	if !reflect.DeepEqual(devices[0], expectedSensor) {
+		t.Errorf("Unexpected device: %+v", devices[0])
+	}
+
+	if ratio := devices[0].RepeatRatio(); ratio != 0.5 {
+		t.Errorf("Unexpected repeat ratio: %f", ratio)
+	}
+
+	if activity := devices[1].Groups[ga2]; activity.Reads != 1 || activity.Responses != 1 {
+		t.Errorf("Unexpected group activity: %+v", activity)
+	}
+
+	expectedLines := map[Line][]cemi.IndividualAddr{0x11: {sensor}, 0x12: {actuator}}
+	if lines := inv.Lines(); !reflect.DeepEqual(lines, expectedLines) {
+		t.Errorf("Unexpected lines: %v", lines)
+	}
+
+	if top := inv.TopTalkers(1); len(top) != 1 || top[0].Address != sensor {
+		t.Errorf("Unexpected top talkers: %v", top)
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		var buffer bytes.Buffer
+		if err := inv.WriteJSON(&buffer); err != nil {
+			t.Fatal(err)
+		}
+
+		var doc jsonInventory
+		if err := json.Unmarshal(buffer.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(doc.Lines, map[string][]string{"1.1": {"1.1.5"}, "1.2": {"1.2.7"}}) {
+			t.Errorf("Unexpected lines: %v", doc.Lines)
+		}
+
+		if len(doc.Devices) != 2 || doc.Devices[0].RepeatRatio != 0.5 ||
+			doc.Devices[0].Groups[0].Address != "1/2/3" {
+			t.Errorf("Unexpected devices: %+v", doc.Devices)
+		}
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		var buffer bytes.Buffer
+		if err := inv.WriteReport(&buffer, -1); err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("Expected 3 lines, got %q", lines)
+		}
+
+		if !strings.HasPrefix(lines[1], "1.1.5") || !strings.Contains(lines[1], "50.0%") ||
+			!strings.HasSuffix(lines[1], "1/2/3") {
+			t.Errorf("Unexpected report line: %q", lines[1])
+		}
+
+		if !strings.HasSuffix(lines[2], "1/2/4") {
+			t.Errorf("Unexpected report line: %q", lines[2])
+		}
+	})
+}
@@ -0,0 +1,144 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// jsonCounters is the JSON representation of Counters.
+type jsonCounters struct {
+	Telegrams   int       `json:"telegrams"`
+	Repeats     int       `json:"repeats"`
+	RepeatRatio float64   `json:"repeatRatio"`
+	FirstSeen   time.Time `json:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen"`
+}
+
+// jsonGroup is the JSON representation of GroupActivity.
+type jsonGroup struct {
+	Address string `json:"address"`
+	jsonCounters
+	Reads     int `json:"reads"`
+	Responses int `json:"responses"`
+	Writes    int `json:"writes"`
+}
+
+// jsonDevice is the JSON representation of Device.
+type jsonDevice struct {
+	Address string `json:"address"`
+	Line    string `json:"line"`
+	jsonCounters
+	Groups []jsonGroup `json:"groups"`
+}
+
+// jsonInventory is the JSON representation of Inventory.
+type jsonInventory struct {
+	Lines   map[string][]string `json:"lines"`
+	Devices []jsonDevice        `json:"devices"`
+	Invalid int                 `json:"invalidFrames"`
+}
+
+// convertCounters converts the counters to their JSON representation.
+func convertCounters(counters Counters) jsonCounters {
+	return jsonCounters{
+		Telegrams:   counters.Telegrams,
+		Repeats:     counters.Repeats,
+		RepeatRatio: counters.RepeatRatio(),
+		FirstSeen:   counters.FirstSeen,
+		LastSeen:    counters.LastSeen,
+	}
+}
+
+// WriteJSON exports the inventory as JSON. Addresses are given in their usual notation; devices
+// and their group addresses are ordered by address.
+func (inv *Inventory) WriteJSON(w io.Writer) error {
+	doc := jsonInventory{
+		Lines:   make(map[string][]string),
+		Devices: []jsonDevice{},
+		Invalid: inv.Invalid(),
+	}
+
+	for _, dev := range inv.Devices() {
+		line := LineOf(dev.Address).String()
+		doc.Lines[line] = append(doc.Lines[line], dev.Address.String())
+
+		converted := jsonDevice{
+			Address:      dev.Address.String(),
+			Line:         line,
+			jsonCounters: convertCounters(dev.Counters),
+			Groups:       []jsonGroup{},
+		}
+
+		for _, addr := range sortedGroups(dev) {
+			activity := dev.Groups[addr]
+			converted.Groups = append(converted.Groups, jsonGroup{
+				Address:      addr.String(),
+				jsonCounters: convertCounters(activity.Counters),
+				Reads:        activity.Reads,
+				Responses:    activity.Responses,
+				Writes:       activity.Writes,
+			})
+		}
+
+		doc.Devices = append(doc.Devices, converted)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(doc)
+}
+
+// WriteReport writes a table of the n devices which have sent the most telegrams, together with
+// the group addresses they write or answer. A negative n includes all devices.
+func (inv *Inventory) WriteReport(w io.Writer, n int) error {
+	table := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(table, "Device\tTelegrams\tRepeated\tFirst seen\tLast seen\tWrites or answers")
+
+	for _, dev := range inv.TopTalkers(n) {
+		var groups []string
+		for _, addr := range sortedGroups(dev) {
+			if activity := dev.Groups[addr]; activity.Writes > 0 || activity.Responses > 0 {
+				groups = append(groups, addr.String())
+			}
+		}
+
+		fmt.Fprintf(table, "%v\t%d\t%.1f%%\t%s\t%s\t%s\n",
+			dev.Address,
+			dev.Telegrams,
+			dev.RepeatRatio()*100,
+			dev.FirstSeen.Format(time.RFC3339),
+			dev.LastSeen.Format(time.RFC3339),
+			strings.Join(groups, ", "))
+	}
+
+	return table.Flush()
+}
+
+// sortedGroups returns the group addresses which the device has used, in ascending order.
+func sortedGroups(dev Device) []cemi.GroupAddr {
+	addrs := make([]cemi.GroupAddr, 0, len(dev.Groups))
+	for addr := range dev.Groups {
+		addrs = append(addrs, addr)
+	}
+
+	sort.Sort(groupAddrSlice(addrs))
+	return addrs
+}
+
+// groupAddrSlice sorts group addresses in ascending order.
+type groupAddrSlice []cemi.GroupAddr
+
+func (addrs groupAddrSlice) Len() int           { return len(addrs) }
+func (addrs groupAddrSlice) Less(i, j int) bool { return addrs[i] < addrs[j] }
+func (addrs groupAddrSlice) Swap(i, j int)      { addrs[i], addrs[j] = addrs[j], addrs[i] }
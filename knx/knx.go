@@ -3,3 +3,13 @@
 
 // Package knx provides the means to communicate with KNXnet/IP gateways and routers.
 package knx
+
+import "github.com/vapourismo/knx-go/knx/cemi"
+
+// A Conn exchanges CEMI-encoded frames with a KNX network, like a Tunnel or a Router does. Its
+// inbound channel is closed when the connection is lost.
+type Conn interface {
+	Send(data cemi.Message) error
+	Inbound() <-chan cemi.Message
+	Close()
+}
@@ -0,0 +1,187 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// A BusLink determines how packets travel between a BusSocket and the Bus it has joined. The
+// properties apply to packets that leave the socket as well as to packets that arrive at it.
+type BusLink struct {
+	// Latency is the constant delay that is added to every packet.
+	Latency time.Duration
+
+	// Jitter is the upper bound of a random delay that is added on top of Latency. Packets which
+	// are delayed differently may overtake each other, therefore a non-zero jitter causes packets to
+	// be reordered.
+	Jitter time.Duration
+
+	// Loss is the probability, ranging from 0 to 1, with which a packet is dropped.
+	Loss float64
+}
+
+// A Bus is an in-memory medium which delivers each packet sent by one of its sockets to all other
+// sockets that have joined it, similar to an IP multicast group.
+type Bus struct {
+	mu      sync.Mutex
+	rand    *rand.Rand
+	sockets map[*BusSocket]struct{}
+}
+
+// NewBus creates an empty Bus. The seed initializes the random source which determines packet
+// loss and jitter.
+func NewBus(seed int64) *Bus {
+	return &Bus{
+		rand:    rand.New(rand.NewSource(seed)),
+		sockets: map[*BusSocket]struct{}{},
+	}
+}
+
+// Join creates a new socket which is attached to the bus using the given link properties.
+func (bus *Bus) Join(link BusLink) *BusSocket {
+	sock := &BusSocket{
+		bus:     bus,
+		link:    link,
+		queue:   newQueue(),
+		inbound: make(chan Service),
+		done:    make(chan struct{}),
+	}
+
+	bus.mu.Lock()
+	bus.sockets[sock] = struct{}{}
+	bus.mu.Unlock()
+
+	go sock.serve()
+
+	return sock
+}
+
+// delay determines the time it takes to transmit a packet across the given links. The second
+// return value is false if the packet shall be dropped. The caller must hold the bus lock.
+func (bus *Bus) delay(links ...BusLink) (time.Duration, bool) {
+	var delay time.Duration
+
+	for _, link := range links {
+		if link.Loss > 0 && bus.rand.Float64() < link.Loss {
+			return 0, false
+		}
+
+		delay += link.Latency
+
+		if link.Jitter > 0 {
+			delay += time.Duration(bus.rand.Int63n(int64(link.Jitter)))
+		}
+	}
+
+	return delay, true
+}
+
+// transmit delivers the packet to every socket except the sender.
+func (bus *Bus) transmit(sender *BusSocket, packet []byte) error {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	if _, ok := bus.sockets[sender]; !ok {
+		return errors.New("Socket has left the bus")
+	}
+
+	for receiver := range bus.sockets {
+		if receiver == sender {
+			continue
+		}
+
+		delay, ok := bus.delay(sender.link, receiver.link)
+		if !ok {
+			continue
+		}
+
+		if delay <= 0 {
+			receiver.queue.push(packet)
+		} else {
+			receiver := receiver
+			time.AfterFunc(delay, func() { receiver.queue.push(packet) })
+		}
+	}
+
+	return nil
+}
+
+// leave detaches the socket from the bus.
+func (bus *Bus) leave(sock *BusSocket) {
+	bus.mu.Lock()
+	delete(bus.sockets, sock)
+	bus.mu.Unlock()
+}
+
+// A BusSocket is a Socket which exchanges packets with the other sockets on a Bus.
+type BusSocket struct {
+	bus   *Bus
+	link  BusLink
+	queue *queue
+
+	inbound chan Service
+	done    chan struct{}
+	once    sync.Once
+}
+
+// serve decodes queued packets and relays them to the inbound channel.
+func (sock *BusSocket) serve() {
+	util.Log(sock, "Started worker")
+	defer util.Log(sock, "Worker exited")
+
+	defer close(sock.inbound)
+
+	for {
+		packet, ok := sock.queue.pop()
+		if !ok {
+			return
+		}
+
+		var payload Service
+		if _, err := Unpack(packet.([]byte), &payload); err != nil {
+			util.Log(sock, "Error during Unpack: %v", err)
+			continue
+		}
+
+		select {
+		case <-sock.done:
+			return
+
+		case sock.inbound <- payload:
+		}
+	}
+}
+
+// Send transmits a KNXnet/IP packet to all other sockets on the bus. Each receiver decodes its own
+// copy of the packet, therefore the payload may be modified after Send returns.
+func (sock *BusSocket) Send(payload ServicePackable) error {
+	packet, err := AppendPack(nil, payload)
+	if err != nil {
+		return err
+	}
+
+	return sock.bus.transmit(sock, packet)
+}
+
+// Inbound provides a channel from which you can retrieve incoming packets.
+func (sock *BusSocket) Inbound() <-chan Service {
+	return sock.inbound
+}
+
+// Close detaches the socket from the bus and terminates its worker.
+func (sock *BusSocket) Close() error {
+	sock.once.Do(func() {
+		sock.bus.leave(sock)
+		sock.queue.close()
+		close(sock.done)
+	})
+
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"testing"
+	"time"
+)
+
+func expectService(t *testing.T, sock Socket) Service {
+	select {
+	case srv, open := <-sock.Inbound():
+		if !open {
+			t.Fatal("Inbound channel has been closed")
+		}
+
+		return srv
+
+	case <-time.After(time.Second):
+		t.Fatal("No packet received")
+	}
+
+	return nil
+}
+
+func expectSilence(t *testing.T, sock Socket, duration time.Duration) {
+	select {
+	case srv := <-sock.Inbound():
+		t.Fatalf("Unexpected packet: %+v", srv)
+
+	case <-time.After(duration):
+	}
+}
+
+func TestBus(t *testing.T) {
+	t.Run("Broadcast", func(t *testing.T) {
+		bus := NewBus(1)
+
+		a := bus.Join(BusLink{})
+		defer a.Close()
+
+		b := bus.Join(BusLink{})
+		defer b.Close()
+
+		c := bus.Join(BusLink{Latency: 5 * time.Millisecond})
+		defer c.Close()
+
+		if err := a.Send(&DiscRes{Channel: 7, Status: 1}); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, sock := range []*BusSocket{b, c} {
+			res, ok := expectService(t, sock).(*DiscRes)
+			if !ok || res.Channel != 7 || res.Status != 1 {
+				t.Errorf("Unexpected packet: %+v", res)
+			}
+		}
+
+		expectSilence(t, a, 10*time.Millisecond)
+	})
+
+	t.Run("Loss", func(t *testing.T) {
+		bus := NewBus(1)
+
+		a := bus.Join(BusLink{})
+		defer a.Close()
+
+		b := bus.Join(BusLink{Loss: 1})
+		defer b.Close()
+
+		if err := a.Send(&DiscRes{Channel: 1}); err != nil {
+			t.Fatal(err)
+		}
+
+		expectSilence(t, b, 10*time.Millisecond)
+	})
+
+	t.Run("Reorder", func(t *testing.T) {
+		bus := NewBus(1)
+
+		a := bus.Join(BusLink{})
+		defer a.Close()
+
+		b := bus.Join(BusLink{Jitter: 20 * time.Millisecond})
+		defer b.Close()
+
+		const count = 20
+
+		for i := 0; i < count; i++ {
+			if err := a.Send(&DiscRes{Channel: uint8(i)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		seen := map[uint8]bool{}
+		inOrder := true
+
+		for i := 0; i < count; i++ {
+			res := expectService(t, b).(*DiscRes)
+			seen[res.Channel] = true
+
+			if res.Channel != uint8(i) {
+				inOrder = false
+			}
+		}
+
+		if len(seen) != count {
+			t.Errorf("Expected %d distinct packets, got %d", count, len(seen))
+		}
+
+		if inOrder {
+			t.Error("Packets have not been reordered")
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		bus := NewBus(1)
+
+		a := bus.Join(BusLink{})
+		a.Close()
+
+		if _, open := <-a.Inbound(); open {
+			t.Error("Inbound channel is still open")
+		}
+
+		if err := a.Send(&DiscRes{}); err == nil {
+			t.Error("Should not succeed")
+		}
+	})
+}
@@ -53,6 +53,13 @@ func (req *ConnReq) Pack(buffer []byte) {
 	buffer[3] = 0
 }
 
+// AppendPack appends the service payload to dst.
+func (req *ConnReq) AppendPack(dst []byte) ([]byte, error) {
+	dst, _ = req.Control.AppendPack(dst)
+	dst, _ = req.Tunnel.AppendPack(dst)
+	return append(dst, 4, 4, byte(req.Layer), 0), nil
+}
+
 // Unpack parses the given service payload in order to initialize the structure.
 func (req *ConnReq) Unpack(data []byte) (n uint, err error) {
 	var length, connType, reserved uint8
@@ -105,6 +112,17 @@ func (res *ConnRes) Pack(buffer []byte) {
 	}
 }
 
+// AppendPack appends the service payload to dst.
+func (res *ConnRes) AppendPack(dst []byte) ([]byte, error) {
+	if res.Status != 0 {
+		return append(dst, res.Channel, uint8(res.Status)), nil
+	}
+
+	dst = append(dst, res.Channel, 0)
+	dst, _ = res.Control.AppendPack(dst)
+	return append(dst, 4, 4, 0, 0), nil
+}
+
 // Unpack parses the given service payload in order to initialize the structure.
 func (res *ConnRes) Unpack(data []byte) (n uint, err error) {
 	n, err = util.UnpackSome(data, &res.Channel, (*uint8)(&res.Status))
@@ -142,6 +160,11 @@ func (req *ConnStateReq) Pack(buffer []byte) {
 	req.Control.Pack(buffer[2:])
 }
 
+// AppendPack appends the service payload to dst.
+func (req *ConnStateReq) AppendPack(dst []byte) ([]byte, error) {
+	return req.Control.AppendPack(append(dst, req.Channel, uint8(req.Status)))
+}
+
 // Unpack parses the given service payload in order to initialize the structure.
 func (req *ConnStateReq) Unpack(data []byte) (uint, error) {
 	return util.UnpackSome(data, &req.Channel, (*uint8)(&req.Status), &req.Control)
@@ -169,6 +192,11 @@ func (res *ConnStateRes) Pack(buffer []byte) {
 	buffer[1] = uint8(res.Status)
 }
 
+// AppendPack appends the service payload to dst.
+func (res *ConnStateRes) AppendPack(dst []byte) ([]byte, error) {
+	return append(dst, res.Channel, uint8(res.Status)), nil
+}
+
 // Unpack parses the given service payload in order to initialize the structure.
 func (res *ConnStateRes) Unpack(data []byte) (uint, error) {
 	return util.UnpackSome(data, &res.Channel, (*uint8)(&res.Status))
@@ -198,6 +226,11 @@ func (req *DiscReq) Pack(buffer []byte) {
 	req.Control.Pack(buffer[2:])
 }
 
+// AppendPack appends the service payload to dst.
+func (req *DiscReq) AppendPack(dst []byte) ([]byte, error) {
+	return req.Control.AppendPack(append(dst, req.Channel, req.Status))
+}
+
 // Unpack parses the given service payload in order to initialize the structure.
 func (req *DiscReq) Unpack(data []byte) (uint, error) {
 	return util.UnpackSome(data, &req.Channel, &req.Status, &req.Control)
@@ -225,6 +258,11 @@ func (res *DiscRes) Pack(data []byte) {
 	data[1] = res.Status
 }
 
+// AppendPack appends the service payload to dst.
+func (res *DiscRes) AppendPack(dst []byte) ([]byte, error) {
+	return append(dst, res.Channel, res.Status), nil
+}
+
 // Unpack parses the given service payload in order to initialize the structure.
 func (res *DiscRes) Unpack(data []byte) (uint, error) {
 	return util.UnpackSome(data, &res.Channel, &res.Status)
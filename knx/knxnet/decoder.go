@@ -0,0 +1,193 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"io"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// A Decoder parses KNXnet/IP packets while reusing memory. Once the structures involved have grown
+// large enough, decoding does not allocate memory anymore. This is useful for applications that
+// process many packets.
+//
+// Unpack decodes into structures owned by the decoder. The service it produces is only valid until
+// the next call to Unpack; copy it if you need to retain it. UnpackInto decodes into a service
+// provided by the caller instead. A Decoder must not be used by multiple goroutines at the same
+// time.
+type Decoder struct {
+	connReq      ConnReq
+	connRes      ConnRes
+	connStateReq ConnStateReq
+	connStateRes ConnStateRes
+	discReq      DiscReq
+	discRes      DiscRes
+	tunnelReq    TunnelReq
+	tunnelRes    TunnelRes
+	routingInd   RoutingInd
+	routingLost  RoutingLost
+	routingBusy  RoutingBusy
+	unknown      UnknownService
+
+	searchReqExt    SearchReqExt
+	searchResExt    SearchResExt
+	remoteDiagReq   RemoteDiagReq
+	remoteDiagRes   RemoteDiagRes
+	remoteConfigReq RemoteConfigReq
+	remoteResetReq  RemoteResetReq
+
+	routingSystemBroadcast RoutingSystemBroadcast
+
+	cemi cemi.Decoder
+}
+
+// Unpack parses a KNXnet/IP packet and retrieves its service payload. It behaves like the
+// package-level Unpack, except that the service points into the structures owned by the decoder.
+func (dec *Decoder) Unpack(data []byte, srv *Service) (uint, error) {
+	if len(data) < 6 {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	var result Service
+
+	switch srvID := ServiceID(data[2])<<8 | ServiceID(data[3]); srvID {
+	case ConnReqService:
+		result = &dec.connReq
+
+	case ConnResService:
+		result = &dec.connRes
+
+	case ConnStateReqService:
+		result = &dec.connStateReq
+
+	case ConnStateResService:
+		result = &dec.connStateRes
+
+	case DiscReqService:
+		result = &dec.discReq
+
+	case DiscResService:
+		result = &dec.discRes
+
+	case SearchReqExtService:
+		result = &dec.searchReqExt
+
+	case SearchResExtService:
+		result = &dec.searchResExt
+
+	case TunnelReqService:
+		result = &dec.tunnelReq
+
+	case TunnelResService:
+		result = &dec.tunnelRes
+
+	case RoutingIndService:
+		result = &dec.routingInd
+
+	case RoutingLostService:
+		result = &dec.routingLost
+
+	case RoutingBusyService:
+		result = &dec.routingBusy
+
+	case RoutingSystemBroadcastService:
+		result = &dec.routingSystemBroadcast
+
+	case RemoteDiagReqService:
+		result = &dec.remoteDiagReq
+
+	case RemoteDiagResService:
+		result = &dec.remoteDiagRes
+
+	case RemoteConfigReqService:
+		result = &dec.remoteConfigReq
+
+	case RemoteResetReqService:
+		result = &dec.remoteResetReq
+
+	default:
+		dec.unknown.service = srvID
+		result = &dec.unknown
+	}
+
+	n, err := dec.UnpackInto(data, &result)
+	if err == nil {
+		*srv = result
+	}
+
+	return n, err
+}
+
+// UnpackInto parses a KNXnet/IP packet into the service provided by the caller. If the service has
+// the type that the packet encodes, its memory is reused, including its CEMI payload. Otherwise, a
+// new service is stored in its place. Decoding repeatedly into the same service does not allocate
+// memory, once it has grown large enough:
+//
+//	var srv Service
+//
+//	for {
+//		// ...
+//		_, err := dec.UnpackInto(packet, &srv)
+//		// ...
+//	}
+//
+// The decoder does not retain the service. If an error occurs, the service may be altered
+// partially.
+func (dec *Decoder) UnpackInto(data []byte, srv *Service) (uint, error) {
+	if len(data) < 6 {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	if data[0] != 6 {
+		return 6, ErrHeaderLength
+	}
+
+	if data[1] != 16 {
+		return 6, ErrHeaderVersion
+	}
+
+	srvID := ServiceID(data[2])<<8 | ServiceID(data[3])
+	body := data[6:]
+
+	if *srv == nil || (*srv).Service() != srvID {
+		*srv = newService(srvID)
+	}
+
+	var (
+		n   uint
+		err error
+	)
+
+	switch result := (*srv).(type) {
+	case *ConnRes:
+		// Responses that indicate an error do not overwrite the host info.
+		*result = ConnRes{}
+		n, err = result.Unpack(body)
+
+	case *TunnelReq:
+		n, err = result.unpackReusing(body, &dec.cemi)
+
+	case *RoutingInd:
+		n, err = dec.cemi.UnpackInto(body, &result.Payload)
+
+	case *RoutingSystemBroadcast:
+		n, err = dec.cemi.UnpackInto(body, &result.Payload)
+
+	case *UnknownService:
+		result.Data = append(result.Data[:0], body...)
+		n = uint(len(body))
+
+	case serviceUnpackable:
+		n, err = result.Unpack(body)
+
+	default:
+		// Services of other types cannot be reused.
+		fresh := newService(srvID)
+		n, err = fresh.Unpack(body)
+		*srv = fresh
+	}
+
+	return 6 + n, err
+}
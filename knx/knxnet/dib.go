@@ -0,0 +1,768 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// DescriptionType identifies the kind of a description information block (DIB).
+type DescriptionType uint8
+
+// These are known description types.
+const (
+	DescriptionTypeDeviceInfo        DescriptionType = 0x01
+	DescriptionTypeSupportedServices DescriptionType = 0x02
+	DescriptionTypeIPConfig          DescriptionType = 0x03
+	DescriptionTypeIPCurrentConfig   DescriptionType = 0x04
+	DescriptionTypeKNXAddresses      DescriptionType = 0x05
+	DescriptionTypeSecuredServices   DescriptionType = 0x06
+	DescriptionTypeTunnellingInfo    DescriptionType = 0x07
+	DescriptionTypeManufacturerData  DescriptionType = 0xFE
+)
+
+// ErrDIBLength is returned when a description information block has an invalid length.
+var ErrDIBLength = errors.New("Description information block has an invalid length")
+
+// A DIB is a description information block. It describes a certain aspect of a KNXnet/IP device.
+type DIB interface {
+	util.Packable
+	DescriptionType() DescriptionType
+}
+
+type dibUnpackable interface {
+	util.Unpackable
+	DIB
+}
+
+// checkDIBHeader validates the header of a description information block. The DIB must not be
+// shorter than minLength. If exact is true, it must be exactly as long as minLength.
+func checkDIBHeader(data []byte, minLength int, exact bool) error {
+	if len(data) < 2 {
+		return io.ErrUnexpectedEOF
+	}
+
+	length := int(data[0])
+	if length < minLength || (exact && length != minLength) {
+		return ErrDIBLength
+	}
+
+	if length > len(data) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
+
+// MACAddress is a hardware address.
+type MACAddress [6]byte
+
+// String formats the address.
+func (mac MACAddress) String() string {
+	return fmt.Sprintf(
+		"%02x:%02x:%02x:%02x:%02x:%02x", mac[0], mac[1], mac[2], mac[3], mac[4], mac[5],
+	)
+}
+
+// Medium identifies a KNX medium.
+type Medium uint8
+
+// These are known KNX media.
+const (
+	MediumTP1   Medium = 0x02
+	MediumPL110 Medium = 0x04
+	MediumRF    Medium = 0x10
+	MediumIP    Medium = 0x20
+)
+
+// friendlyNameLength is the size of the friendly name in a device information block.
+const friendlyNameLength = 30
+
+// DeviceInfo is a description information block that describes the device hardware.
+type DeviceInfo struct {
+	Medium           Medium
+	ProgMode         bool
+	Address          cemi.IndividualAddr
+	ProjectID        uint16
+	SerialNumber     [6]byte
+	MulticastAddress Address
+	MAC              MACAddress
+	FriendlyName     string
+}
+
+// DescriptionType returns the description type for device information.
+func (DeviceInfo) DescriptionType() DescriptionType {
+	return DescriptionTypeDeviceInfo
+}
+
+// Size returns the packed size.
+func (DeviceInfo) Size() uint {
+	return 24 + friendlyNameLength
+}
+
+// Pack assembles the description information block in the given buffer.
+func (info *DeviceInfo) Pack(buffer []byte) {
+	var status uint8
+	if info.ProgMode {
+		status = 1
+	}
+
+	util.PackSome(
+		buffer,
+		uint8(info.Size()),
+		uint8(DescriptionTypeDeviceInfo),
+		uint8(info.Medium),
+		status,
+		uint16(info.Address),
+		info.ProjectID,
+		info.SerialNumber[:],
+		info.MulticastAddress[:],
+		info.MAC[:],
+	)
+
+	// The friendly name is encoded in ISO 8859-1 and padded with zeros.
+	name := buffer[24:info.Size()]
+	for i := range name {
+		name[i] = 0
+	}
+
+	i := 0
+	for _, char := range info.FriendlyName {
+		if i >= len(name) {
+			break
+		}
+
+		if char > 255 {
+			char = '?'
+		}
+
+		name[i] = byte(char)
+		i++
+	}
+}
+
+// Unpack parses the given data in order to initialize the structure.
+func (info *DeviceInfo) Unpack(data []byte) (uint, error) {
+	if err := checkDIBHeader(data, int(info.Size()), true); err != nil {
+		return 0, err
+	}
+
+	info.Medium = Medium(data[2])
+	info.ProgMode = data[3]&1 == 1
+	info.Address = cemi.IndividualAddr(uint16(data[4])<<8 | uint16(data[5]))
+	info.ProjectID = uint16(data[6])<<8 | uint16(data[7])
+	copy(info.SerialNumber[:], data[8:14])
+	copy(info.MulticastAddress[:], data[14:18])
+	copy(info.MAC[:], data[18:24])
+
+	var name []rune
+	for _, char := range data[24:info.Size()] {
+		if char == 0 {
+			break
+		}
+
+		name = append(name, rune(char))
+	}
+
+	info.FriendlyName = string(name)
+
+	return info.Size(), nil
+}
+
+// ServiceFamilyID identifies a family of KNXnet/IP services.
+type ServiceFamilyID uint8
+
+// These are known service families.
+const (
+	ServiceFamilyCore                ServiceFamilyID = 0x02
+	ServiceFamilyDeviceManagement    ServiceFamilyID = 0x03
+	ServiceFamilyTunnelling          ServiceFamilyID = 0x04
+	ServiceFamilyRouting             ServiceFamilyID = 0x05
+	ServiceFamilyRemoteLogging       ServiceFamilyID = 0x06
+	ServiceFamilyRemoteConfiguration ServiceFamilyID = 0x07
+	ServiceFamilyObjectServer        ServiceFamilyID = 0x08
+	ServiceFamilySecurity            ServiceFamilyID = 0x09
+)
+
+// A ServiceFamily is a family of KNXnet/IP services in a certain version.
+type ServiceFamily struct {
+	ID      ServiceFamilyID
+	Version uint8
+}
+
+// packFamilies assembles a description information block that lists service families.
+func packFamilies(buffer []byte, typ DescriptionType, families []ServiceFamily) {
+	buffer[0] = uint8(2 + 2*len(families))
+	buffer[1] = uint8(typ)
+
+	for i, family := range families {
+		buffer[2+2*i] = uint8(family.ID)
+		buffer[3+2*i] = family.Version
+	}
+}
+
+// unpackFamilies parses a description information block that lists service families.
+func unpackFamilies(data []byte) ([]ServiceFamily, uint, error) {
+	if err := checkDIBHeader(data, 2, false); err != nil {
+		return nil, 0, err
+	}
+
+	length := int(data[0])
+	if length%2 != 0 {
+		return nil, 0, ErrDIBLength
+	}
+
+	families := make([]ServiceFamily, (length-2)/2)
+	for i := range families {
+		families[i] = ServiceFamily{ServiceFamilyID(data[2+2*i]), data[3+2*i]}
+	}
+
+	return families, uint(length), nil
+}
+
+// familyVersion returns the version of the given service family, or 0 if it is not listed.
+func familyVersion(families []ServiceFamily, id ServiceFamilyID) uint8 {
+	for _, family := range families {
+		if family.ID == id {
+			return family.Version
+		}
+	}
+
+	return 0
+}
+
+// SupportedServices is a description information block that lists the supported service families.
+type SupportedServices []ServiceFamily
+
+// DescriptionType returns the description type for supported service families.
+func (SupportedServices) DescriptionType() DescriptionType {
+	return DescriptionTypeSupportedServices
+}
+
+// Size returns the packed size.
+func (families SupportedServices) Size() uint {
+	return 2 + 2*uint(len(families))
+}
+
+// Pack assembles the description information block in the given buffer.
+func (families SupportedServices) Pack(buffer []byte) {
+	packFamilies(buffer, DescriptionTypeSupportedServices, families)
+}
+
+// Unpack parses the given data in order to initialize the structure.
+func (families *SupportedServices) Unpack(data []byte) (n uint, err error) {
+	*families, n, err = unpackFamilies(data)
+	return
+}
+
+// Version returns the version of the given service family, or 0 if it is not supported.
+func (families SupportedServices) Version(id ServiceFamilyID) uint8 {
+	return familyVersion(families, id)
+}
+
+// SecuredServices is a description information block that lists the service families which are
+// only accessible using KNXnet/IP secure.
+type SecuredServices []ServiceFamily
+
+// DescriptionType returns the description type for secured service families.
+func (SecuredServices) DescriptionType() DescriptionType {
+	return DescriptionTypeSecuredServices
+}
+
+// Size returns the packed size.
+func (families SecuredServices) Size() uint {
+	return 2 + 2*uint(len(families))
+}
+
+// Pack assembles the description information block in the given buffer.
+func (families SecuredServices) Pack(buffer []byte) {
+	packFamilies(buffer, DescriptionTypeSecuredServices, families)
+}
+
+// Unpack parses the given data in order to initialize the structure.
+func (families *SecuredServices) Unpack(data []byte) (n uint, err error) {
+	*families, n, err = unpackFamilies(data)
+	return
+}
+
+// Version returns the version of the given service family, or 0 if it is not secured.
+func (families SecuredServices) Version(id ServiceFamilyID) uint8 {
+	return familyVersion(families, id)
+}
+
+// IPCapabilities describes the IP assignment methods that a device is capable of.
+type IPCapabilities uint8
+
+// These are the possible IP capabilities.
+const (
+	IPCapabilityBootP  IPCapabilities = 0x01
+	IPCapabilityDHCP   IPCapabilities = 0x02
+	IPCapabilityAutoIP IPCapabilities = 0x04
+)
+
+// IPAssignment determines how a device obtains its IP address.
+type IPAssignment uint8
+
+// These are the possible IP assignment methods.
+const (
+	IPAssignmentManual IPAssignment = 0x01
+	IPAssignmentBootP  IPAssignment = 0x02
+	IPAssignmentDHCP   IPAssignment = 0x04
+	IPAssignmentAutoIP IPAssignment = 0x08
+)
+
+// IPConfig is a description information block that contains the configured IP settings.
+type IPConfig struct {
+	IP           Address
+	SubnetMask   Address
+	Gateway      Address
+	Capabilities IPCapabilities
+	Assignment   IPAssignment
+}
+
+// DescriptionType returns the description type for IP configurations.
+func (IPConfig) DescriptionType() DescriptionType {
+	return DescriptionTypeIPConfig
+}
+
+// Size returns the packed size.
+func (IPConfig) Size() uint {
+	return 16
+}
+
+// Pack assembles the description information block in the given buffer.
+func (config *IPConfig) Pack(buffer []byte) {
+	util.PackSome(
+		buffer,
+		uint8(config.Size()),
+		uint8(DescriptionTypeIPConfig),
+		config.IP[:],
+		config.SubnetMask[:],
+		config.Gateway[:],
+		uint8(config.Capabilities),
+		uint8(config.Assignment),
+	)
+}
+
+// Unpack parses the given data in order to initialize the structure.
+func (config *IPConfig) Unpack(data []byte) (uint, error) {
+	if err := checkDIBHeader(data, int(config.Size()), true); err != nil {
+		return 0, err
+	}
+
+	copy(config.IP[:], data[2:6])
+	copy(config.SubnetMask[:], data[6:10])
+	copy(config.Gateway[:], data[10:14])
+	config.Capabilities = IPCapabilities(data[14])
+	config.Assignment = IPAssignment(data[15])
+
+	return config.Size(), nil
+}
+
+// IPCurrentConfig is a description information block that contains the IP settings in use.
+type IPCurrentConfig struct {
+	IP         Address
+	SubnetMask Address
+	Gateway    Address
+	DHCPServer Address
+	Assignment IPAssignment
+}
+
+// DescriptionType returns the description type for current IP configurations.
+func (IPCurrentConfig) DescriptionType() DescriptionType {
+	return DescriptionTypeIPCurrentConfig
+}
+
+// Size returns the packed size.
+func (IPCurrentConfig) Size() uint {
+	return 20
+}
+
+// Pack assembles the description information block in the given buffer.
+func (config *IPCurrentConfig) Pack(buffer []byte) {
+	util.PackSome(
+		buffer,
+		uint8(config.Size()),
+		uint8(DescriptionTypeIPCurrentConfig),
+		config.IP[:],
+		config.SubnetMask[:],
+		config.Gateway[:],
+		config.DHCPServer[:],
+		uint8(config.Assignment),
+		uint8(0),
+	)
+}
+
+// Unpack parses the given data in order to initialize the structure.
+func (config *IPCurrentConfig) Unpack(data []byte) (uint, error) {
+	if err := checkDIBHeader(data, int(config.Size()), true); err != nil {
+		return 0, err
+	}
+
+	copy(config.IP[:], data[2:6])
+	copy(config.SubnetMask[:], data[6:10])
+	copy(config.Gateway[:], data[10:14])
+	copy(config.DHCPServer[:], data[14:18])
+	config.Assignment = IPAssignment(data[18])
+
+	return config.Size(), nil
+}
+
+// KNXAddresses is a description information block that lists the individual addresses of a
+// device. The first one is the device's own address.
+type KNXAddresses []cemi.IndividualAddr
+
+// DescriptionType returns the description type for KNX addresses.
+func (KNXAddresses) DescriptionType() DescriptionType {
+	return DescriptionTypeKNXAddresses
+}
+
+// Size returns the packed size.
+func (addrs KNXAddresses) Size() uint {
+	return 2 + 2*uint(len(addrs))
+}
+
+// Pack assembles the description information block in the given buffer.
+func (addrs KNXAddresses) Pack(buffer []byte) {
+	buffer[0] = uint8(addrs.Size())
+	buffer[1] = uint8(DescriptionTypeKNXAddresses)
+
+	for i, addr := range addrs {
+		util.Pack(buffer[2+2*i:], uint16(addr))
+	}
+}
+
+// Unpack parses the given data in order to initialize the structure.
+func (addrs *KNXAddresses) Unpack(data []byte) (uint, error) {
+	if err := checkDIBHeader(data, 4, false); err != nil {
+		return 0, err
+	}
+
+	length := int(data[0])
+	if length%2 != 0 {
+		return 0, ErrDIBLength
+	}
+
+	*addrs = make(KNXAddresses, (length-2)/2)
+	for i := range *addrs {
+		(*addrs)[i] = cemi.IndividualAddr(uint16(data[2+2*i])<<8 | uint16(data[3+2*i]))
+	}
+
+	return uint(length), nil
+}
+
+// TunnelSlotStatus describes the state of a tunnelling slot.
+type TunnelSlotStatus uint16
+
+// These are the flags of a tunnelling slot status.
+const (
+	// TunnelSlotFree indicates that the slot is not in use.
+	TunnelSlotFree TunnelSlotStatus = 0x01
+
+	// TunnelSlotAuthorized indicates that the client is authorized to use the slot.
+	TunnelSlotAuthorized TunnelSlotStatus = 0x02
+
+	// TunnelSlotUsable indicates that the slot can be used at all.
+	TunnelSlotUsable TunnelSlotStatus = 0x04
+)
+
+// A TunnelSlot is a tunnelling connection that a device offers.
+type TunnelSlot struct {
+	// Address is the individual address which is assigned to a connection using this slot.
+	Address cemi.IndividualAddr
+	Status  TunnelSlotStatus
+}
+
+// Available determines whether a new tunnelling connection can use this slot.
+func (slot TunnelSlot) Available() bool {
+	return slot.Status&TunnelSlotFree != 0 && slot.Status&TunnelSlotUsable != 0
+}
+
+// TunnellingInfo is a description information block that lists the tunnelling slots of a device.
+type TunnellingInfo struct {
+	MaxAPDULength uint16
+	Slots         []TunnelSlot
+}
+
+// DescriptionType returns the description type for tunnelling information.
+func (TunnellingInfo) DescriptionType() DescriptionType {
+	return DescriptionTypeTunnellingInfo
+}
+
+// Size returns the packed size.
+func (info *TunnellingInfo) Size() uint {
+	return 4 + 4*uint(len(info.Slots))
+}
+
+// Pack assembles the description information block in the given buffer.
+func (info *TunnellingInfo) Pack(buffer []byte) {
+	util.PackSome(
+		buffer,
+		uint8(info.Size()),
+		uint8(DescriptionTypeTunnellingInfo),
+		info.MaxAPDULength,
+	)
+
+	for i, slot := range info.Slots {
+		util.PackSome(buffer[4+4*i:], uint16(slot.Address), uint16(slot.Status))
+	}
+}
+
+// Unpack parses the given data in order to initialize the structure.
+func (info *TunnellingInfo) Unpack(data []byte) (uint, error) {
+	if err := checkDIBHeader(data, 4, false); err != nil {
+		return 0, err
+	}
+
+	length := int(data[0])
+	if length%4 != 0 {
+		return 0, ErrDIBLength
+	}
+
+	info.MaxAPDULength = uint16(data[2])<<8 | uint16(data[3])
+	info.Slots = make([]TunnelSlot, (length-4)/4)
+
+	for i := range info.Slots {
+		slot := data[4+4*i:]
+		info.Slots[i] = TunnelSlot{
+			Address: cemi.IndividualAddr(uint16(slot[0])<<8 | uint16(slot[1])),
+			Status:  TunnelSlotStatus(uint16(slot[2])<<8 | uint16(slot[3])),
+		}
+	}
+
+	return uint(length), nil
+}
+
+// Available returns the slots that a new tunnelling connection can use.
+func (info *TunnellingInfo) Available() []TunnelSlot {
+	var slots []TunnelSlot
+	for _, slot := range info.Slots {
+		if slot.Available() {
+			slots = append(slots, slot)
+		}
+	}
+
+	return slots
+}
+
+// ManufacturerData is a description information block that contains manufacturer-specific data.
+type ManufacturerData struct {
+	Manufacturer uint16
+	Data         []byte
+}
+
+// DescriptionType returns the description type for manufacturer data.
+func (ManufacturerData) DescriptionType() DescriptionType {
+	return DescriptionTypeManufacturerData
+}
+
+// Size returns the packed size.
+func (md *ManufacturerData) Size() uint {
+	return 4 + uint(len(md.Data))
+}
+
+// Pack assembles the description information block in the given buffer.
+func (md *ManufacturerData) Pack(buffer []byte) {
+	util.PackSome(
+		buffer,
+		uint8(md.Size()),
+		uint8(DescriptionTypeManufacturerData),
+		md.Manufacturer,
+		md.Data,
+	)
+}
+
+// Unpack parses the given data in order to initialize the structure.
+func (md *ManufacturerData) Unpack(data []byte) (uint, error) {
+	if err := checkDIBHeader(data, 4, false); err != nil {
+		return 0, err
+	}
+
+	length := int(data[0])
+
+	md.Manufacturer = uint16(data[2])<<8 | uint16(data[3])
+	md.Data = make([]byte, length-4)
+	copy(md.Data, data[4:length])
+
+	return uint(length), nil
+}
+
+// UnknownDIB is a description information block of an unknown type.
+type UnknownDIB struct {
+	Type DescriptionType
+	Data []byte
+}
+
+// DescriptionType returns the description type.
+func (dib *UnknownDIB) DescriptionType() DescriptionType {
+	return dib.Type
+}
+
+// Size returns the packed size.
+func (dib *UnknownDIB) Size() uint {
+	return 2 + uint(len(dib.Data))
+}
+
+// Pack assembles the description information block in the given buffer.
+func (dib *UnknownDIB) Pack(buffer []byte) {
+	util.PackSome(buffer, uint8(dib.Size()), uint8(dib.Type), dib.Data)
+}
+
+// Unpack parses the given data in order to initialize the structure.
+func (dib *UnknownDIB) Unpack(data []byte) (uint, error) {
+	if err := checkDIBHeader(data, 2, false); err != nil {
+		return 0, err
+	}
+
+	length := int(data[0])
+
+	dib.Type = DescriptionType(data[1])
+	dib.Data = make([]byte, length-2)
+	copy(dib.Data, data[2:length])
+
+	return uint(length), nil
+}
+
+// DIBs is a sequence of description information blocks.
+type DIBs []DIB
+
+// Size returns the packed size.
+func (dibs DIBs) Size() uint {
+	var size uint
+	for _, dib := range dibs {
+		size += dib.Size()
+	}
+
+	return size
+}
+
+// Pack assembles the description information blocks in the given buffer.
+func (dibs DIBs) Pack(buffer []byte) {
+	var offset uint
+	for _, dib := range dibs {
+		dib.Pack(buffer[offset:])
+		offset += dib.Size()
+	}
+}
+
+// Unpack parses all description information blocks in the given data.
+func (dibs *DIBs) Unpack(data []byte) (n uint, err error) {
+	*dibs = nil
+
+	for n < uint(len(data)) {
+		rest := data[n:]
+
+		if len(rest) < 2 {
+			return n, io.ErrUnexpectedEOF
+		}
+
+		var dib dibUnpackable
+
+		switch DescriptionType(rest[1]) {
+		case DescriptionTypeDeviceInfo:
+			dib = &DeviceInfo{}
+
+		case DescriptionTypeSupportedServices:
+			dib = &SupportedServices{}
+
+		case DescriptionTypeIPConfig:
+			dib = &IPConfig{}
+
+		case DescriptionTypeIPCurrentConfig:
+			dib = &IPCurrentConfig{}
+
+		case DescriptionTypeKNXAddresses:
+			dib = &KNXAddresses{}
+
+		case DescriptionTypeSecuredServices:
+			dib = &SecuredServices{}
+
+		case DescriptionTypeTunnellingInfo:
+			dib = &TunnellingInfo{}
+
+		case DescriptionTypeManufacturerData:
+			dib = &ManufacturerData{}
+
+		default:
+			dib = &UnknownDIB{}
+		}
+
+		m, err := dib.Unpack(rest)
+		if err != nil {
+			return n, err
+		}
+
+		*dibs = append(*dibs, dib)
+		n += m
+	}
+
+	return n, nil
+}
+
+// find returns the first description information block of the given type.
+func (dibs DIBs) find(typ DescriptionType) DIB {
+	for _, dib := range dibs {
+		if dib.DescriptionType() == typ {
+			return dib
+		}
+	}
+
+	return nil
+}
+
+// DeviceInfo returns the device information, or nil if it is not present.
+func (dibs DIBs) DeviceInfo() *DeviceInfo {
+	info, _ := dibs.find(DescriptionTypeDeviceInfo).(*DeviceInfo)
+	return info
+}
+
+// SupportedServices returns the supported service families, or nil if they are not present.
+func (dibs DIBs) SupportedServices() SupportedServices {
+	if families, ok := dibs.find(DescriptionTypeSupportedServices).(*SupportedServices); ok {
+		return *families
+	}
+
+	return nil
+}
+
+// IPConfig returns the configured IP settings, or nil if they are not present.
+func (dibs DIBs) IPConfig() *IPConfig {
+	config, _ := dibs.find(DescriptionTypeIPConfig).(*IPConfig)
+	return config
+}
+
+// IPCurrentConfig returns the IP settings in use, or nil if they are not present.
+func (dibs DIBs) IPCurrentConfig() *IPCurrentConfig {
+	config, _ := dibs.find(DescriptionTypeIPCurrentConfig).(*IPCurrentConfig)
+	return config
+}
+
+// KNXAddresses returns the individual addresses, or nil if they are not present.
+func (dibs DIBs) KNXAddresses() KNXAddresses {
+	if addrs, ok := dibs.find(DescriptionTypeKNXAddresses).(*KNXAddresses); ok {
+		return *addrs
+	}
+
+	return nil
+}
+
+// SecuredServices returns the secured service families, or nil if they are not present.
+func (dibs DIBs) SecuredServices() SecuredServices {
+	if families, ok := dibs.find(DescriptionTypeSecuredServices).(*SecuredServices); ok {
+		return *families
+	}
+
+	return nil
+}
+
+// TunnellingInfo returns the tunnelling slots, or nil if they are not present.
+func (dibs DIBs) TunnellingInfo() *TunnellingInfo {
+	info, _ := dibs.find(DescriptionTypeTunnellingInfo).(*TunnellingInfo)
+	return info
+}
@@ -0,0 +1,145 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+func makeDIBs() DIBs {
+	return DIBs{
+		&DeviceInfo{
+			Medium:           MediumTP1,
+			ProgMode:         true,
+			Address:          0x1101,
+			ProjectID:        0x0011,
+			SerialNumber:     [6]byte{0, 1, 2, 3, 4, 5},
+			MulticastAddress: Address{224, 0, 23, 12},
+			MAC:              MACAddress{1, 2, 3, 4, 5, 6},
+			FriendlyName:     "Schaltaktor Küche",
+		},
+		&SupportedServices{
+			{ServiceFamilyCore, 1},
+			{ServiceFamilyTunnelling, 2},
+			{ServiceFamilyRemoteConfiguration, 1},
+		},
+		&IPConfig{
+			IP:           Address{192, 168, 1, 20},
+			SubnetMask:   Address{255, 255, 255, 0},
+			Gateway:      Address{192, 168, 1, 1},
+			Capabilities: IPCapabilityDHCP,
+			Assignment:   IPAssignmentManual,
+		},
+		&IPCurrentConfig{
+			IP:         Address{10, 0, 0, 5},
+			SubnetMask: Address{255, 0, 0, 0},
+			Assignment: IPAssignmentDHCP,
+		},
+		&KNXAddresses{0x1101, 0x1102},
+		&SecuredServices{{ServiceFamilyTunnelling, 1}},
+		&TunnellingInfo{
+			MaxAPDULength: 254,
+			Slots: []TunnelSlot{
+				{0x11F1, TunnelSlotUsable | TunnelSlotAuthorized},
+				{0x11F2, TunnelSlotUsable | TunnelSlotAuthorized | TunnelSlotFree},
+			},
+		},
+		&ManufacturerData{Manufacturer: 0x00C5, Data: []byte{1, 2}},
+		&UnknownDIB{Type: 0x42, Data: []byte{3, 4}},
+	}
+}
+
+func TestDIBs(t *testing.T) {
+	dibs := makeDIBs()
+
+	buffer := make([]byte, dibs.Size())
+	dibs.Pack(buffer)
+
+	var result DIBs
+	n, err := result.Unpack(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != dibs.Size() {
+		t.Errorf("Unexpected length: %d != %d", n, dibs.Size())
+	}
+
+	if !reflect.DeepEqual(result, dibs) {
+		t.Errorf("Unexpected result: %+v != %+v", result, dibs)
+	}
+
+	if info := result.DeviceInfo(); info == nil || info.FriendlyName != "Schaltaktor Küche" {
+		t.Errorf("Unexpected device info: %+v", info)
+	}
+
+	if version := result.SupportedServices().Version(ServiceFamilyTunnelling); version != 2 {
+		t.Errorf("Unexpected tunnelling version: %d", version)
+	}
+
+	if config := result.IPConfig(); config == nil || config.IP != (Address{192, 168, 1, 20}) {
+		t.Errorf("Unexpected IP config: %+v", config)
+	}
+
+	if addrs := result.KNXAddresses(); len(addrs) != 2 {
+		t.Errorf("Unexpected KNX addresses: %v", addrs)
+	}
+
+	if slots := result.TunnellingInfo().Available(); len(slots) != 1 || slots[0].Address != 0x11F2 {
+		t.Errorf("Unexpected available tunnelling slots: %v", slots)
+	}
+
+	t.Run("LongFriendlyName", func(t *testing.T) {
+		info := DeviceInfo{FriendlyName: "An unreasonably long friendly name ☃"}
+
+		buffer := make([]byte, info.Size())
+		info.Pack(buffer)
+
+		var result DeviceInfo
+		if _, err := result.Unpack(buffer); err != nil {
+			t.Fatal(err)
+		}
+
+		if result.FriendlyName != info.FriendlyName[:friendlyNameLength] {
+			t.Errorf("Unexpected friendly name: %q", result.FriendlyName)
+		}
+	})
+
+	t.Run("InvalidLength", func(t *testing.T) {
+		var result DIBs
+
+		// The IP config block is too short.
+		if _, err := result.Unpack([]byte{4, 3, 0, 0}); err != ErrDIBLength {
+			t.Fatal("Should not succeed")
+		}
+
+		// The block exceeds the data.
+		if _, err := result.Unpack([]byte{6, 0x42, 0, 0}); err != io.ErrUnexpectedEOF {
+			t.Fatal("Should not succeed")
+		}
+	})
+}
+
+func TestSelector(t *testing.T) {
+	info := DeviceInfo{MAC: MACAddress{1, 2, 3, 4, 5, 6}}
+
+	if !MACSelector(info.MAC).Matches(&info) {
+		t.Error("MAC selector does not match")
+	}
+
+	if MACSelector(MACAddress{1}).Matches(&info) {
+		t.Error("MAC selector matches a different device")
+	}
+
+	if ProgModeSelector().Matches(&info) {
+		t.Error("Programming mode selector matches a device that is not in programming mode")
+	}
+
+	var sel Selector
+	if _, err := sel.Unpack([]byte{2, 3}); err != ErrSelectorType {
+		t.Fatal("Should not succeed")
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// FaultRates determines the probabilities, ranging from 0 to 1, with which faults are injected into
+// packets that travel in one direction.
+type FaultRates struct {
+	// Drop is the probability with which a packet is discarded.
+	Drop float64
+
+	// Duplicate is the probability with which a packet is delivered twice.
+	Duplicate float64
+
+	// Delay is the probability with which a packet is held back for a random duration of up to
+	// MaxDelay. Other packets may overtake it in the meantime.
+	Delay float64
+
+	// Reorder is the probability with which a packet is swapped with the packet that follows it.
+	// If no packet follows within MaxDelay, the held packet is delivered on its own.
+	Reorder float64
+
+	// Corrupt is the probability with which a random bit in the payload of a packet is flipped.
+	// Corrupted inbound packets that can no longer be parsed are dropped, just like a UDP socket
+	// would do.
+	Corrupt float64
+
+	// MaxDelay is the upper bound for delays. Delay and reordering require it to be positive.
+	MaxDelay time.Duration
+}
+
+// A FaultConfig configures a FaultySocket.
+type FaultConfig struct {
+	// Outbound faults are applied to packets passed to Send.
+	Outbound FaultRates
+
+	// Inbound faults are applied to packets received from the underlying socket.
+	Inbound FaultRates
+
+	// Seed initializes the random sources. Given the same sequence of packets, the same faults are
+	// injected.
+	Seed int64
+}
+
+// rawPacket is an encoded KNXnet/IP packet that can be sent as it is.
+type rawPacket []byte
+
+// Service returns the service identifier from the packet header.
+func (packet rawPacket) Service() ServiceID {
+	return ServiceID(packet[2])<<8 | ServiceID(packet[3])
+}
+
+// Size returns the size of the packet body.
+func (packet rawPacket) Size() uint {
+	return uint(len(packet) - 6)
+}
+
+// Pack copies the packet body into the buffer.
+func (packet rawPacket) Pack(buffer []byte) {
+	copy(buffer, packet[6:])
+}
+
+// AppendPack appends the packet body to dst.
+func (packet rawPacket) AppendPack(dst []byte) ([]byte, error) {
+	return append(dst, packet[6:]...), nil
+}
+
+// A faultStage injects faults into the packets that travel in one direction.
+type faultStage struct {
+	rates   FaultRates
+	deliver func(Service) error
+	decode  bool
+
+	mu    sync.Mutex
+	rand  *rand.Rand
+	held  Service
+	flush *time.Timer
+}
+
+// newFaultStage creates a fault stage. If decode is true, corrupted packets are parsed again
+// before they are delivered; otherwise they are delivered in their encoded form.
+func newFaultStage(
+	rates FaultRates,
+	seed int64,
+	decode bool,
+	deliver func(Service) error,
+) *faultStage {
+	return &faultStage{
+		rates:   rates,
+		deliver: deliver,
+		decode:  decode,
+		rand:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+// chance rolls the dice. The caller must hold the lock.
+func (stage *faultStage) chance(probability float64) bool {
+	return probability > 0 && stage.rand.Float64() < probability
+}
+
+// corrupt flips a random bit in the packet body. The caller must hold the lock.
+func (stage *faultStage) corrupt(srv Service) (Service, bool) {
+	packet, ok := srv.(rawPacket)
+	if !ok {
+		packable, ok := srv.(ServicePackable)
+		if !ok {
+			return srv, true
+		}
+
+		var err error
+		if packet, err = AppendPack(nil, packable); err != nil {
+			return srv, true
+		}
+	}
+
+	if len(packet) <= 6 {
+		return srv, true
+	}
+
+	packet[6+stage.rand.Intn(len(packet)-6)] ^= 1 << uint(stage.rand.Intn(8))
+
+	if !stage.decode {
+		return packet, true
+	}
+
+	var result Service
+	if _, err := Unpack(packet, &result); err != nil {
+		return nil, false
+	}
+
+	return result, true
+}
+
+// releaseHeld delivers the packet that has been held back for reordering. The caller must hold
+// the lock.
+func (stage *faultStage) releaseHeld() {
+	if stage.held == nil {
+		return
+	}
+
+	stage.flush.Stop()
+	stage.deliver(stage.held)
+	stage.held = nil
+}
+
+// process injects faults into the packet and delivers whatever remains of it. The returned error
+// stems from the delivery of the packet, if it has been delivered immediately.
+func (stage *faultStage) process(srv Service) (err error) {
+	stage.mu.Lock()
+	defer stage.mu.Unlock()
+
+	if stage.chance(stage.rates.Drop) {
+		return
+	}
+
+	if stage.chance(stage.rates.Corrupt) {
+		var ok bool
+		if srv, ok = stage.corrupt(srv); !ok {
+			return
+		}
+	}
+
+	copies := 1
+	if stage.chance(stage.rates.Duplicate) {
+		copies = 2
+	}
+
+	maxDelay := int64(stage.rates.MaxDelay)
+
+	for i := 0; i < copies; i++ {
+		switch {
+		case maxDelay > 0 && stage.chance(stage.rates.Delay):
+			delayed := srv
+			time.AfterFunc(time.Duration(stage.rand.Int63n(maxDelay)), func() {
+				stage.mu.Lock()
+				defer stage.mu.Unlock()
+
+				stage.deliver(delayed)
+			})
+
+		case maxDelay > 0 && stage.chance(stage.rates.Reorder):
+			stage.releaseHeld()
+
+			stage.held = srv
+			stage.flush = time.AfterFunc(stage.rates.MaxDelay, func() {
+				stage.mu.Lock()
+				defer stage.mu.Unlock()
+
+				stage.releaseHeld()
+			})
+
+		default:
+			err = stage.deliver(srv)
+			stage.releaseHeld()
+		}
+	}
+
+	return
+}
+
+// A FaultySocket is a Socket decorator that drops, duplicates, delays, reorders or corrupts
+// packets. It is meant to simulate lossy links in tests.
+type FaultySocket struct {
+	sock     Socket
+	outbound *faultStage
+	inbound  *faultStage
+
+	queue  *queue
+	output chan Service
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewFaultySocket wraps the given socket. The FaultySocket takes ownership of the socket.
+func NewFaultySocket(sock Socket, config FaultConfig) *FaultySocket {
+	faulty := &FaultySocket{
+		sock:   sock,
+		queue:  newQueue(),
+		output: make(chan Service),
+		done:   make(chan struct{}),
+	}
+
+	faulty.outbound = newFaultStage(config.Outbound, config.Seed, false, faulty.transmit)
+	faulty.inbound = newFaultStage(config.Inbound, config.Seed+1, true, func(srv Service) error {
+		faulty.queue.push(srv)
+		return nil
+	})
+
+	go faulty.receive()
+	go faulty.serve()
+
+	return faulty
+}
+
+// transmit sends a packet through the underlying socket.
+func (faulty *FaultySocket) transmit(srv Service) error {
+	err := faulty.sock.Send(srv.(ServicePackable))
+	if err != nil {
+		util.Log(faulty, "Error during Send: %v", err)
+	}
+
+	return err
+}
+
+// receive injects faults into the packets that arrive at the underlying socket.
+func (faulty *FaultySocket) receive() {
+	for srv := range faulty.sock.Inbound() {
+		faulty.inbound.process(srv)
+	}
+
+	faulty.queue.close()
+}
+
+// serve relays the packets that survived to the inbound channel.
+func (faulty *FaultySocket) serve() {
+	util.Log(faulty, "Started worker")
+	defer util.Log(faulty, "Worker exited")
+
+	defer close(faulty.output)
+
+	for {
+		srv, ok := faulty.queue.pop()
+		if !ok {
+			return
+		}
+
+		select {
+		case <-faulty.done:
+			return
+
+		case faulty.output <- srv.(Service):
+		}
+	}
+}
+
+// Send transmits a KNXnet/IP packet after injecting faults. The packet is encoded right away, so
+// the payload may be modified after Send returns, even if the packet is delivered later. Errors
+// that occur when sending delayed packets are only logged.
+func (faulty *FaultySocket) Send(payload ServicePackable) error {
+	packet, err := AppendPack(nil, payload)
+	if err != nil {
+		return err
+	}
+
+	return faulty.outbound.process(rawPacket(packet))
+}
+
+// Inbound provides a channel from which you can retrieve incoming packets.
+func (faulty *FaultySocket) Inbound() <-chan Service {
+	return faulty.output
+}
+
+// Close closes the underlying socket.
+func (faulty *FaultySocket) Close() error {
+	var err error
+
+	faulty.once.Do(func() {
+		err = faulty.sock.Close()
+		faulty.queue.close()
+		close(faulty.done)
+	})
+
+	return err
+}
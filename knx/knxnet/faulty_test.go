@@ -0,0 +1,168 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sendThroughFaults sends count disconnect responses through a faulty socket and returns the
+// channels of the responses that arrived at the other end.
+func sendThroughFaults(t *testing.T, config FaultConfig, count int) []uint8 {
+	bus := NewBus(1)
+
+	sender := NewFaultySocket(bus.Join(BusLink{}), config)
+	defer sender.Close()
+
+	receiver := bus.Join(BusLink{})
+	defer receiver.Close()
+
+	for i := 0; i < count; i++ {
+		if err := sender.Send(&DiscRes{Channel: uint8(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var channels []uint8
+
+	for {
+		select {
+		case srv := <-receiver.Inbound():
+			if res, ok := srv.(*DiscRes); ok {
+				channels = append(channels, res.Channel)
+			}
+
+		case <-time.After(50 * time.Millisecond):
+			return channels
+		}
+	}
+}
+
+func TestFaultySocket(t *testing.T) {
+	t.Run("Drop", func(t *testing.T) {
+		config := FaultConfig{Outbound: FaultRates{Drop: 1}}
+
+		if channels := sendThroughFaults(t, config, 10); len(channels) > 0 {
+			t.Errorf("Expected all packets to be dropped, got %v", channels)
+		}
+	})
+
+	t.Run("Deterministic", func(t *testing.T) {
+		config := FaultConfig{Outbound: FaultRates{Drop: 0.5}, Seed: 42}
+
+		first := sendThroughFaults(t, config, 50)
+		second := sendThroughFaults(t, config, 50)
+
+		if len(first) == 0 || len(first) == 50 {
+			t.Errorf("Unexpected number of surviving packets: %d", len(first))
+		}
+
+		if !reflect.DeepEqual(first, second) {
+			t.Errorf("Different results for the same seed: %v != %v", first, second)
+		}
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		config := FaultConfig{Outbound: FaultRates{Duplicate: 1}}
+
+		channels := sendThroughFaults(t, config, 5)
+		if !reflect.DeepEqual(channels, []uint8{0, 0, 1, 1, 2, 2, 3, 3, 4, 4}) {
+			t.Errorf("Unexpected packets: %v", channels)
+		}
+	})
+
+	t.Run("Reorder", func(t *testing.T) {
+		config := FaultConfig{
+			Outbound: FaultRates{Reorder: 0.5, MaxDelay: 10 * time.Millisecond},
+			Seed:     3,
+		}
+
+		channels := sendThroughFaults(t, config, 20)
+		if len(channels) != 20 {
+			t.Fatalf("Expected 20 packets, got %v", channels)
+		}
+
+		inOrder := true
+		for i, channel := range channels {
+			if channel != uint8(i) {
+				inOrder = false
+			}
+		}
+
+		if inOrder {
+			t.Error("Packets have not been reordered")
+		}
+	})
+
+	t.Run("Corrupt", func(t *testing.T) {
+		config := FaultConfig{Outbound: FaultRates{Corrupt: 1}}
+
+		channels := sendThroughFaults(t, config, 1)
+		if len(channels) == 1 && channels[0] == 0 {
+			t.Error("Packet has not been corrupted")
+		}
+	})
+
+	t.Run("ModifiedAfterSend", func(t *testing.T) {
+		bus := NewBus(1)
+
+		sender := NewFaultySocket(bus.Join(BusLink{}), FaultConfig{
+			Outbound: FaultRates{Delay: 1, MaxDelay: 10 * time.Millisecond},
+		})
+		defer sender.Close()
+
+		receiver := bus.Join(BusLink{})
+		defer receiver.Close()
+
+		res := &DiscRes{Channel: 1}
+		if err := sender.Send(res); err != nil {
+			t.Fatal(err)
+		}
+
+		// The delayed packet must carry the contents at the time of sending.
+		res.Channel = 2
+
+		select {
+		case srv := <-receiver.Inbound():
+			if res, ok := srv.(*DiscRes); !ok || res.Channel != 1 {
+				t.Fatalf("Expected channel 1, got %+v", srv)
+			}
+
+		case <-time.After(time.Second):
+			t.Fatal("Packet has not been delivered")
+		}
+	})
+
+	t.Run("Unpackable", func(t *testing.T) {
+		sender := NewFaultySocket(NewBus(1).Join(BusLink{}), FaultConfig{
+			Outbound: FaultRates{Corrupt: 1},
+		})
+		defer sender.Close()
+
+		if err := sender.Send(nil); err == nil {
+			t.Fatal("Expected an error for an unpackable payload")
+		}
+	})
+
+	t.Run("InboundClosed", func(t *testing.T) {
+		bus := NewBus(1)
+
+		inner := bus.Join(BusLink{})
+		faulty := NewFaultySocket(inner, FaultConfig{})
+
+		inner.Close()
+
+		select {
+		case _, open := <-faulty.Inbound():
+			if open {
+				t.Fatal("Unexpected packet")
+			}
+
+		case <-time.After(time.Second):
+			t.Fatal("Inbound channel has not been closed")
+		}
+	})
+}
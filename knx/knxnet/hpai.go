@@ -62,6 +62,13 @@ func (info *HostInfo) Pack(buffer []byte) {
 	)
 }
 
+// AppendPack appends the host info structure to dst.
+func (info *HostInfo) AppendPack(dst []byte) ([]byte, error) {
+	dst = append(dst, 8, uint8(info.Protocol))
+	dst = append(dst, info.Address[:]...)
+	return util.AppendUint16(dst, uint16(info.Port)), nil
+}
+
 // Unpack parses the given data in order to initialize the structure.
 func (info *HostInfo) Unpack(data []byte) (n uint, err error) {
 	var length uint8
@@ -28,11 +28,20 @@ const (
 	ConnStateResService ServiceID = 0x0208
 	DiscReqService      ServiceID = 0x0209
 	DiscResService      ServiceID = 0x020a
+	SearchReqExtService ServiceID = 0x020b
+	SearchResExtService ServiceID = 0x020c
 	TunnelReqService    ServiceID = 0x0420
 	TunnelResService    ServiceID = 0x0421
 	RoutingIndService   ServiceID = 0x0530
 	RoutingLostService  ServiceID = 0x0531
 	RoutingBusyService  ServiceID = 0x0532
+
+	RoutingSystemBroadcastService ServiceID = 0x0533
+
+	RemoteDiagReqService   ServiceID = 0x0740
+	RemoteDiagResService   ServiceID = 0x0741
+	RemoteConfigReqService ServiceID = 0x0742
+	RemoteResetReqService  ServiceID = 0x0743
 )
 
 // Service describes a KNXnet/IP service.
@@ -67,6 +76,11 @@ func (us *UnknownService) Pack(buffer []byte) {
 	copy(buffer, us.Data)
 }
 
+// AppendPack appends the payload to dst.
+func (us *UnknownService) AppendPack(dst []byte) ([]byte, error) {
+	return append(dst, us.Data...), nil
+}
+
 // Unpack copies the entire data.
 func (us *UnknownService) Unpack(data []byte) (uint, error) {
 	us.Data = make([]byte, len(data))
@@ -94,6 +108,31 @@ func AllocAndPack(srv ServicePackable) []byte {
 	return buffer
 }
 
+// AppendPack appends a KNXnet/IP packet to dst. Unlike Pack, it does not panic when the service is
+// incomplete, but returns an error instead. In that case, dst is returned unaltered.
+//
+// By reusing the buffer, packets can be generated without allocating memory:
+//
+// 	buffer, err = AppendPack(buffer[:0], srv)
+//
+func AppendPack(dst []byte, srv ServicePackable) ([]byte, error) {
+	if srv == nil {
+		return dst, util.ErrNilPackable
+	}
+
+	srvID := srv.Service()
+	result, err := util.AppendPack(append(dst, 6, 16, byte(srvID>>8), byte(srvID), 0, 0), srv)
+	if err != nil {
+		return dst, err
+	}
+
+	totalLen := len(result) - len(dst)
+	result[len(dst)+4] = byte(totalLen >> 8)
+	result[len(dst)+5] = byte(totalLen)
+
+	return result, nil
+}
+
 // These are errors that might occur during unpacking.
 var (
 	ErrHeaderLength  = errors.New("Header length is not 6")
@@ -105,6 +144,68 @@ type serviceUnpackable interface {
 	Service
 }
 
+// newService allocates the service structure for the given service identifier.
+func newService(srvID ServiceID) serviceUnpackable {
+	switch srvID {
+	case ConnReqService:
+		return &ConnReq{}
+
+	case ConnResService:
+		return &ConnRes{}
+
+	case ConnStateReqService:
+		return &ConnStateReq{}
+
+	case ConnStateResService:
+		return &ConnStateRes{}
+
+	case DiscReqService:
+		return &DiscReq{}
+
+	case DiscResService:
+		return &DiscRes{}
+
+	case SearchReqExtService:
+		return &SearchReqExt{}
+
+	case SearchResExtService:
+		return &SearchResExt{}
+
+	case TunnelReqService:
+		return &TunnelReq{}
+
+	case TunnelResService:
+		return &TunnelRes{}
+
+	case RoutingIndService:
+		return &RoutingInd{}
+
+	case RoutingLostService:
+		return &RoutingLost{}
+
+	case RoutingBusyService:
+		return &RoutingBusy{}
+
+	case RoutingSystemBroadcastService:
+		return &RoutingSystemBroadcast{}
+
+	case RemoteDiagReqService:
+		return &RemoteDiagReq{}
+
+	case RemoteDiagResService:
+		return &RemoteDiagRes{}
+
+	case RemoteConfigReqService:
+		return &RemoteConfigReq{}
+
+	case RemoteResetReqService:
+		return &RemoteResetReq{}
+
+	default:
+		return &UnknownService{service: srvID}
+	}
+}
+
 // Unpack parses a KNXnet/IP packet and retrieves its service payload.
 //
 // On success, the variable pointed to by srv will contain a pointer to a service type.
@@ -145,44 +246,7 @@ func Unpack(data []byte, srv *Service) (uint, error) {
 		return n, ErrHeaderVersion
 	}
 
-	var body serviceUnpackable
-	switch srvID {
-	case ConnReqService:
-		body = &ConnReq{}
-
-	case ConnResService:
-		body = &ConnRes{}
-
-	case ConnStateReqService:
-		body = &ConnStateReq{}
-
-	case ConnStateResService:
-		body = &ConnStateRes{}
-
-	case DiscReqService:
-		body = &DiscReq{}
-
-	case DiscResService:
-		body = &DiscRes{}
-
-	case TunnelReqService:
-		body = &TunnelReq{}
-
-	case TunnelResService:
-		body = &TunnelRes{}
-
-	case RoutingIndService:
-		body = &RoutingInd{}
-
-	case RoutingLostService:
-		body = &RoutingLost{}
-
-	case RoutingBusyService:
-		body = &RoutingBusy{}
-
-	default:
-		body = &UnknownService{service: srvID}
-	}
+	body := newService(srvID)
 
 	m, err := body.Unpack(data[n:])
 
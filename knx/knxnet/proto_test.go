@@ -4,16 +4,17 @@
 package knxnet
 
 import (
+	"bytes"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/vapourismo/knx-go/knx/cemi"
 	"github.com/vapourismo/knx-go/knx/util"
 )
 
-func BenchmarkPack(b *testing.B) {
-	b.ReportAllocs()
-
-	req := &TunnelReq{
+func makeTunnelReq() *TunnelReq {
+	return &TunnelReq{
 		Channel:   1,
 		SeqNumber: 0,
 		Payload: &cemi.LDataReq{
@@ -30,8 +31,229 @@ func BenchmarkPack(b *testing.B) {
 			},
 		},
 	}
+}
+
+func makeServices() []ServicePackable {
+	control := HostInfo{Protocol: UDP4, Address: Address{192, 168, 1, 2}, Port: 3671}
+
+	return []ServicePackable{
+		&ConnReq{Control: control, Tunnel: control, Layer: TunnelLayerData},
+		&ConnRes{Channel: 1, Control: control},
+		&ConnRes{Channel: 1, Status: ErrNoMoreConnections},
+		&ConnStateReq{Channel: 1, Control: control},
+		&ConnStateRes{Channel: 1, Status: ErrConnectionID},
+		&DiscReq{Channel: 1, Control: control},
+		&DiscRes{Channel: 1},
+		&SearchReqExt{Discovery: control, Params: []SearchParam{
+			SelectMAC(MACAddress{1, 2, 3, 4, 5, 6}),
+			SelectService(ServiceFamilyTunnelling, 2),
+			RequestDIBs(DescriptionTypeTunnellingInfo),
+		}},
+		&SearchResExt{Control: control, DIBs: makeDIBs()},
+		makeTunnelReq(),
+		&TunnelRes{Channel: 1, SeqNumber: 2},
+		&RoutingInd{Payload: &cemi.LDataInd{LData: cemi.LData{Data: &cemi.ControlData{}}}},
+		&RoutingLost{Status: DeviceStateKNXError, Count: 1000},
+		&RoutingBusy{WaitTime: 100 * time.Millisecond, Control: 1},
+		&RoutingSystemBroadcast{Payload: &cemi.LDataInd{LData: cemi.LData{Data: &cemi.ControlData{}}}},
+		&RemoteDiagReq{Discovery: control, Selector: ProgModeSelector()},
+		&RemoteDiagRes{Selector: MACSelector(MACAddress{1, 2, 3, 4, 5, 6}), DIBs: makeDIBs()},
+		&RemoteConfigReq{Discovery: control, Selector: ProgModeSelector(), DIBs: makeDIBs()[2:3]},
+		&RemoteResetReq{Selector: MACSelector(MACAddress{1, 2, 3, 4, 5, 6}), Mode: ResetMaster},
+		&UnknownService{service: 0x0801, Data: []byte{1, 2, 3}},
+	}
+}
+
+func TestAppendPack(t *testing.T) {
+	t.Run("Services", func(t *testing.T) {
+		buffer := []byte{0xFF}
+
+		for _, srv := range makeServices() {
+			result, err := AppendPack(buffer, srv)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(result[1:], AllocAndPack(srv)) {
+				t.Errorf("Unexpected packet for %T: %v != %v", srv, result[1:], AllocAndPack(srv))
+			}
+
+			buffer = result[:1]
+		}
+	})
+
+	t.Run("NoPayload", func(t *testing.T) {
+		dst := []byte{1, 2, 3}
+
+		result, err := AppendPack(dst, &TunnelReq{})
+		if err == nil {
+			t.Fatal("Should not succeed")
+		}
+
+		if !bytes.Equal(result, dst) {
+			t.Error("Buffer has been altered:", result)
+		}
+	})
+}
+
+func TestDecoder_Unpack(t *testing.T) {
+	var dec Decoder
+
+	for _, srv := range makeServices() {
+		packet := AllocAndPack(srv)
+
+		var expected Service
+		expectedNum, err := Unpack(packet, &expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var result Service
+		num, err := dec.Unpack(packet, &result)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if num != expectedNum {
+			t.Error("Unexpected length:", num, expectedNum)
+		}
+
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("Unexpected result: %+v != %+v", result, expected)
+		}
+	}
+
+	t.Run("InvalidHeader", func(t *testing.T) {
+		var srv Service
+
+		if _, err := dec.Unpack([]byte{6, 16, 4}, &srv); err == nil {
+			t.Fatal("Should not succeed")
+		}
+
+		if _, err := dec.Unpack([]byte{6, 17, 4, 32, 0, 6}, &srv); err != ErrHeaderVersion {
+			t.Fatal("Should not succeed")
+		}
+
+		if srv != nil {
+			t.Error("Result has been assigned:", srv)
+		}
+	})
+}
+
+func TestDecoder_UnpackInto(t *testing.T) {
+	var dec Decoder
+	var srv Service
+
+	// Decoding the services twice makes sure that reused structures are overwritten correctly.
+	for _, services := range [][]ServicePackable{makeServices(), makeServices()} {
+		for _, expected := range services {
+			packet := AllocAndPack(expected)
+
+			var unpacked Service
+			expectedNum, err := Unpack(packet, &unpacked)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			num, err := dec.UnpackInto(packet, &srv)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if num != expectedNum {
+				t.Error("Unexpected length:", num, expectedNum)
+			}
+
+			if !reflect.DeepEqual(srv, unpacked) {
+				t.Errorf("Unexpected result: %+v != %+v", srv, unpacked)
+			}
+		}
+	}
+
+	t.Run("Retain", func(t *testing.T) {
+		first := makeTunnelReq()
+		second := makeTunnelReq()
+		second.SeqNumber = 1
+
+		var retained, srv Service
+		if _, err := dec.UnpackInto(AllocAndPack(first), &retained); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := dec.UnpackInto(AllocAndPack(second), &srv); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(retained, Service(first)) {
+			t.Errorf("Retained service has been altered: %+v", retained)
+		}
+	})
+}
+
+func BenchmarkPack(b *testing.B) {
+	b.ReportAllocs()
+
+	req := makeTunnelReq()
 
 	for i := 0; i < b.N; i++ {
 		util.AllocAndPack(req)
 	}
 }
+
+func BenchmarkAppendPack(b *testing.B) {
+	b.ReportAllocs()
+
+	req := makeTunnelReq()
+
+	var buffer []byte
+	for i := 0; i < b.N; i++ {
+		buffer, _ = AppendPack(buffer[:0], req)
+	}
+}
+
+func BenchmarkUnpack(b *testing.B) {
+	b.ReportAllocs()
+
+	packet := AllocAndPack(makeTunnelReq())
+
+	for i := 0; i < b.N; i++ {
+		var srv Service
+		Unpack(packet, &srv)
+	}
+}
+
+func BenchmarkDecoder_Unpack(b *testing.B) {
+	b.ReportAllocs()
+
+	packet := AllocAndPack(makeTunnelReq())
+
+	var dec Decoder
+	for i := 0; i < b.N; i++ {
+		var srv Service
+		dec.Unpack(packet, &srv)
+	}
+}
+
+func BenchmarkDecoder_UnpackInto(b *testing.B) {
+	packet := AllocAndPack(makeTunnelReq())
+
+	var dec Decoder
+
+	b.Run("New", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			var srv Service
+			dec.UnpackInto(packet, &srv)
+		}
+	})
+
+	b.Run("Reuse", func(b *testing.B) {
+		b.ReportAllocs()
+
+		var srv Service
+		for i := 0; i < b.N; i++ {
+			dec.UnpackInto(packet, &srv)
+		}
+	})
+}
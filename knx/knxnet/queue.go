@@ -0,0 +1,64 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"container/list"
+	"sync"
+)
+
+// A queue is an unbounded FIFO queue. Once it has been closed, pushing has no effect and popping
+// does not block anymore.
+type queue struct {
+	cond   *sync.Cond
+	items  *list.List
+	closed bool
+}
+
+// newQueue creates an empty queue.
+func newQueue() *queue {
+	return &queue{
+		cond:  sync.NewCond(&sync.Mutex{}),
+		items: list.New(),
+	}
+}
+
+// push appends an item to the queue.
+func (q *queue) push(item interface{}) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	if q.closed {
+		return
+	}
+
+	q.items.PushBack(item)
+	q.cond.Broadcast()
+}
+
+// pop waits for the next item. It returns false once the queue has been closed.
+func (q *queue) pop() (interface{}, bool) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	for !q.closed && q.items.Len() < 1 {
+		q.cond.Wait()
+	}
+
+	if q.closed {
+		return nil, false
+	}
+
+	return q.items.Remove(q.items.Front()), true
+}
+
+// close discards the remaining items and wakes up all waiting consumers.
+func (q *queue) close() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	q.closed = true
+	q.items.Init()
+	q.cond.Broadcast()
+}
@@ -0,0 +1,229 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"errors"
+	"io"
+
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// SelectorType determines which devices shall respond to a remote diagnosis or configuration
+// request.
+type SelectorType uint8
+
+const (
+	// SelectorProgMode selects the devices that are in programming mode.
+	SelectorProgMode SelectorType = 0x01
+
+	// SelectorMAC selects the device with a certain MAC address.
+	SelectorMAC SelectorType = 0x02
+)
+
+// ErrSelectorType is returned when a selector has an unknown type.
+var ErrSelectorType = errors.New("Selector type is unknown")
+
+// A Selector determines the devices that a remote diagnosis or configuration request is meant for.
+type Selector struct {
+	Type SelectorType
+
+	// MAC is the address of the selected device. It is only used by the MAC selector.
+	MAC MACAddress
+}
+
+// ProgModeSelector selects the devices that are in programming mode.
+func ProgModeSelector() Selector {
+	return Selector{Type: SelectorProgMode}
+}
+
+// MACSelector selects the device with the given MAC address.
+func MACSelector(mac MACAddress) Selector {
+	return Selector{Type: SelectorMAC, MAC: mac}
+}
+
+// Size returns the packed size.
+func (sel *Selector) Size() uint {
+	if sel.Type == SelectorMAC {
+		return 8
+	}
+
+	return 2
+}
+
+// Pack assembles the selector in the given buffer.
+func (sel *Selector) Pack(buffer []byte) {
+	buffer[0] = uint8(sel.Size())
+	buffer[1] = uint8(sel.Type)
+
+	if sel.Type == SelectorMAC {
+		copy(buffer[2:], sel.MAC[:])
+	}
+}
+
+// Unpack parses the given data in order to initialize the structure.
+func (sel *Selector) Unpack(data []byte) (uint, error) {
+	if len(data) < 2 {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	sel.Type = SelectorType(data[1])
+
+	switch sel.Type {
+	case SelectorProgMode, SelectorMAC:
+	default:
+		return 0, ErrSelectorType
+	}
+
+	size := sel.Size()
+	if uint(data[0]) != size {
+		return 0, errors.New("Selector structure length is invalid")
+	}
+
+	if uint(len(data)) < size {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	sel.MAC = MACAddress{}
+	if sel.Type == SelectorMAC {
+		copy(sel.MAC[:], data[2:8])
+	}
+
+	return size, nil
+}
+
+// Matches determines whether the device described by the given device information is selected.
+func (sel Selector) Matches(info *DeviceInfo) bool {
+	switch sel.Type {
+	case SelectorProgMode:
+		return info.ProgMode
+
+	case SelectorMAC:
+		return info.MAC == sel.MAC
+
+	default:
+		return false
+	}
+}
+
+// A RemoteDiagReq requests the configuration of the selected devices. Responses are sent to the
+// discovery endpoint, which is usually the KNXnet/IP system setup multicast address, so that
+// devices with wrong IP settings can be reached.
+type RemoteDiagReq struct {
+	Discovery HostInfo
+	Selector  Selector
+}
+
+// Service returns the service identifier for remote diagnostic requests.
+func (RemoteDiagReq) Service() ServiceID {
+	return RemoteDiagReqService
+}
+
+// Size returns the packed size.
+func (req *RemoteDiagReq) Size() uint {
+	return hostInfoSize + req.Selector.Size()
+}
+
+// Pack assembles the service payload in the given buffer.
+func (req *RemoteDiagReq) Pack(buffer []byte) {
+	util.PackSome(buffer, &req.Discovery, &req.Selector)
+}
+
+// Unpack parses the given service payload in order to initialize the structure.
+func (req *RemoteDiagReq) Unpack(data []byte) (uint, error) {
+	return util.UnpackSome(data, &req.Discovery, &req.Selector)
+}
+
+// A RemoteDiagRes is a response to a remote diagnostic request. It carries the description of the
+// responding device.
+type RemoteDiagRes struct {
+	Selector Selector
+	DIBs     DIBs
+}
+
+// Service returns the service identifier for remote diagnostic responses.
+func (RemoteDiagRes) Service() ServiceID {
+	return RemoteDiagResService
+}
+
+// Size returns the packed size.
+func (res *RemoteDiagRes) Size() uint {
+	return res.Selector.Size() + res.DIBs.Size()
+}
+
+// Pack assembles the service payload in the given buffer.
+func (res *RemoteDiagRes) Pack(buffer []byte) {
+	util.PackSome(buffer, &res.Selector, res.DIBs)
+}
+
+// Unpack parses the given service payload in order to initialize the structure.
+func (res *RemoteDiagRes) Unpack(data []byte) (uint, error) {
+	return util.UnpackSome(data, &res.Selector, &res.DIBs)
+}
+
+// A RemoteConfigReq instructs the selected devices to adopt the given configuration, e.g. an
+// IPConfig description information block. Devices do not respond to it.
+type RemoteConfigReq struct {
+	Discovery HostInfo
+	Selector  Selector
+	DIBs      DIBs
+}
+
+// Service returns the service identifier for remote basic configuration requests.
+func (RemoteConfigReq) Service() ServiceID {
+	return RemoteConfigReqService
+}
+
+// Size returns the packed size.
+func (req *RemoteConfigReq) Size() uint {
+	return hostInfoSize + req.Selector.Size() + req.DIBs.Size()
+}
+
+// Pack assembles the service payload in the given buffer.
+func (req *RemoteConfigReq) Pack(buffer []byte) {
+	util.PackSome(buffer, &req.Discovery, &req.Selector, req.DIBs)
+}
+
+// Unpack parses the given service payload in order to initialize the structure.
+func (req *RemoteConfigReq) Unpack(data []byte) (uint, error) {
+	return util.UnpackSome(data, &req.Discovery, &req.Selector, &req.DIBs)
+}
+
+// ResetMode determines how a device is reset.
+type ResetMode uint8
+
+const (
+	// ResetRestart restarts the device.
+	ResetRestart ResetMode = 0x01
+
+	// ResetMaster restores the factory settings of the device.
+	ResetMaster ResetMode = 0x02
+)
+
+// A RemoteResetReq instructs the selected devices to reset. Devices do not respond to it.
+type RemoteResetReq struct {
+	Selector Selector
+	Mode     ResetMode
+}
+
+// Service returns the service identifier for remote reset requests.
+func (RemoteResetReq) Service() ServiceID {
+	return RemoteResetReqService
+}
+
+// Size returns the packed size.
+func (req *RemoteResetReq) Size() uint {
+	return req.Selector.Size() + 2
+}
+
+// Pack assembles the service payload in the given buffer.
+func (req *RemoteResetReq) Pack(buffer []byte) {
+	util.PackSome(buffer, &req.Selector, uint8(req.Mode), uint8(0))
+}
+
+// Unpack parses the given service payload in order to initialize the structure.
+func (req *RemoteResetReq) Unpack(data []byte) (uint, error) {
+	var reserved uint8
+	return util.UnpackSome(data, &req.Selector, (*uint8)(&req.Mode), &reserved)
+}
@@ -31,6 +31,11 @@ func (ind *RoutingInd) Pack(buffer []byte) {
 	cemi.Pack(buffer, ind.Payload)
 }
 
+// AppendPack appends the service payload to dst.
+func (ind *RoutingInd) AppendPack(dst []byte) ([]byte, error) {
+	return cemi.AppendPack(dst, ind.Payload)
+}
+
 // Unpack parses the given service payload in order to initialize the structure.
 func (ind *RoutingInd) Unpack(data []byte) (uint, error) {
 	return cemi.Unpack(data, &ind.Payload)
@@ -81,6 +86,21 @@ func (RoutingLost) Service() ServiceID {
 	return RoutingLostService
 }
 
+// Size returns the packed size.
+func (RoutingLost) Size() uint {
+	return 4
+}
+
+// Pack assembles the service payload in the given buffer.
+func (rl *RoutingLost) Pack(buffer []byte) {
+	util.PackSome(buffer, uint8(4), uint8(rl.Status), rl.Count)
+}
+
+// AppendPack appends the service payload to dst.
+func (rl *RoutingLost) AppendPack(dst []byte) ([]byte, error) {
+	return util.AppendUint16(append(dst, 4, uint8(rl.Status)), rl.Count), nil
+}
+
 // Unpack parses the given service payload in order to initialize the structure.
 func (rl *RoutingLost) Unpack(data []byte) (uint, error) {
 	var length uint8
@@ -106,6 +126,24 @@ func (RoutingBusy) Service() ServiceID {
 	return RoutingBusyService
 }
 
+// Size returns the packed size.
+func (RoutingBusy) Size() uint {
+	return 6
+}
+
+// Pack assembles the service payload in the given buffer.
+func (rl *RoutingBusy) Pack(buffer []byte) {
+	util.PackSome(
+		buffer, uint8(6), uint8(rl.Status), uint16(rl.WaitTime/time.Millisecond), rl.Control,
+	)
+}
+
+// AppendPack appends the service payload to dst.
+func (rl *RoutingBusy) AppendPack(dst []byte) ([]byte, error) {
+	dst = util.AppendUint16(append(dst, 6, uint8(rl.Status)), uint16(rl.WaitTime/time.Millisecond))
+	return util.AppendUint16(dst, rl.Control), nil
+}
+
 // Unpack parses the given service payload in order to initialize the structure.
 func (rl *RoutingBusy) Unpack(data []byte) (n uint, err error) {
 	var length uint8
@@ -123,3 +161,34 @@ func (rl *RoutingBusy) Unpack(data []byte) (n uint, err error) {
 
 	return
 }
+
+// A RoutingSystemBroadcast carries a frame in system broadcast mode, i.e. a broadcast frame whose
+// Control1NoSysBroadcast flag is cleared. Routers forward it regardless of their filter tables.
+type RoutingSystemBroadcast struct {
+	Payload cemi.Message
+}
+
+// Service returns the service identifier for routing system broadcasts.
+func (RoutingSystemBroadcast) Service() ServiceID {
+	return RoutingSystemBroadcastService
+}
+
+// Size returns the packed size.
+func (sb *RoutingSystemBroadcast) Size() uint {
+	return cemi.Size(sb.Payload)
+}
+
+// Pack assembles the service payload in the given buffer.
+func (sb *RoutingSystemBroadcast) Pack(buffer []byte) {
+	cemi.Pack(buffer, sb.Payload)
+}
+
+// AppendPack appends the service payload to dst.
+func (sb *RoutingSystemBroadcast) AppendPack(dst []byte) ([]byte, error) {
+	return cemi.AppendPack(dst, sb.Payload)
+}
+
+// Unpack parses the given service payload in order to initialize the structure.
+func (sb *RoutingSystemBroadcast) Unpack(data []byte) (uint, error) {
+	return cemi.Unpack(data, &sb.Payload)
+}
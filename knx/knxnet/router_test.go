@@ -0,0 +1,47 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testRoundTrip packs the service, compares the packet with the expected one and unpacks it again.
+func testRoundTrip(t *testing.T, srv ServicePackable, expected []byte) {
+	packet := AllocAndPack(srv)
+	if !bytes.Equal(packet, expected) {
+		t.Fatalf("Expected %v, got %v", expected, packet)
+	}
+
+	var result Service
+	num, err := Unpack(packet, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if num != uint(len(packet)) {
+		t.Errorf("Expected %d bytes to be consumed, got %d", len(packet), num)
+	}
+
+	if !reflect.DeepEqual(result, srv) {
+		t.Errorf("Expected %+v, got %+v", srv, result)
+	}
+}
+
+func TestRoutingLost(t *testing.T) {
+	testRoundTrip(t, &RoutingLost{Status: DeviceStateKNXError, Count: 0x0102}, []byte{
+		0x06, 0x10, 0x05, 0x31, 0x00, 0x0A,
+		0x04, 0x01, 0x01, 0x02,
+	})
+}
+
+func TestRoutingBusy(t *testing.T) {
+	testRoundTrip(t, &RoutingBusy{WaitTime: 100 * time.Millisecond, Control: 0x0001}, []byte{
+		0x06, 0x10, 0x05, 0x32, 0x00, 0x0C,
+		0x06, 0x00, 0x00, 0x64, 0x00, 0x01,
+	})
+}
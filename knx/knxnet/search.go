@@ -0,0 +1,283 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"errors"
+	"io"
+
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// SearchParamType identifies the kind of a search request parameter (SRP).
+type SearchParamType uint8
+
+// These are known search request parameter types.
+const (
+	SearchParamProgMode SearchParamType = 0x02
+	SearchParamMAC      SearchParamType = 0x03
+	SearchParamService  SearchParamType = 0x04
+	SearchParamDIBs     SearchParamType = 0x05
+)
+
+// searchParamMandatory is the flag in the type field that marks a parameter as mandatory.
+const searchParamMandatory = 0x80
+
+// ErrSearchParamLength is returned when a search request parameter has an invalid length.
+var ErrSearchParamLength = errors.New("Search request parameter has an invalid length")
+
+// A SearchParam restricts the devices that respond to an extended search request, or requests
+// additional description information blocks. Devices which do not understand a mandatory parameter
+// do not respond at all.
+type SearchParam struct {
+	Type      SearchParamType
+	Mandatory bool
+
+	// MAC is the address of the selected device. It is only used by SearchParamMAC.
+	MAC MACAddress
+
+	// Service is the service family that a device must support in at least the given version. It is
+	// only used by SearchParamService.
+	Service ServiceFamily
+
+	// DIBs lists the description types that are requested. It is only used by SearchParamDIBs.
+	DIBs []DescriptionType
+}
+
+// SelectProgMode selects the devices that are in programming mode.
+func SelectProgMode() SearchParam {
+	return SearchParam{Type: SearchParamProgMode, Mandatory: true}
+}
+
+// SelectMAC selects the device with the given MAC address.
+func SelectMAC(mac MACAddress) SearchParam {
+	return SearchParam{Type: SearchParamMAC, Mandatory: true, MAC: mac}
+}
+
+// SelectService selects the devices that support the given service family in at least the given
+// version.
+func SelectService(id ServiceFamilyID, minVersion uint8) SearchParam {
+	return SearchParam{
+		Type:      SearchParamService,
+		Mandatory: true,
+		Service:   ServiceFamily{id, minVersion},
+	}
+}
+
+// RequestDIBs requests the given description information blocks in addition to the device
+// information and supported service families.
+func RequestDIBs(types ...DescriptionType) SearchParam {
+	return SearchParam{Type: SearchParamDIBs, Mandatory: true, DIBs: types}
+}
+
+// Size returns the packed size.
+func (param *SearchParam) Size() uint {
+	switch param.Type {
+	case SearchParamMAC:
+		return 8
+
+	case SearchParamService:
+		return 4
+
+	case SearchParamDIBs:
+		// The list is padded to an even length.
+		return 2 + (uint(len(param.DIBs))+1)/2*2
+
+	default:
+		return 2
+	}
+}
+
+// Pack assembles the search request parameter in the given buffer.
+func (param *SearchParam) Pack(buffer []byte) {
+	size := param.Size()
+
+	buffer[0] = uint8(size)
+	buffer[1] = uint8(param.Type)
+
+	if param.Mandatory {
+		buffer[1] |= searchParamMandatory
+	}
+
+	switch param.Type {
+	case SearchParamMAC:
+		copy(buffer[2:], param.MAC[:])
+
+	case SearchParamService:
+		buffer[2] = uint8(param.Service.ID)
+		buffer[3] = param.Service.Version
+
+	case SearchParamDIBs:
+		for i := uint(2); i < size; i++ {
+			buffer[i] = 0
+		}
+
+		for i, typ := range param.DIBs {
+			buffer[2+i] = uint8(typ)
+		}
+	}
+}
+
+// Unpack parses the given data in order to initialize the structure. Parameters of an unknown type
+// retain only their type.
+func (param *SearchParam) Unpack(data []byte) (uint, error) {
+	if len(data) < 2 {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	length := uint(data[0])
+	if length < 2 {
+		return 0, ErrSearchParamLength
+	}
+
+	if length > uint(len(data)) {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	*param = SearchParam{
+		Type:      SearchParamType(data[1] &^ searchParamMandatory),
+		Mandatory: data[1]&searchParamMandatory != 0,
+	}
+
+	switch param.Type {
+	case SearchParamProgMode:
+		if length != 2 {
+			return 0, ErrSearchParamLength
+		}
+
+	case SearchParamMAC:
+		if length != 8 {
+			return 0, ErrSearchParamLength
+		}
+
+		copy(param.MAC[:], data[2:8])
+
+	case SearchParamService:
+		if length != 4 {
+			return 0, ErrSearchParamLength
+		}
+
+		param.Service = ServiceFamily{ServiceFamilyID(data[2]), data[3]}
+
+	case SearchParamDIBs:
+		if length%2 != 0 {
+			return 0, ErrSearchParamLength
+		}
+
+		for _, typ := range data[2:length] {
+			// Zeros are padding.
+			if typ != 0 {
+				param.DIBs = append(param.DIBs, DescriptionType(typ))
+			}
+		}
+	}
+
+	return length, nil
+}
+
+// Matches determines whether the device described by the given DIBs is selected by the parameter.
+// Parameters that do not select devices match every device.
+func (param SearchParam) Matches(dibs DIBs) bool {
+	switch param.Type {
+	case SearchParamProgMode:
+		info := dibs.DeviceInfo()
+		return info != nil && info.ProgMode
+
+	case SearchParamMAC:
+		info := dibs.DeviceInfo()
+		return info != nil && info.MAC == param.MAC
+
+	case SearchParamService:
+		version := dibs.SupportedServices().Version(param.Service.ID)
+		return version != 0 && version >= param.Service.Version
+
+	default:
+		return true
+	}
+}
+
+// A SearchReqExt is an extended search request. Devices that satisfy all of its parameters respond
+// to the discovery endpoint. Use a route-back discovery endpoint (all zeros, except the protocol)
+// in order to receive the responses at the sending socket.
+type SearchReqExt struct {
+	Discovery HostInfo
+	Params    []SearchParam
+}
+
+// Service returns the service identifier for extended search requests.
+func (SearchReqExt) Service() ServiceID {
+	return SearchReqExtService
+}
+
+// Size returns the packed size.
+func (req *SearchReqExt) Size() uint {
+	size := hostInfoSize
+	for i := range req.Params {
+		size += req.Params[i].Size()
+	}
+
+	return size
+}
+
+// Pack assembles the service payload in the given buffer.
+func (req *SearchReqExt) Pack(buffer []byte) {
+	req.Discovery.Pack(buffer)
+
+	offset := hostInfoSize
+	for i := range req.Params {
+		req.Params[i].Pack(buffer[offset:])
+		offset += req.Params[i].Size()
+	}
+}
+
+// Unpack parses the given service payload in order to initialize the structure.
+func (req *SearchReqExt) Unpack(data []byte) (uint, error) {
+	n, err := req.Discovery.Unpack(data)
+	if err != nil {
+		return n, err
+	}
+
+	req.Params = nil
+
+	for n < uint(len(data)) {
+		var param SearchParam
+
+		m, err := param.Unpack(data[n:])
+		if err != nil {
+			return n, err
+		}
+
+		req.Params = append(req.Params, param)
+		n += m
+	}
+
+	return n, nil
+}
+
+// A SearchResExt is a response to an extended search request. It carries the control endpoint of
+// the responding device and its description.
+type SearchResExt struct {
+	Control HostInfo
+	DIBs    DIBs
+}
+
+// Service returns the service identifier for extended search responses.
+func (SearchResExt) Service() ServiceID {
+	return SearchResExtService
+}
+
+// Size returns the packed size.
+func (res *SearchResExt) Size() uint {
+	return hostInfoSize + res.DIBs.Size()
+}
+
+// Pack assembles the service payload in the given buffer.
+func (res *SearchResExt) Pack(buffer []byte) {
+	util.PackSome(buffer, &res.Control, res.DIBs)
+}
+
+// Unpack parses the given service payload in order to initialize the structure.
+func (res *SearchResExt) Unpack(data []byte) (uint, error) {
+	return util.UnpackSome(data, &res.Control, &res.DIBs)
+}
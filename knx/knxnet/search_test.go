@@ -0,0 +1,66 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestSearchParam(t *testing.T) {
+	t.Run("Padding", func(t *testing.T) {
+		param := RequestDIBs(DescriptionTypeTunnellingInfo)
+
+		buffer := make([]byte, param.Size())
+		param.Pack(buffer)
+
+		if !bytes.Equal(buffer, []byte{4, 0x85, 0x07, 0}) {
+			t.Fatalf("Unexpected parameter: %v", buffer)
+		}
+
+		var result SearchParam
+		if _, err := result.Unpack(buffer); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(result, param) {
+			t.Errorf("Unexpected result: %+v != %+v", result, param)
+		}
+	})
+
+	t.Run("InvalidLength", func(t *testing.T) {
+		var result SearchParam
+		if _, err := result.Unpack([]byte{4, 0x83, 1, 2}); err != ErrSearchParamLength {
+			t.Fatal("Should not succeed")
+		}
+	})
+
+	t.Run("Matches", func(t *testing.T) {
+		dibs := DIBs{
+			&DeviceInfo{MAC: MACAddress{1, 2, 3, 4, 5, 6}},
+			&SupportedServices{{ServiceFamilyTunnelling, 1}},
+		}
+
+		if !SelectMAC(MACAddress{1, 2, 3, 4, 5, 6}).Matches(dibs) {
+			t.Error("MAC parameter does not match")
+		}
+
+		if SelectProgMode().Matches(dibs) {
+			t.Error("Programming mode parameter matches")
+		}
+
+		if !SelectService(ServiceFamilyTunnelling, 1).Matches(dibs) {
+			t.Error("Service parameter does not match")
+		}
+
+		if SelectService(ServiceFamilyTunnelling, 2).Matches(dibs) {
+			t.Error("Service parameter matches an older version")
+		}
+
+		if SelectService(ServiceFamilyRouting, 0).Matches(dibs) {
+			t.Error("Service parameter matches an unsupported service family")
+		}
+	})
+}
@@ -5,6 +5,7 @@ package knxnet
 
 import (
 	"net"
+	"sync"
 	"time"
 
 	"github.com/vapourismo/knx-go/knx/util"
@@ -17,6 +18,18 @@ type Socket interface {
 	Close() error
 }
 
+// maxPacketSize is the size of the receive buffers and the initial size of the send buffers.
+const maxPacketSize = 1024
+
+// bufferPool holds the buffers that the UDP sockets use to assemble outgoing packets. This spares
+// the garbage collector a buffer per packet.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		buffer := make([]byte, 0, maxPacketSize)
+		return &buffer
+	},
+}
+
 // TunnelSocket is a UDP socket for KNXnet/IP packet exchange.
 type TunnelSocket struct {
 	conn    *net.UDPConn
@@ -46,11 +59,18 @@ func DialTunnel(address string) (*TunnelSocket, error) {
 
 // Send transmits a KNXnet/IP packet.
 func (sock *TunnelSocket) Send(payload ServicePackable) error {
-	buffer := make([]byte, Size(payload))
-	Pack(buffer, payload)
+	buffer := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buffer)
+
+	packet, err := AppendPack((*buffer)[:0], payload)
+	if err != nil {
+		return err
+	}
+
+	*buffer = packet[:0]
 
 	// Transmission of the buffer contents
-	_, err := sock.conn.Write(buffer)
+	_, err = sock.conn.Write(packet)
 	return err
 }
 
@@ -94,11 +114,18 @@ func ListenRouter(multicastAddress string) (*RouterSocket, error) {
 
 // Send transmits a KNXnet/IP packet.
 func (sock *RouterSocket) Send(payload ServicePackable) error {
-	buffer := make([]byte, Size(payload))
-	Pack(buffer, payload)
+	buffer := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buffer)
+
+	packet, err := AppendPack((*buffer)[:0], payload)
+	if err != nil {
+		return err
+	}
+
+	*buffer = packet[:0]
 
 	// Transmission of the buffer contents
-	_, err := sock.conn.WriteToUDP(buffer, sock.addr)
+	_, err = sock.conn.WriteToUDP(packet, sock.addr)
 	return err
 }
 
@@ -112,6 +139,63 @@ func (sock *RouterSocket) Close() error {
 	return sock.conn.Close()
 }
 
+// SearchSocket is a UDP socket which sends KNXnet/IP packets to a multicast group, but receives the
+// responses on its own unicast address. It is meant for search requests with a route-back
+// discovery endpoint.
+type SearchSocket struct {
+	conn    *net.UDPConn
+	addr    *net.UDPAddr
+	inbound <-chan Service
+}
+
+// ListenSearch creates a new Socket which sends packets to the given multicast group and receives
+// packets from any endpoint.
+func ListenSearch(multicastAddress string) (*SearchSocket, error) {
+	addr, err := net.ResolveUDPAddr("udp4", multicastAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	inbound := make(chan Service)
+	go serveUDPSocket(conn, nil, inbound)
+
+	return &SearchSocket{conn, addr, inbound}, nil
+}
+
+// Send transmits a KNXnet/IP packet.
+func (sock *SearchSocket) Send(payload ServicePackable) error {
+	buffer := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buffer)
+
+	packet, err := AppendPack((*buffer)[:0], payload)
+	if err != nil {
+		return err
+	}
+
+	*buffer = packet[:0]
+
+	// Transmission of the buffer contents
+	_, err = sock.conn.WriteToUDP(packet, sock.addr)
+	return err
+}
+
+// Inbound provides a channel from which you can retrieve incoming packets.
+func (sock *SearchSocket) Inbound() <-chan Service {
+	return sock.inbound
+}
+
+// Close shuts the socket down. This will indirectly terminate the associated workers.
+func (sock *SearchSocket) Close() error {
+	return sock.conn.Close()
+}
+
 // serveUDPSocket is the receiver worker for a UDP socket.
 func serveUDPSocket(conn *net.UDPConn, addr *net.UDPAddr, inbound chan<- Service) {
 	util.Log(conn, "Started worker")
@@ -120,7 +204,11 @@ func serveUDPSocket(conn *net.UDPConn, addr *net.UDPAddr, inbound chan<- Service
 	// A closed inbound channel indicates to its readers that the worker has terminated.
 	defer close(inbound)
 
-	buffer := [1024]byte{}
+	buffer := [maxPacketSize]byte{}
+
+	// Packets are decoded into new services, because the readers of the inbound channel may retain
+	// them. The decoder parses the packets without intermediate allocations.
+	var dec Decoder
 
 	for {
 		len, sender, err := conn.ReadFromUDP(buffer[:])
@@ -136,7 +224,7 @@ func serveUDPSocket(conn *net.UDPConn, addr *net.UDPAddr, inbound chan<- Service
 		}
 
 		var payload Service
-		_, err = Unpack(buffer[:len], &payload)
+		_, err = dec.UnpackInto(buffer[:len], &payload)
 		if err != nil {
 			util.Log(conn, "Error during Unpack: %v", err)
 			continue
@@ -0,0 +1,43 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"net"
+	"testing"
+)
+
+// BenchmarkTunnelSocket_receive measures the receive loop of a UDP socket, from reading a routing
+// indication off the wire to handing it to the inbound channel.
+func BenchmarkTunnelSocket_receive(b *testing.B) {
+	gateway, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		b.Skip(err)
+	}
+
+	defer gateway.Close()
+
+	sock, err := DialTunnel(gateway.LocalAddr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer sock.Close()
+
+	client := sock.conn.LocalAddr().(*net.UDPAddr)
+	packet := AllocAndPack(makeTunnelReq())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := gateway.WriteToUDP(packet, client); err != nil {
+			b.Fatal(err)
+		}
+
+		if _, ok := (<-sock.Inbound()).(*TunnelReq); !ok {
+			b.Fatal("Unexpected packet")
+		}
+	}
+}
@@ -5,6 +5,7 @@ package knxnet
 
 import (
 	"errors"
+	"io"
 
 	"github.com/vapourismo/knx-go/knx/cemi"
 	"github.com/vapourismo/knx-go/knx/util"
@@ -41,6 +42,11 @@ func (req *TunnelReq) Pack(buffer []byte) {
 	cemi.Pack(buffer[4:], req.Payload)
 }
 
+// AppendPack appends the service payload to dst.
+func (req *TunnelReq) AppendPack(dst []byte) ([]byte, error) {
+	return cemi.AppendPack(append(dst, 4, req.Channel, req.SeqNumber, 0), req.Payload)
+}
+
 // Unpack parses the given service payload in order to initialize the structure.
 func (req *TunnelReq) Unpack(data []byte) (n uint, err error) {
 	var length, reserved uint8
@@ -61,6 +67,23 @@ func (req *TunnelReq) Unpack(data []byte) (n uint, err error) {
 	return
 }
 
+// unpackReusing is like Unpack, but it reuses the memory of the payload.
+func (req *TunnelReq) unpackReusing(data []byte, dec *cemi.Decoder) (uint, error) {
+	if len(data) < 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	if data[0] != 4 {
+		return 4, errors.New("Length header is not 4")
+	}
+
+	req.Channel = data[1]
+	req.SeqNumber = data[2]
+
+	n, err := dec.UnpackInto(data[4:], &req.Payload)
+	return 4 + n, err
+}
+
 // A TunnelRes is a response to a TunnelRequest. It acts as an acknowledgement.
 type TunnelRes struct {
 	// Communication channel
@@ -91,6 +114,11 @@ func (res *TunnelRes) Pack(buffer []byte) {
 	buffer[3] = uint8(res.Status)
 }
 
+// AppendPack appends the service payload to dst.
+func (res *TunnelRes) AppendPack(dst []byte) ([]byte, error) {
+	return append(dst, 4, res.Channel, res.SeqNumber, uint8(res.Status)), nil
+}
+
 // Unpack parses the given service payload in order to initialize the structure.
 func (res *TunnelRes) Unpack(data []byte) (n uint, err error) {
 	var length uint8
@@ -0,0 +1,369 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Package knxtest provides utilities to test clients of KNXnet/IP gateways.
+package knxtest
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// These are errors that can be returned by the Gateway.
+var (
+	ErrNotConnected = errors.New("No client is connected")
+	ErrAckTimeout   = errors.New("Client did not acknowledge the tunnel request")
+	ErrNoFrame      = errors.New("No frame has been received")
+)
+
+// A Gateway is a scriptable KNXnet/IP tunnelling server that listens on a local UDP port. By
+// default it accepts connection requests, acknowledges tunnel requests and answers heartbeats.
+type Gateway struct {
+	conn *net.UDPConn
+
+	mu          sync.Mutex
+	client      *net.UDPAddr
+	channel     uint8
+	connected   bool
+	sendSeq     uint8
+	recvSeq     uint8
+	connections int
+	heartbeats  int
+
+	connStatus      knxnet.ErrCode
+	heartbeatStatus knxnet.ErrCode
+	noHeartbeats    bool
+	ackDelay        time.Duration
+	dropAcks        int
+
+	// AckTimeout is the time Inject waits for the client to acknowledge a tunnel request.
+	AckTimeout time.Duration
+
+	acks     chan *knxnet.TunnelRes
+	received chan cemi.Message
+	wait     sync.WaitGroup
+}
+
+// NewGateway starts a Gateway on a random UDP port of the loopback interface.
+func NewGateway() (*Gateway, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return nil, err
+	}
+
+	gw := &Gateway{
+		conn:       conn,
+		AckTimeout: time.Second,
+		acks:       make(chan *knxnet.TunnelRes, 16),
+		received:   make(chan cemi.Message, 256),
+	}
+
+	gw.wait.Add(1)
+	go gw.serve()
+
+	return gw, nil
+}
+
+// Addr returns the address on which the gateway listens. Pass it to knx.NewTunnel.
+func (gw *Gateway) Addr() string {
+	return gw.conn.LocalAddr().String()
+}
+
+// Close stops the gateway without notifying the client.
+func (gw *Gateway) Close() error {
+	err := gw.conn.Close()
+	gw.wait.Wait()
+
+	return err
+}
+
+// SetConnStatus determines the status with which connection requests are answered. Pass
+// knxnet.NoError to accept connections, or an error such as knxnet.ErrNoMoreConnections to refuse
+// them.
+func (gw *Gateway) SetConnStatus(status knxnet.ErrCode) {
+	gw.mu.Lock()
+	gw.connStatus = status
+	gw.mu.Unlock()
+}
+
+// SetHeartbeatStatus determines the status with which connection state requests for the active
+// channel are answered.
+func (gw *Gateway) SetHeartbeatStatus(status knxnet.ErrCode) {
+	gw.mu.Lock()
+	gw.heartbeatStatus = status
+	gw.mu.Unlock()
+}
+
+// AnswerHeartbeats enables or disables responses to connection state requests.
+func (gw *Gateway) AnswerHeartbeats(answer bool) {
+	gw.mu.Lock()
+	gw.noHeartbeats = !answer
+	gw.mu.Unlock()
+}
+
+// DelayAcks delays the acknowledgement of tunnel requests by the given duration.
+func (gw *Gateway) DelayAcks(delay time.Duration) {
+	gw.mu.Lock()
+	gw.ackDelay = delay
+	gw.mu.Unlock()
+}
+
+// DropAcks ignores the next count tunnel requests. They are neither acknowledged nor recorded.
+func (gw *Gateway) DropAcks(count int) {
+	gw.mu.Lock()
+	gw.dropAcks = count
+	gw.mu.Unlock()
+}
+
+// Connections returns the number of connections which have been accepted so far.
+func (gw *Gateway) Connections() int {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+
+	return gw.connections
+}
+
+// Heartbeats returns the number of connection state requests which have been received so far.
+func (gw *Gateway) Heartbeats() int {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+
+	return gw.heartbeats
+}
+
+// Connected determines whether a client is connected.
+func (gw *Gateway) Connected() bool {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+
+	return gw.connected
+}
+
+// Received returns the channel which transmits the payload of each tunnel request that has been
+// acknowledged. Repeated requests are not transmitted twice.
+func (gw *Gateway) Received() <-chan cemi.Message {
+	return gw.received
+}
+
+// NextFrame waits for the next frame received from the client.
+func (gw *Gateway) NextFrame(timeout time.Duration) (cemi.Message, error) {
+	select {
+	case msg := <-gw.received:
+		return msg, nil
+
+	case <-time.After(timeout):
+		return nil, ErrNoFrame
+	}
+}
+
+// Disconnect sends a disconnect request to the client and considers the connection terminated.
+func (gw *Gateway) Disconnect() error {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+
+	if !gw.connected {
+		return ErrNotConnected
+	}
+
+	gw.connected = false
+
+	return gw.send(&knxnet.DiscReq{
+		Channel: gw.channel,
+		Control: gw.hostInfo(),
+	})
+}
+
+// Inject sends a tunnel request with the given payload to the client and waits for its
+// acknowledgement.
+func (gw *Gateway) Inject(msg cemi.Message) error {
+	gw.mu.Lock()
+
+	if !gw.connected {
+		gw.mu.Unlock()
+		return ErrNotConnected
+	}
+
+	req := &knxnet.TunnelReq{Channel: gw.channel, SeqNumber: gw.sendSeq, Payload: msg}
+	gw.sendSeq++
+
+	err := gw.send(req)
+	gw.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	timeout := time.After(gw.AckTimeout)
+
+	for {
+		select {
+		case res := <-gw.acks:
+			if res.Channel == req.Channel && res.SeqNumber == req.SeqNumber {
+				if res.Status != knxnet.NoError {
+					return res.Status
+				}
+
+				return nil
+			}
+
+		case <-timeout:
+			return ErrAckTimeout
+		}
+	}
+}
+
+// hostInfo describes the gateway's endpoint. The caller must hold the lock.
+func (gw *Gateway) hostInfo() knxnet.HostInfo {
+	addr := gw.conn.LocalAddr().(*net.UDPAddr)
+
+	info := knxnet.HostInfo{Protocol: knxnet.UDP4, Port: knxnet.Port(addr.Port)}
+	copy(info.Address[:], addr.IP.To4())
+
+	return info
+}
+
+// send transmits a packet to the client. The caller must hold the lock.
+func (gw *Gateway) send(srv knxnet.ServicePackable) error {
+	if gw.client == nil {
+		return ErrNotConnected
+	}
+
+	_, err := gw.conn.WriteToUDP(knxnet.AllocAndPack(srv), gw.client)
+	return err
+}
+
+// handleConnReq accepts or refuses a connection request.
+func (gw *Gateway) handleConnReq(sender *net.UDPAddr) {
+	gw.client = sender
+
+	if gw.connStatus != knxnet.NoError {
+		gw.send(&knxnet.ConnRes{Status: gw.connStatus})
+		return
+	}
+
+	gw.channel++
+	gw.connected = true
+	gw.connections++
+	gw.sendSeq = 0
+	gw.recvSeq = 0
+
+	gw.send(&knxnet.ConnRes{Channel: gw.channel, Status: knxnet.NoError, Control: gw.hostInfo()})
+}
+
+// handleConnStateReq answers a heartbeat, unless heartbeats are to be ignored.
+func (gw *Gateway) handleConnStateReq(req *knxnet.ConnStateReq) {
+	gw.heartbeats++
+
+	if gw.noHeartbeats {
+		return
+	}
+
+	status := gw.heartbeatStatus
+	if !gw.connected || req.Channel != gw.channel {
+		status = knxnet.ErrConnectionID
+	}
+
+	gw.send(&knxnet.ConnStateRes{Channel: req.Channel, Status: status})
+}
+
+// handleTunnelReq records and acknowledges a tunnel request.
+func (gw *Gateway) handleTunnelReq(req *knxnet.TunnelReq) {
+	if !gw.connected || req.Channel != gw.channel {
+		return
+	}
+
+	if gw.dropAcks > 0 {
+		gw.dropAcks--
+		return
+	}
+
+	switch req.SeqNumber {
+	case gw.recvSeq:
+		gw.recvSeq++
+
+		select {
+		case gw.received <- req.Payload:
+		default:
+			util.Log(gw, "Discarding received frame because nobody consumes them")
+		}
+
+	case gw.recvSeq - 1:
+		// The client repeated a request whose acknowledgement got lost.
+
+	default:
+		return
+	}
+
+	res := &knxnet.TunnelRes{Channel: req.Channel, SeqNumber: req.SeqNumber}
+
+	if gw.ackDelay > 0 {
+		time.AfterFunc(gw.ackDelay, func() {
+			gw.mu.Lock()
+			gw.send(res)
+			gw.mu.Unlock()
+		})
+	} else {
+		gw.send(res)
+	}
+}
+
+// handle processes a packet received from the client.
+func (gw *Gateway) handle(srv knxnet.Service, sender *net.UDPAddr) {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+
+	switch srv := srv.(type) {
+	case *knxnet.ConnReq:
+		gw.handleConnReq(sender)
+
+	case *knxnet.ConnStateReq:
+		gw.handleConnStateReq(srv)
+
+	case *knxnet.DiscReq:
+		if srv.Channel == gw.channel {
+			gw.connected = false
+		}
+
+		gw.send(&knxnet.DiscRes{Channel: srv.Channel})
+
+	case *knxnet.TunnelReq:
+		gw.handleTunnelReq(srv)
+
+	case *knxnet.TunnelRes:
+		select {
+		case gw.acks <- srv:
+		default:
+		}
+	}
+}
+
+// serve receives packets until the socket is closed.
+func (gw *Gateway) serve() {
+	util.Log(gw, "Started worker")
+	defer util.Log(gw, "Worker exited")
+
+	defer gw.wait.Done()
+
+	buffer := [1024]byte{}
+
+	for {
+		n, sender, err := gw.conn.ReadFromUDP(buffer[:])
+		if err != nil {
+			return
+		}
+
+		var srv knxnet.Service
+		if _, err := knxnet.Unpack(buffer[:n], &srv); err != nil {
+			util.Log(gw, "Error during Unpack: %v", err)
+			continue
+		}
+
+		gw.handle(srv, sender)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+)
+
+var testConfig = knx.TunnelConfig{
+	ResendInterval:    10 * time.Millisecond,
+	HeartbeatInterval: 50 * time.Millisecond,
+	ResponseTimeout:   200 * time.Millisecond,
+}
+
+func TestGateway(t *testing.T) {
+	t.Run("Send", func(t *testing.T) {
+		gw, err := NewGateway()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer gw.Close()
+
+		client, err := knx.NewGroupTunnel(gw.Addr(), testConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+
+		gw.DropAcks(1)
+
+		err = client.Send(knx.GroupEvent{
+			Command:     knx.GroupWrite,
+			Destination: cemi.NewGroupAddr3(1, 2, 3),
+			Data:        []byte{1},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msg, err := gw.NextFrame(time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, ok := msg.(*cemi.LDataReq)
+		if !ok {
+			t.Fatalf("Unexpected frame %T", msg)
+		}
+
+		if req.Destination != uint16(cemi.NewGroupAddr3(1, 2, 3)) {
+			t.Errorf("Unexpected destination %v", req.Destination)
+		}
+	})
+
+	t.Run("Inject", func(t *testing.T) {
+		gw, err := NewGateway()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer gw.Close()
+
+		client, err := knx.NewGroupTunnel(gw.Addr(), testConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+
+		err = gw.Inject(&cemi.LDataInd{LData: cemi.LData{
+			Control2:    cemi.Control2GroupAddr,
+			Source:      cemi.NewIndividualAddr3(1, 1, 5),
+			Destination: uint16(cemi.NewGroupAddr3(2, 0, 1)),
+			Data:        &cemi.AppData{Command: cemi.GroupValueWrite, Data: []byte{1}},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case event := <-client.Inbound():
+			if event.Source != cemi.NewIndividualAddr3(1, 1, 5) {
+				t.Errorf("Unexpected source %v", event.Source)
+			}
+
+		case <-time.After(time.Second):
+			t.Fatal("No event received")
+		}
+	})
+
+	t.Run("Refuse", func(t *testing.T) {
+		gw, err := NewGateway()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer gw.Close()
+
+		gw.SetConnStatus(knxnet.ErrConnectionType)
+
+		_, err = knx.NewGroupTunnel(gw.Addr(), testConfig)
+		if err != knxnet.ErrCode(knxnet.ErrConnectionType) {
+			t.Fatalf("Expected error %v, got %v", knxnet.ErrConnectionType, err)
+		}
+	})
+
+	t.Run("Reconnect", func(t *testing.T) {
+		gw, err := NewGateway()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer gw.Close()
+
+		client, err := knx.NewGroupTunnel(gw.Addr(), testConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+
+		if err := gw.Disconnect(); err != nil {
+			t.Fatal(err)
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for gw.Connections() < 2 {
+			if time.Now().After(deadline) {
+				t.Fatal("Client did not reconnect")
+			}
+
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+
+	t.Run("HeartbeatFailure", func(t *testing.T) {
+		gw, err := NewGateway()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer gw.Close()
+
+		client, err := knx.NewGroupTunnel(gw.Addr(), testConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+
+		gw.AnswerHeartbeats(false)
+		gw.SetConnStatus(knxnet.ErrNoMoreConnections)
+
+		select {
+		case _, open := <-client.Inbound():
+			if open {
+				t.Fatal("Unexpected event")
+			}
+
+		case <-time.After(2 * time.Second):
+			t.Fatal("Inbound channel has not been closed")
+		}
+
+		if gw.Heartbeats() < 1 {
+			t.Error("No heartbeat has been received")
+		}
+	})
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Package mgmt implements the management of KNX devices through connection-oriented
+// point-to-point communication, i.e. memory and property access, authorisation and key management.
+package mgmt
+
+import (
+	"errors"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// These are the commands of transport control data units.
+const (
+	tConnect    = 0
+	tDisconnect = 1
+	tAck        = 2
+	tNak        = 3
+)
+
+// These are the low parts of extended application services, whose high part is cemi.Escape.
+const (
+	extAuthorizeRequest      = 0x11
+	extAuthorizeResponse     = 0x12
+	extKeyWrite              = 0x13
+	extKeyResponse           = 0x14
+	extPropertyValueRead     = 0x15
+	extPropertyValueResponse = 0x16
+	extPropertyValueWrite    = 0x17
+)
+
+// maxMemoryChunk is the largest number of memory bytes that a standard frame can transport.
+const maxMemoryChunk = 12
+
+// AccessLevel is the access level of a management session. Level 0 is the most privileged one,
+// level 15 grants free access.
+type AccessLevel uint8
+
+// These are notable access levels.
+const (
+	AccessLevelHighest AccessLevel = 0
+	AccessLevelFree    AccessLevel = 15
+
+	// accessLevelFailure is reported by devices which refuse to change a key.
+	accessLevelFailure = 0xFF
+)
+
+// These are errors which devices report through their responses.
+var (
+	ErrAccessDenied   = errors.New("Access has been denied by the device")
+	ErrPropertyAccess = errors.New("Property access has been refused by the device")
+	ErrKeyWrite       = errors.New("Key has not been written by the device")
+	ErrAccessLevel    = errors.New("Key of a more privileged access level cannot be written")
+	ErrDataLength     = errors.New("Data is too long")
+)
+
+// ErrPropertyRange indicates that the start index or element count of a property access cannot be
+// encoded. Up to 15 elements can be accessed at once, the start index must not exceed 4095.
+var ErrPropertyRange = errors.New("Property start index or element count is out of range")
+
+// isExtended determines whether the application data carries the given extended service.
+func isExtended(app *cemi.AppData, service byte) bool {
+	return app.Command == cemi.Escape && len(app.Data) > 0 && app.Data[0]&63 == service
+}
+
+// extended builds application data for an extended service.
+func extended(service byte, data ...byte) *cemi.AppData {
+	return &cemi.AppData{Command: cemi.Escape, Data: append([]byte{service}, data...)}
+}
+
+// appendKey appends the 32-bit big-endian key.
+func appendKey(data []byte, key uint32) []byte {
+	return append(data, byte(key>>24), byte(key>>16), byte(key>>8), byte(key))
+}
+
+// propertyHeader encodes the header of property value services.
+func propertyHeader(object, property uint8, start uint16, count uint8) ([]byte, error) {
+	if count > 15 || start > 0xFFF {
+		return nil, ErrPropertyRange
+	}
+
+	return []byte{object, property, count<<4 | byte(start>>8), byte(start)}, nil
+}
+
+// memoryHeader encodes the header of memory services.
+func memoryHeader(command cemi.APCI, addr uint16, count int) *cemi.AppData {
+	return &cemi.AppData{
+		Command: command,
+		Data:    []byte{byte(count) & 63, byte(addr >> 8), byte(addr)},
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package mgmt
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// Config allows you to configure the management client.
+type Config struct {
+	// Address is the individual address of the client. If it is 0, the gateway fills in its own
+	// address and frames are accepted regardless of their destination.
+	Address cemi.IndividualAddr
+
+	// AckTimeout specifies how long to wait for the transport layer acknowledgement of a frame.
+	AckTimeout time.Duration
+
+	// Retries is the number of times a frame is repeated when it has not been acknowledged.
+	Retries int
+
+	// ResponseTimeout specifies how long to wait for the response to a request.
+	ResponseTimeout time.Duration
+
+	// Clock is used for all timeouts. Tests may use a util.FakeClock in order to control the
+	// passage of time.
+	Clock util.Clock
+}
+
+// DefaultConfig is a good default configuration for a management client.
+var DefaultConfig = Config{
+	AckTimeout:      3 * time.Second,
+	Retries:         3,
+	ResponseTimeout: 3 * time.Second,
+	Clock:           util.RealClock,
+}
+
+// checkConfig makes sure that the configuration is actually usable.
+func checkConfig(config Config) Config {
+	if config.AckTimeout <= 0 {
+		config.AckTimeout = DefaultConfig.AckTimeout
+	}
+
+	if config.Retries < 0 {
+		config.Retries = DefaultConfig.Retries
+	}
+
+	if config.ResponseTimeout <= 0 {
+		config.ResponseTimeout = DefaultConfig.ResponseTimeout
+	}
+
+	if config.Clock == nil {
+		config.Clock = DefaultConfig.Clock
+	}
+
+	return config
+}
+
+// These are errors that can occur when using a management client.
+var (
+	ErrConnected = errors.New("A connection to the device already exists")
+	ErrClosed    = errors.New("Client has been closed")
+	ErrPending   = errors.New("A connectionless request to the device is pending")
+)
+
+// A Client manages devices through the given KNX connection, usually a knx.Tunnel. It maintains
+// transport connections to individual devices, at most one per device.
+type Client struct {
+	conn   knx.Conn
+	config Config
+
+	mu          sync.Mutex
+	connections map[cemi.IndividualAddr]*Connection
+	pending     map[cemi.IndividualAddr]chan *cemi.AppData
+	closed      bool
+	done        chan struct{}
+}
+
+// NewClient creates a management client on top of the given connection. The client takes
+// ownership of the connection and closes it when it is closed.
+func NewClient(conn knx.Conn, config Config) *Client {
+	client := &Client{
+		conn:        conn,
+		config:      checkConfig(config),
+		connections: make(map[cemi.IndividualAddr]*Connection),
+		pending:     make(map[cemi.IndividualAddr]chan *cemi.AppData),
+		done:        make(chan struct{}),
+	}
+
+	go client.serve()
+
+	return client
+}
+
+// serve dispatches the inbound frames to the transport connections.
+func (client *Client) serve() {
+	util.Log(client, "Started worker")
+	defer util.Log(client, "Worker exited")
+
+	for msg := range client.conn.Inbound() {
+		ind, ok := msg.(*cemi.LDataInd)
+		if !ok || ind.Control2.IsGroupAddr() {
+			continue
+		}
+
+		if client.config.Address != 0 &&
+			cemi.IndividualAddr(ind.Destination) != client.config.Address {
+			continue
+		}
+
+		client.mu.Lock()
+		connection := client.connections[ind.Source]
+		pending := client.pending[ind.Source]
+		client.mu.Unlock()
+
+		// Unnumbered application data answers connectionless requests.
+		if app, ok := ind.Data.(*cemi.AppData); ok && !app.Numbered {
+			if pending != nil {
+				select {
+				case pending <- app:
+				default:
+				}
+			}
+
+			continue
+		}
+
+		if connection != nil {
+			connection.handle(ind.Data)
+		}
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	client.closed = true
+	close(client.done)
+
+	for _, connection := range client.connections {
+		connection.terminate()
+	}
+}
+
+// send transmits the transport unit to the device.
+func (client *Client) send(device cemi.IndividualAddr, unit cemi.TransportUnit) error {
+	ldata := cemi.LData{
+		Control1: cemi.Control1NoRepeat | cemi.Control1NoSysBroadcast | cemi.Control1WantAck |
+			cemi.Control1Prio(cemi.PrioLow),
+		Control2:    cemi.Control2Hops(6),
+		Source:      client.config.Address,
+		Destination: uint16(device),
+		Data:        unit,
+	}
+
+	if app, ok := unit.(*cemi.AppData); !ok || len(app.Data) <= 15 {
+		ldata.Control1 |= cemi.Control1StdFrame
+	}
+
+	// Routers exchange indications, whereas tunnels expect requests.
+	if _, ok := client.conn.(*knx.Router); ok {
+		return client.conn.Send(&cemi.LDataInd{LData: ldata})
+	}
+
+	return client.conn.Send(&cemi.LDataReq{LData: ldata})
+}
+
+// Connect establishes a transport connection to the device.
+func (client *Client) Connect(device cemi.IndividualAddr) (*Connection, error) {
+	client.mu.Lock()
+
+	if client.closed {
+		client.mu.Unlock()
+		return nil, ErrClosed
+	}
+
+	if _, ok := client.connections[device]; ok {
+		client.mu.Unlock()
+		return nil, ErrConnected
+	}
+
+	connection := newConnection(client, device)
+	client.connections[device] = connection
+
+	client.mu.Unlock()
+
+	if err := client.send(device, &cemi.ControlData{Command: tConnect}); err != nil {
+		client.release(connection)
+		return nil, err
+	}
+
+	return connection, nil
+}
+
+// ReadDeviceDescriptor reads the device descriptor type 0, also known as mask version, without
+// establishing a transport connection. Only one such request per device may be pending.
+func (client *Client) ReadDeviceDescriptor(device cemi.IndividualAddr) (uint16, error) {
+	responses := make(chan *cemi.AppData, 1)
+
+	client.mu.Lock()
+
+	if client.closed {
+		client.mu.Unlock()
+		return 0, ErrClosed
+	}
+
+	if _, ok := client.pending[device]; ok {
+		client.mu.Unlock()
+		return 0, ErrPending
+	}
+
+	client.pending[device] = responses
+
+	client.mu.Unlock()
+
+	defer func() {
+		client.mu.Lock()
+		delete(client.pending, device)
+		client.mu.Unlock()
+	}()
+
+	err := client.send(device, &cemi.AppData{Command: cemi.MaskVersionRead, Data: []byte{0}})
+	if err != nil {
+		return 0, err
+	}
+
+	timeout := client.config.Clock.NewTimer(client.config.ResponseTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case <-timeout.C():
+			return 0, ErrResponseTimeout
+
+		case <-client.done:
+			return 0, ErrClosed
+
+		case res := <-responses:
+			if res.Command == cemi.MaskVersionResponse && len(res.Data) >= 3 {
+				return uint16(res.Data[1])<<8 | uint16(res.Data[2]), nil
+			}
+		}
+	}
+}
+
+// release forgets about the transport connection.
+func (client *Client) release(connection *Connection) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.connections[connection.device] == connection {
+		delete(client.connections, connection.device)
+	}
+}
+
+// Close shuts the underlying connection down.
+func (client *Client) Close() {
+	client.conn.Close()
+}
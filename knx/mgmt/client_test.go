@@ -0,0 +1,210 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package mgmt
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+func TestConnection(t *testing.T) {
+	bus := &testBus{}
+	defer bus.close()
+
+	newTestDevice(bus, testDeviceAddr)
+	client := newTestClient(bus, DefaultConfig)
+
+	conn, err := client.Connect(testDeviceAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	if _, err := client.Connect(testDeviceAddr); err != ErrConnected {
+		t.Fatalf("Expected %v, got %v", ErrConnected, err)
+	}
+
+	t.Run("DeviceDescriptor", func(t *testing.T) {
+		mask, err := conn.ReadDeviceDescriptor()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if mask != 0x07B0 {
+			t.Errorf("Unexpected mask version: %#04x", mask)
+		}
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		if _, err := conn.ReadMemory(protectedMemory, 4); err != ErrAccessDenied {
+			t.Fatalf("Expected %v, got %v", ErrAccessDenied, err)
+		}
+
+		if err := conn.WriteProperty(0, 11, 1, 1, []byte{1, 2, 3, 4, 5, 6}); err != ErrPropertyAccess {
+			t.Fatalf("Expected %v, got %v", ErrPropertyAccess, err)
+		}
+
+		level, err := conn.Authorize(0xDEADBEEF)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if level != AccessLevelFree || conn.AccessLevel() != AccessLevelFree {
+			t.Errorf("Unexpected access level: %d", level)
+		}
+
+		if err := conn.WriteKey(1, 0xDEADBEEF); err != ErrAccessLevel {
+			t.Fatalf("Expected %v, got %v", ErrAccessLevel, err)
+		}
+	})
+
+	t.Run("Authorized", func(t *testing.T) {
+		level, err := conn.Authorize(0x11223344)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if level != 1 || conn.AccessLevel() != 1 {
+			t.Fatalf("Unexpected access level: %d", level)
+		}
+
+		data := make([]byte, 30)
+		for i := range data {
+			data[i] = byte(i)
+		}
+
+		if err := conn.WriteMemory(protectedMemory, data); err != nil {
+			t.Fatal(err)
+		}
+
+		read, err := conn.ReadMemory(protectedMemory, len(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(read, data) {
+			t.Errorf("Unexpected memory: %x", read)
+		}
+
+		if err := conn.WriteKey(AccessLevelHighest, 1); err != ErrAccessLevel {
+			t.Fatalf("Expected %v, got %v", ErrAccessLevel, err)
+		}
+
+		if err := conn.WriteKey(2, 0x55667788); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Property", func(t *testing.T) {
+		serial, err := conn.ReadProperty(0, 11, 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(serial, []byte{0x00, 0xFA, 0, 0, 0, 1}) {
+			t.Errorf("Unexpected serial number: %x", serial)
+		}
+
+		if err := conn.WriteProperty(0, 11, 1, 1, []byte{0, 0xFA, 0, 0, 0, 2}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := conn.ReadProperty(0, 99, 1, 1); err != ErrPropertyAccess {
+			t.Fatalf("Expected %v, got %v", ErrPropertyAccess, err)
+		}
+	})
+
+	t.Run("PropertyRange", func(t *testing.T) {
+		if _, err := conn.ReadProperty(0, 11, 1, 16); err != ErrPropertyRange {
+			t.Fatalf("Expected %v, got %v", ErrPropertyRange, err)
+		}
+
+		if _, err := conn.ReadProperty(0, 11, 0x1001, 1); err != ErrPropertyRange {
+			t.Fatalf("Expected %v, got %v", ErrPropertyRange, err)
+		}
+
+		if err := conn.WriteProperty(0, 11, 1, 16, make([]byte, 96)); err != ErrPropertyRange {
+			t.Fatalf("Expected %v, got %v", ErrPropertyRange, err)
+		}
+	})
+
+	t.Run("Reconnect", func(t *testing.T) {
+		if err := conn.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := conn.ReadDeviceDescriptor(); err != ErrDisconnected {
+			t.Fatalf("Expected %v, got %v", ErrDisconnected, err)
+		}
+
+		other, err := client.Connect(testDeviceAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer other.Close()
+
+		if _, err := other.ReadDeviceDescriptor(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Restart", func(t *testing.T) {
+		other, err := client.Connect(testDeviceAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := other.Restart(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := other.ReadDeviceDescriptor(); err != ErrDisconnected {
+			t.Fatalf("Expected %v, got %v", ErrDisconnected, err)
+		}
+	})
+}
+
+func TestConnection_NoAck(t *testing.T) {
+	bus := &testBus{}
+	defer bus.close()
+
+	dev := newTestDevice(bus, testDeviceAddr)
+	dev.mu.Lock()
+	dev.silent = true
+	dev.mu.Unlock()
+
+	clock := util.NewFakeClock(time.Unix(0, 0))
+
+	config := DefaultConfig
+	config.Retries = 1
+	config.Clock = clock
+
+	client := newTestClient(bus, config)
+
+	conn, err := client.Connect(testDeviceAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for i := 0; i <= config.Retries; i++ {
+			clock.BlockUntil(1)
+			clock.Advance(config.AckTimeout)
+		}
+	}()
+
+	if _, err := conn.ReadDeviceDescriptor(); err != ErrNoAck {
+		t.Fatalf("Expected %v, got %v", ErrNoAck, err)
+	}
+
+	// The connection is closed after the failure.
+	if _, err := conn.ReadDeviceDescriptor(); err != ErrDisconnected {
+		t.Fatalf("Expected %v, got %v", ErrDisconnected, err)
+	}
+}
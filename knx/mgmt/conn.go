@@ -0,0 +1,270 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package mgmt
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// These are errors that can occur on a transport connection.
+var (
+	ErrNoAck           = errors.New("Frame has not been acknowledged")
+	ErrResponseTimeout = errors.New("Response timeout reached")
+	ErrDisconnected    = errors.New("Connection has been closed")
+)
+
+// ack is a transport layer acknowledgement.
+type ack struct {
+	seqNumber uint8
+	positive  bool
+}
+
+// A Connection is a connection-oriented transport layer session with a device. Requests are
+// processed one at a time.
+type Connection struct {
+	client *Client
+	device cemi.IndividualAddr
+
+	// Serializes requests, so that only one frame awaits its acknowledgement and responses can be
+	// attributed to their request.
+	requestMu sync.Mutex
+	seqSend   uint8
+
+	mu      sync.Mutex
+	seqRecv uint8
+	level   AccessLevel
+
+	acks      chan ack
+	responses chan *cemi.AppData
+	done      chan struct{}
+	doneOnce  sync.Once
+}
+
+// newConnection creates the state of a transport connection to the device.
+func newConnection(client *Client, device cemi.IndividualAddr) *Connection {
+	return &Connection{
+		client:    client,
+		device:    device,
+		level:     AccessLevelFree,
+		acks:      make(chan ack, 1),
+		responses: make(chan *cemi.AppData, 1),
+		done:      make(chan struct{}),
+	}
+}
+
+// Device returns the address of the remote device.
+func (conn *Connection) Device() cemi.IndividualAddr {
+	return conn.device
+}
+
+// AccessLevel returns the access level which the device has granted. It is AccessLevelFree until
+// the connection has been authorized.
+func (conn *Connection) AccessLevel() AccessLevel {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	return conn.level
+}
+
+// terminate marks the connection as closed.
+func (conn *Connection) terminate() {
+	conn.doneOnce.Do(func() { close(conn.done) })
+}
+
+// handle processes a transport unit that has been received from the device.
+func (conn *Connection) handle(unit cemi.TransportUnit) {
+	switch unit := unit.(type) {
+	case *cemi.ControlData:
+		switch unit.Command {
+		case tDisconnect:
+			util.Log(conn, "Device %v closed the connection", conn.device)
+			conn.client.release(conn)
+			conn.terminate()
+
+		case tAck, tNak:
+			if !unit.Numbered {
+				return
+			}
+
+			// Replace an acknowledgement that nobody waits for.
+			select {
+			case <-conn.acks:
+			default:
+			}
+
+			conn.acks <- ack{seqNumber: unit.SeqNumber, positive: unit.Command == tAck}
+		}
+
+	case *cemi.AppData:
+		if !unit.Numbered {
+			return
+		}
+
+		conn.mu.Lock()
+		expected := conn.seqRecv
+
+		switch unit.SeqNumber {
+		case expected:
+			conn.seqRecv = (expected + 1) & 15
+			conn.mu.Unlock()
+
+			conn.acknowledge(tAck, unit.SeqNumber)
+
+			// Replace a response that nobody waits for.
+			select {
+			case <-conn.responses:
+			default:
+			}
+
+			conn.responses <- unit
+
+		case (expected - 1) & 15:
+			// The device repeats a frame, because our acknowledgement got lost.
+			conn.mu.Unlock()
+			conn.acknowledge(tAck, unit.SeqNumber)
+
+		default:
+			conn.mu.Unlock()
+			conn.acknowledge(tNak, unit.SeqNumber)
+		}
+	}
+}
+
+// acknowledge sends a transport layer acknowledgement.
+func (conn *Connection) acknowledge(command uint8, seqNumber uint8) {
+	err := conn.client.send(conn.device, &cemi.ControlData{
+		Numbered:  true,
+		SeqNumber: seqNumber,
+		Command:   command,
+	})
+	if err != nil {
+		util.Log(conn, "Failed to acknowledge frame: %v", err)
+	}
+}
+
+// transmit sends the application data and waits for its acknowledgement. The caller must hold the
+// request lock.
+func (conn *Connection) transmit(app *cemi.AppData) error {
+	app.Numbered = true
+	app.SeqNumber = conn.seqSend
+
+	config := conn.client.config
+
+	for attempt := 0; attempt <= config.Retries; attempt++ {
+		// Forget about acknowledgements that arrived too late.
+		select {
+		case <-conn.acks:
+		default:
+		}
+
+		select {
+		case <-conn.done:
+			return ErrDisconnected
+		default:
+		}
+
+		if err := conn.client.send(conn.device, app); err != nil {
+			return err
+		}
+
+		if conn.awaitAck(app.SeqNumber) {
+			conn.seqSend = (conn.seqSend + 1) & 15
+			return nil
+		}
+	}
+
+	// The transport connection is considered broken.
+	conn.Close()
+
+	return ErrNoAck
+}
+
+// awaitAck waits for the positive acknowledgement of the frame with the given sequence number.
+func (conn *Connection) awaitAck(seqNumber uint8) bool {
+	timeout := conn.client.config.Clock.NewTimer(conn.client.config.AckTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case <-timeout.C():
+			util.Log(conn, "Frame has not been acknowledged in time")
+			return false
+
+		case <-conn.done:
+			return false
+
+		case ack := <-conn.acks:
+			if ack.seqNumber != seqNumber {
+				continue
+			}
+
+			return ack.positive
+		}
+	}
+}
+
+// request sends the request and waits for the response which satisfies the given predicate.
+func (conn *Connection) request(
+	req *cemi.AppData,
+	match func(*cemi.AppData) bool,
+) (*cemi.AppData, error) {
+	conn.requestMu.Lock()
+	defer conn.requestMu.Unlock()
+
+	// Discard responses to previous requests that have timed out.
+	select {
+	case <-conn.responses:
+	default:
+	}
+
+	if err := conn.transmit(req); err != nil {
+		return nil, err
+	}
+
+	timeout := conn.client.config.Clock.NewTimer(conn.client.config.ResponseTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case <-timeout.C():
+			return nil, ErrResponseTimeout
+
+		case <-conn.done:
+			return nil, ErrDisconnected
+
+		case res := <-conn.responses:
+			if match(res) {
+				return res, nil
+			}
+
+			util.Log(conn, "Ignored unexpected response %v", res.Command)
+		}
+	}
+}
+
+// send transmits the application data, without waiting for a response.
+func (conn *Connection) send(app *cemi.AppData) error {
+	conn.requestMu.Lock()
+	defer conn.requestMu.Unlock()
+
+	return conn.transmit(app)
+}
+
+// Close disconnects from the device.
+func (conn *Connection) Close() error {
+	select {
+	case <-conn.done:
+		return nil
+	default:
+	}
+
+	conn.client.release(conn)
+	conn.terminate()
+
+	return conn.client.send(conn.device, &cemi.ControlData{Command: tDisconnect})
+}
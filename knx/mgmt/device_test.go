@@ -0,0 +1,274 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package mgmt
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// protectedMemory is the start of the memory that can only be accessed with access level 1.
+const protectedMemory = 0x4000
+
+var (
+	testClientAddr = cemi.NewIndividualAddr3(1, 1, 250)
+	testDeviceAddr = cemi.NewIndividualAddr3(1, 1, 5)
+)
+
+// testBus connects test links. Unlike a knx.Router, it delivers frames strictly in order.
+type testBus struct {
+	mu    sync.Mutex
+	links []*testLink
+}
+
+// testLink is a knx.Conn attached to a testBus. Every frame it sends arrives as L_Data.ind at
+// the other links.
+type testLink struct {
+	bus     *testBus
+	inbound chan cemi.Message
+	closed  bool
+}
+
+func (bus *testBus) join() *testLink {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	link := &testLink{bus: bus, inbound: make(chan cemi.Message, 256)}
+	bus.links = append(bus.links, link)
+
+	return link
+}
+
+// close closes all links of the bus.
+func (bus *testBus) close() {
+	bus.mu.Lock()
+	links := bus.links
+	bus.mu.Unlock()
+
+	for _, link := range links {
+		link.Close()
+	}
+}
+
+func (link *testLink) Send(msg cemi.Message) error {
+	var ldata cemi.LData
+	switch msg := msg.(type) {
+	case *cemi.LDataReq:
+		ldata = msg.LData
+
+	case *cemi.LDataInd:
+		ldata = msg.LData
+
+	default:
+		return errors.New("Unsupported message")
+	}
+
+	link.bus.mu.Lock()
+	defer link.bus.mu.Unlock()
+
+	if link.closed {
+		return errors.New("Link has been closed")
+	}
+
+	for _, other := range link.bus.links {
+		if other != link && !other.closed {
+			other.inbound <- &cemi.LDataInd{LData: ldata}
+		}
+	}
+
+	return nil
+}
+
+func (link *testLink) Inbound() <-chan cemi.Message {
+	return link.inbound
+}
+
+func (link *testLink) Close() {
+	link.bus.mu.Lock()
+	defer link.bus.mu.Unlock()
+
+	if !link.closed {
+		link.closed = true
+		close(link.inbound)
+	}
+}
+
+// testDevice simulates the management server of a KNX device.
+type testDevice struct {
+	link *testLink
+	addr cemi.IndividualAddr
+
+	mu         sync.Mutex
+	silent     bool
+	seqSend    uint8
+	seqRecv    uint8
+	level      AccessLevel
+	keys       map[AccessLevel]uint32
+	memory     map[uint16]byte
+	properties map[[2]uint8][]byte
+}
+
+func newTestDevice(bus *testBus, addr cemi.IndividualAddr) *testDevice {
+	dev := &testDevice{
+		link:       bus.join(),
+		addr:       addr,
+		level:      AccessLevelFree,
+		keys:       map[AccessLevel]uint32{1: 0x11223344},
+		memory:     make(map[uint16]byte),
+		properties: map[[2]uint8][]byte{{0, 11}: {0x00, 0xFA, 0, 0, 0, 1}},
+	}
+
+	go dev.serve()
+
+	return dev
+}
+
+func (dev *testDevice) send(dest cemi.IndividualAddr, unit cemi.TransportUnit) {
+	dev.link.Send(&cemi.LDataInd{LData: cemi.LData{
+		Control1:    cemi.Control1StdFrame | cemi.Control1NoSysBroadcast,
+		Control2:    cemi.Control2Hops(6),
+		Source:      dev.addr,
+		Destination: uint16(dest),
+		Data:        unit,
+	}})
+}
+
+func (dev *testDevice) serve() {
+	for msg := range dev.link.Inbound() {
+		ind, ok := msg.(*cemi.LDataInd)
+		if !ok || ind.Control2.IsGroupAddr() || cemi.IndividualAddr(ind.Destination) != dev.addr {
+			continue
+		}
+
+		dev.mu.Lock()
+
+		switch unit := ind.Data.(type) {
+		case *cemi.ControlData:
+			if unit.Command == tConnect {
+				dev.seqSend, dev.seqRecv, dev.level = 0, 0, AccessLevelFree
+			}
+
+		case *cemi.AppData:
+			if dev.silent {
+				break
+			}
+
+			// Connectionless requests are answered without acknowledgement.
+			if !unit.Numbered {
+				if unit.Command == cemi.MaskVersionRead {
+					dev.send(ind.Source, dev.respond(unit))
+				}
+
+				break
+			}
+
+			dev.send(ind.Source, &cemi.ControlData{
+				Numbered:  true,
+				SeqNumber: unit.SeqNumber,
+				Command:   tAck,
+			})
+
+			if unit.SeqNumber != dev.seqRecv {
+				break
+			}
+
+			dev.seqRecv = (dev.seqRecv + 1) & 15
+
+			if res := dev.respond(unit); res != nil {
+				res.Numbered = true
+				res.SeqNumber = dev.seqSend
+				dev.seqSend = (dev.seqSend + 1) & 15
+
+				dev.send(ind.Source, res)
+			}
+		}
+
+		dev.mu.Unlock()
+	}
+}
+
+func (dev *testDevice) respond(req *cemi.AppData) *cemi.AppData {
+	switch {
+	case req.Command == cemi.MaskVersionRead:
+		return &cemi.AppData{Command: cemi.MaskVersionResponse, Data: []byte{0, 0x07, 0xB0}}
+
+	case req.Command == cemi.MemoryRead:
+		count := int(req.Data[0] & 63)
+		addr := uint16(req.Data[1])<<8 | uint16(req.Data[2])
+
+		res := &cemi.AppData{
+			Command: cemi.MemoryResponse,
+			Data:    []byte{byte(count), req.Data[1], req.Data[2]},
+		}
+		if addr >= protectedMemory && dev.level > 1 {
+			res.Data[0] = 0
+			return res
+		}
+
+		for i := 0; i < count; i++ {
+			res.Data = append(res.Data, dev.memory[addr+uint16(i)])
+		}
+
+		return res
+
+	case req.Command == cemi.MemoryWrite:
+		addr := uint16(req.Data[1])<<8 | uint16(req.Data[2])
+		if addr < protectedMemory || dev.level <= 1 {
+			for i, b := range req.Data[3:] {
+				dev.memory[addr+uint16(i)] = b
+			}
+		}
+
+	case isExtended(req, extAuthorizeRequest):
+		key := uint32(req.Data[2])<<24 | uint32(req.Data[3])<<16 | uint32(req.Data[4])<<8 |
+			uint32(req.Data[5])
+
+		dev.level = AccessLevelFree
+		for level, other := range dev.keys {
+			if other == key {
+				dev.level = level
+			}
+		}
+
+		return extended(extAuthorizeResponse, byte(dev.level))
+
+	case isExtended(req, extKeyWrite):
+		level := AccessLevel(req.Data[1])
+		if level < dev.level {
+			return extended(extKeyResponse, accessLevelFailure)
+		}
+
+		dev.keys[level] = uint32(req.Data[2])<<24 | uint32(req.Data[3])<<16 |
+			uint32(req.Data[4])<<8 | uint32(req.Data[5])
+
+		return extended(extKeyResponse, byte(level))
+
+	case isExtended(req, extPropertyValueRead), isExtended(req, extPropertyValueWrite):
+		id := [2]uint8{req.Data[1], req.Data[2]}
+		res := extended(extPropertyValueResponse, req.Data[1:5]...)
+
+		if isExtended(req, extPropertyValueWrite) && dev.level <= 1 {
+			dev.properties[id] = append([]byte(nil), req.Data[5:]...)
+		}
+
+		value, ok := dev.properties[id]
+		if !ok || (isExtended(req, extPropertyValueWrite) && dev.level > 1) {
+			res.Data[3] &= 15
+			return res
+		}
+
+		res.Data = append(res.Data, value...)
+		return res
+	}
+
+	return nil
+}
+
+func newTestClient(bus *testBus, config Config) *Client {
+	config.Address = testClientAddr
+
+	return NewClient(bus.join(), config)
+}
@@ -0,0 +1,634 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package mgmt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/ets"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// A DeviceWriter provides read and write access to the memory and interface object properties of
+// a device. Connection implements it.
+type DeviceWriter interface {
+	MemoryReader
+	WriteMemory(addr uint16, data []byte) error
+	WriteProperty(object, property uint8, start uint16, count uint8, data []byte) error
+	Restart() error
+}
+
+// These are errors that can occur when downloading an application program.
+var (
+	ErrMaskMismatch     = errors.New("Mask version of the device differs from the application")
+	ErrUnsupportedStep  = errors.New("Load procedure step is not supported")
+	ErrCompare          = errors.New("Property or memory differs from the expected value")
+	ErrVerify           = errors.New("Memory does not contain the written data")
+	ErrUnknownSegment   = errors.New("Code segment has not been found")
+	ErrUnknownParameter = errors.New("Parameter has not been found")
+	ErrParameterValue   = errors.New("Parameter value cannot be encoded")
+	ErrUnknownObject    = errors.New("Group object has not been found")
+	ErrNoTable          = errors.New("Application does not define the location of the table")
+	ErrTableSize        = errors.New("Table exceeds its maximum size")
+	ErrLocation         = errors.New("Value is located outside of its code segment")
+)
+
+// These are the property and the events of the load state machines.
+const (
+	pidLoadStateControl = 5
+
+	loadEventStart    = 1
+	loadEventComplete = 2
+	loadEventSegment  = 3
+	loadEventUnload   = 4
+
+	// loadEventSize is the size of a load event including its additional data.
+	loadEventSize = 10
+)
+
+// These are the segment types of additional load controls.
+const (
+	segmentAbsolute = 0
+	segmentTask     = 2
+)
+
+// StepError is returned when a step of the load procedure fails.
+type StepError struct {
+	Index int
+	Step  string
+	Err   error
+}
+
+// Error implements the error interface.
+func (err *StepError) Error() string {
+	return fmt.Sprintf("Load procedure step %d (%s): %v", err.Index+1, err.Step, err.Err)
+}
+
+// Unwrap returns the underlying error.
+func (err *StepError) Unwrap() error {
+	return err.Err
+}
+
+// ParameterError is returned when a parameter cannot be stored in the code segments.
+type ParameterError struct {
+	ID  string
+	Err error
+}
+
+// Error implements the error interface.
+func (err *ParameterError) Error() string {
+	return fmt.Sprintf("Parameter %s: %v", err.ID, err.Err)
+}
+
+// Unwrap returns the underlying error.
+func (err *ParameterError) Unwrap() error {
+	return err.Err
+}
+
+// TableError is returned when the group address table or the association table cannot be stored
+// in its code segment.
+type TableError struct {
+	Table string
+	Err   error
+}
+
+// Error implements the error interface.
+func (err *TableError) Error() string {
+	return fmt.Sprintf("%s: %v", err.Table, err.Err)
+}
+
+// Unwrap returns the underlying error.
+func (err *TableError) Unwrap() error {
+	return err.Err
+}
+
+// Download describes what is downloaded to a device: the application program from the product
+// database, its parameters and the links between group objects and group addresses.
+type Download struct {
+	Application *ets.Application
+
+	// Address is the individual address of the device. System 1 devices store it in their group
+	// address table.
+	Address cemi.IndividualAddr
+
+	// Links maps the numbers of group objects to the group addresses they are linked to.
+	Links map[int][]cemi.GroupAddr
+
+	// Parameters maps the IDs of parameters, or of references to them, to their values. Numbers
+	// may also be given as the text of an enumeration value. Parameters which are not listed keep
+	// the default value from the code segments.
+	Parameters map[string]string
+
+	// Clock is used for the delays of the load procedure. It defaults to util.RealClock.
+	Clock util.Clock
+}
+
+// Image generates the contents of the code segments, indexed by segment ID. Parameters and tables
+// that cannot be stored are reported as *ParameterError and *TableError.
+func (dl *Download) Image() (map[string][]byte, error) {
+	app := dl.Application
+
+	image := make(map[string][]byte, len(app.Segments))
+	for _, seg := range app.Segments {
+		data := make([]byte, seg.Size)
+		copy(data, seg.Data)
+		image[seg.ID] = data
+	}
+
+	// Apply the parameters in a deterministic order, in case their locations overlap.
+	ids := make([]string, 0, len(dl.Parameters))
+	for id := range dl.Parameters {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if err := dl.storeParameter(image, id, dl.Parameters[id]); err != nil {
+			return nil, &ParameterError{ID: id, Err: err}
+		}
+	}
+
+	if err := dl.storeTables(image); err != nil {
+		return nil, err
+	}
+
+	return image, nil
+}
+
+// storeBits stores the lower size bits of the value at the given bit offset. Bits are counted
+// starting with the most significant bit of the first byte.
+func storeBits(data []byte, offset, size int, value uint64) error {
+	if size <= 0 || size > 64 || offset < 0 || offset+size > len(data)*8 {
+		return ErrLocation
+	}
+
+	for i := 0; i < size; i++ {
+		pos := offset + i
+		mask := byte(0x80 >> uint(pos%8))
+
+		if value>>uint(size-1-i)&1 != 0 {
+			data[pos/8] |= mask
+		} else {
+			data[pos/8] &^= mask
+		}
+	}
+
+	return nil
+}
+
+// storeBytes copies the value into the data at the given offset.
+func storeBytes(data []byte, offset int, value []byte) error {
+	if offset < 0 || offset+len(value) > len(data) {
+		return ErrLocation
+	}
+
+	copy(data[offset:], value)
+	return nil
+}
+
+// storeParameter encodes the parameter value into its code segment.
+func (dl *Download) storeParameter(image map[string][]byte, id, value string) error {
+	param, ok := dl.Application.Parameter(id)
+	if !ok {
+		return ErrUnknownParameter
+	}
+
+	// Parameters which are not stored in memory only affect the parameter dialog of the ETS.
+	if param.Memory == nil {
+		return nil
+	}
+
+	typ, ok := dl.Application.ParameterTypes[param.TypeID]
+	if !ok {
+		return ErrParameterValue
+	}
+
+	data, ok := image[param.Memory.Segment]
+	if !ok {
+		return ErrUnknownSegment
+	}
+
+	switch typ.Kind {
+	case ets.ParameterNumber:
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			enum, ok := typ.Enumeration[value]
+			if !ok {
+				return ErrParameterValue
+			}
+
+			number = enum
+		}
+
+		size := typ.SizeInBit
+		if size < 64 && (number < -(1<<uint(size-1)) || number >= 1<<uint(size)) {
+			return ErrParameterValue
+		}
+
+		return storeBits(data, param.Memory.Offset*8+param.Memory.BitOffset, size, uint64(number))
+
+	case ets.ParameterText:
+		text := make([]byte, typ.SizeInBit/8)
+		if len(value) > len(text) {
+			return ErrParameterValue
+		}
+
+		copy(text, value)
+		return storeBytes(data, param.Memory.Offset, text)
+
+	default:
+		return ErrParameterValue
+	}
+}
+
+// groupAddresses collects the linked group addresses in ascending order, which is the order of
+// the group address table.
+func (dl *Download) groupAddresses() []cemi.GroupAddr {
+	seen := make(map[cemi.GroupAddr]bool)
+	addrs := []cemi.GroupAddr{}
+
+	for _, links := range dl.Links {
+		for _, addr := range links {
+			if !seen[addr] {
+				seen[addr] = true
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+
+	sort.Sort(groupAddrSlice(addrs))
+	return addrs
+}
+
+// groupAddrSlice sorts group addresses in ascending order.
+type groupAddrSlice []cemi.GroupAddr
+
+func (addrs groupAddrSlice) Len() int           { return len(addrs) }
+func (addrs groupAddrSlice) Less(i, j int) bool { return addrs[i] < addrs[j] }
+func (addrs groupAddrSlice) Swap(i, j int)      { addrs[i], addrs[j] = addrs[j], addrs[i] }
+
+// associationSlice sorts associations by group address and then by group object.
+type associationSlice []Association
+
+func (assocs associationSlice) Len() int { return len(assocs) }
+
+func (assocs associationSlice) Less(i, j int) bool {
+	if assocs[i].AddrIndex != assocs[j].AddrIndex {
+		return assocs[i].AddrIndex < assocs[j].AddrIndex
+	}
+
+	return assocs[i].Object < assocs[j].Object
+}
+
+func (assocs associationSlice) Swap(i, j int) { assocs[i], assocs[j] = assocs[j], assocs[i] }
+
+// associations generates the entries of the association table, ordered by group address.
+func (dl *Download) associations(addrs []cemi.GroupAddr) ([]Association, error) {
+	objects := make(map[int]bool, len(dl.Application.ComObjects))
+	for _, obj := range dl.Application.ComObjects {
+		objects[obj.Number] = true
+	}
+
+	var assocs []Association
+
+	for number, links := range dl.Links {
+		if !objects[number] {
+			return nil, ErrUnknownObject
+		}
+
+		for _, addr := range links {
+			index := sort.Search(len(addrs), func(i int) bool { return addrs[i] >= addr })
+			assocs = append(assocs, Association{AddrIndex: index + 1, Object: number})
+		}
+	}
+
+	sort.Sort(associationSlice(assocs))
+
+	return assocs, nil
+}
+
+// storeTable stores the table at its location.
+func storeTable(
+	image map[string][]byte,
+	loc *ets.TableLocation,
+	table []byte,
+	entries int,
+) error {
+	if loc == nil {
+		return ErrNoTable
+	}
+
+	if loc.MaxEntries > 0 && entries > loc.MaxEntries {
+		return ErrTableSize
+	}
+
+	data, ok := image[loc.Segment]
+	if !ok {
+		return ErrUnknownSegment
+	}
+
+	return storeBytes(data, loc.Offset, table)
+}
+
+// storeTables encodes the group address and association tables into their code segments. The
+// layout is chosen by the mask version, like in ReadTables.
+func (dl *Download) storeTables(image map[string][]byte) error {
+	app := dl.Application
+	if app.AddressTable == nil && app.AssociationTable == nil && len(dl.Links) == 0 {
+		return nil
+	}
+
+	addrs := dl.groupAddresses()
+
+	assocs, err := dl.associations(addrs)
+	if err != nil {
+		return err
+	}
+
+	var addrTable, assocTable []byte
+
+	switch {
+	case isSystem1(app.MaskVersion):
+		// The individual address counts as the first entry of the group address table.
+		if len(addrs)+1 > 255 || len(assocs) > 255 {
+			return ErrTableSize
+		}
+
+		addrTable = []byte{byte(len(addrs) + 1), byte(dl.Address >> 8), byte(dl.Address)}
+		for _, addr := range addrs {
+			addrTable = append(addrTable, byte(addr>>8), byte(addr))
+		}
+
+		assocTable = []byte{byte(len(assocs))}
+		for _, assoc := range assocs {
+			if assoc.Object > 255 {
+				return ErrTableSize
+			}
+
+			assocTable = append(assocTable, byte(assoc.AddrIndex), byte(assoc.Object))
+		}
+
+	case isSystemB(app.MaskVersion):
+		if len(addrs) > 0xFFFF || len(assocs) > 0xFFFF {
+			return ErrTableSize
+		}
+
+		addrTable = []byte{byte(len(addrs) >> 8), byte(len(addrs))}
+		for _, addr := range addrs {
+			addrTable = append(addrTable, byte(addr>>8), byte(addr))
+		}
+
+		assocTable = []byte{byte(len(assocs) >> 8), byte(len(assocs))}
+		for _, assoc := range assocs {
+			assocTable = append(assocTable,
+				byte(assoc.AddrIndex>>8), byte(assoc.AddrIndex),
+				byte(assoc.Object>>8), byte(assoc.Object))
+		}
+
+	default:
+		return ErrUnsupportedMask
+	}
+
+	if err := storeTable(image, app.AddressTable, addrTable, len(addrs)); err != nil {
+		return &TableError{Table: "Group address table", Err: err}
+	}
+
+	if err := storeTable(image, app.AssociationTable, assocTable, len(assocs)); err != nil {
+		return &TableError{Table: "Association table", Err: err}
+	}
+
+	return nil
+}
+
+// stepReader parses the attributes of a load procedure step. It remembers the first error.
+type stepReader struct {
+	step ets.LoadStep
+	err  error
+}
+
+// int parses an integer attribute. Missing attributes have the given default value.
+func (reader *stepReader) int(name string, def int64) int64 {
+	if reader.step.Attr(name) == "" {
+		return def
+	}
+
+	value, err := reader.step.Int(name)
+	if err != nil && reader.err == nil {
+		reader.err = err
+	}
+
+	return value
+}
+
+// data parses a hexadecimal attribute, e.g. InlineData.
+func (reader *stepReader) data(name string) []byte {
+	value, err := hex.DecodeString(reader.step.Attr(name))
+	if err != nil && reader.err == nil {
+		reader.err = err
+	}
+
+	return value
+}
+
+// loadEvent sends an event to the load state machine of the given interface object.
+func loadEvent(dev DeviceWriter, lsm int64, event ...byte) error {
+	data := make([]byte, loadEventSize)
+	copy(data, event)
+
+	return dev.WriteProperty(uint8(lsm), pidLoadStateControl, 1, 1, data)
+}
+
+// runStep executes a step of the load procedure.
+func (dl *Download) runStep(dev DeviceWriter, image map[string][]byte, step ets.LoadStep) error {
+	reader := &stepReader{step: step}
+
+	switch step.Name {
+	case "LdCtrlConnect", "LdCtrlDisconnect":
+		// The caller is in charge of the transport connection.
+		return nil
+
+	case "LdCtrlUnload", "LdCtrlLoad", "LdCtrlLoadCompleted":
+		lsm := reader.int("LsmIdx", 0)
+		if reader.err != nil {
+			return reader.err
+		}
+
+		event := map[string]byte{
+			"LdCtrlUnload":        loadEventUnload,
+			"LdCtrlLoad":          loadEventStart,
+			"LdCtrlLoadCompleted": loadEventComplete,
+		}[step.Name]
+
+		return loadEvent(dev, lsm, event)
+
+	case "LdCtrlAbsSegment":
+		lsm := reader.int("LsmIdx", 0)
+		addr := uint16(reader.int("Address", 0))
+		size := uint16(reader.int("Size", 0))
+		event := []byte{
+			loadEventSegment, segmentAbsolute,
+			byte(addr >> 8), byte(addr), byte(size >> 8), byte(size),
+			byte(reader.int("Access", 0)),
+			byte(reader.int("MemType", 0)),
+			byte(reader.int("SegFlags", 0)),
+		}
+
+		if reader.err != nil {
+			return reader.err
+		}
+
+		if err := loadEvent(dev, lsm, event...); err != nil {
+			return err
+		}
+
+		// Allocated segments are filled with their content right away.
+		for _, seg := range dl.Application.Segments {
+			if seg.Address == addr {
+				return dev.WriteMemory(addr, image[seg.ID])
+			}
+		}
+
+		return nil
+
+	case "LdCtrlTaskSegment":
+		lsm := reader.int("LsmIdx", 0)
+		addr := uint16(reader.int("Address", 0))
+		if reader.err != nil {
+			return reader.err
+		}
+
+		return loadEvent(dev, lsm, loadEventSegment, segmentTask, byte(addr>>8), byte(addr))
+
+	case "LdCtrlWriteProp", "LdCtrlCompareProp":
+		object := uint8(reader.int("ObjIdx", 0))
+		property := uint8(reader.int("PropId", 0))
+		start := reader.int("StartElement", 1)
+		count := reader.int("Count", 1)
+		data := reader.data("InlineData")
+
+		if reader.err != nil {
+			return reader.err
+		}
+
+		if start < 0 || start > 0xFFF || count < 0 || count > 15 {
+			return ErrPropertyRange
+		}
+
+		if step.Name == "LdCtrlWriteProp" {
+			return dev.WriteProperty(object, property, uint16(start), uint8(count), data)
+		}
+
+		value, err := dev.ReadProperty(object, property, uint16(start), uint8(count))
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(value, data) {
+			return ErrCompare
+		}
+
+		return nil
+
+	case "LdCtrlWriteMem", "LdCtrlCompareMem":
+		addr := uint16(reader.int("Address", 0))
+		data := reader.data("InlineData")
+
+		if reader.err != nil {
+			return reader.err
+		}
+
+		if step.Name == "LdCtrlWriteMem" {
+			if err := dev.WriteMemory(addr, data); err != nil {
+				return err
+			}
+
+			if step.Attr("Verify") != "true" {
+				return nil
+			}
+		}
+
+		value, err := dev.ReadMemory(addr, len(data))
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(value, data) {
+			if step.Name == "LdCtrlWriteMem" {
+				return ErrVerify
+			}
+
+			return ErrCompare
+		}
+
+		return nil
+
+	case "LdCtrlDelay":
+		delay := reader.int("MilliSeconds", 0)
+		if reader.err != nil {
+			return reader.err
+		}
+
+		clock := dl.Clock
+		if clock == nil {
+			clock = util.RealClock
+		}
+
+		<-clock.After(time.Duration(delay) * time.Millisecond)
+		return nil
+
+	case "LdCtrlRestart":
+		return dev.Restart()
+
+	default:
+		return ErrUnsupportedStep
+	}
+}
+
+// Run downloads the application program to the device by executing its load procedure. The memory
+// and property accesses usually require authorization, see Connection.Authorize. If the
+// application program has no load procedure, as is the case for many System 1 devices, the code
+// segments are written to memory directly.
+//
+// A failing step is reported as *StepError. Restarting the device closes the connection.
+func (dl *Download) Run(dev DeviceWriter) error {
+	mask, err := dev.ReadDeviceDescriptor()
+	if err != nil {
+		return err
+	}
+
+	if mask != dl.Application.MaskVersion {
+		return ErrMaskMismatch
+	}
+
+	image, err := dl.Image()
+	if err != nil {
+		return err
+	}
+
+	if len(dl.Application.LoadProcedure) == 0 {
+		for _, seg := range dl.Application.Segments {
+			if err := dev.WriteMemory(seg.Address, image[seg.ID]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	for i, step := range dl.Application.LoadProcedure {
+		if err := dl.runStep(dev, image, step); err != nil {
+			return &StepError{Index: i, Step: step.Name, Err: err}
+		}
+	}
+
+	return nil
+}
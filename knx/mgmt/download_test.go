@@ -0,0 +1,283 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package mgmt
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/ets"
+)
+
+var _ DeviceWriter = (*Connection)(nil)
+
+// fakeDevice is a DeviceWriter which records the property writes and restarts.
+type fakeDevice struct {
+	*fakeMemory
+	events   []string
+	restarts int
+}
+
+func (dev *fakeDevice) WriteMemory(addr uint16, data []byte) error {
+	dev.store(addr, data...)
+	return nil
+}
+
+func (dev *fakeDevice) WriteProperty(
+	object, property uint8,
+	start uint16,
+	count uint8,
+	data []byte,
+) error {
+	dev.events = append(dev.events, fmt.Sprintf("%d/%d: %x", object, property, data))
+	return nil
+}
+
+func (dev *fakeDevice) Restart() error {
+	dev.restarts++
+	return nil
+}
+
+const testSegment = "M-00FA_A-0001-10-0000_AS-4000"
+
+func makeTestApplication() *ets.Application {
+	return &ets.Application{
+		MaskVersion: 0x07B0,
+		Segments: []ets.Segment{
+			// The group object table contains objects 1 and 2.
+			{ID: testSegment, Address: 0x4000, Size: 0x40, Data: []byte{
+				0x30: 0, 0x31: 2, 0x32: 0xDC, 0x33: 8, 0x34: 0x4C, 0x35: 0,
+			}},
+		},
+		ParameterTypes: map[string]*ets.ParameterType{
+			"Delay": {ID: "Delay", Kind: ets.ParameterNumber, SizeInBit: 16},
+			"Mode": {
+				ID:          "Mode",
+				Kind:        ets.ParameterNumber,
+				SizeInBit:   2,
+				Enumeration: map[string]int64{"Off": 0, "On": 1, "Auto": 2},
+			},
+			"Name": {ID: "Name", Kind: ets.ParameterText, SizeInBit: 32},
+		},
+		Parameters: map[string]*ets.Parameter{
+			"P-1": {ID: "P-1", TypeID: "Delay", Memory: &ets.MemoryLocation{
+				Segment: testSegment, Offset: 0x20,
+			}},
+			"P-2": {ID: "P-2", TypeID: "Mode", Memory: &ets.MemoryLocation{
+				Segment: testSegment, Offset: 0x22, BitOffset: 2,
+			}},
+			"P-3": {ID: "P-3", TypeID: "Name", Memory: &ets.MemoryLocation{
+				Segment: testSegment, Offset: 0x24,
+			}},
+		},
+		ParameterRefs:    map[string]string{"P-1_R-1": "P-1"},
+		ComObjects:       []ets.ComObject{{Number: 1}, {Number: 2}},
+		AddressTable:     &ets.TableLocation{Segment: testSegment, Offset: 0x00, MaxEntries: 4},
+		AssociationTable: &ets.TableLocation{Segment: testSegment, Offset: 0x10, MaxEntries: 3},
+		ComObjectTable:   &ets.TableLocation{Segment: testSegment, Offset: 0x30},
+		LoadProcedure: []ets.LoadStep{
+			{Name: "LdCtrlConnect"},
+			{Name: "LdCtrlCompareProp", Attrs: map[string]string{
+				"ObjIdx": "0", "PropId": "12", "InlineData": "00FA",
+			}},
+			{Name: "LdCtrlUnload", Attrs: map[string]string{"LsmIdx": "4"}},
+			{Name: "LdCtrlLoad", Attrs: map[string]string{"LsmIdx": "4"}},
+			{Name: "LdCtrlAbsSegment", Attrs: map[string]string{
+				"LsmIdx": "4", "SegType": "0", "Address": "16384", "Size": "64", "Access": "255",
+				"MemType": "3", "SegFlags": "128",
+			}},
+			{Name: "LdCtrlTaskSegment", Attrs: map[string]string{"LsmIdx": "4", "Address": "16384"}},
+			{Name: "LdCtrlWriteMem", Attrs: map[string]string{
+				"Address": "0x4040", "InlineData": "AABB", "Verify": "true",
+			}},
+			{Name: "LdCtrlLoadCompleted", Attrs: map[string]string{"LsmIdx": "4"}},
+			{Name: "LdCtrlRestart"},
+			{Name: "LdCtrlDisconnect"},
+		},
+	}
+}
+
+func newFakeDevice() *fakeDevice {
+	return &fakeDevice{fakeMemory: &fakeMemory{
+		mask:   0x07B0,
+		memory: make(map[uint16]byte),
+		properties: map[[2]uint8][]byte{
+			{0, 12}:                {0x00, 0xFA},
+			{0, pidObjectType}:     {0, 0},
+			{1, pidObjectType}:     {0, objectTypeAddrTable},
+			{1, pidTableReference}: {0, 0, 0x40, 0x00},
+			{2, pidObjectType}:     {0, objectTypeAssocTable},
+			{2, pidTableReference}: {0, 0, 0x40, 0x10},
+			{3, pidObjectType}:     {0, objectTypeGroupObject},
+			{3, pidTableReference}: {0, 0, 0x40, 0x30},
+		},
+	}}
+}
+
+// downloadCause returns the error that is wrapped by the download errors.
+func downloadCause(err error) error {
+	switch err := err.(type) {
+	case *StepError:
+		return err.Err
+
+	case *ParameterError:
+		return err.Err
+
+	case *TableError:
+		return err.Err
+	}
+
+	return err
+}
+
+func TestDownload(t *testing.T) {
+	ga1 := cemi.NewGroupAddr3(1, 2, 3)
+	ga2 := cemi.NewGroupAddr3(1, 2, 4)
+
+	dl := &Download{
+		Application: makeTestApplication(),
+		Address:     testDeviceAddr,
+		Links:       map[int][]cemi.GroupAddr{1: {ga2, ga1}, 2: {ga2}},
+		Parameters:  map[string]string{"P-1_R-1": "600", "P-2": "Auto", "P-3": "Hall"},
+	}
+
+	dev := newFakeDevice()
+	if err := dl.Run(dev); err != nil {
+		t.Fatal(err)
+	}
+
+	// The segment is written when it is allocated, that is before the task segment is set up.
+	expectedEvents := []string{
+		"4/5: 04000000000000000000",
+		"4/5: 01000000000000000000",
+		"4/5: 030040000040ff038000",
+		"4/5: 03024000000000000000",
+		"4/5: 02000000000000000000",
+	}
+	if !reflect.DeepEqual(dev.events, expectedEvents) {
+		t.Errorf("Unexpected load events: %q", dev.events)
+	}
+
+	if dev.restarts != 1 {
+		t.Errorf("Expected 1 restart, got %d", dev.restarts)
+	}
+
+	params, err := dev.ReadMemory(0x4020, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(params, []byte{0x02, 0x58, 0x20, 0, 'H', 'a', 'l', 'l'}) {
+		t.Errorf("Unexpected parameters: %x", params)
+	}
+
+	tables, err := ReadTables(dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []GroupObject{
+		{Number: 1, Flags: 0xDC, Type: 8, Addresses: []cemi.GroupAddr{ga1, ga2}},
+		{Number: 2, Flags: 0x4C, Type: 0, Addresses: []cemi.GroupAddr{ga2}},
+	}
+	if !reflect.DeepEqual(tables.Objects, expected) {
+		t.Errorf("Unexpected group objects: %v", tables.Objects)
+	}
+
+	t.Run("System1", func(t *testing.T) {
+		app := makeTestApplication()
+		app.MaskVersion = 0x0012
+		app.LoadProcedure = nil
+
+		dl := &Download{
+			Application: app,
+			Address:     testDeviceAddr,
+			Links:       map[int][]cemi.GroupAddr{1: {ga1}, 2: {ga1, ga2}},
+		}
+
+		image, err := dl.Image()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data := image[testSegment]
+		if !bytes.Equal(data[:7], []byte{3, 0x11, 0x05, 0x0A, 0x03, 0x0A, 0x04}) {
+			t.Errorf("Unexpected group address table: %x", data[:7])
+		}
+
+		if !bytes.Equal(data[0x10:0x17], []byte{3, 1, 1, 1, 2, 2, 2}) {
+			t.Errorf("Unexpected association table: %x", data[0x10:0x17])
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, test := range []struct {
+			name     string
+			download Download
+			err      error
+		}{
+			{"Parameter", Download{Parameters: map[string]string{"P-4": "1"}}, ErrUnknownParameter},
+			{"Value", Download{Parameters: map[string]string{"P-2": "4"}}, ErrParameterValue},
+			{"Enumeration", Download{Parameters: map[string]string{"P-2": "?"}}, ErrParameterValue},
+			{"Text", Download{Parameters: map[string]string{"P-3": "Kitchen"}}, ErrParameterValue},
+			{"Object", Download{Links: map[int][]cemi.GroupAddr{3: {ga1}}}, ErrUnknownObject},
+			{"Size", Download{Links: map[int][]cemi.GroupAddr{1: {1, 2, 3, 4, 5}}}, ErrTableSize},
+		} {
+			t.Run(test.name, func(t *testing.T) {
+				test.download.Application = makeTestApplication()
+				if _, err := test.download.Image(); downloadCause(err) != test.err {
+					t.Fatalf("Expected %v, got %v", test.err, err)
+				}
+			})
+		}
+	})
+
+	t.Run("MaskMismatch", func(t *testing.T) {
+		dev := newFakeDevice()
+		dev.mask = 0x0705
+
+		if err := dl.Run(dev); err != ErrMaskMismatch {
+			t.Fatalf("Expected %v, got %v", ErrMaskMismatch, err)
+		}
+	})
+
+	t.Run("UnsupportedStep", func(t *testing.T) {
+		app := makeTestApplication()
+		app.LoadProcedure = append(app.LoadProcedure[:1], ets.LoadStep{Name: "LdCtrlRelSegment"})
+
+		err := (&Download{Application: app}).Run(newFakeDevice())
+
+		stepErr, ok := err.(*StepError)
+		if !ok || stepErr.Index != 1 || stepErr.Err != ErrUnsupportedStep {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Compare", func(t *testing.T) {
+		dev := newFakeDevice()
+		dev.properties[[2]uint8{0, 12}] = []byte{0x00, 0x01}
+
+		if err := dl.Run(dev); downloadCause(err) != ErrCompare {
+			t.Fatalf("Expected %v, got %v", ErrCompare, err)
+		}
+	})
+
+	t.Run("PropertyRange", func(t *testing.T) {
+		app := makeTestApplication()
+		app.LoadProcedure = append(app.LoadProcedure[:1], ets.LoadStep{
+			Name:  "LdCtrlWriteProp",
+			Attrs: map[string]string{"ObjIdx": "0", "PropId": "12", "Count": "16"},
+		})
+
+		err := (&Download{Application: app}).Run(newFakeDevice())
+
+		stepErr, ok := err.(*StepError)
+		if !ok || stepErr.Index != 1 || stepErr.Err != ErrPropertyRange {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
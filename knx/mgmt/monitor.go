@@ -0,0 +1,334 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package mgmt
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// ProbeMethod determines how the monitor checks whether a device is reachable.
+type ProbeMethod uint8
+
+// These are the supported probe methods.
+const (
+	// ProbeDescriptor reads the device descriptor without a transport connection. This is the
+	// least intrusive method, but some devices do not answer connectionless requests.
+	ProbeDescriptor ProbeMethod = iota
+
+	// ProbeConnection establishes a transport connection and reads the device descriptor through
+	// it. The transport layer acknowledgement proves that the device is alive.
+	ProbeConnection
+)
+
+// MonitorConfig allows you to configure the reachability monitor.
+type MonitorConfig struct {
+	// Devices are the individual addresses to check.
+	Devices []cemi.IndividualAddr
+
+	// Interval is the time between two rounds of checks.
+	Interval time.Duration
+
+	// Method determines how devices are checked.
+	Method ProbeMethod
+
+	// DownThreshold is the number of consecutive failed checks after which a device is
+	// considered down.
+	DownThreshold int
+
+	// UpThreshold is the number of consecutive successful checks after which a device that is
+	// down is considered up again.
+	UpThreshold int
+
+	// Clock is used to schedule the checks.
+	Clock util.Clock
+}
+
+// DefaultMonitorConfig is a good default configuration for a reachability monitor.
+var DefaultMonitorConfig = MonitorConfig{
+	Interval:      time.Minute,
+	Method:        ProbeDescriptor,
+	DownThreshold: 3,
+	UpThreshold:   2,
+	Clock:         util.RealClock,
+}
+
+// checkMonitorConfig makes sure that the configuration is actually usable.
+func checkMonitorConfig(config MonitorConfig) MonitorConfig {
+	if config.Interval <= 0 {
+		config.Interval = DefaultMonitorConfig.Interval
+	}
+
+	if config.DownThreshold <= 0 {
+		config.DownThreshold = DefaultMonitorConfig.DownThreshold
+	}
+
+	if config.UpThreshold <= 0 {
+		config.UpThreshold = DefaultMonitorConfig.UpThreshold
+	}
+
+	if config.Clock == nil {
+		config.Clock = DefaultMonitorConfig.Clock
+	}
+
+	return config
+}
+
+// State is the reachability of a device.
+type State uint8
+
+// These are the states of a device.
+const (
+	// StateUnknown means that the device has not been checked yet, or that it has not answered
+	// since the monitor started but has not failed often enough to be considered down.
+	StateUnknown State = iota
+	StateUp
+	StateDown
+)
+
+// String generates a string representation.
+func (state State) String() string {
+	switch state {
+	case StateUp:
+		return "Up"
+
+	case StateDown:
+		return "Down"
+	}
+
+	return "Unknown"
+}
+
+// Event reports a change of a device's state.
+type Event struct {
+	Device   cemi.IndividualAddr
+	State    State
+	Previous State
+	Time     time.Time
+
+	// Err is the error of the latest check, if it has failed.
+	Err error
+}
+
+// DeviceStatus contains the state and the statistics of a monitored device.
+type DeviceStatus struct {
+	Device cemi.IndividualAddr
+	State  State
+
+	// MaskVersion is the device descriptor that the device has reported last.
+	MaskVersion uint16
+
+	Checks    int
+	Failures  int
+	LastCheck time.Time
+	LastSeen  time.Time
+
+	// RoundTrip is the duration of the latest successful check.
+	RoundTrip time.Duration
+
+	// LastError is the error of the latest failed check.
+	LastError error
+
+	consecutive int
+}
+
+// A Monitor checks the reachability of devices on a schedule. State changes are reported through
+// the event channel, which must be drained, whereas Status provides the statistics of all
+// devices, e.g. for metrics.
+//
+// A device's state only changes after a number of consecutive checks with the same outcome, which
+// prevents alerts caused by single lost frames.
+type Monitor struct {
+	client *Client
+	config MonitorConfig
+	events chan Event
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+
+	mu     sync.Mutex
+	status map[cemi.IndividualAddr]*DeviceStatus
+}
+
+// NewMonitor starts monitoring the configured devices using the management client.
+func NewMonitor(client *Client, config MonitorConfig) *Monitor {
+	config = checkMonitorConfig(config)
+
+	monitor := &Monitor{
+		client: client,
+		config: config,
+		events: make(chan Event, len(config.Devices)),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		status: make(map[cemi.IndividualAddr]*DeviceStatus, len(config.Devices)),
+	}
+
+	for _, device := range config.Devices {
+		monitor.status[device] = &DeviceStatus{Device: device}
+	}
+
+	go monitor.serve()
+
+	return monitor
+}
+
+// Events returns the channel through which state changes are reported. It is closed when the
+// monitor is closed.
+func (monitor *Monitor) Events() <-chan Event {
+	return monitor.events
+}
+
+// Status returns a snapshot of the status of all devices, ordered by individual address.
+func (monitor *Monitor) Status() []DeviceStatus {
+	monitor.mu.Lock()
+	defer monitor.mu.Unlock()
+
+	status := make([]DeviceStatus, 0, len(monitor.status))
+	for _, device := range monitor.status {
+		status = append(status, *device)
+	}
+
+	sort.Sort(statusByDevice(status))
+	return status
+}
+
+// statusByDevice sorts device states by individual address.
+type statusByDevice []DeviceStatus
+
+func (status statusByDevice) Len() int           { return len(status) }
+func (status statusByDevice) Less(i, j int) bool { return status[i].Device < status[j].Device }
+func (status statusByDevice) Swap(i, j int)      { status[i], status[j] = status[j], status[i] }
+
+// Close stops monitoring and waits for a running check to finish. The management client is not
+// closed.
+func (monitor *Monitor) Close() {
+	monitor.once.Do(func() { close(monitor.stop) })
+	<-monitor.done
+}
+
+// serve checks the devices until the monitor is closed.
+func (monitor *Monitor) serve() {
+	util.Log(monitor, "Started worker")
+	defer util.Log(monitor, "Worker exited")
+
+	defer close(monitor.done)
+	defer close(monitor.events)
+
+	for {
+		for _, device := range monitor.config.Devices {
+			select {
+			case <-monitor.stop:
+				return
+			default:
+			}
+
+			if !monitor.check(device) {
+				return
+			}
+		}
+
+		select {
+		case <-monitor.stop:
+			return
+
+		case <-monitor.config.Clock.After(monitor.config.Interval):
+		}
+	}
+}
+
+// probe checks whether the device is reachable.
+func (monitor *Monitor) probe(device cemi.IndividualAddr) (uint16, error) {
+	if monitor.config.Method != ProbeConnection {
+		return monitor.client.ReadDeviceDescriptor(device)
+	}
+
+	conn, err := monitor.client.Connect(device)
+	if err != nil {
+		return 0, err
+	}
+
+	defer conn.Close()
+
+	return conn.ReadDeviceDescriptor()
+}
+
+// check probes the device and updates its state. It returns false if the monitor has been closed
+// while reporting a state change.
+func (monitor *Monitor) check(device cemi.IndividualAddr) bool {
+	start := monitor.config.Clock.Now()
+	mask, err := monitor.probe(device)
+	now := monitor.config.Clock.Now()
+
+	// These errors reveal nothing about the device.
+	if err == ErrConnected || err == ErrPending || err == ErrClosed {
+		util.Log(monitor, "Skipped check of %v: %v", device, err)
+		return true
+	}
+
+	monitor.mu.Lock()
+
+	status := monitor.status[device]
+	status.Checks++
+	status.LastCheck = now
+
+	previous := status.State
+	success := err == nil
+
+	if success {
+		status.MaskVersion = mask
+		status.LastSeen = now
+		status.RoundTrip = now.Sub(start)
+	} else {
+		status.Failures++
+		status.LastError = err
+	}
+
+	// The consecutive counter is positive for successes and negative for failures.
+	switch {
+	case success && status.consecutive >= 0:
+		status.consecutive++
+
+	case success:
+		status.consecutive = 1
+
+	case status.consecutive <= 0:
+		status.consecutive--
+
+	default:
+		status.consecutive = -1
+	}
+
+	switch {
+	case status.State == StateUnknown && success:
+		status.State = StateUp
+
+	case status.State == StateDown && status.consecutive >= monitor.config.UpThreshold:
+		status.State = StateUp
+
+	case status.State != StateDown && -status.consecutive >= monitor.config.DownThreshold:
+		status.State = StateDown
+	}
+
+	event := Event{Device: device, State: status.State, Previous: previous, Time: now, Err: err}
+
+	monitor.mu.Unlock()
+
+	if event.State == previous {
+		return true
+	}
+
+	util.Log(monitor, "Device %v is %v", device, event.State)
+
+	select {
+	case monitor.events <- event:
+		return true
+
+	case <-monitor.stop:
+		return false
+	}
+}
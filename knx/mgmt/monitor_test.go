@@ -0,0 +1,105 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package mgmt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+func expectEvent(t *testing.T, monitor *Monitor, device cemi.IndividualAddr, state State) {
+	select {
+	case event := <-monitor.Events():
+		if event.Device != device || event.State != state {
+			t.Fatalf("Unexpected event: %+v", event)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected %v to be %v", device, state)
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	otherAddr := cemi.NewIndividualAddr3(1, 1, 6)
+
+	bus := &testBus{}
+	defer bus.close()
+
+	newTestDevice(bus, testDeviceAddr)
+
+	other := newTestDevice(bus, otherAddr)
+	other.mu.Lock()
+	other.silent = true
+	other.mu.Unlock()
+
+	config := DefaultConfig
+	config.ResponseTimeout = 100 * time.Millisecond
+	client := newTestClient(bus, config)
+
+	monitor := NewMonitor(client, MonitorConfig{
+		Devices:       []cemi.IndividualAddr{testDeviceAddr, otherAddr},
+		Interval:      time.Millisecond,
+		DownThreshold: 2,
+		UpThreshold:   2,
+	})
+
+	expectEvent(t, monitor, testDeviceAddr, StateUp)
+	expectEvent(t, monitor, otherAddr, StateDown)
+
+	status := monitor.Status()
+	if len(status) != 2 || status[0].MaskVersion != 0x07B0 || status[1].Failures < 2 ||
+		status[1].LastError != ErrResponseTimeout {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+
+	other.mu.Lock()
+	other.silent = false
+	other.mu.Unlock()
+
+	expectEvent(t, monitor, otherAddr, StateUp)
+
+	monitor.Close()
+
+	if _, ok := <-monitor.Events(); ok {
+		t.Error("Event channel should be closed")
+	}
+
+	t.Run("Connection", func(t *testing.T) {
+		monitor := NewMonitor(client, MonitorConfig{
+			Devices: []cemi.IndividualAddr{testDeviceAddr},
+			Method:  ProbeConnection,
+		})
+
+		defer monitor.Close()
+
+		expectEvent(t, monitor, testDeviceAddr, StateUp)
+	})
+}
+
+func TestClient_ReadDeviceDescriptor(t *testing.T) {
+	bus := &testBus{}
+	defer bus.close()
+
+	newTestDevice(bus, testDeviceAddr)
+
+	config := DefaultConfig
+	config.ResponseTimeout = 100 * time.Millisecond
+	client := newTestClient(bus, config)
+
+	mask, err := client.ReadDeviceDescriptor(testDeviceAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mask != 0x07B0 {
+		t.Errorf("Unexpected mask version: %#04x", mask)
+	}
+
+	absent := cemi.NewIndividualAddr3(1, 1, 7)
+	if _, err := client.ReadDeviceDescriptor(absent); err != ErrResponseTimeout {
+		t.Fatalf("Expected %v, got %v", ErrResponseTimeout, err)
+	}
+}
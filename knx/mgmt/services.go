@@ -0,0 +1,216 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package mgmt
+
+import (
+	"io"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// Authorize authorizes the connection with the given key (A_Authorize). The device grants the
+// access level which is associated with the key, or the free access level if the key is unknown.
+func (conn *Connection) Authorize(key uint32) (AccessLevel, error) {
+	res, err := conn.request(
+		extended(extAuthorizeRequest, appendKey([]byte{0}, key)...),
+		func(res *cemi.AppData) bool {
+			return isExtended(res, extAuthorizeResponse) && len(res.Data) >= 2
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	level := AccessLevel(res.Data[1])
+
+	conn.mu.Lock()
+	conn.level = level
+	conn.mu.Unlock()
+
+	return level, nil
+}
+
+// WriteKey sets the key of the given access level (A_Key_Write). Only the keys of the granted or
+// less privileged access levels can be written.
+func (conn *Connection) WriteKey(level AccessLevel, key uint32) error {
+	if level < conn.AccessLevel() {
+		return ErrAccessLevel
+	}
+
+	res, err := conn.request(
+		extended(extKeyWrite, appendKey([]byte{byte(level)}, key)...),
+		func(res *cemi.AppData) bool {
+			return isExtended(res, extKeyResponse) && len(res.Data) >= 2
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if res.Data[1] == accessLevelFailure || AccessLevel(res.Data[1]) != level {
+		return ErrKeyWrite
+	}
+
+	return nil
+}
+
+// ReadDeviceDescriptor reads the device descriptor type 0, also known as mask version.
+func (conn *Connection) ReadDeviceDescriptor() (uint16, error) {
+	res, err := conn.request(
+		&cemi.AppData{Command: cemi.MaskVersionRead, Data: []byte{0}},
+		func(res *cemi.AppData) bool {
+			return res.Command == cemi.MaskVersionResponse && len(res.Data) >= 3
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint16(res.Data[1])<<8 | uint16(res.Data[2]), nil
+}
+
+// readMemoryChunk reads a piece of memory which fits into a single frame.
+func (conn *Connection) readMemoryChunk(addr uint16, count int) ([]byte, error) {
+	res, err := conn.request(
+		memoryHeader(cemi.MemoryRead, addr, count),
+		func(res *cemi.AppData) bool {
+			return res.Command == cemi.MemoryResponse && len(res.Data) >= 3 &&
+				uint16(res.Data[1])<<8|uint16(res.Data[2]) == addr
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	n := int(res.Data[0] & 63)
+	if n == 0 {
+		return nil, ErrAccessDenied
+	}
+
+	if len(res.Data) < 3+n || n > count {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return append([]byte(nil), res.Data[3:3+n]...), nil
+}
+
+// ReadMemory reads count bytes of memory, starting at the given address. Large reads are split
+// into multiple requests.
+func (conn *Connection) ReadMemory(addr uint16, count int) ([]byte, error) {
+	data := make([]byte, 0, count)
+
+	for len(data) < count {
+		chunk := count - len(data)
+		if chunk > maxMemoryChunk {
+			chunk = maxMemoryChunk
+		}
+
+		part, err := conn.readMemoryChunk(addr+uint16(len(data)), chunk)
+		if err != nil {
+			return nil, err
+		}
+
+		data = append(data, part...)
+	}
+
+	return data, nil
+}
+
+// WriteMemory writes the data to memory, starting at the given address. Large writes are split
+// into multiple requests. Devices do not confirm memory writes, therefore reading the memory back
+// is the only way to verify them.
+func (conn *Connection) WriteMemory(addr uint16, data []byte) error {
+	for offset := 0; offset < len(data); offset += maxMemoryChunk {
+		end := offset + maxMemoryChunk
+		if end > len(data) {
+			end = len(data)
+		}
+
+		app := memoryHeader(cemi.MemoryWrite, addr+uint16(offset), end-offset)
+		app.Data = append(app.Data, data[offset:end]...)
+
+		if err := conn.send(app); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// propertyMatcher creates a predicate for responses to the given property.
+func propertyMatcher(object, property uint8, start uint16) func(*cemi.AppData) bool {
+	return func(res *cemi.AppData) bool {
+		return isExtended(res, extPropertyValueResponse) && len(res.Data) >= 5 &&
+			res.Data[1] == object && res.Data[2] == property &&
+			uint16(res.Data[3]&15)<<8|uint16(res.Data[4]) == start
+	}
+}
+
+// ReadProperty reads count elements of a property value, starting with the given element.
+func (conn *Connection) ReadProperty(
+	object, property uint8,
+	start uint16,
+	count uint8,
+) ([]byte, error) {
+	header, err := propertyHeader(object, property, start, count)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := conn.request(
+		extended(extPropertyValueRead, header...),
+		propertyMatcher(object, property, start),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Data[3]>>4 == 0 {
+		return nil, ErrPropertyAccess
+	}
+
+	return append([]byte(nil), res.Data[5:]...), nil
+}
+
+// WriteProperty writes count elements of a property value, starting with the given element.
+func (conn *Connection) WriteProperty(
+	object, property uint8,
+	start uint16,
+	count uint8,
+	data []byte,
+) error {
+	header, err := propertyHeader(object, property, start, count)
+	if err != nil {
+		return err
+	}
+
+	if len(header)+len(data) > 254 {
+		return ErrDataLength
+	}
+
+	res, err := conn.request(
+		extended(extPropertyValueWrite, append(header, data...)...),
+		propertyMatcher(object, property, start),
+	)
+	if err != nil {
+		return err
+	}
+
+	if res.Data[3]>>4 == 0 {
+		return ErrPropertyAccess
+	}
+
+	return nil
+}
+
+// Restart restarts the device (A_Restart). Since the device drops the transport connection while
+// restarting, the connection is closed afterwards.
+func (conn *Connection) Restart() error {
+	err := conn.send(&cemi.AppData{Command: cemi.Restart, Data: []byte{0}})
+
+	conn.client.release(conn)
+	conn.terminate()
+
+	return err
+}
@@ -0,0 +1,400 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package mgmt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// A MemoryReader provides read access to the memory and interface object properties of a device.
+// Connection implements it.
+type MemoryReader interface {
+	ReadDeviceDescriptor() (uint16, error)
+	ReadMemory(addr uint16, count int) ([]byte, error)
+	ReadProperty(object, property uint8, start uint16, count uint8) ([]byte, error)
+}
+
+// These are errors that can occur when reading the tables of a device.
+var (
+	ErrUnsupportedMask = errors.New("Table layout of the mask version is not supported")
+	ErrTableObject     = errors.New("Interface object of the table has not been found")
+	ErrTableAddress    = errors.New("Table is located outside of the addressable memory")
+	ErrTableIndex      = errors.New("Association refers to an unknown address")
+)
+
+// These are the locations of the tables in System 1 devices (BCU 1 and BCU 2).
+const (
+	system1AddrTable = 0x0116
+	system1AssocPtr  = 0x0111
+	system1ObjectPtr = 0x0112
+	system1PtrBase   = 0x0100
+)
+
+// These are the interface objects and properties through which System B devices reveal the
+// location of their tables.
+const (
+	objectTypeAddrTable   = 1
+	objectTypeAssocTable  = 2
+	objectTypeGroupObject = 9
+
+	pidObjectType     = 1
+	pidTableReference = 7
+
+	// maxObjectIndex limits the search for the interface objects.
+	maxObjectIndex = 32
+)
+
+// isSystem1 determines whether the mask version belongs to a System 1 device (BCU 1 or BCU 2).
+func isSystem1(mask uint16) bool {
+	return mask >= 0x0010 && mask <= 0x0013 || mask >= 0x0020 && mask <= 0x0025
+}
+
+// isSystemB determines whether the mask version belongs to a System B device.
+func isSystemB(mask uint16) bool {
+	return mask == 0x07B0
+}
+
+// ObjectFlags is the configuration of a group object.
+type ObjectFlags uint8
+
+// These are the flags of a group object.
+const (
+	FlagCommunication ObjectFlags = 1 << 2
+	FlagRead          ObjectFlags = 1 << 3
+	FlagWrite         ObjectFlags = 1 << 4
+	FlagTransmit      ObjectFlags = 1 << 6
+	FlagUpdate        ObjectFlags = 1 << 7
+)
+
+// Priority returns the priority with which the group object transmits.
+func (flags ObjectFlags) Priority() cemi.Priority {
+	return cemi.Priority(flags & 3)
+}
+
+// String generates the flag letters as known from the ETS, e.g. "CRWTU".
+func (flags ObjectFlags) String() string {
+	letters := make([]byte, 0, 5)
+
+	for _, flag := range []struct {
+		flag   ObjectFlags
+		letter byte
+	}{
+		{FlagCommunication, 'C'},
+		{FlagRead, 'R'},
+		{FlagWrite, 'W'},
+		{FlagTransmit, 'T'},
+		{FlagUpdate, 'U'},
+	} {
+		if flags&flag.flag != 0 {
+			letters = append(letters, flag.letter)
+		}
+	}
+
+	return string(letters)
+}
+
+// ObjectType encodes the size of a group object's value.
+type ObjectType uint8
+
+// objectTypeBits contains the sizes of the object types, in bits.
+var objectTypeBits = [...]int{1, 2, 3, 4, 5, 6, 7, 8, 16, 24, 32, 48, 64, 80, 112}
+
+// Bits returns the size of the value in bits, or 0 if the type is unknown.
+func (typ ObjectType) Bits() int {
+	if int(typ) < len(objectTypeBits) {
+		return objectTypeBits[typ]
+	}
+
+	return 0
+}
+
+// String generates a string representation.
+func (typ ObjectType) String() string {
+	switch bits := typ.Bits(); {
+	case bits == 0:
+		return fmt.Sprintf("unknown type %d", uint8(typ))
+
+	case bits == 1:
+		return "1 bit"
+
+	case bits < 8:
+		return fmt.Sprintf("%d bits", bits)
+
+	case bits == 8:
+		return "1 byte"
+
+	default:
+		return fmt.Sprintf("%d bytes", bits/8)
+	}
+}
+
+// GroupObject is a group object of a device together with the group addresses it is linked to.
+type GroupObject struct {
+	Number    int
+	Flags     ObjectFlags
+	Type      ObjectType
+	Addresses []cemi.GroupAddr
+}
+
+// String describes the group object and its links.
+func (obj GroupObject) String() string {
+	addrs := make([]string, len(obj.Addresses))
+	for i, addr := range obj.Addresses {
+		addrs[i] = addr.String()
+	}
+
+	if len(addrs) == 0 {
+		return fmt.Sprintf("Group object %d (%v, %v) is not linked", obj.Number, obj.Type, obj.Flags)
+	}
+
+	return fmt.Sprintf("Group object %d (%v, %v) is linked to %s",
+		obj.Number, obj.Type, obj.Flags, strings.Join(addrs, ", "))
+}
+
+// Association links a group address to a group object.
+type Association struct {
+	// AddrIndex is the 1-based index of the group address in the address table.
+	AddrIndex int
+	Object    int
+}
+
+// Tables contains the decoded group address, association and group object tables of a device.
+type Tables struct {
+	MaskVersion  uint16
+	Addresses    []cemi.GroupAddr
+	Associations []Association
+	Objects      []GroupObject
+}
+
+// ReadTables downloads the tables of the device. The layout is chosen by the mask version; System
+// 1 (BCU 1 and BCU 2) and System B devices are supported.
+func ReadTables(mem MemoryReader) (*Tables, error) {
+	mask, err := mem.ReadDeviceDescriptor()
+	if err != nil {
+		return nil, err
+	}
+
+	tables := &Tables{MaskVersion: mask}
+
+	switch {
+	case isSystem1(mask):
+		err = tables.readSystem1(mem)
+
+	case isSystemB(mask):
+		err = tables.readSystemB(mem)
+
+	default:
+		err = ErrUnsupportedMask
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tables.link(); err != nil {
+		return nil, err
+	}
+
+	return tables, nil
+}
+
+// readSystem1 reads the tables with the layout of System 1 devices, whose tables are located at
+// fixed addresses or referred to by pointers at fixed addresses.
+func (tables *Tables) readSystem1(mem MemoryReader) error {
+	header, err := mem.ReadMemory(system1AddrTable, 1)
+	if err != nil {
+		return err
+	}
+
+	// The first entry is the individual address of the device.
+	if count := int(header[0]); count > 1 {
+		data, err := mem.ReadMemory(system1AddrTable+1, count*2)
+		if err != nil {
+			return err
+		}
+
+		for i := 2; i+1 < len(data); i += 2 {
+			tables.Addresses = append(tables.Addresses,
+				cemi.GroupAddr(data[i])<<8|cemi.GroupAddr(data[i+1]))
+		}
+	}
+
+	pointers, err := mem.ReadMemory(system1AssocPtr, 2)
+	if err != nil {
+		return err
+	}
+
+	assocTable := system1PtrBase + uint16(pointers[0])
+	objectTable := system1PtrBase + uint16(pointers[1])
+
+	header, err = mem.ReadMemory(assocTable, 1)
+	if err != nil {
+		return err
+	}
+
+	if count := int(header[0]); count > 0 {
+		data, err := mem.ReadMemory(assocTable+1, count*2)
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i+1 < len(data); i += 2 {
+			tables.Associations = append(tables.Associations,
+				Association{AddrIndex: int(data[i]), Object: int(data[i+1])})
+		}
+	}
+
+	// The object count is followed by the pointer to the RAM flags table.
+	header, err = mem.ReadMemory(objectTable, 2)
+	if err != nil {
+		return err
+	}
+
+	if count := int(header[0]); count > 0 {
+		data, err := mem.ReadMemory(objectTable+2, count*3)
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i+2 < len(data); i += 3 {
+			tables.Objects = append(tables.Objects, GroupObject{
+				Number: i / 3,
+				Flags:  ObjectFlags(data[i+1]),
+				Type:   ObjectType(data[i+2]),
+			})
+		}
+	}
+
+	return nil
+}
+
+// findTables locates the tables of a System B device through its interface objects.
+func findTables(mem MemoryReader) (map[uint16]uint16, error) {
+	refs := make(map[uint16]uint16)
+
+	for index := 0; index < maxObjectIndex && len(refs) < 3; index++ {
+		typ, err := mem.ReadProperty(uint8(index), pidObjectType, 1, 1)
+		if err == ErrPropertyAccess {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if len(typ) < 2 {
+			continue
+		}
+
+		objectType := uint16(typ[0])<<8 | uint16(typ[1])
+		if objectType != objectTypeAddrTable && objectType != objectTypeAssocTable &&
+			objectType != objectTypeGroupObject {
+			continue
+		}
+
+		ref, err := mem.ReadProperty(uint8(index), pidTableReference, 1, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(ref) < 4 {
+			return nil, ErrTableObject
+		}
+
+		if ref[0] != 0 || ref[1] != 0 {
+			return nil, ErrTableAddress
+		}
+
+		refs[objectType] = uint16(ref[2])<<8 | uint16(ref[3])
+	}
+
+	if len(refs) < 3 {
+		return nil, ErrTableObject
+	}
+
+	return refs, nil
+}
+
+// readSystemBTable reads a table whose entry count is stored in its first two bytes.
+func readSystemBTable(mem MemoryReader, addr uint16, entrySize int) ([]byte, error) {
+	header, err := mem.ReadMemory(addr, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	count := int(header[0])<<8 | int(header[1])
+	if count == 0 {
+		return nil, nil
+	}
+
+	return mem.ReadMemory(addr+2, count*entrySize)
+}
+
+// readSystemB reads the tables with the layout of System B devices.
+func (tables *Tables) readSystemB(mem MemoryReader) error {
+	refs, err := findTables(mem)
+	if err != nil {
+		return err
+	}
+
+	data, err := readSystemBTable(mem, refs[objectTypeAddrTable], 2)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i+1 < len(data); i += 2 {
+		tables.Addresses = append(tables.Addresses,
+			cemi.GroupAddr(data[i])<<8|cemi.GroupAddr(data[i+1]))
+	}
+
+	data, err = readSystemBTable(mem, refs[objectTypeAssocTable], 4)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i+3 < len(data); i += 4 {
+		tables.Associations = append(tables.Associations, Association{
+			AddrIndex: int(data[i])<<8 | int(data[i+1]),
+			Object:    int(data[i+2])<<8 | int(data[i+3]),
+		})
+	}
+
+	data, err = readSystemBTable(mem, refs[objectTypeGroupObject], 2)
+	if err != nil {
+		return err
+	}
+
+	// Group objects are numbered starting with 1.
+	for i := 0; i+1 < len(data); i += 2 {
+		tables.Objects = append(tables.Objects, GroupObject{
+			Number: i/2 + 1,
+			Flags:  ObjectFlags(data[i]),
+			Type:   ObjectType(data[i+1]),
+		})
+	}
+
+	return nil
+}
+
+// link resolves the associations and attaches the group addresses to their group objects.
+func (tables *Tables) link() error {
+	objects := make(map[int]*GroupObject, len(tables.Objects))
+	for i := range tables.Objects {
+		objects[tables.Objects[i].Number] = &tables.Objects[i]
+	}
+
+	for _, assoc := range tables.Associations {
+		if assoc.AddrIndex < 1 || assoc.AddrIndex > len(tables.Addresses) {
+			return ErrTableIndex
+		}
+
+		if obj, ok := objects[assoc.Object]; ok {
+			obj.Addresses = append(obj.Addresses, tables.Addresses[assoc.AddrIndex-1])
+		}
+	}
+
+	return nil
+}
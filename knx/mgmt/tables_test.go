@@ -0,0 +1,132 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package mgmt
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+var _ MemoryReader = (*Connection)(nil)
+
+// fakeMemory is a MemoryReader which serves the memory and properties of a device image.
+type fakeMemory struct {
+	mask       uint16
+	memory     map[uint16]byte
+	properties map[[2]uint8][]byte
+}
+
+func (mem *fakeMemory) ReadDeviceDescriptor() (uint16, error) {
+	return mem.mask, nil
+}
+
+func (mem *fakeMemory) ReadMemory(addr uint16, count int) ([]byte, error) {
+	data := make([]byte, count)
+	for i := range data {
+		data[i] = mem.memory[addr+uint16(i)]
+	}
+
+	return data, nil
+}
+
+func (mem *fakeMemory) ReadProperty(
+	object, property uint8,
+	start uint16,
+	count uint8,
+) ([]byte, error) {
+	value, ok := mem.properties[[2]uint8{object, property}]
+	if !ok {
+		return nil, ErrPropertyAccess
+	}
+
+	return value, nil
+}
+
+// store writes the data to memory.
+func (mem *fakeMemory) store(addr uint16, data ...byte) {
+	for i, b := range data {
+		mem.memory[addr+uint16(i)] = b
+	}
+}
+
+func expectTables(t *testing.T, mem MemoryReader, expected []GroupObject) {
+	tables, err := ReadTables(mem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(tables.Objects, expected) {
+		t.Errorf("Unexpected group objects: %v", tables.Objects)
+	}
+}
+
+func TestReadTables(t *testing.T) {
+	ga1 := cemi.NewGroupAddr3(1, 2, 3)
+	ga2 := cemi.NewGroupAddr3(1, 2, 4)
+
+	t.Run("System1", func(t *testing.T) {
+		mem := &fakeMemory{mask: 0x0012, memory: make(map[uint16]byte)}
+
+		// The address table contains the individual address and two group addresses.
+		mem.store(0x0116, 3, 0x11, 0x05, 0x0A, 0x03, 0x0A, 0x04)
+		mem.store(0x0111, 0x30, 0x40)
+
+		// Object 0 is linked to both group addresses, object 1 to the second one.
+		mem.store(0x0130, 3, 1, 0, 2, 0, 2, 1)
+		mem.store(0x0140, 2, 0x50, 0x60, 0x5C, 0, 0x61, 0x4C, 7)
+
+		expectTables(t, mem, []GroupObject{
+			{Number: 0, Flags: 0x5C, Type: 0, Addresses: []cemi.GroupAddr{ga1, ga2}},
+			{Number: 1, Flags: 0x4C, Type: 7, Addresses: []cemi.GroupAddr{ga2}},
+		})
+	})
+
+	t.Run("SystemB", func(t *testing.T) {
+		mem := &fakeMemory{
+			mask:   0x07B0,
+			memory: make(map[uint16]byte),
+			properties: map[[2]uint8][]byte{
+				{0, pidObjectType}:     {0, 0},
+				{1, pidObjectType}:     {0, objectTypeAddrTable},
+				{1, pidTableReference}: {0, 0, 0x40, 0x00},
+				{2, pidObjectType}:     {0, objectTypeAssocTable},
+				{2, pidTableReference}: {0, 0, 0x41, 0x00},
+				{3, pidObjectType}:     {0, 3},
+				{4, pidObjectType}:     {0, objectTypeGroupObject},
+				{4, pidTableReference}: {0, 0, 0x42, 0x00},
+			},
+		}
+
+		mem.store(0x4000, 0, 2, 0x0A, 0x03, 0x0A, 0x04)
+		mem.store(0x4100, 0, 2, 0, 1, 0, 1, 0, 2, 0, 2)
+		mem.store(0x4200, 0, 2, 0xDC, 8, 0x4C, 0)
+
+		expectTables(t, mem, []GroupObject{
+			{Number: 1, Flags: 0xDC, Type: 8, Addresses: []cemi.GroupAddr{ga1}},
+			{Number: 2, Flags: 0x4C, Type: 0, Addresses: []cemi.GroupAddr{ga2}},
+		})
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		if _, err := ReadTables(&fakeMemory{mask: 0x0705}); err != ErrUnsupportedMask {
+			t.Fatalf("Expected %v, got %v", ErrUnsupportedMask, err)
+		}
+	})
+}
+
+func TestGroupObject_String(t *testing.T) {
+	obj := GroupObject{
+		Number:    3,
+		Flags:     FlagCommunication | FlagWrite | FlagTransmit,
+		Type:      7,
+		Addresses: []cemi.GroupAddr{cemi.NewGroupAddr3(1, 2, 3), cemi.NewGroupAddr3(1, 2, 4)},
+	}
+
+	expected := "Group object 3 (1 byte, CWT) is linked to 1/2/3, 1/2/4"
+	if str := obj.String(); str != expected {
+		t.Errorf("Unexpected description: %s", str)
+	}
+}
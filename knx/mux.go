@@ -0,0 +1,160 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// A GroupFilter selects the group events that a subscriber is interested in.
+type GroupFilter func(event GroupEvent) bool
+
+// FilterDestinations creates a filter for events which target one of the given group addresses.
+func FilterDestinations(addrs ...cemi.GroupAddr) GroupFilter {
+	set := make(map[cemi.GroupAddr]bool, len(addrs))
+	for _, addr := range addrs {
+		set[addr] = true
+	}
+
+	return func(event GroupEvent) bool {
+		return set[event.Destination]
+	}
+}
+
+// FilterCommands creates a filter for events with one of the given commands.
+func FilterCommands(commands ...GroupCommand) GroupFilter {
+	return func(event GroupEvent) bool {
+		for _, command := range commands {
+			if event.Command == command {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// A Subscription receives the group events that match its filter.
+type Subscription struct {
+	// dropped is accessed atomically. It comes first, because 64-bit atomic operations require
+	// 8-byte alignment, which only the first word of an allocated struct has on 32-bit platforms.
+	dropped uint64
+
+	mux    *GroupMux
+	filter GroupFilter
+	events chan GroupEvent
+}
+
+// Events returns the channel on which the matching events can be received. It is closed when the
+// subscription is cancelled or the inbound channel of the group client is closed.
+func (sub *Subscription) Events() <-chan GroupEvent {
+	return sub.events
+}
+
+// Dropped returns the number of events which have been dropped, because the subscriber did not
+// keep up.
+func (sub *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&sub.dropped)
+}
+
+// Unsubscribe cancels the subscription.
+func (sub *Subscription) Unsubscribe() {
+	sub.mux.mu.Lock()
+	defer sub.mux.mu.Unlock()
+
+	if _, ok := sub.mux.subs[sub]; ok {
+		delete(sub.mux.subs, sub)
+		close(sub.events)
+	}
+}
+
+// A GroupMux distributes the inbound events of a group client to any number of subscribers, so
+// that independent components can share one connection. Every subscriber has its own buffer; a
+// subscriber whose buffer is full misses events instead of stalling the others.
+type GroupMux struct {
+	client GroupClient
+
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// NewGroupMux starts distributing the inbound events of the group client. The mux must be the only
+// reader of the client's inbound channel.
+func NewGroupMux(client GroupClient) *GroupMux {
+	mux := &GroupMux{
+		client: client,
+		subs:   make(map[*Subscription]struct{}),
+	}
+
+	go mux.serve()
+
+	return mux
+}
+
+// serve distributes the inbound events until the inbound channel is closed.
+func (mux *GroupMux) serve() {
+	util.Log(mux, "Started worker")
+	defer util.Log(mux, "Worker exited")
+
+	for event := range mux.client.Inbound() {
+		mux.mu.Lock()
+
+		for sub := range mux.subs {
+			if sub.filter != nil && !sub.filter(event) {
+				continue
+			}
+
+			select {
+			case sub.events <- event:
+			default:
+				atomic.AddUint64(&sub.dropped, 1)
+				util.Log(mux, "Dropped event for %v, because a subscriber is too slow",
+					event.Destination)
+			}
+		}
+
+		mux.mu.Unlock()
+	}
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	mux.closed = true
+
+	for sub := range mux.subs {
+		delete(mux.subs, sub)
+		close(sub.events)
+	}
+}
+
+// Subscribe registers a subscriber for the events that match the filter. A nil filter matches all
+// events. The buffer size determines how many events may be queued for the subscriber.
+func (mux *GroupMux) Subscribe(filter GroupFilter, bufferSize int) *Subscription {
+	sub := &Subscription{
+		mux:    mux,
+		filter: filter,
+		events: make(chan GroupEvent, bufferSize),
+	}
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if mux.closed {
+		close(sub.events)
+	} else {
+		mux.subs[sub] = struct{}{}
+	}
+
+	return sub
+}
+
+// Send transmits a group event through the group client.
+func (mux *GroupMux) Send(event GroupEvent) error {
+	return mux.client.Send(event)
+}
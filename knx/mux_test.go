@@ -0,0 +1,65 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"testing"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+func TestGroupMux(t *testing.T) {
+	conn := newBlockingConn()
+	mux := NewGroupMux(conn)
+
+	ga1 := cemi.NewGroupAddr3(1, 2, 3)
+	ga2 := cemi.NewGroupAddr3(1, 2, 4)
+
+	all := mux.Subscribe(nil, 4)
+	writes := mux.Subscribe(FilterCommands(GroupWrite), 4)
+	single := mux.Subscribe(FilterDestinations(ga2), 1)
+
+	write := GroupEvent{Command: GroupWrite, Destination: ga1, Data: []byte{1}}
+	read := GroupEvent{Command: GroupRead, Destination: ga2}
+
+	conn.inbound <- write
+	conn.inbound <- read
+	conn.inbound <- read
+
+	expectGroupEvent(t, all.Events(), write)
+	expectGroupEvent(t, all.Events(), read)
+	expectGroupEvent(t, all.Events(), read)
+	expectGroupEvent(t, writes.Events(), write)
+	expectGroupEvent(t, single.Events(), read)
+
+	// The second read did not fit into the buffer.
+	waitUntil(t, func() bool { return single.Dropped() == 1 })
+
+	single.Unsubscribe()
+	if _, ok := <-single.Events(); ok {
+		t.Fatal("Events should be closed after unsubscribing")
+	}
+
+	t.Run("Send", func(t *testing.T) {
+		close(conn.release)
+
+		if err := mux.Send(write); err != nil {
+			t.Fatal(err)
+		}
+
+		conn.expectSent(t)
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		conn.Close()
+
+		if _, ok := <-all.Events(); ok {
+			t.Fatal("Events should be closed with the inbound channel")
+		}
+
+		if _, ok := <-mux.Subscribe(nil, 1).Events(); ok {
+			t.Fatal("Late subscriptions should be closed")
+		}
+	})
+}
@@ -0,0 +1,437 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Package policy sends the values of group objects which are owned by software, e.g. virtual
+// sensors, the way a well-configured KNX device would: on change, on significant change, at a
+// limited rate and cyclically.
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// Policy determines when the value of a group object is sent. The zero policy sends every update.
+type Policy struct {
+	// OnChange suppresses values that do not differ from the value that has been sent last.
+	// Values are compared in their encoded form, i.e. with the precision of the datapoint type.
+	OnChange bool
+
+	// AbsoluteDelta suppresses values that differ from the value that has been sent last by less
+	// than the given amount. It implies OnChange.
+	AbsoluteDelta float64
+
+	// RelativeDelta suppresses values that differ from the value that has been sent last by less
+	// than the given fraction of it, e.g. 0.05 for 5 %. If both deltas are given, exceeding either
+	// of them suffices. It implies OnChange.
+	RelativeDelta float64
+
+	// MinInterval is the minimum time between two telegrams. A value that arrives earlier is held
+	// back; once the interval has elapsed, the latest value is sent if it is still significant.
+	MinInterval time.Duration
+
+	// MaxInterval is the maximum time between two telegrams. If nothing has been sent for this
+	// long, the current value is sent again. 0 disables cyclic sending.
+	MaxInterval time.Duration
+}
+
+// usesDelta determines whether the policy compares values numerically.
+func (policy Policy) usesDelta() bool {
+	return policy.AbsoluteDelta > 0 || policy.RelativeDelta > 0
+}
+
+// Object is a group object whose value is sent according to a policy.
+type Object struct {
+	// Type is a value of the datapoint type, e.g. new(dpt.DPT_9001). All values of the object must
+	// have the same type, which is used to encode and compare them.
+	Type dpt.DatapointValue
+
+	Policy Policy
+}
+
+// Config allows you to configure the publisher.
+type Config struct {
+	// Objects contains the group objects by their group address.
+	Objects map[cemi.GroupAddr]Object
+
+	// Clock is used for all intervals. Tests may use a util.FakeClock in order to control the
+	// passage of time.
+	Clock util.Clock
+}
+
+// DefaultConfig is a good default configuration for a publisher. Objects must be added.
+var DefaultConfig = Config{
+	Clock: util.RealClock,
+}
+
+// checkConfig makes sure that the configuration is actually usable.
+func checkConfig(config Config) Config {
+	if config.Clock == nil {
+		config.Clock = DefaultConfig.Clock
+	}
+
+	return config
+}
+
+// These are errors that can occur when using a Publisher.
+var (
+	ErrUnknownObject = errors.New("Group address does not belong to a configured object")
+	ErrType          = errors.New("Value does not match the datapoint type of the object")
+	ErrInvalidType   = errors.New("Datapoint type must be given as a pointer, e.g. new(dpt.DPT_9001)")
+)
+
+// value is an encoded value, along with its numeric representation.
+type value struct {
+	data    []byte
+	number  float64
+	numeric bool
+}
+
+// numeric converts the datapoint value to a number, if its type is based on one.
+func numeric(datapoint dpt.DatapointValue) (float64, bool) {
+	v := reflect.Indirect(reflect.ValueOf(datapoint))
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return 1, true
+		}
+
+		return 0, true
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+
+	return 0, false
+}
+
+// object is the state of a group object.
+type object struct {
+	Object
+	typ reflect.Type
+
+	// sending is held from the decision to send a value until it has been sent, so that values go
+	// out on the bus in the order in which they are recorded as sent. It must be acquired before
+	// the lock of the publisher.
+	sending sync.Mutex
+
+	current  *value
+	sent     *value
+	lastSent time.Time
+	holding  bool
+}
+
+// encode packs the datapoint value. It is compared in the form in which it is received by others,
+// so the packed value is unpacked again.
+func (obj *object) encode(datapoint dpt.DatapointValue) (*value, error) {
+	if reflect.TypeOf(datapoint) != obj.typ {
+		return nil, ErrType
+	}
+
+	data := datapoint.Pack()
+
+	decoded := reflect.New(obj.typ.Elem()).Interface().(dpt.DatapointValue)
+	if err := decoded.Unpack(data); err != nil {
+		return nil, err
+	}
+
+	number, ok := numeric(decoded)
+
+	return &value{data: data, number: number, numeric: ok}, nil
+}
+
+// significant determines whether the value differs enough from the value that has been sent last.
+func (obj *object) significant(val *value) bool {
+	if obj.sent == nil {
+		return true
+	}
+
+	policy := obj.Policy
+
+	if policy.usesDelta() && val.numeric && obj.sent.numeric {
+		diff := math.Abs(val.number - obj.sent.number)
+
+		if policy.AbsoluteDelta > 0 && diff >= policy.AbsoluteDelta {
+			return true
+		}
+
+		if policy.RelativeDelta > 0 && diff > 0 &&
+			diff >= policy.RelativeDelta*math.Abs(obj.sent.number) {
+			return true
+		}
+
+		return false
+	}
+
+	if policy.OnChange || policy.usesDelta() {
+		return !bytes.Equal(val.data, obj.sent.data)
+	}
+
+	return true
+}
+
+// deadline returns the time at which the object needs attention.
+func (obj *object) deadline() (time.Time, bool) {
+	if obj.sent == nil {
+		return time.Time{}, false
+	}
+
+	if obj.holding {
+		return obj.lastSent.Add(obj.Policy.MinInterval), true
+	}
+
+	if obj.Policy.MaxInterval > 0 {
+		return obj.lastSent.Add(obj.Policy.MaxInterval), true
+	}
+
+	return time.Time{}, false
+}
+
+// A Publisher sends the values of group objects according to their policies. Update the values as
+// often as they are measured; the publisher decides which of them go out on the bus.
+type Publisher struct {
+	client knx.GroupClient
+	config Config
+	wake   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+	wait   sync.WaitGroup
+
+	// objects is not modified after creation, but the objects are protected by mu.
+	mu      sync.Mutex
+	objects map[cemi.GroupAddr]*object
+}
+
+// NewPublisher creates a publisher which sends through the given client.
+func NewPublisher(client knx.GroupClient, config Config) (*Publisher, error) {
+	config = checkConfig(config)
+
+	pub := &Publisher{
+		client:  client,
+		config:  config,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		objects: make(map[cemi.GroupAddr]*object, len(config.Objects)),
+	}
+
+	for addr, obj := range config.Objects {
+		typ := reflect.TypeOf(obj.Type)
+		if typ == nil || typ.Kind() != reflect.Ptr {
+			return nil, ErrInvalidType
+		}
+
+		pub.objects[addr] = &object{Object: obj, typ: typ}
+	}
+
+	pub.wait.Add(1)
+	go pub.serve()
+
+	return pub, nil
+}
+
+// Update sets the current value of the group object. The value is sent right away if the policy
+// permits it; the returned error is the result of sending. A value that is held back because of
+// the minimum interval is sent later by the publisher.
+func (pub *Publisher) Update(addr cemi.GroupAddr, datapoint dpt.DatapointValue) error {
+	obj, ok := pub.objects[addr]
+	if !ok {
+		return ErrUnknownObject
+	}
+
+	obj.sending.Lock()
+	defer obj.sending.Unlock()
+
+	pub.mu.Lock()
+
+	val, err := obj.encode(datapoint)
+	if err != nil {
+		pub.mu.Unlock()
+		return err
+	}
+
+	obj.current = val
+
+	now := pub.config.Clock.Now()
+
+	switch {
+	case !obj.significant(val):
+		// A held back value which has become insignificant need not be sent anymore.
+		obj.holding = false
+		pub.mu.Unlock()
+
+		return nil
+
+	case obj.sent != nil && obj.Policy.MinInterval > 0 &&
+		now.Sub(obj.lastSent) < obj.Policy.MinInterval:
+		obj.holding = true
+		pub.mu.Unlock()
+
+		pub.notify()
+		return nil
+	}
+
+	pub.markSent(obj, now)
+	pub.mu.Unlock()
+
+	pub.notify()
+
+	return pub.client.Send(knx.GroupEvent{
+		Command:     knx.GroupWrite,
+		Destination: addr,
+		Data:        val.data,
+	})
+}
+
+// markSent records that the current value of the object is sent. The caller must hold the lock
+// and the sending mutex of the object.
+func (pub *Publisher) markSent(obj *object, now time.Time) {
+	obj.sent = obj.current
+	obj.lastSent = now
+	obj.holding = false
+}
+
+// Close stops sending held back values and cyclic updates. The client is left open.
+func (pub *Publisher) Close() {
+	pub.once.Do(func() { close(pub.done) })
+	pub.wait.Wait()
+}
+
+// notify wakes up the worker, so that it takes changed deadlines into account.
+func (pub *Publisher) notify() {
+	select {
+	case pub.wake <- struct{}{}:
+	default:
+	}
+}
+
+// due collects the group addresses of the objects whose values have to be sent now and
+// determines the time until the next deadline of the others, or 0 if there is none.
+func (pub *Publisher) due(now time.Time) ([]cemi.GroupAddr, time.Duration) {
+	pub.mu.Lock()
+	defer pub.mu.Unlock()
+
+	var addrs []cemi.GroupAddr
+	var wait time.Duration
+
+	for addr, obj := range pub.objects {
+		deadline, ok := obj.deadline()
+		if !ok {
+			continue
+		}
+
+		if until := deadline.Sub(now); until > 0 {
+			if wait == 0 || until < wait {
+				wait = until
+			}
+
+			continue
+		}
+
+		addrs = append(addrs, addr)
+	}
+
+	sort.Sort(groupAddrSlice(addrs))
+
+	return addrs, wait
+}
+
+// groupAddrSlice sorts group addresses in ascending order.
+type groupAddrSlice []cemi.GroupAddr
+
+func (addrs groupAddrSlice) Len() int           { return len(addrs) }
+func (addrs groupAddrSlice) Less(i, j int) bool { return addrs[i] < addrs[j] }
+func (addrs groupAddrSlice) Swap(i, j int)      { addrs[i], addrs[j] = addrs[j], addrs[i] }
+
+// resend sends the current value of the object, unless it is no longer due because it has been
+// sent in the meantime.
+func (pub *Publisher) resend(addr cemi.GroupAddr) {
+	obj := pub.objects[addr]
+
+	obj.sending.Lock()
+	defer obj.sending.Unlock()
+
+	pub.mu.Lock()
+
+	now := pub.config.Clock.Now()
+
+	deadline, ok := obj.deadline()
+	if !ok || deadline.After(now) {
+		pub.mu.Unlock()
+		return
+	}
+
+	pub.markSent(obj, now)
+	data := obj.current.data
+	pub.mu.Unlock()
+
+	err := pub.client.Send(knx.GroupEvent{
+		Command:     knx.GroupWrite,
+		Destination: addr,
+		Data:        data,
+	})
+	if err != nil {
+		util.Log(pub, "Failed to send value of %v: %v", addr, err)
+	}
+}
+
+// serve sends held back values and cyclic updates until the publisher is closed.
+func (pub *Publisher) serve() {
+	util.Log(pub, "Started worker")
+	defer util.Log(pub, "Worker exited")
+
+	defer pub.wait.Done()
+
+	for {
+		addrs, wait := pub.due(pub.config.Clock.Now())
+
+		if len(addrs) > 0 {
+			for _, addr := range addrs {
+				pub.resend(addr)
+			}
+
+			// Sending has moved the deadlines.
+			continue
+		}
+
+		var timer util.Timer
+		var timeout <-chan time.Time
+
+		if wait > 0 {
+			timer = pub.config.Clock.NewTimer(wait)
+			timeout = timer.C()
+		}
+
+		select {
+		case <-pub.done:
+			if timer != nil {
+				timer.Stop()
+			}
+
+			return
+
+		case <-pub.wake:
+		case <-timeout:
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package policy
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// testClient records the events that are sent.
+type testClient struct {
+	sent chan knx.GroupEvent
+}
+
+func (client *testClient) Send(event knx.GroupEvent) error {
+	client.sent <- event
+	return nil
+}
+
+func (client *testClient) Inbound() <-chan knx.GroupEvent {
+	return nil
+}
+
+// expectSent waits for the value to be sent.
+func (client *testClient) expectSent(t *testing.T, datapoint dpt.DatapointValue) {
+	select {
+	case event := <-client.sent:
+		if event.Command != knx.GroupWrite || !bytes.Equal(event.Data, datapoint.Pack()) {
+			t.Fatalf("Expected %v, got %+v", datapoint, event)
+		}
+
+	case <-time.After(time.Second):
+		t.Fatalf("Expected %v to be sent", datapoint)
+	}
+}
+
+// expectNothing makes sure that nothing has been sent.
+func (client *testClient) expectNothing(t *testing.T) {
+	if len(client.sent) > 0 {
+		t.Fatalf("Expected nothing to be sent, got %+v", <-client.sent)
+	}
+}
+
+var addr = cemi.NewGroupAddr3(1, 2, 3)
+
+func newTestPublisher(t *testing.T, obj Object) (*Publisher, *testClient, *util.FakeClock) {
+	client := &testClient{sent: make(chan knx.GroupEvent, 16)}
+	clock := util.NewFakeClock(time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	pub, err := NewPublisher(client, Config{
+		Objects: map[cemi.GroupAddr]Object{addr: obj},
+		Clock:   clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pub, client, clock
+}
+
+func temperature(value float32) *dpt.DPT_9001 {
+	datapoint := dpt.DPT_9001(value)
+	return &datapoint
+}
+
+// update sets the value and expects sending it to succeed.
+func update(t *testing.T, pub *Publisher, datapoint dpt.DatapointValue) {
+	if err := pub.Update(addr, datapoint); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	t.Run("Always", func(t *testing.T) {
+		pub, client, _ := newTestPublisher(t, Object{Type: new(dpt.DPT_9001)})
+		defer pub.Close()
+
+		update(t, pub, temperature(20))
+		update(t, pub, temperature(20))
+
+		client.expectSent(t, temperature(20))
+		client.expectSent(t, temperature(20))
+	})
+
+	t.Run("OnChange", func(t *testing.T) {
+		pub, client, _ := newTestPublisher(t, Object{
+			Type:   new(dpt.DPT_9001),
+			Policy: Policy{OnChange: true},
+		})
+		defer pub.Close()
+
+		update(t, pub, temperature(20))
+		client.expectSent(t, temperature(20))
+
+		// Differences below the precision of the datapoint type are not a change.
+		update(t, pub, temperature(20.001))
+		client.expectNothing(t)
+
+		update(t, pub, temperature(21))
+		client.expectSent(t, temperature(21))
+	})
+
+	t.Run("AbsoluteDelta", func(t *testing.T) {
+		pub, client, _ := newTestPublisher(t, Object{
+			Type:   new(dpt.DPT_9001),
+			Policy: Policy{AbsoluteDelta: 0.5},
+		})
+		defer pub.Close()
+
+		update(t, pub, temperature(20))
+		client.expectSent(t, temperature(20))
+
+		update(t, pub, temperature(20.3))
+		update(t, pub, temperature(19.7))
+		client.expectNothing(t)
+
+		// The difference is measured against the value that has been sent.
+		update(t, pub, temperature(20.6))
+		client.expectSent(t, temperature(20.6))
+	})
+
+	t.Run("RelativeDelta", func(t *testing.T) {
+		counter := func(value uint32) *dpt.DPT_12001 {
+			datapoint := dpt.DPT_12001(value)
+			return &datapoint
+		}
+
+		pub, client, _ := newTestPublisher(t, Object{
+			Type:   new(dpt.DPT_12001),
+			Policy: Policy{RelativeDelta: 0.1},
+		})
+		defer pub.Close()
+
+		update(t, pub, counter(100))
+		client.expectSent(t, counter(100))
+
+		update(t, pub, counter(105))
+		client.expectNothing(t)
+
+		update(t, pub, counter(110))
+		client.expectSent(t, counter(110))
+	})
+
+	t.Run("MinInterval", func(t *testing.T) {
+		pub, client, clock := newTestPublisher(t, Object{
+			Type:   new(dpt.DPT_9001),
+			Policy: Policy{OnChange: true, MinInterval: time.Second},
+		})
+		defer pub.Close()
+
+		update(t, pub, temperature(20))
+		client.expectSent(t, temperature(20))
+
+		update(t, pub, temperature(21))
+		update(t, pub, temperature(22))
+		client.expectNothing(t)
+
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		client.expectSent(t, temperature(22))
+
+		// A held back value that returns to the value that has been sent is dropped.
+		clock.Advance(100 * time.Millisecond)
+		update(t, pub, temperature(23))
+		update(t, pub, temperature(22))
+
+		clock.Advance(time.Second)
+		update(t, pub, temperature(22))
+		client.expectNothing(t)
+	})
+
+	t.Run("MaxInterval", func(t *testing.T) {
+		pub, client, clock := newTestPublisher(t, Object{
+			Type:   new(dpt.DPT_9001),
+			Policy: Policy{OnChange: true, MaxInterval: time.Minute},
+		})
+		defer pub.Close()
+
+		update(t, pub, temperature(20))
+		client.expectSent(t, temperature(20))
+
+		for i := 0; i < 3; i++ {
+			clock.BlockUntil(1)
+			clock.Advance(time.Minute)
+			client.expectSent(t, temperature(20))
+		}
+
+		// Sending a change postpones the cyclic update.
+		clock.BlockUntil(1)
+		clock.Advance(30 * time.Second)
+		update(t, pub, temperature(21))
+		client.expectSent(t, temperature(21))
+
+		clock.Advance(30 * time.Second)
+		client.expectNothing(t)
+
+		clock.BlockUntil(1)
+		clock.Advance(30 * time.Second)
+		client.expectSent(t, temperature(21))
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		pub, _, _ := newTestPublisher(t, Object{Type: new(dpt.DPT_9001)})
+		defer pub.Close()
+
+		if err := pub.Update(cemi.NewGroupAddr3(1, 2, 4), temperature(20)); err != ErrUnknownObject {
+			t.Fatalf("Expected %v, got %v", ErrUnknownObject, err)
+		}
+
+		if err := pub.Update(addr, new(dpt.DPT_9004)); err != ErrType {
+			t.Fatalf("Expected %v, got %v", ErrType, err)
+		}
+
+		_, err := NewPublisher(&testClient{}, Config{Objects: map[cemi.GroupAddr]Object{addr: {}}})
+		if err != ErrInvalidType {
+			t.Fatalf("Expected %v, got %v", ErrInvalidType, err)
+		}
+	})
+}
+
+// blockingClient holds the first event back until it is released.
+type blockingClient struct {
+	sent    chan knx.GroupEvent
+	release chan struct{}
+	once    sync.Once
+}
+
+func (client *blockingClient) Send(event knx.GroupEvent) error {
+	client.sent <- event
+	client.once.Do(func() { <-client.release })
+
+	return nil
+}
+
+func (client *blockingClient) Inbound() <-chan knx.GroupEvent {
+	return nil
+}
+
+func TestPublisher_order(t *testing.T) {
+	client := &blockingClient{sent: make(chan knx.GroupEvent, 2), release: make(chan struct{})}
+
+	pub, err := NewPublisher(client, Config{
+		Objects: map[cemi.GroupAddr]Object{addr: {Type: new(dpt.DPT_9001)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer pub.Close()
+
+	results := make(chan error, 2)
+	go func() { results <- pub.Update(addr, temperature(20)) }()
+
+	if event := <-client.sent; !bytes.Equal(event.Data, temperature(20).Pack()) {
+		t.Fatalf("Unexpected event: %+v", event)
+	}
+
+	// The second value must not overtake the first one, which is still being sent.
+	go func() { results <- pub.Update(addr, temperature(21)) }()
+
+	select {
+	case event := <-client.sent:
+		t.Fatalf("Expected nothing to be sent, got %+v", event)
+
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(client.release)
+
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if event := <-client.sent; !bytes.Equal(event.Data, temperature(21).Pack()) {
+		t.Fatalf("Unexpected event: %+v", event)
+	}
+}
@@ -0,0 +1,442 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// PoolConfig allows you to configure the pooled client's behavior.
+type PoolConfig struct {
+	// QueueLength is the number of events that can be queued for each connection before sending
+	// blocks.
+	QueueLength int
+
+	// DedupWindow is the time span in which identical events that arrive through different
+	// connections are considered to be the same telegram.
+	DedupWindow time.Duration
+
+	// Clock is used for de-duplication. Tests may use a util.FakeClock in order to control the
+	// passage of time.
+	Clock util.Clock
+}
+
+// DefaultPoolConfig is a good default configuration for a GroupPool client.
+var DefaultPoolConfig = PoolConfig{
+	QueueLength: 64,
+	DedupWindow: time.Second,
+	Clock:       util.RealClock,
+}
+
+// checkPoolConfig makes sure that the configuration is actually usable.
+func checkPoolConfig(config PoolConfig) PoolConfig {
+	if config.QueueLength <= 0 {
+		config.QueueLength = DefaultPoolConfig.QueueLength
+	}
+
+	if config.DedupWindow <= 0 {
+		config.DedupWindow = DefaultPoolConfig.DedupWindow
+	}
+
+	if config.Clock == nil {
+		config.Clock = DefaultPoolConfig.Clock
+	}
+
+	return config
+}
+
+// maxPoolSize is the maximum number of connections in a pool.
+const maxPoolSize = 64
+
+// These are errors that can occur when using a GroupPool.
+var (
+	ErrPoolSize   = errors.New("A pool supports up to 64 connections")
+	ErrPoolClosed = errors.New("Pool has been closed")
+)
+
+// dedupFilter recognizes events that have already been received through another connection.
+type dedupFilter struct {
+	window time.Duration
+	seen   map[string]*dedupEntry
+}
+
+// dedupEntry records which connections have delivered an event.
+type dedupEntry struct {
+	time time.Time
+	mask uint64
+}
+
+// dedupKey identifies an event.
+func dedupKey(event GroupEvent) string {
+	key := []byte{
+		byte(event.Command),
+		byte(event.Source >> 8),
+		byte(event.Source),
+		byte(event.Destination >> 8),
+		byte(event.Destination),
+	}
+
+	return string(append(key, event.Data...))
+}
+
+// duplicate determines whether the event received through the given connection has already been
+// received through another connection. If the same connection delivers the event again, it is a new
+// telegram.
+func (filter *dedupFilter) duplicate(event GroupEvent, index int, now time.Time) bool {
+	key := dedupKey(event)
+	bit := uint64(1) << uint(index)
+
+	entry, ok := filter.seen[key]
+	if ok && now.Sub(entry.time) < filter.window && entry.mask&bit == 0 {
+		entry.mask |= bit
+		return true
+	}
+
+	filter.seen[key] = &dedupEntry{time: now, mask: bit}
+
+	return false
+}
+
+// prune forgets events that are older than the window.
+func (filter *dedupFilter) prune(now time.Time) {
+	for key, entry := range filter.seen {
+		if now.Sub(entry.time) >= filter.window {
+			delete(filter.seen, key)
+		}
+	}
+}
+
+// A poolJob is an event that waits to be sent.
+type poolJob struct {
+	event  GroupEvent
+	result chan<- error
+}
+
+// A poolWorker sends the events that have been assigned to its connection.
+type poolWorker struct {
+	conn GroupConn
+	jobs chan poolJob
+
+	// Protected by the pool's mutex
+	load  int
+	alive bool
+}
+
+// A poolRoute assigns a destination to a worker as long as events for it are pending.
+type poolRoute struct {
+	worker  *poolWorker
+	pending int
+}
+
+// An indexedEvent is an event that has been received through a connection of the pool.
+type indexedEvent struct {
+	index int
+	event GroupEvent
+}
+
+// A GroupPool is a group client that spreads outbound events across several connections, e.g.
+// multiple tunnels to one or more gateways. This circumvents the stop-and-wait behaviour of a single
+// tunnel.
+//
+// Events for the same destination are sent in order through the same connection as long as one of
+// them is pending. Other events are assigned to the connection with the fewest pending events.
+//
+// The inbound events of all connections are merged. Since every connection sees the same bus
+// traffic, events that have been received through another connection within the de-duplication
+// window are dropped.
+type GroupPool struct {
+	config  PoolConfig
+	workers []*poolWorker
+
+	mu     sync.Mutex
+	routes map[cemi.GroupAddr]*poolRoute
+
+	sendMu sync.RWMutex
+	closed bool
+
+	merged  chan indexedEvent
+	lost    chan int
+	inbound chan GroupEvent
+	done    chan struct{}
+	once    sync.Once
+	wait    sync.WaitGroup
+}
+
+// NewGroupPool establishes a connection with each of the given dialers. Pass the same dialer
+// multiple times in order to open several tunnels to one gateway. Dialers that fail are left out;
+// the pool only fails if no connection can be established.
+func NewGroupPool(dialers []GroupDialer, config PoolConfig) (*GroupPool, error) {
+	if len(dialers) == 0 {
+		return nil, ErrNoDialers
+	}
+
+	if len(dialers) > maxPoolSize {
+		return nil, ErrPoolSize
+	}
+
+	config = checkPoolConfig(config)
+
+	pool := &GroupPool{
+		config:  config,
+		routes:  make(map[cemi.GroupAddr]*poolRoute),
+		merged:  make(chan indexedEvent),
+		lost:    make(chan int),
+		inbound: make(chan GroupEvent),
+		done:    make(chan struct{}),
+	}
+
+	var err error
+
+	for index, dialer := range dialers {
+		var conn GroupConn
+
+		conn, err = dialer()
+		if err != nil {
+			util.Log(pool, "Dialer %d failed: %v", index, err)
+			continue
+		}
+
+		pool.workers = append(pool.workers, &poolWorker{
+			conn:  conn,
+			jobs:  make(chan poolJob, config.QueueLength),
+			alive: true,
+		})
+	}
+
+	if len(pool.workers) == 0 {
+		return nil, err
+	}
+
+	for index, worker := range pool.workers {
+		pool.wait.Add(2)
+		go pool.serveWorker(worker)
+		go pool.forwardInbound(index, worker.conn)
+	}
+
+	go pool.serveInbound()
+
+	return pool, nil
+}
+
+// assign selects the worker for an event with the given destination.
+func (pool *GroupPool) assign(dest cemi.GroupAddr) (*poolWorker, error) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	route, ok := pool.routes[dest]
+	if !ok {
+		var best *poolWorker
+
+		for _, worker := range pool.workers {
+			if worker.alive && (best == nil || worker.load < best.load) {
+				best = worker
+			}
+		}
+
+		if best == nil {
+			return nil, ErrNotConnected
+		}
+
+		route = &poolRoute{worker: best}
+		pool.routes[dest] = route
+	}
+
+	route.pending++
+	route.worker.load++
+
+	return route.worker, nil
+}
+
+// release is called when an event for the given destination is no longer pending.
+func (pool *GroupPool) release(worker *poolWorker, dest cemi.GroupAddr) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	worker.load--
+
+	if route, ok := pool.routes[dest]; ok {
+		route.pending--
+
+		if route.pending <= 0 {
+			delete(pool.routes, dest)
+		}
+	}
+}
+
+// serveWorker sends the events that have been queued for the worker.
+func (pool *GroupPool) serveWorker(worker *poolWorker) {
+	defer pool.wait.Done()
+
+	for job := range worker.jobs {
+		pool.mu.Lock()
+		alive := worker.alive
+		pool.mu.Unlock()
+
+		var err error
+
+		select {
+		case <-pool.done:
+			err = ErrPoolClosed
+
+		default:
+			if alive {
+				err = worker.conn.Send(job.event)
+			} else {
+				err = ErrNotConnected
+			}
+		}
+
+		pool.release(worker, job.event.Destination)
+		job.result <- err
+	}
+}
+
+// forwardInbound relays the inbound events of a connection to the merging worker.
+func (pool *GroupPool) forwardInbound(index int, conn GroupConn) {
+	defer pool.wait.Done()
+
+	for event := range conn.Inbound() {
+		select {
+		case <-pool.done:
+		case pool.merged <- indexedEvent{index, event}:
+		}
+	}
+
+	select {
+	case <-pool.done:
+	case pool.lost <- index:
+	}
+}
+
+// serveInbound merges and de-duplicates the inbound events of all connections.
+func (pool *GroupPool) serveInbound() {
+	util.Log(pool, "Started worker")
+	defer util.Log(pool, "Worker exited")
+
+	defer close(pool.inbound)
+
+	filter := dedupFilter{
+		window: pool.config.DedupWindow,
+		seen:   make(map[string]*dedupEntry),
+	}
+
+	prune := pool.config.Clock.NewTicker(pool.config.DedupWindow)
+	defer prune.Stop()
+
+	for open := len(pool.workers); open > 0; {
+		select {
+		case <-pool.done:
+			return
+
+		case <-prune.C():
+			filter.prune(pool.config.Clock.Now())
+
+		case index := <-pool.lost:
+			util.Log(pool, "Lost connection %d", index)
+
+			pool.mu.Lock()
+			pool.workers[index].alive = false
+			pool.mu.Unlock()
+
+			open--
+
+		case in := <-pool.merged:
+			if filter.duplicate(in.event, in.index, pool.config.Clock.Now()) {
+				continue
+			}
+
+			select {
+			case <-pool.done:
+				return
+
+			case pool.inbound <- in.event:
+			}
+		}
+	}
+}
+
+// SendAsync queues a group event for sending. The returned channel delivers the result once the
+// event has been sent.
+func (pool *GroupPool) SendAsync(event GroupEvent) <-chan error {
+	result := make(chan error, 1)
+
+	pool.sendMu.RLock()
+	defer pool.sendMu.RUnlock()
+
+	if pool.closed {
+		result <- ErrPoolClosed
+		return result
+	}
+
+	worker, err := pool.assign(event.Destination)
+	if err != nil {
+		result <- err
+		return result
+	}
+
+	select {
+	case <-pool.done:
+		pool.release(worker, event.Destination)
+		result <- ErrPoolClosed
+
+	case worker.jobs <- poolJob{event, result}:
+	}
+
+	return result
+}
+
+// Send transmits a group event and waits until it has been sent. Use SendAsync in order to send
+// multiple events concurrently.
+func (pool *GroupPool) Send(event GroupEvent) error {
+	return <-pool.SendAsync(event)
+}
+
+// Size returns the number of connections that are still alive.
+func (pool *GroupPool) Size() int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	size := 0
+	for _, worker := range pool.workers {
+		if worker.alive {
+			size++
+		}
+	}
+
+	return size
+}
+
+// Inbound returns the channel on which the merged group communication can be received. It is
+// closed when the pool is closed or all connections have been lost.
+func (pool *GroupPool) Inbound() <-chan GroupEvent {
+	return pool.inbound
+}
+
+// Close terminates all connections. Events that are still queued fail with ErrPoolClosed.
+func (pool *GroupPool) Close() {
+	pool.once.Do(func() {
+		close(pool.done)
+
+		// Wait for pending calls to SendAsync before the queues are closed.
+		pool.sendMu.Lock()
+		pool.closed = true
+
+		for _, worker := range pool.workers {
+			close(worker.jobs)
+		}
+
+		pool.sendMu.Unlock()
+
+		for _, worker := range pool.workers {
+			worker.conn.Close()
+		}
+
+		pool.wait.Wait()
+	})
+}
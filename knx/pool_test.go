@@ -0,0 +1,160 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+)
+
+// blockingConn is a GroupConn whose Send blocks until it is released.
+type blockingConn struct {
+	sent    chan GroupEvent
+	release chan struct{}
+	inbound chan GroupEvent
+	once    sync.Once
+}
+
+func newBlockingConn() *blockingConn {
+	return &blockingConn{
+		sent:    make(chan GroupEvent, 16),
+		release: make(chan struct{}),
+		inbound: make(chan GroupEvent),
+	}
+}
+
+func (conn *blockingConn) Send(event GroupEvent) error {
+	conn.sent <- event
+	<-conn.release
+	return nil
+}
+
+func (conn *blockingConn) Inbound() <-chan GroupEvent {
+	return conn.inbound
+}
+
+func (conn *blockingConn) Close() {
+	conn.once.Do(func() { close(conn.inbound) })
+}
+
+// expectSent waits for the connection to send an event.
+func (conn *blockingConn) expectSent(t *testing.T) GroupEvent {
+	select {
+	case event := <-conn.sent:
+		return event
+
+	case <-time.After(time.Second):
+		t.Fatal("Nothing has been sent")
+		return GroupEvent{}
+	}
+}
+
+func TestGroupPool(t *testing.T) {
+	t.Run("Distribute", func(t *testing.T) {
+		conns := []*blockingConn{newBlockingConn(), newBlockingConn(), newBlockingConn()}
+
+		var dialers []GroupDialer
+		for _, conn := range conns {
+			conn := conn
+			dialers = append(dialers, func() (GroupConn, error) { return conn, nil })
+		}
+
+		pool, err := NewGroupPool(dialers, DefaultPoolConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer pool.Close()
+
+		var results []<-chan error
+
+		// Each destination is assigned to a different connection.
+		destinations := make(map[cemi.GroupAddr]*blockingConn)
+		for i := 1; i <= 3; i++ {
+			results = append(results, pool.SendAsync(GroupEvent{Destination: cemi.GroupAddr(i)}))
+		}
+
+		for _, conn := range conns {
+			destinations[conn.expectSent(t).Destination] = conn
+		}
+
+		if len(destinations) != 3 {
+			t.Fatalf("Unexpected distribution: %v", destinations)
+		}
+
+		// Another event for a pending destination sticks to the same connection.
+		results = append(results, pool.SendAsync(GroupEvent{Destination: 2, Data: []byte{1}}))
+
+		for _, conn := range conns {
+			close(conn.release)
+		}
+
+		if event := destinations[2].expectSent(t); event.Destination != 2 {
+			t.Errorf("Event has been sent through the wrong connection: %+v", event)
+		}
+
+		for _, result := range results {
+			if err := <-result; err != nil {
+				t.Error(err)
+			}
+		}
+	})
+
+	t.Run("Deduplicate", func(t *testing.T) {
+		bus := knxnet.NewBus(1)
+
+		peer := NewGroupRouterWithSocket(bus.Join(knxnet.BusLink{}), DefaultRouterConfig)
+		defer peer.Close()
+
+		pool, err := NewGroupPool(newBusGateways(bus, true, true, true).dialers(), DefaultPoolConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer pool.Close()
+
+		event := GroupEvent{
+			Command:     GroupWrite,
+			Destination: cemi.NewGroupAddr3(1, 2, 3),
+			Data:        []byte{1},
+		}
+
+		// A repeated telegram is delivered again.
+		for i := 0; i < 2; i++ {
+			if err := peer.Send(event); err != nil {
+				t.Fatal(err)
+			}
+
+			expectGroupEvent(t, pool.Inbound(), event)
+
+			select {
+			case received := <-pool.Inbound():
+				t.Fatalf("Unexpected duplicate %+v", received)
+
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		pool, err := NewGroupPool(newBusGateways(knxnet.NewBus(1), true).dialers(), DefaultPoolConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pool.Close()
+
+		if err := pool.Send(GroupEvent{}); err != ErrPoolClosed {
+			t.Fatalf("Expected %v, got %v", ErrPoolClosed, err)
+		}
+
+		if _, open := <-pool.Inbound(); open {
+			t.Error("Inbound channel is still open")
+		}
+	})
+}
@@ -0,0 +1,217 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/knxnet"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// ConfiguratorConfig allows you to configure the remote configuration client's behavior.
+type ConfiguratorConfig struct {
+	// ResponseTimeout specifies how long to wait for devices to respond to a diagnostic request.
+	ResponseTimeout time.Duration
+
+	// Discovery is the endpoint to which devices send their responses. It should be a multicast
+	// group which the client has joined, because devices with wrong IP settings might not be able
+	// to reach a unicast address.
+	Discovery knxnet.HostInfo
+
+	// Clock is used for response timeouts. Tests may use a util.FakeClock in order to control the
+	// passage of time.
+	Clock util.Clock
+}
+
+// DefaultConfiguratorConfig is a good default configuration for a Configurator client. Responses
+// are expected at the KNXnet/IP system setup multicast address.
+var DefaultConfiguratorConfig = ConfiguratorConfig{
+	ResponseTimeout: 3 * time.Second,
+	Discovery: knxnet.HostInfo{
+		Protocol: knxnet.UDP4,
+		Address:  knxnet.Address{224, 0, 23, 12},
+		Port:     3671,
+	},
+	Clock: util.RealClock,
+}
+
+// checkConfiguratorConfig makes sure that the configuration is actually usable.
+func checkConfiguratorConfig(config ConfiguratorConfig) ConfiguratorConfig {
+	if config.ResponseTimeout <= 0 {
+		config.ResponseTimeout = DefaultConfiguratorConfig.ResponseTimeout
+	}
+
+	if config.Discovery.Protocol == 0 {
+		config.Discovery = DefaultConfiguratorConfig.Discovery
+	}
+
+	if config.Clock == nil {
+		config.Clock = DefaultConfiguratorConfig.Clock
+	}
+
+	return config
+}
+
+// These are errors that can occur when using a Configurator.
+var (
+	ErrDeviceNotFound     = errors.New("Device did not respond")
+	ErrConfiguratorClosed = errors.New("Configurator has been closed")
+)
+
+// A Configurator finds and reconfigures KNXnet/IP devices using remote diagnosis and configuration.
+// All requests are sent to a multicast group, therefore devices can be reached even if their IP
+// settings do not match the local network.
+type Configurator struct {
+	sock   knxnet.Socket
+	config ConfiguratorConfig
+
+	// Serializes diagnoses, so that responses can be attributed to their request.
+	mu        sync.Mutex
+	responses chan *knxnet.RemoteDiagRes
+}
+
+// NewConfigurator creates a new Configurator that joins the given multicast group, usually the
+// KNXnet/IP system setup multicast address "224.0.23.12:3671". You may pass a zero-initialized
+// value as parameter config, the default values will be set up.
+func NewConfigurator(multicastAddress string, config ConfiguratorConfig) (*Configurator, error) {
+	sock, err := knxnet.ListenRouter(multicastAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewConfiguratorWithSocket(sock, config), nil
+}
+
+// NewConfiguratorWithSocket creates a new Configurator that communicates through the given socket.
+// The Configurator takes ownership of the socket and closes it when it is closed.
+func NewConfiguratorWithSocket(sock knxnet.Socket, config ConfiguratorConfig) *Configurator {
+	conf := &Configurator{
+		sock:      sock,
+		config:    checkConfiguratorConfig(config),
+		responses: make(chan *knxnet.RemoteDiagRes, 16),
+	}
+
+	go conf.serve()
+
+	return conf
+}
+
+// serve collects the diagnostic responses.
+func (conf *Configurator) serve() {
+	util.Log(conf, "Started worker")
+	defer util.Log(conf, "Worker exited")
+
+	defer close(conf.responses)
+
+	for msg := range conf.sock.Inbound() {
+		if res, ok := msg.(*knxnet.RemoteDiagRes); ok {
+			select {
+			case conf.responses <- res:
+			default:
+				util.Log(conf, "Dropped diagnostic response, because the queue is full")
+			}
+		}
+	}
+}
+
+// Diagnose requests the description of the selected devices. It collects the responses until the
+// response timeout has passed. When a device is selected by its MAC address, it returns as soon as
+// the device has responded.
+func (conf *Configurator) Diagnose(selector knxnet.Selector) ([]*knxnet.RemoteDiagRes, error) {
+	conf.mu.Lock()
+	defer conf.mu.Unlock()
+
+	// Discard responses to previous requests.
+	for drained := false; !drained; {
+		select {
+		case _, open := <-conf.responses:
+			if !open {
+				return nil, ErrConfiguratorClosed
+			}
+
+		default:
+			drained = true
+		}
+	}
+
+	timeout := conf.config.Clock.NewTimer(conf.config.ResponseTimeout)
+	defer timeout.Stop()
+
+	err := conf.sock.Send(&knxnet.RemoteDiagReq{
+		Discovery: conf.config.Discovery,
+		Selector:  selector,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*knxnet.RemoteDiagRes
+
+	for {
+		select {
+		case <-timeout.C():
+			return results, nil
+
+		case res, open := <-conf.responses:
+			if !open {
+				return results, ErrConfiguratorClosed
+			}
+
+			// Responses of devices which have not been selected, belong to someone else's request.
+			info := res.DIBs.DeviceInfo()
+			if info == nil || !selector.Matches(info) {
+				continue
+			}
+
+			results = append(results, res)
+
+			if selector.Type == knxnet.SelectorMAC {
+				return results, nil
+			}
+		}
+	}
+}
+
+// Find requests the description of the device with the given MAC address. If the device does not
+// respond in time, ErrDeviceNotFound is returned.
+func (conf *Configurator) Find(mac knxnet.MACAddress) (*knxnet.RemoteDiagRes, error) {
+	results, err := conf.Diagnose(knxnet.MACSelector(mac))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 {
+		return nil, ErrDeviceNotFound
+	}
+
+	return results[0], nil
+}
+
+// Configure instructs the device with the given MAC address to adopt the given IP settings. Devices
+// do not acknowledge this request. Most devices apply the settings once they have been restarted,
+// see Reset. Use Find in order to verify the outcome.
+func (conf *Configurator) Configure(mac knxnet.MACAddress, ipConfig knxnet.IPConfig) error {
+	return conf.sock.Send(&knxnet.RemoteConfigReq{
+		Discovery: conf.config.Discovery,
+		Selector:  knxnet.MACSelector(mac),
+		DIBs:      knxnet.DIBs{&ipConfig},
+	})
+}
+
+// Reset instructs the device with the given MAC address to reset itself. Devices do not acknowledge
+// this request.
+func (conf *Configurator) Reset(mac knxnet.MACAddress, mode knxnet.ResetMode) error {
+	return conf.sock.Send(&knxnet.RemoteResetReq{
+		Selector: knxnet.MACSelector(mac),
+		Mode:     mode,
+	})
+}
+
+// Close shuts down the socket.
+func (conf *Configurator) Close() {
+	conf.sock.Close()
+}
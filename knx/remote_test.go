@@ -0,0 +1,144 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/knxnet"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// remoteDevice simulates a KNXnet/IP device which supports remote diagnosis and configuration.
+func remoteDevice(sock knxnet.Socket, info knxnet.DeviceInfo, requests chan<- knxnet.Service) {
+	for msg := range sock.Inbound() {
+		switch msg := msg.(type) {
+		case *knxnet.RemoteDiagReq:
+			if msg.Selector.Matches(&info) {
+				sock.Send(&knxnet.RemoteDiagRes{
+					Selector: msg.Selector,
+					DIBs:     knxnet.DIBs{&info},
+				})
+			}
+
+		case *knxnet.RemoteConfigReq:
+			if msg.Selector.Matches(&info) {
+				requests <- msg
+			}
+
+		case *knxnet.RemoteResetReq:
+			if msg.Selector.Matches(&info) {
+				requests <- msg
+			}
+		}
+	}
+}
+
+func TestConfigurator(t *testing.T) {
+	bus := knxnet.NewBus(1)
+
+	devices := []knxnet.DeviceInfo{
+		{MAC: knxnet.MACAddress{0, 1, 2, 3, 4, 5}, FriendlyName: "Router"},
+		{MAC: knxnet.MACAddress{0, 1, 2, 3, 4, 6}, FriendlyName: "Interface", ProgMode: true},
+	}
+
+	requests := make(chan knxnet.Service, 4)
+
+	for _, info := range devices {
+		sock := bus.Join(knxnet.BusLink{})
+		defer sock.Close()
+
+		go remoteDevice(sock, info, requests)
+	}
+
+	t.Run("Find", func(t *testing.T) {
+		conf := NewConfiguratorWithSocket(bus.Join(knxnet.BusLink{}), DefaultConfiguratorConfig)
+		defer conf.Close()
+
+		res, err := conf.Find(devices[1].MAC)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info := res.DIBs.DeviceInfo(); info.FriendlyName != "Interface" {
+			t.Errorf("Unexpected device: %+v", info)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		clock := util.NewFakeClock(time.Unix(0, 0))
+
+		config := DefaultConfiguratorConfig
+		config.Clock = clock
+
+		conf := NewConfiguratorWithSocket(bus.Join(knxnet.BusLink{}), config)
+		defer conf.Close()
+
+		go func() {
+			clock.BlockUntil(1)
+			clock.Advance(config.ResponseTimeout)
+		}()
+
+		if _, err := conf.Find(knxnet.MACAddress{1}); err != ErrDeviceNotFound {
+			t.Fatalf("Expected %v, got %v", ErrDeviceNotFound, err)
+		}
+	})
+
+	t.Run("ProgMode", func(t *testing.T) {
+		config := DefaultConfiguratorConfig
+		config.ResponseTimeout = 100 * time.Millisecond
+
+		conf := NewConfiguratorWithSocket(bus.Join(knxnet.BusLink{}), config)
+		defer conf.Close()
+
+		results, err := conf.Diagnose(knxnet.ProgModeSelector())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(results) != 1 || results[0].DIBs.DeviceInfo().MAC != devices[1].MAC {
+			t.Errorf("Unexpected responses: %+v", results)
+		}
+	})
+
+	t.Run("Reconfigure", func(t *testing.T) {
+		conf := NewConfiguratorWithSocket(bus.Join(knxnet.BusLink{}), DefaultConfiguratorConfig)
+		defer conf.Close()
+
+		ipConfig := knxnet.IPConfig{
+			IP:         knxnet.Address{192, 168, 1, 20},
+			SubnetMask: knxnet.Address{255, 255, 255, 0},
+			Assignment: knxnet.IPAssignmentManual,
+		}
+
+		if err := conf.Configure(devices[0].MAC, ipConfig); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := conf.Reset(devices[0].MAC, knxnet.ResetRestart); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 2; i++ {
+			select {
+			case req := <-requests:
+				switch req := req.(type) {
+				case *knxnet.RemoteConfigReq:
+					if config := req.DIBs.IPConfig(); config == nil || *config != ipConfig {
+						t.Errorf("Unexpected configuration: %+v", req.DIBs)
+					}
+
+				case *knxnet.RemoteResetReq:
+					if req.Mode != knxnet.ResetRestart {
+						t.Errorf("Unexpected reset mode: %v", req.Mode)
+					}
+				}
+
+			case <-time.After(time.Second):
+				t.Fatal("Device has not received the request")
+			}
+		}
+	})
+}
@@ -7,7 +7,6 @@ import (
 	"container/list"
 	"errors"
 	"sync"
-	"time"
 
 	"github.com/vapourismo/knx-go/knx/cemi"
 	"github.com/vapourismo/knx-go/knx/knxnet"
@@ -19,11 +18,16 @@ type RouterConfig struct {
 	// Specify how many sent messages to retain. This is important for when a router indicates that
 	// it has lost some messages. If you do not expect to saturate the router, keep this low.
 	RetainCount uint
+
+	// Clock is used to time flow control. Tests may use a util.FakeClock in order to control the
+	// passage of time.
+	Clock util.Clock
 }
 
 // DefaultRouterConfig is a good default configuration for a Router client.
 var DefaultRouterConfig = RouterConfig{
 	RetainCount: 32,
+	Clock:       util.RealClock,
 }
 
 // checkRouterConfig validates the given RouterConfig.
@@ -32,9 +36,42 @@ func checkRouterConfig(config RouterConfig) RouterConfig {
 		config.RetainCount = DefaultRouterConfig.RetainCount
 	}
 
+	if config.Clock == nil {
+		config.Clock = DefaultRouterConfig.Clock
+	}
+
 	return config
 }
 
+// SystemBroadcast wraps a message that is transmitted as a KNXnet/IP system broadcast. Routers
+// deliver received system broadcasts in this form, so that they can be told apart from routing
+// indications. Sending a SystemBroadcast enforces the system broadcast service.
+type SystemBroadcast struct {
+	cemi.Message
+}
+
+// isSystemBroadcast determines whether the message is a broadcast frame in system broadcast mode.
+func isSystemBroadcast(msg cemi.Message) bool {
+	var ldata *cemi.LData
+
+	switch msg := msg.(type) {
+	case *cemi.LDataReq:
+		ldata = &msg.LData
+
+	case *cemi.LDataInd:
+		ldata = &msg.LData
+
+	case *cemi.LDataCon:
+		ldata = &msg.LData
+
+	default:
+		return false
+	}
+
+	return ldata.Control2.IsGroupAddr() && ldata.Destination == 0 &&
+		ldata.Control1&cemi.Control1NoSysBroadcast == 0
+}
+
 // A Router provides the means to communicate with KNXnet/IP routers in a IP multicast group.
 // It supports sending and receiving CEMI-encoded frames, aswell as basic flow control.
 type Router struct {
@@ -103,10 +140,13 @@ func (router *Router) serve() {
 			// Try to push it to the client without blocking this goroutine to long.
 			router.pushInbound(msg.Payload)
 
+		case *knxnet.RoutingSystemBroadcast:
+			router.pushInbound(&SystemBroadcast{msg.Payload})
+
 		case *knxnet.RoutingBusy:
 			// Inhibit sending for the given time.
 			router.sendMu.Lock()
-			time.AfterFunc(msg.WaitTime, router.sendMu.Unlock)
+			router.config.Clock.AfterFunc(msg.WaitTime, router.sendMu.Unlock)
 
 			// TODO: Slow down pace after busy indication.
 
@@ -125,6 +165,12 @@ func NewRouter(multicastAddress string, config RouterConfig) (*Router, error) {
 		return nil, err
 	}
 
+	return NewRouterWithSocket(sock, config), nil
+}
+
+// NewRouterWithSocket creates a new Router that communicates through the given socket, e.g. a
+// knxnet.BusSocket. The Router takes ownership of the socket and closes it when it is closed.
+func NewRouterWithSocket(sock knxnet.Socket, config RouterConfig) *Router {
 	r := &Router{
 		sock:     sock,
 		config:   checkRouterConfig(config),
@@ -134,7 +180,7 @@ func NewRouter(multicastAddress string, config RouterConfig) (*Router, error) {
 
 	go r.serve()
 
-	return r, nil
+	return r
 }
 
 // Send transmits a packet.
@@ -147,7 +193,15 @@ func (router *Router) Send(data cemi.Message) error {
 	router.sendMu.Lock()
 	defer router.sendMu.Unlock()
 
-	err := router.sock.Send(&knxnet.RoutingInd{Payload: data})
+	var srv knxnet.ServicePackable = &knxnet.RoutingInd{Payload: data}
+
+	if sb, ok := data.(*SystemBroadcast); ok {
+		srv = &knxnet.RoutingSystemBroadcast{Payload: sb.Message}
+	} else if isSystemBroadcast(data) {
+		srv = &knxnet.RoutingSystemBroadcast{Payload: data}
+	}
+
+	err := router.sock.Send(srv)
 
 	if err == nil {
 		// Store this for potential resending.
@@ -193,6 +247,16 @@ func NewGroupRouter(multicastAddress string, config RouterConfig) (gr GroupRoute
 	return
 }
 
+// NewGroupRouterWithSocket creates a new Router for group communication that communicates through
+// the given socket.
+func NewGroupRouterWithSocket(sock knxnet.Socket, config RouterConfig) (gr GroupRouter) {
+	gr.Router = NewRouterWithSocket(sock, config)
+	gr.inbound = make(chan GroupEvent)
+	go serveGroupInbound(gr.Router.Inbound(), gr.inbound)
+
+	return
+}
+
 // Send a group communication.
 func (gr *GroupRouter) Send(event GroupEvent) error {
 	return gr.Router.Send(&cemi.LDataInd{LData: buildGroupOutbound(event)})
@@ -0,0 +1,147 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+func TestGroupRouter_Bus(t *testing.T) {
+	bus := knxnet.NewBus(1)
+
+	sender := NewGroupRouterWithSocket(bus.Join(knxnet.BusLink{}), DefaultRouterConfig)
+	defer sender.Close()
+
+	receiver := NewGroupRouterWithSocket(bus.Join(knxnet.BusLink{}), DefaultRouterConfig)
+	defer receiver.Close()
+
+	event := GroupEvent{
+		Command:     GroupWrite,
+		Source:      cemi.NewIndividualAddr3(1, 1, 1),
+		Destination: cemi.NewGroupAddr3(1, 2, 3),
+		Data:        []byte{1},
+	}
+
+	if err := sender.Send(event); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case received := <-receiver.Inbound():
+		if received.Command != event.Command || received.Source != event.Source ||
+			received.Destination != event.Destination || !bytes.Equal(received.Data, event.Data) {
+			t.Errorf("Unexpected event: %+v", received)
+		}
+
+	case <-time.After(time.Second):
+		t.Fatal("No event received")
+	}
+}
+
+func TestRouter_Busy(t *testing.T) {
+	bus := knxnet.NewBus(1)
+	clock := util.NewFakeClock(time.Unix(0, 0))
+
+	config := DefaultRouterConfig
+	config.Clock = clock
+
+	router := NewRouterWithSocket(bus.Join(knxnet.BusLink{}), config)
+	defer router.Close()
+
+	peer := bus.Join(knxnet.BusLink{})
+	defer peer.Close()
+
+	err := peer.Send(&knxnet.RoutingBusy{WaitTime: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for the router to inhibit sending.
+	clock.BlockUntil(1)
+
+	sent := make(chan error)
+	go func() {
+		sent <- router.Send(&cemi.LDataInd{LData: buildGroupOutbound(GroupEvent{})})
+	}()
+
+	select {
+	case <-sent:
+		t.Fatal("Router did not wait")
+
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clock.Advance(100 * time.Millisecond)
+
+	if err := <-sent; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := (<-peer.Inbound()).(*knxnet.RoutingInd); !ok {
+		t.Error("Expected a routing indication")
+	}
+}
+
+func TestRouter_SystemBroadcast(t *testing.T) {
+	bus := knxnet.NewBus(1)
+
+	router := NewRouterWithSocket(bus.Join(knxnet.BusLink{}), DefaultRouterConfig)
+	defer router.Close()
+
+	peer := bus.Join(knxnet.BusLink{})
+	defer peer.Close()
+
+	ldata := buildGroupOutbound(GroupEvent{Command: GroupWrite, Data: []byte{1}})
+	ldata.Control1 &^= cemi.Control1NoSysBroadcast
+
+	t.Run("Send", func(t *testing.T) {
+		if err := router.Send(&cemi.LDataInd{LData: ldata}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := (<-peer.Inbound()).(*knxnet.RoutingSystemBroadcast); !ok {
+			t.Error("Expected a routing system broadcast")
+		}
+
+		// Frames which are not sent to the broadcast address are routed normally.
+		other := ldata
+		other.Destination = uint16(cemi.NewGroupAddr3(1, 2, 3))
+
+		if err := router.Send(&cemi.LDataInd{LData: other}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := (<-peer.Inbound()).(*knxnet.RoutingInd); !ok {
+			t.Error("Expected a routing indication")
+		}
+	})
+
+	t.Run("Receive", func(t *testing.T) {
+		err := peer.Send(&knxnet.RoutingSystemBroadcast{Payload: &cemi.LDataInd{LData: ldata}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case msg := <-router.Inbound():
+			sb, ok := msg.(*SystemBroadcast)
+			if !ok {
+				t.Fatalf("Expected a system broadcast, got %T", msg)
+			}
+
+			if _, ok := sb.Message.(*cemi.LDataInd); !ok {
+				t.Errorf("Unexpected payload: %T", sb.Message)
+			}
+
+		case <-time.After(time.Second):
+			t.Fatal("No message received")
+		}
+	})
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"sync"
+	"time"
+)
+
+// rtoEstimator derives a retransmission timeout from measured round-trip times, much like TCP does
+// (RFC 6298). Its zero value has no estimate.
+type rtoEstimator struct {
+	mu       sync.Mutex
+	measured bool
+	srtt     time.Duration
+	rttvar   time.Duration
+	rto      time.Duration
+}
+
+// clampDuration limits the duration to the given bounds.
+func clampDuration(d, min, max time.Duration) time.Duration {
+	if d < min {
+		return min
+	}
+
+	if d > max {
+		return max
+	}
+
+	return d
+}
+
+// timeout returns the current retransmission timeout. If nothing has been measured yet, initial is
+// returned.
+func (est *rtoEstimator) timeout(initial time.Duration) time.Duration {
+	est.mu.Lock()
+	defer est.mu.Unlock()
+
+	if est.rto == 0 {
+		return initial
+	}
+
+	return est.rto
+}
+
+// sample incorporates a round-trip time measurement. Only requests which have not been resent may
+// be measured, because it is unknown which transmission a response belongs to (Karn's algorithm).
+func (est *rtoEstimator) sample(rtt, min, max time.Duration) {
+	est.mu.Lock()
+	defer est.mu.Unlock()
+
+	if !est.measured {
+		est.measured = true
+		est.srtt = rtt
+		est.rttvar = rtt / 2
+	} else {
+		delta := est.srtt - rtt
+		if delta < 0 {
+			delta = -delta
+		}
+
+		est.rttvar = (3*est.rttvar + delta) / 4
+		est.srtt = (7*est.srtt + rtt) / 8
+	}
+
+	est.rto = clampDuration(est.srtt+4*est.rttvar, min, max)
+}
+
+// backoff doubles the retransmission timeout after a request had to be resent. The timeout stays
+// increased until the next measurement.
+func (est *rtoEstimator) backoff(initial, max time.Duration) {
+	est.mu.Lock()
+	defer est.mu.Unlock()
+
+	if est.rto == 0 {
+		est.rto = initial
+	}
+
+	est.rto = clampDuration(2*est.rto, 0, max)
+}
@@ -0,0 +1,66 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRTOEstimator(t *testing.T) {
+	const (
+		initial = 500 * time.Millisecond
+		min     = 10 * time.Millisecond
+		max     = time.Second
+	)
+
+	t.Run("Converge", func(t *testing.T) {
+		var est rtoEstimator
+
+		if rto := est.timeout(initial); rto != initial {
+			t.Fatalf("Expected initial timeout, got %v", rto)
+		}
+
+		for i := 0; i < 50; i++ {
+			est.sample(20*time.Millisecond, min, max)
+		}
+
+		if rto := est.timeout(initial); rto < 20*time.Millisecond || rto > 25*time.Millisecond {
+			t.Errorf("Timeout did not converge: %v", rto)
+		}
+	})
+
+	t.Run("Bounds", func(t *testing.T) {
+		var est rtoEstimator
+
+		est.sample(time.Millisecond, min, max)
+		if rto := est.timeout(initial); rto != min {
+			t.Errorf("Expected lower bound, got %v", rto)
+		}
+
+		est.sample(10*time.Second, min, max)
+		if rto := est.timeout(initial); rto != max {
+			t.Errorf("Expected upper bound, got %v", rto)
+		}
+	})
+
+	t.Run("Backoff", func(t *testing.T) {
+		var est rtoEstimator
+
+		est.backoff(initial, max)
+		if rto := est.timeout(initial); rto != 2*initial {
+			t.Errorf("Expected doubled timeout, got %v", rto)
+		}
+
+		est.backoff(initial, max)
+		if rto := est.timeout(initial); rto != max {
+			t.Errorf("Expected upper bound, got %v", rto)
+		}
+
+		est.sample(20*time.Millisecond, min, max)
+		if rto := est.timeout(initial); rto != 60*time.Millisecond {
+			t.Errorf("Expected measured timeout, got %v", rto)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/knxnet"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// SearchConfig allows you to configure the behavior of a search.
+type SearchConfig struct {
+	// ResponseTimeout specifies how long to wait for devices to respond.
+	ResponseTimeout time.Duration
+
+	// Clock is used for the response timeout. Tests may use a util.FakeClock in order to control
+	// the passage of time.
+	Clock util.Clock
+}
+
+// DefaultSearchConfig is a good default configuration for a search.
+var DefaultSearchConfig = SearchConfig{
+	ResponseTimeout: 3 * time.Second,
+	Clock:           util.RealClock,
+}
+
+// checkSearchConfig makes sure that the configuration is actually usable.
+func checkSearchConfig(config SearchConfig) SearchConfig {
+	if config.ResponseTimeout <= 0 {
+		config.ResponseTimeout = DefaultSearchConfig.ResponseTimeout
+	}
+
+	if config.Clock == nil {
+		config.Clock = DefaultSearchConfig.Clock
+	}
+
+	return config
+}
+
+// routeBack is a discovery endpoint which instructs devices to respond to the sender of a request.
+var routeBack = knxnet.HostInfo{Protocol: knxnet.UDP4}
+
+// Search sends an extended search request to the given multicast group, usually the KNXnet/IP
+// system setup multicast address "224.0.23.12:3671". It collects the responses of the devices that
+// satisfy the given parameters until the response timeout has passed.
+func Search(
+	multicastAddress string,
+	config SearchConfig,
+	params ...knxnet.SearchParam,
+) ([]*knxnet.SearchResExt, error) {
+	sock, err := knxnet.ListenSearch(multicastAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	defer sock.Close()
+
+	return SearchWithSocket(sock, config, params...)
+}
+
+// SearchWithSocket performs a search like Search does, but uses the given socket. The socket is not
+// closed afterwards.
+func SearchWithSocket(
+	sock knxnet.Socket,
+	config SearchConfig,
+	params ...knxnet.SearchParam,
+) ([]*knxnet.SearchResExt, error) {
+	config = checkSearchConfig(config)
+
+	timeout := config.Clock.NewTimer(config.ResponseTimeout)
+	defer timeout.Stop()
+
+	err := sock.Send(&knxnet.SearchReqExt{Discovery: routeBack, Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*knxnet.SearchResExt
+
+	for {
+		select {
+		case <-timeout.C():
+			return results, nil
+
+		case msg, open := <-sock.Inbound():
+			if !open {
+				return results, nil
+			}
+
+			res, ok := msg.(*knxnet.SearchResExt)
+			if !ok || !matchesSearch(res, params) {
+				continue
+			}
+
+			results = append(results, res)
+		}
+	}
+}
+
+// matchesSearch determines whether the response satisfies the search parameters. Devices that
+// ignore the parameters, or respond to someone else's search, are filtered out this way.
+func matchesSearch(res *knxnet.SearchResExt, params []knxnet.SearchParam) bool {
+	for i := range params {
+		if !params[i].Matches(res.DIBs) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// A FreeTunnel is a KNXnet/IP interface which has at least one tunnelling slot available.
+type FreeTunnel struct {
+	Control knxnet.HostInfo
+	Device  *knxnet.DeviceInfo
+	Slots   []knxnet.TunnelSlot
+}
+
+// Addr returns the address of the control endpoint, which can be passed to NewTunnel.
+func (tunnel FreeTunnel) Addr() string {
+	return fmt.Sprintf("%v:%d", tunnel.Control.Address, tunnel.Control.Port)
+}
+
+// FindFreeTunnels searches for KNXnet/IP interfaces that have a free tunnelling slot. Only devices
+// that support tunnelling version 2 report their slots.
+func FindFreeTunnels(multicastAddress string, config SearchConfig) ([]FreeTunnel, error) {
+	sock, err := knxnet.ListenSearch(multicastAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	defer sock.Close()
+
+	return FindFreeTunnelsWithSocket(sock, config)
+}
+
+// FindFreeTunnelsWithSocket searches for free tunnelling slots like FindFreeTunnels does, but uses
+// the given socket. The socket is not closed afterwards.
+func FindFreeTunnelsWithSocket(sock knxnet.Socket, config SearchConfig) ([]FreeTunnel, error) {
+	results, err := SearchWithSocket(
+		sock,
+		config,
+		knxnet.SelectService(knxnet.ServiceFamilyTunnelling, 2),
+		knxnet.RequestDIBs(knxnet.DescriptionTypeTunnellingInfo),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var tunnels []FreeTunnel
+
+	for _, res := range results {
+		info := res.DIBs.TunnellingInfo()
+		if info == nil {
+			continue
+		}
+
+		if slots := info.Available(); len(slots) > 0 {
+			tunnels = append(tunnels, FreeTunnel{
+				Control: res.Control,
+				Device:  res.DIBs.DeviceInfo(),
+				Slots:   slots,
+			})
+		}
+	}
+
+	return tunnels, nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/knxnet"
+)
+
+// searchDevice simulates a KNXnet/IP device which responds to extended search requests.
+func searchDevice(sock knxnet.Socket, control knxnet.HostInfo, dibs knxnet.DIBs) {
+	for msg := range sock.Inbound() {
+		req, ok := msg.(*knxnet.SearchReqExt)
+		if !ok {
+			continue
+		}
+
+		res := &knxnet.SearchResExt{Control: control, DIBs: dibs[:2]}
+
+		for _, param := range req.Params {
+			if param.Type == knxnet.SearchParamDIBs {
+				for _, typ := range param.DIBs {
+					if typ == knxnet.DescriptionTypeTunnellingInfo && len(dibs) > 2 {
+						res.DIBs = append(res.DIBs, dibs[2])
+					}
+				}
+			} else if !param.Matches(dibs) {
+				res = nil
+				break
+			}
+		}
+
+		if res != nil {
+			sock.Send(res)
+		}
+	}
+}
+
+func TestFindFreeTunnels(t *testing.T) {
+	bus := knxnet.NewBus(1)
+
+	makeDevice := func(last byte, version uint8, slots ...knxnet.TunnelSlot) *knxnet.BusSocket {
+		dibs := knxnet.DIBs{
+			&knxnet.DeviceInfo{MAC: knxnet.MACAddress{0, 1, 2, 3, 4, last}},
+			&knxnet.SupportedServices{{ID: knxnet.ServiceFamilyTunnelling, Version: version}},
+		}
+
+		if slots != nil {
+			dibs = append(dibs, &knxnet.TunnellingInfo{MaxAPDULength: 254, Slots: slots})
+		}
+
+		control := knxnet.HostInfo{
+			Protocol: knxnet.UDP4,
+			Address:  knxnet.Address{192, 168, 1, last},
+			Port:     3671,
+		}
+
+		sock := bus.Join(knxnet.BusLink{})
+		go searchDevice(sock, control, dibs)
+
+		return sock
+	}
+
+	usable := knxnet.TunnelSlotUsable | knxnet.TunnelSlotAuthorized
+
+	// All slots are occupied.
+	occupied := makeDevice(1, 2, knxnet.TunnelSlot{Address: 0x11F1, Status: usable})
+	defer occupied.Close()
+
+	// One slot is free.
+	free := makeDevice(2, 2,
+		knxnet.TunnelSlot{Address: 0x11F1, Status: usable},
+		knxnet.TunnelSlot{Address: 0x11F2, Status: usable | knxnet.TunnelSlotFree},
+	)
+	defer free.Close()
+
+	// The device does not support extended search.
+	old := makeDevice(3, 1)
+	defer old.Close()
+
+	sock := bus.Join(knxnet.BusLink{})
+	defer sock.Close()
+
+	config := DefaultSearchConfig
+	config.ResponseTimeout = 100 * time.Millisecond
+
+	tunnels, err := FindFreeTunnelsWithSocket(sock, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tunnels) != 1 {
+		t.Fatalf("Unexpected tunnels: %+v", tunnels)
+	}
+
+	if addr := tunnels[0].Addr(); addr != "192.168.1.2:3671" {
+		t.Errorf("Unexpected address: %s", addr)
+	}
+
+	if slots := tunnels[0].Slots; len(slots) != 1 || slots[0].Address != 0x11F2 {
+		t.Errorf("Unexpected slots: %+v", slots)
+	}
+}
@@ -0,0 +1,419 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Package secure implements KNX Data Secure, the application layer security of KNX. Secure APDUs
+// (S-A_Data) are authenticated and optionally encrypted using AES-128 in CCM mode.
+package secure
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// secureAPCI is the low part of the APCI A_SecureService (0x3F1). The high part is cemi.Escape.
+const secureAPCI = 0x31
+
+// Algorithm determines how a secure APDU is protected.
+type Algorithm uint8
+
+// These are the known security algorithms.
+const (
+	// AlgorithmAuth authenticates the APDU, but transmits it in plain text.
+	AlgorithmAuth Algorithm = 0
+
+	// AlgorithmAuthConf authenticates and encrypts the APDU.
+	AlgorithmAuthConf Algorithm = 1
+)
+
+// Service identifies the secure service that is carried by a secure APDU.
+type Service uint8
+
+// These are the known secure services.
+const (
+	ServiceData    Service = 0
+	ServiceSyncReq Service = 2
+	ServiceSyncRes Service = 3
+)
+
+// SecurityControl is the security control field of a secure APDU.
+type SecurityControl struct {
+	// ToolAccess indicates that the APDU is protected with the tool key of a device instead of a
+	// group key.
+	ToolAccess      bool
+	Algorithm       Algorithm
+	SystemBroadcast bool
+	Service         Service
+}
+
+// pack encodes the security control field.
+func (scf SecurityControl) pack() byte {
+	b := byte(scf.Algorithm&7)<<4 | byte(scf.Service&7)
+
+	if scf.ToolAccess {
+		b |= 1 << 7
+	}
+
+	if scf.SystemBroadcast {
+		b |= 1 << 3
+	}
+
+	return b
+}
+
+// unpackSecurityControl decodes the security control field.
+func unpackSecurityControl(b byte) SecurityControl {
+	return SecurityControl{
+		ToolAccess:      b&(1<<7) != 0,
+		Algorithm:       Algorithm(b>>4) & 7,
+		SystemBroadcast: b&(1<<3) != 0,
+		Service:         Service(b & 7),
+	}
+}
+
+// These are errors that can occur when processing secure APDUs.
+var (
+	ErrNotSecure     = errors.New("APDU is not secured")
+	ErrAPDULength    = errors.New("Secure APDU is too short")
+	ErrAlgorithm     = errors.New("Unsupported security algorithm")
+	ErrMAC           = errors.New("Message authentication code does not match")
+	ErrKeyLength     = errors.New("Key must be 16 bytes long")
+	ErrPlainAPDU     = errors.New("Plain APDU is too short")
+	ErrSeqNumberSize = errors.New("Sequence number exceeds 48 bits")
+)
+
+// MaxSeqNumber is the largest sequence number that fits into a secure APDU.
+const MaxSeqNumber = 1<<48 - 1
+
+// APDU is a secure APDU (S-A_Data).
+type APDU struct {
+	Control   SecurityControl
+	SeqNumber uint64
+
+	// Payload is the protected APDU. It is encrypted, unless the algorithm is AlgorithmAuth.
+	Payload []byte
+
+	MAC [4]byte
+}
+
+// IsSecure determines whether the application data carries a secure APDU.
+func IsSecure(app *cemi.AppData) bool {
+	return app != nil && app.Command == cemi.Escape && len(app.Data) > 0 &&
+		app.Data[0]&63 == secureAPCI
+}
+
+// ParseAPDU extracts the secure APDU from the application data.
+func ParseAPDU(app *cemi.AppData) (*APDU, error) {
+	if !IsSecure(app) {
+		return nil, ErrNotSecure
+	}
+
+	data := app.Data[1:]
+	if len(data) < 11 {
+		return nil, ErrAPDULength
+	}
+
+	apdu := &APDU{
+		Control:   unpackSecurityControl(data[0]),
+		SeqNumber: unpackSeqNumber(data[1:7]),
+		Payload:   append([]byte(nil), data[7:len(data)-4]...),
+	}
+	copy(apdu.MAC[:], data[len(data)-4:])
+
+	return apdu, nil
+}
+
+// appData builds the application data that carries the secure APDU.
+func (apdu *APDU) appData(numbered bool, seqNumber uint8) *cemi.AppData {
+	data := make([]byte, 0, len(apdu.Payload)+12)
+	data = append(data, secureAPCI, apdu.Control.pack())
+	data = appendSeqNumber(data, apdu.SeqNumber)
+	data = append(data, apdu.Payload...)
+	data = append(data, apdu.MAC[:]...)
+
+	return &cemi.AppData{
+		Numbered:  numbered,
+		SeqNumber: seqNumber,
+		Command:   cemi.Escape,
+		Data:      data,
+	}
+}
+
+// appendSeqNumber appends the 48-bit big-endian sequence number.
+func appendSeqNumber(data []byte, seq uint64) []byte {
+	return append(data, byte(seq>>40), byte(seq>>32), byte(seq>>24), byte(seq>>16), byte(seq>>8),
+		byte(seq))
+}
+
+// unpackSeqNumber decodes a 48-bit big-endian sequence number.
+func unpackSeqNumber(data []byte) uint64 {
+	var seq uint64
+	for _, b := range data[:6] {
+		seq = seq<<8 | uint64(b)
+	}
+
+	return seq
+}
+
+// packPlain encodes the application data as a plain APDU without transport control information.
+func packPlain(app *cemi.AppData) []byte {
+	var first byte
+	if len(app.Data) > 0 {
+		first = app.Data[0] & 63
+	}
+
+	plain := []byte{byte(app.Command>>2) & 3, byte(app.Command&3)<<6 | first}
+	if len(app.Data) > 1 {
+		plain = append(plain, app.Data[1:]...)
+	}
+
+	return plain
+}
+
+// unpackPlain decodes a plain APDU. The transport control information is taken from the given
+// application data, which carried the secure APDU.
+func unpackPlain(plain []byte, outer *cemi.AppData) (*cemi.AppData, error) {
+	if len(plain) < 2 {
+		return nil, ErrPlainAPDU
+	}
+
+	data := make([]byte, len(plain)-1)
+	data[0] = plain[1] & 63
+	copy(data[1:], plain[2:])
+
+	return &cemi.AppData{
+		Numbered:  outer.Numbered,
+		SeqNumber: outer.SeqNumber,
+		Command:   cemi.APCI((plain[0]&3)<<2 | plain[1]>>6),
+		Data:      data,
+	}, nil
+}
+
+// frameInfo contains the information of the frame which is part of the authentication.
+type frameInfo struct {
+	source      cemi.IndividualAddr
+	destination uint16
+	control2    cemi.ControlField2
+	numbered    bool
+	seqNumber   uint8
+}
+
+// tpci returns the first byte of the transport unit that carries the secure APDU.
+func (info frameInfo) tpci() byte {
+	b := byte(cemi.Escape>>2) & 3
+	if info.numbered {
+		b |= 1<<6 | (info.seqNumber&15)<<2
+	}
+
+	return b
+}
+
+// blockZero builds the first block of the CBC-MAC calculation.
+func (info frameInfo) blockZero(seq uint64, length int) []byte {
+	block := make([]byte, 0, aes.BlockSize)
+	block = appendSeqNumber(block, seq)
+	block = append(block,
+		byte(info.source>>8), byte(info.source),
+		byte(info.destination>>8), byte(info.destination),
+		0, byte(info.control2)&0x8F, info.tpci(), byte(cemi.Escape&3)<<6|secureAPCI,
+		0, byte(length))
+
+	return block
+}
+
+// counterZero builds the initial counter block of the CTR encryption.
+func (info frameInfo) counterZero(seq uint64) []byte {
+	block := make([]byte, 0, aes.BlockSize)
+	block = appendSeqNumber(block, seq)
+	block = append(block,
+		byte(info.source>>8), byte(info.source),
+		byte(info.destination>>8), byte(info.destination),
+		0, 0, 0, 0, 1, 0)
+
+	return block
+}
+
+// cbcMAC calculates the CBC-MAC over the first block, the associated data and the payload.
+func cbcMAC(block cipher.Block, b0, assoc, payload []byte) []byte {
+	data := make([]byte, 0, len(b0)+2+len(assoc)+len(payload)+aes.BlockSize)
+	data = append(data, b0...)
+	data = append(data, byte(len(assoc)>>8), byte(len(assoc)))
+	data = append(data, assoc...)
+	data = append(data, payload...)
+
+	if rest := len(data) % aes.BlockSize; rest > 0 {
+		data = append(data, make([]byte, aes.BlockSize-rest)...)
+	}
+
+	mac := make([]byte, aes.BlockSize)
+	cipher.NewCBCEncrypter(block, mac).CryptBlocks(data, data)
+
+	return data[len(data)-aes.BlockSize:]
+}
+
+// newCipher creates the block cipher for the given key.
+func newCipher(key []byte) (cipher.Block, error) {
+	if len(key) != 16 {
+		return nil, ErrKeyLength
+	}
+
+	return aes.NewCipher(key)
+}
+
+// seal protects the plain APDU.
+func seal(
+	key []byte,
+	info frameInfo,
+	scf SecurityControl,
+	seq uint64,
+	plain []byte,
+) (*APDU, error) {
+	block, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if seq > MaxSeqNumber {
+		return nil, ErrSeqNumberSize
+	}
+
+	apdu := &APDU{Control: scf, SeqNumber: seq}
+	stream := cipher.NewCTR(block, info.counterZero(seq))
+
+	switch scf.Algorithm {
+	case AlgorithmAuthConf:
+		mac := cbcMAC(block, info.blockZero(seq, len(plain)), []byte{scf.pack()}, plain)
+
+		// The MAC is encrypted with the whole first counter block, the payload with the next ones.
+		stream.XORKeyStream(mac, mac)
+		copy(apdu.MAC[:], mac)
+
+		apdu.Payload = make([]byte, len(plain))
+		stream.XORKeyStream(apdu.Payload, plain)
+
+	case AlgorithmAuth:
+		assoc := append([]byte{scf.pack()}, plain...)
+		mac := cbcMAC(block, info.blockZero(seq, 0), assoc, nil)
+
+		stream.XORKeyStream(apdu.MAC[:], mac[:4])
+		apdu.Payload = append([]byte(nil), plain...)
+
+	default:
+		return nil, ErrAlgorithm
+	}
+
+	return apdu, nil
+}
+
+// open verifies the secure APDU and returns the plain APDU.
+func open(key []byte, info frameInfo, apdu *APDU) ([]byte, error) {
+	block, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := cipher.NewCTR(block, info.counterZero(apdu.SeqNumber))
+
+	var plain, mac []byte
+
+	switch apdu.Control.Algorithm {
+	case AlgorithmAuthConf:
+		received := make([]byte, aes.BlockSize)
+		copy(received, apdu.MAC[:])
+		stream.XORKeyStream(received, received)
+
+		plain = make([]byte, len(apdu.Payload))
+		stream.XORKeyStream(plain, apdu.Payload)
+
+		mac = cbcMAC(block, info.blockZero(apdu.SeqNumber, len(plain)),
+			[]byte{apdu.Control.pack()}, plain)
+
+		if subtle.ConstantTimeCompare(mac[:4], received[:4]) != 1 {
+			return nil, ErrMAC
+		}
+
+	case AlgorithmAuth:
+		plain = apdu.Payload
+		assoc := append([]byte{apdu.Control.pack()}, plain...)
+		mac = cbcMAC(block, info.blockZero(apdu.SeqNumber, 0), assoc, nil)
+
+		stream.XORKeyStream(mac[:4], mac[:4])
+		if subtle.ConstantTimeCompare(mac[:4], apdu.MAC[:]) != 1 {
+			return nil, ErrMAC
+		}
+
+	default:
+		return nil, ErrAlgorithm
+	}
+
+	return append([]byte(nil), plain...), nil
+}
+
+// ldataInfo extracts the authenticated information from the frame.
+func ldataInfo(ldata *cemi.LData, app *cemi.AppData) frameInfo {
+	return frameInfo{
+		source:      ldata.Source,
+		destination: ldata.Destination,
+		control2:    ldata.Control2,
+		numbered:    app.Numbered,
+		seqNumber:   app.SeqNumber,
+	}
+}
+
+// Seal secures the application data of the frame using the given key, security control field and
+// sequence number. The returned frame carries the secure APDU.
+func Seal(
+	ldata cemi.LData,
+	key []byte,
+	scf SecurityControl,
+	seq uint64,
+) (cemi.LData, error) {
+	app, ok := ldata.Data.(*cemi.AppData)
+	if !ok {
+		return ldata, ErrPlainAPDU
+	}
+
+	apdu, err := seal(key, ldataInfo(&ldata, app), scf, seq, packPlain(app))
+	if err != nil {
+		return ldata, err
+	}
+
+	secured := apdu.appData(app.Numbered, app.SeqNumber)
+	ldata.Data = secured
+
+	// Secure APDUs easily exceed the length limit of standard frames.
+	if len(secured.Data) > 15 {
+		ldata.Control1 &^= cemi.Control1StdFrame
+	}
+
+	return ldata, nil
+}
+
+// Open verifies the secure APDU of the frame using the given key. The returned frame carries the
+// plain application data.
+func Open(ldata cemi.LData, key []byte) (cemi.LData, *APDU, error) {
+	app, ok := ldata.Data.(*cemi.AppData)
+	if !ok {
+		return ldata, nil, ErrNotSecure
+	}
+
+	apdu, err := ParseAPDU(app)
+	if err != nil {
+		return ldata, nil, err
+	}
+
+	plain, err := open(key, ldataInfo(&ldata, app), apdu)
+	if err != nil {
+		return ldata, apdu, err
+	}
+
+	ldata.Data, err = unpackPlain(plain, app)
+	if err != nil {
+		return ldata, apdu, err
+	}
+
+	return ldata, apdu, nil
+}
@@ -0,0 +1,211 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package secure
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+var testKey = []byte{
+	0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+	0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F,
+}
+
+func makeGroupWrite(data ...byte) cemi.LData {
+	return cemi.LData{
+		Control1:    cemi.Control1StdFrame | cemi.Control1NoSysBroadcast,
+		Control2:    cemi.Control2GroupAddr | cemi.Control2Hops(6),
+		Source:      cemi.NewIndividualAddr3(1, 1, 1),
+		Destination: uint16(cemi.NewGroupAddr3(1, 2, 3)),
+		Data:        &cemi.AppData{Command: cemi.GroupValueWrite, Data: data},
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	for _, alg := range []Algorithm{AlgorithmAuth, AlgorithmAuthConf} {
+		scf := SecurityControl{Algorithm: alg}
+		ldata := makeGroupWrite(0, 0x0C, 0x1A)
+
+		secured, err := Seal(ldata, testKey, scf, 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		app := secured.Data.(*cemi.AppData)
+		if !IsSecure(app) {
+			t.Fatal("Secured frame does not carry a secure APDU")
+		}
+
+		// The plain APDU is 4 bytes long.
+		if len(app.Data) != 16 {
+			t.Errorf("Unexpected length of secure APDU: %d", len(app.Data))
+		}
+
+		if bytes.Contains(app.Data, []byte{0x0C, 0x1A}) != (alg == AlgorithmAuth) {
+			t.Errorf("Unexpected payload for algorithm %d: %x", alg, app.Data)
+		}
+
+		t.Run("Open", func(t *testing.T) {
+			opened, apdu, err := Open(secured, testKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			plain := opened.Data.(*cemi.AppData)
+			if plain.Command != cemi.GroupValueWrite || !bytes.Equal(plain.Data, []byte{0, 0x0C, 0x1A}) {
+				t.Errorf("Unexpected plain APDU: %+v", plain)
+			}
+
+			if apdu.SeqNumber != 42 || apdu.Control != scf {
+				t.Errorf("Unexpected secure APDU: %+v", apdu)
+			}
+		})
+
+		t.Run("WrongKey", func(t *testing.T) {
+			key := append([]byte(nil), testKey...)
+			key[0] ^= 1
+
+			if _, _, err := Open(secured, key); err != ErrMAC {
+				t.Fatalf("Expected %v, got %v", ErrMAC, err)
+			}
+		})
+
+		t.Run("Tampered", func(t *testing.T) {
+			tampered := secured
+			tampered.Source++
+
+			if _, _, err := Open(tampered, testKey); err != ErrMAC {
+				t.Fatalf("Expected %v, got %v", ErrMAC, err)
+			}
+
+			data := append([]byte(nil), app.Data...)
+			data[len(data)-5] ^= 1
+			tampered = secured
+			tampered.Data = &cemi.AppData{Command: app.Command, Data: data}
+
+			if _, _, err := Open(tampered, testKey); err != ErrMAC {
+				t.Fatalf("Expected %v, got %v", ErrMAC, err)
+			}
+		})
+	}
+}
+
+// The expected secure APDUs below have been computed independently of this package. The blocks
+// for the CBC-MAC and the counter were laid out as specified in KNX AN158 and encrypted with
+// OpenSSL.
+func TestSeal_knownAnswer(t *testing.T) {
+	toolAccess := cemi.LData{
+		Control1:    cemi.Control1StdFrame | cemi.Control1NoSysBroadcast,
+		Control2:    cemi.Control2Hops(6),
+		Source:      cemi.NewIndividualAddr3(1, 1, 1),
+		Destination: uint16(cemi.NewIndividualAddr3(1, 1, 9)),
+		Data: &cemi.AppData{
+			Numbered:  true,
+			SeqNumber: 2,
+			Command:   cemi.MemoryRead,
+			Data:      []byte{2, 0x40, 0x00},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		ldata    cemi.LData
+		scf      SecurityControl
+		expected []byte
+	}{
+		{
+			"Group",
+			makeGroupWrite(1),
+			SecurityControl{Algorithm: AlgorithmAuthConf},
+			[]byte{
+				0x31, 0x10, 0x00, 0x00, 0x00, 0x00, 0x04, 0xD2,
+				0xEB, 0xAA, 0xF5, 0x8A, 0x42, 0xF1,
+			},
+		},
+		{
+			"GroupAuth",
+			makeGroupWrite(1),
+			SecurityControl{Algorithm: AlgorithmAuth},
+			[]byte{
+				0x31, 0x00, 0x00, 0x00, 0x00, 0x00, 0x04, 0xD2,
+				0x00, 0x81, 0x0F, 0xFF, 0xB1, 0x0B,
+			},
+		},
+		{
+			"ToolAccess",
+			toolAccess,
+			SecurityControl{ToolAccess: true, Algorithm: AlgorithmAuthConf},
+			[]byte{
+				0x31, 0x90, 0x00, 0x00, 0x00, 0x00, 0x04, 0xD2,
+				0x2A, 0xED, 0x0F, 0xFF, 0x8E, 0x52, 0xA3, 0x0A,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			secured, err := Seal(test.ldata, testKey, test.scf, 1234)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			app := secured.Data.(*cemi.AppData)
+			if app.Command != cemi.Escape || !bytes.Equal(app.Data, test.expected) {
+				t.Fatalf("Expected %x, got %x", test.expected, app.Data)
+			}
+
+			opened, _, err := Open(secured, testKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			plain := opened.Data.(*cemi.AppData)
+			original := test.ldata.Data.(*cemi.AppData)
+			if plain.Command != original.Command || !bytes.Equal(plain.Data, original.Data) {
+				t.Errorf("Expected %+v, got %+v", original, plain)
+			}
+		})
+	}
+}
+
+func TestParseAPDU(t *testing.T) {
+	plain := &cemi.AppData{Command: cemi.GroupValueWrite, Data: []byte{1}}
+	if _, err := ParseAPDU(plain); err != ErrNotSecure {
+		t.Errorf("Expected %v, got %v", ErrNotSecure, err)
+	}
+
+	short := &cemi.AppData{Command: cemi.Escape, Data: []byte{secureAPCI, 0x10}}
+	if _, err := ParseAPDU(short); err != ErrAPDULength {
+		t.Errorf("Expected %v, got %v", ErrAPDULength, err)
+	}
+
+	scf := SecurityControl{ToolAccess: true, Algorithm: AlgorithmAuthConf, SystemBroadcast: true}
+	if other := unpackSecurityControl(scf.pack()); other != scf {
+		t.Errorf("Security control field does not survive packing: %+v", other)
+	}
+}
+
+func TestPlain(t *testing.T) {
+	apps := []*cemi.AppData{
+		{Command: cemi.GroupValueRead, Data: []byte{0}},
+		{Command: cemi.GroupValueWrite, Data: []byte{1}},
+		{Command: cemi.GroupValueResponse, Data: []byte{0, 1, 2, 3}},
+		{Numbered: true, SeqNumber: 3, Command: cemi.MemoryRead, Data: []byte{4, 0x10, 0x00}},
+	}
+
+	for _, app := range apps {
+		other, err := unpackPlain(packPlain(app), app)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if other.Command != app.Command || other.Numbered != app.Numbered ||
+			other.SeqNumber != app.SeqNumber || !bytes.Equal(other.Data, app.Data) {
+			t.Errorf("Plain APDU does not survive packing: %+v != %+v", other, app)
+		}
+	}
+}
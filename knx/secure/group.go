@@ -0,0 +1,155 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package secure
+
+import (
+	"errors"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// GroupConfig allows you to configure a secure group client.
+type GroupConfig struct {
+	// Address is the individual address of the client. It is part of the authentication, therefore
+	// it cannot be left to the gateway to fill it in.
+	Address cemi.IndividualAddr
+
+	// Keys provides the group keys of the secure group addresses.
+	Keys KeyStore
+
+	// Sequences persists the sequence number state.
+	Sequences SequenceStore
+}
+
+// ErrNoAddress is returned when the secure group client has not been given an individual address.
+var ErrNoAddress = errors.New("Individual address must be configured")
+
+// GroupClient is a knx.GroupClient that applies KNX Data Secure to all group addresses for which
+// a group key is known. Communication with other group addresses remains plain.
+//
+// Inbound secure frames that fail verification are dropped.
+type GroupClient struct {
+	conn    knx.Conn
+	layer   *Layer
+	address cemi.IndividualAddr
+	inbound chan knx.GroupEvent
+}
+
+// NewGroupClient creates a secure group client on top of the given connection. The client takes
+// ownership of the connection and closes it when it is closed.
+func NewGroupClient(conn knx.Conn, config GroupConfig) (*GroupClient, error) {
+	if config.Address == 0 {
+		return nil, ErrNoAddress
+	}
+
+	layer, err := NewLayer(config.Keys, config.Sequences)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &GroupClient{
+		conn:    conn,
+		layer:   layer,
+		address: config.Address,
+		inbound: make(chan knx.GroupEvent),
+	}
+
+	go client.serve()
+
+	return client, nil
+}
+
+// serve processes the inbound frames.
+func (client *GroupClient) serve() {
+	util.Log(client, "Started worker")
+	defer util.Log(client, "Worker exited")
+
+	defer close(client.inbound)
+
+	for msg := range client.conn.Inbound() {
+		ind, ok := msg.(*cemi.LDataInd)
+		if !ok || !ind.Control2.IsGroupAddr() {
+			continue
+		}
+
+		ldata := ind.LData
+		secure := false
+
+		if app, ok := ldata.Data.(*cemi.AppData); ok && IsSecure(app) {
+			plain, err := client.layer.Open(ldata)
+			if err != nil {
+				util.Log(client, "Dropped secure frame from %v: %v", ldata.Source, err)
+				continue
+			}
+
+			ldata = plain
+			secure = true
+		}
+
+		app, ok := ldata.Data.(*cemi.AppData)
+		if !ok || !app.Command.IsGroupCommand() {
+			continue
+		}
+
+		client.inbound <- knx.GroupEvent{
+			Command:     knx.GroupCommand(app.Command),
+			Source:      ldata.Source,
+			Destination: cemi.GroupAddr(ldata.Destination),
+			Data:        app.Data,
+			Secure:      secure,
+		}
+	}
+}
+
+// Send transmits the group event. It is secured if a group key is known for its destination. If the
+// event demands security, but no key is known, ErrNoKey is returned.
+func (client *GroupClient) Send(event knx.GroupEvent) error {
+	ldata := cemi.LData{
+		Control1: cemi.Control1NoRepeat | cemi.Control1NoSysBroadcast | cemi.Control1WantAck |
+			cemi.Control1Prio(cemi.PrioLow),
+		Control2:    cemi.Control2GroupAddr | cemi.Control2Hops(6),
+		Source:      client.address,
+		Destination: uint16(event.Destination),
+		Data: &cemi.AppData{
+			Command: cemi.APCI(event.Command),
+			Data:    event.Data,
+		},
+	}
+
+	if len(event.Data) <= 15 {
+		ldata.Control1 |= cemi.Control1StdFrame
+	}
+
+	if client.layer.CanSecure(ldata) {
+		var err error
+		if ldata, err = client.layer.Seal(ldata); err != nil {
+			return err
+		}
+	} else if event.Secure {
+		return ErrNoKey
+	}
+
+	// Routers exchange indications, whereas tunnels expect requests.
+	if _, ok := client.conn.(*knx.Router); ok {
+		return client.conn.Send(&cemi.LDataInd{LData: ldata})
+	}
+
+	return client.conn.Send(&cemi.LDataReq{LData: ldata})
+}
+
+// Inbound returns the channel on which group communication can be received.
+func (client *GroupClient) Inbound() <-chan knx.GroupEvent {
+	return client.inbound
+}
+
+// Close persists the sequence number state and shuts the underlying connection down.
+func (client *GroupClient) Close() {
+	if err := client.layer.Flush(); err != nil {
+		util.Log(client, "Failed to persist the sequence number state: %v", err)
+	}
+
+	client.conn.Close()
+}
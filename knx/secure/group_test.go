@@ -0,0 +1,115 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package secure
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/knxnet"
+)
+
+func newTestGroupClient(
+	t *testing.T,
+	bus *knxnet.Bus,
+	addr cemi.IndividualAddr,
+	keys KeyStore,
+) *GroupClient {
+	router := knx.NewRouterWithSocket(bus.Join(knxnet.BusLink{}), knx.DefaultRouterConfig)
+
+	client, err := NewGroupClient(router, GroupConfig{
+		Address:   addr,
+		Keys:      keys,
+		Sequences: NewMemorySequenceStore(SequenceState{}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+func expectEvent(t *testing.T, client *GroupClient) knx.GroupEvent {
+	select {
+	case event := <-client.Inbound():
+		return event
+
+	case <-time.After(time.Second):
+		t.Fatal("No event received")
+	}
+
+	return knx.GroupEvent{}
+}
+
+func TestGroupClient(t *testing.T) {
+	bus := knxnet.NewBus(1)
+
+	keys := NewMemoryKeyStore()
+	keys.SetGroupKey(cemi.NewGroupAddr3(1, 2, 3), testKey)
+
+	sender := newTestGroupClient(t, bus, cemi.NewIndividualAddr3(1, 1, 1), keys)
+	defer sender.Close()
+
+	receiver := newTestGroupClient(t, bus, cemi.NewIndividualAddr3(1, 1, 2), keys)
+	defer receiver.Close()
+
+	outsider := newTestGroupClient(t, bus, cemi.NewIndividualAddr3(1, 1, 3), NewMemoryKeyStore())
+	defer outsider.Close()
+
+	t.Run("Secure", func(t *testing.T) {
+		event := knx.GroupEvent{
+			Command:     knx.GroupWrite,
+			Destination: cemi.NewGroupAddr3(1, 2, 3),
+			Data:        []byte{1},
+		}
+
+		if err := sender.Send(event); err != nil {
+			t.Fatal(err)
+		}
+
+		received := expectEvent(t, receiver)
+		if !received.Secure || received.Command != knx.GroupWrite ||
+			received.Source != cemi.NewIndividualAddr3(1, 1, 1) ||
+			received.Destination != event.Destination || !bytes.Equal(received.Data, event.Data) {
+			t.Errorf("Unexpected event: %+v", received)
+		}
+	})
+
+	t.Run("Plain", func(t *testing.T) {
+		event := knx.GroupEvent{
+			Command:     knx.GroupWrite,
+			Destination: cemi.NewGroupAddr3(1, 2, 4),
+			Data:        []byte{0},
+		}
+
+		if err := sender.Send(event); err != nil {
+			t.Fatal(err)
+		}
+
+		// The outsider cannot verify the secure event from before, so this is the first event it
+		// receives.
+		for _, client := range []*GroupClient{receiver, outsider} {
+			received := expectEvent(t, client)
+			if received.Secure || received.Destination != event.Destination {
+				t.Errorf("Unexpected event: %+v", received)
+			}
+		}
+	})
+
+	t.Run("NoKey", func(t *testing.T) {
+		event := knx.GroupEvent{
+			Command:     knx.GroupWrite,
+			Destination: cemi.NewGroupAddr3(1, 2, 3),
+			Data:        []byte{1},
+			Secure:      true,
+		}
+
+		if err := outsider.Send(event); err != ErrNoKey {
+			t.Fatalf("Expected %v, got %v", ErrNoKey, err)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package secure
+
+import (
+	"sync"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// A KeyStore provides the keys of a secured installation. Keys are 16 bytes long.
+type KeyStore interface {
+	// GroupKey returns the key of the secure group address.
+	GroupKey(addr cemi.GroupAddr) ([]byte, bool)
+
+	// ToolKey returns the tool key of the device, which protects its point-to-point communication.
+	ToolKey(addr cemi.IndividualAddr) ([]byte, bool)
+}
+
+// MemoryKeyStore is a KeyStore which keeps its keys in memory.
+type MemoryKeyStore struct {
+	mu     sync.RWMutex
+	groups map[cemi.GroupAddr][]byte
+	tools  map[cemi.IndividualAddr][]byte
+}
+
+// NewMemoryKeyStore creates an empty key store.
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{
+		groups: make(map[cemi.GroupAddr][]byte),
+		tools:  make(map[cemi.IndividualAddr][]byte),
+	}
+}
+
+// SetGroupKey sets the key of the secure group address.
+func (store *MemoryKeyStore) SetGroupKey(addr cemi.GroupAddr, key []byte) error {
+	if len(key) != 16 {
+		return ErrKeyLength
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.groups[addr] = append([]byte(nil), key...)
+
+	return nil
+}
+
+// SetToolKey sets the tool key of the device.
+func (store *MemoryKeyStore) SetToolKey(addr cemi.IndividualAddr, key []byte) error {
+	if len(key) != 16 {
+		return ErrKeyLength
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.tools[addr] = append([]byte(nil), key...)
+
+	return nil
+}
+
+// GroupKey returns the key of the secure group address.
+func (store *MemoryKeyStore) GroupKey(addr cemi.GroupAddr) ([]byte, bool) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	key, ok := store.groups[addr]
+	return key, ok
+}
+
+// ToolKey returns the tool key of the device.
+func (store *MemoryKeyStore) ToolKey(addr cemi.IndividualAddr) ([]byte, bool) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	key, ok := store.tools[addr]
+	return key, ok
+}
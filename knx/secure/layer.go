@@ -0,0 +1,246 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package secure
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// These are errors that can occur in the secure layer.
+var (
+	ErrNoKey          = errors.New("No key is known for the destination")
+	ErrReplay         = errors.New("Sequence number has already been used")
+	ErrSeqNumberSpent = errors.New("Sequence numbers have been exhausted")
+)
+
+// These control how often the sequence number state is persisted.
+const (
+	// reserveSeqNumbers is the number of outgoing sequence numbers that are reserved at once.
+	reserveSeqNumbers = 256
+
+	// saveReceivedInterval is the number of accepted inbound frames after which the last received
+	// sequence numbers are persisted.
+	saveReceivedInterval = 64
+)
+
+// A Layer secures outgoing and verifies incoming frames. It looks up the keys in a KeyStore and
+// keeps track of the sequence numbers, which are persisted in a SequenceStore.
+//
+// Outgoing sequence numbers are reserved in blocks. The end of the block is persisted before its
+// first number is used, so that no number is used twice, even if the block is not used up before a
+// restart. The last received sequence numbers are persisted together with the next block, after a
+// number of accepted frames, or when Flush is called.
+//
+// Frames to group addresses are protected with the group key, frames to individual addresses with
+// the tool key of the remote device.
+type Layer struct {
+	keys  KeyStore
+	store SequenceStore
+
+	mu    sync.Mutex
+	state SequenceState
+
+	// reserved is the first outgoing sequence number that has not been reserved yet.
+	reserved uint64
+
+	// unsaved is the number of accepted inbound frames since the state has been persisted.
+	unsaved int
+}
+
+// NewLayer creates a secure layer and loads the sequence number state.
+func NewLayer(keys KeyStore, store SequenceStore) (*Layer, error) {
+	state, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	// Receivers reject sequence number 0, because it is not greater than their initial state.
+	if state.Own == 0 {
+		state.Own = 1
+	}
+
+	if state.Received == nil {
+		state.Received = make(map[cemi.IndividualAddr]uint64)
+	}
+
+	return &Layer{
+		keys:     keys,
+		store:    store,
+		state:    state,
+		reserved: state.Own,
+	}, nil
+}
+
+// outboundKey finds the key with which the frame has to be secured.
+func (layer *Layer) outboundKey(ldata *cemi.LData) ([]byte, bool, bool) {
+	if ldata.Control2.IsGroupAddr() {
+		key, ok := layer.keys.GroupKey(cemi.GroupAddr(ldata.Destination))
+		return key, false, ok
+	}
+
+	key, ok := layer.keys.ToolKey(cemi.IndividualAddr(ldata.Destination))
+	return key, true, ok
+}
+
+// CanSecure determines whether a key is known for the destination of the frame.
+func (layer *Layer) CanSecure(ldata cemi.LData) bool {
+	_, _, ok := layer.outboundKey(&ldata)
+	return ok
+}
+
+// Seal secures the application data of the frame with the next sequence number. The application
+// data is authenticated and encrypted.
+func (layer *Layer) Seal(ldata cemi.LData) (cemi.LData, error) {
+	key, tool, ok := layer.outboundKey(&ldata)
+	if !ok {
+		return ldata, ErrNoKey
+	}
+
+	layer.mu.Lock()
+	defer layer.mu.Unlock()
+
+	seq := layer.state.Own
+	if seq > MaxSeqNumber {
+		return ldata, ErrSeqNumberSpent
+	}
+
+	broadcast := ldata.Control2.IsGroupAddr() && ldata.Destination == 0 &&
+		ldata.Control1&cemi.Control1NoSysBroadcast == 0
+
+	scf := SecurityControl{
+		ToolAccess:      tool,
+		Algorithm:       AlgorithmAuthConf,
+		SystemBroadcast: broadcast,
+		Service:         ServiceData,
+	}
+
+	secured, err := Seal(ldata, key, scf, seq)
+	if err != nil {
+		return ldata, err
+	}
+
+	// Reserve the next block before the frame leaves, so that the sequence number is never used
+	// twice.
+	if seq >= layer.reserved {
+		reserved := seq + reserveSeqNumbers
+		if reserved > MaxSeqNumber+1 {
+			reserved = MaxSeqNumber + 1
+		}
+
+		if err := layer.save(reserved); err != nil {
+			return ldata, err
+		}
+	}
+
+	layer.state.Own = seq + 1
+
+	return secured, nil
+}
+
+// save persists the state with the given end of the reserved outgoing sequence numbers.
+func (layer *Layer) save(reserved uint64) error {
+	state := layer.state.clone()
+	state.Own = reserved
+
+	if err := layer.store.Save(state); err != nil {
+		return err
+	}
+
+	layer.reserved = reserved
+	layer.unsaved = 0
+
+	return nil
+}
+
+// inboundKey finds the key with which the frame has been secured.
+func (layer *Layer) inboundKey(ldata *cemi.LData, apdu *APDU) ([]byte, bool) {
+	if apdu.Control.ToolAccess {
+		if key, ok := layer.keys.ToolKey(ldata.Source); ok {
+			return key, true
+		}
+
+		if ldata.Control2.IsGroupAddr() {
+			return nil, false
+		}
+
+		return layer.keys.ToolKey(cemi.IndividualAddr(ldata.Destination))
+	}
+
+	if !ldata.Control2.IsGroupAddr() {
+		return nil, false
+	}
+
+	return layer.keys.GroupKey(cemi.GroupAddr(ldata.Destination))
+}
+
+// Open verifies the secure APDU of the frame and returns the frame with the plain application
+// data. Frames whose sequence number is not greater than the last one of their source are rejected
+// as replays.
+func (layer *Layer) Open(ldata cemi.LData) (cemi.LData, error) {
+	app, ok := ldata.Data.(*cemi.AppData)
+	if !ok {
+		return ldata, ErrNotSecure
+	}
+
+	apdu, err := ParseAPDU(app)
+	if err != nil {
+		return ldata, err
+	}
+
+	key, ok := layer.inboundKey(&ldata, apdu)
+	if !ok {
+		return ldata, ErrNoKey
+	}
+
+	layer.mu.Lock()
+	defer layer.mu.Unlock()
+
+	if last, ok := layer.state.Received[ldata.Source]; ok && apdu.SeqNumber <= last {
+		return ldata, ErrReplay
+	}
+
+	plain, _, err := Open(ldata, key)
+	if err != nil {
+		return ldata, err
+	}
+
+	layer.state.Received[ldata.Source] = apdu.SeqNumber
+
+	// The frame is valid, therefore it is accepted even if the state cannot be persisted. Saving is
+	// attempted again with the next frame.
+	layer.unsaved++
+	if layer.unsaved >= saveReceivedInterval {
+		if err := layer.save(layer.reserved); err != nil {
+			util.Log(layer, "Failed to persist the sequence number state: %v", err)
+		}
+	}
+
+	return plain, nil
+}
+
+// Flush persists the last received sequence numbers, if they have changed since the state has been
+// persisted. Call it before shutting down, so that frames which have been accepted cannot be
+// replayed after a restart.
+func (layer *Layer) Flush() error {
+	layer.mu.Lock()
+	defer layer.mu.Unlock()
+
+	if layer.unsaved == 0 {
+		return nil
+	}
+
+	return layer.save(layer.reserved)
+}
+
+// SeqNumber returns the sequence number which will be used for the next outgoing secure APDU.
+func (layer *Layer) SeqNumber() uint64 {
+	layer.mu.Lock()
+	defer layer.mu.Unlock()
+
+	return layer.state.Own
+}
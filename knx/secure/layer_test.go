@@ -0,0 +1,232 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package secure
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+func newTestLayer(t *testing.T, store SequenceStore) *Layer {
+	keys := NewMemoryKeyStore()
+	keys.SetGroupKey(cemi.NewGroupAddr3(1, 2, 3), testKey)
+	keys.SetToolKey(cemi.NewIndividualAddr3(1, 1, 9), testKey)
+
+	layer, err := NewLayer(keys, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return layer
+}
+
+func TestLayer(t *testing.T) {
+	sender := newTestLayer(t, NewMemorySequenceStore(SequenceState{}))
+	receiver := newTestLayer(t, NewMemorySequenceStore(SequenceState{}))
+
+	secured, err := sender.Seal(makeGroupWrite(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sender.SeqNumber() != 2 {
+		t.Errorf("Unexpected sequence number: %d", sender.SeqNumber())
+	}
+
+	if _, err := receiver.Open(secured); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Replay", func(t *testing.T) {
+		if _, err := receiver.Open(secured); err != ErrReplay {
+			t.Fatalf("Expected %v, got %v", ErrReplay, err)
+		}
+	})
+
+	t.Run("NoKey", func(t *testing.T) {
+		ldata := makeGroupWrite(1)
+		ldata.Destination++
+
+		if sender.CanSecure(ldata) {
+			t.Error("Should not be able to secure")
+		}
+
+		if _, err := sender.Seal(ldata); err != ErrNoKey {
+			t.Fatalf("Expected %v, got %v", ErrNoKey, err)
+		}
+	})
+
+	t.Run("ToolAccess", func(t *testing.T) {
+		ldata := cemi.LData{
+			Control1:    cemi.Control1StdFrame | cemi.Control1NoSysBroadcast,
+			Control2:    cemi.Control2Hops(6),
+			Source:      cemi.NewIndividualAddr3(1, 1, 1),
+			Destination: uint16(cemi.NewIndividualAddr3(1, 1, 9)),
+			Data: &cemi.AppData{
+				Numbered: true,
+				Command:  cemi.MemoryRead,
+				Data:     []byte{2, 0x40, 0x00},
+			},
+		}
+
+		secured, err := sender.Seal(ldata)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		apdu, err := ParseAPDU(secured.Data.(*cemi.AppData))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !apdu.Control.ToolAccess {
+			t.Error("Point-to-point frame has not been secured with the tool key")
+		}
+
+		if _, err := receiver.Open(secured); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestFileSequenceStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "knx-secure")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	store := &FileSequenceStore{
+		Path:    filepath.Join(dir, "sequence.json"),
+		Initial: SequenceState{Own: 100},
+	}
+
+	layer := newTestLayer(t, store)
+	if layer.SeqNumber() != 100 {
+		t.Fatalf("Initial state has not been used: %d", layer.SeqNumber())
+	}
+
+	if _, err := layer.Seal(makeGroupWrite(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	other := newTestLayer(t, NewMemorySequenceStore(SequenceState{}))
+	secured, err := other.Seal(makeGroupWrite(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := layer.Open(secured); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := layer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The state must survive a restart. The rest of the reserved block is skipped.
+	restarted := newTestLayer(t, store)
+	if restarted.SeqNumber() != 100+reserveSeqNumbers {
+		t.Errorf("Sequence number has not been persisted: %d", restarted.SeqNumber())
+	}
+
+	if _, err := restarted.Open(secured); err != ErrReplay {
+		t.Errorf("Expected %v, got %v", ErrReplay, err)
+	}
+}
+
+// countingStore counts how often the state is saved.
+type countingStore struct {
+	*MemorySequenceStore
+	saves int
+}
+
+func (store *countingStore) Save(state SequenceState) error {
+	store.saves++
+	return store.MemorySequenceStore.Save(state)
+}
+
+func TestLayer_save(t *testing.T) {
+	store := &countingStore{MemorySequenceStore: NewMemorySequenceStore(SequenceState{})}
+	sender := newTestLayer(t, store)
+
+	t.Run("Reserve", func(t *testing.T) {
+		for i := 0; i < reserveSeqNumbers; i++ {
+			if _, err := sender.Seal(makeGroupWrite(1)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if store.saves != 1 {
+			t.Fatalf("Expected 1 save, got %d", store.saves)
+		}
+
+		// The stored state marks the end of the block, which has been used up.
+		state, _ := store.Load()
+		if state.Own != 1+reserveSeqNumbers || sender.SeqNumber() != state.Own {
+			t.Fatalf("Expected %d, got %d", sender.SeqNumber(), state.Own)
+		}
+
+		if _, err := sender.Seal(makeGroupWrite(1)); err != nil {
+			t.Fatal(err)
+		}
+
+		if store.saves != 2 {
+			t.Fatalf("Expected 2 saves, got %d", store.saves)
+		}
+	})
+
+	t.Run("Received", func(t *testing.T) {
+		other := newTestLayer(t, NewMemorySequenceStore(SequenceState{}))
+		source := cemi.NewIndividualAddr3(1, 1, 1)
+
+		open := func() {
+			secured, err := other.Seal(makeGroupWrite(1))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := sender.Open(secured); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		saves := store.saves
+		for i := 0; i < saveReceivedInterval-1; i++ {
+			open()
+		}
+
+		if store.saves != saves {
+			t.Fatalf("Expected %d saves, got %d", saves, store.saves)
+		}
+
+		open()
+
+		state, _ := store.Load()
+		if store.saves != saves+1 || state.Received[source] != saveReceivedInterval {
+			t.Fatalf("Received sequence numbers have not been saved: %+v", state)
+		}
+
+		open()
+
+		if err := sender.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		state, _ = store.Load()
+		if store.saves != saves+2 || state.Received[source] != saveReceivedInterval+1 {
+			t.Fatalf("Received sequence numbers have not been flushed: %+v", state)
+		}
+
+		// Nothing has changed since.
+		if err := sender.Flush(); err != nil || store.saves != saves+2 {
+			t.Fatalf("Unexpected save: %v", err)
+		}
+	})
+}
@@ -0,0 +1,193 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package secure
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// VerifyError reports a secure group telegram that could not be authenticated or decrypted.
+type VerifyError struct {
+	Source      cemi.IndividualAddr
+	Destination cemi.GroupAddr
+	SeqNumber   uint64
+	Err         error
+}
+
+// Error implements the error interface.
+func (err *VerifyError) Error() string {
+	return fmt.Sprintf("Secure telegram from %v to %v (sequence number %d): %v",
+		err.Source, err.Destination, err.SeqNumber, err.Err)
+}
+
+// Unwrap returns the underlying error.
+func (err *VerifyError) Unwrap() error {
+	return err.Err
+}
+
+// passiveReceived identifies the last secure telegram that has been received from a device.
+type passiveReceived struct {
+	seqNumber uint64
+	mac       [4]byte
+}
+
+// A PassiveDecoder authenticates and decrypts secure group telegrams without taking part in the
+// communication, e.g. in bus monitors or when analysing captures. Replays are detected using the
+// sequence numbers seen so far, which are not persisted.
+//
+// Telegrams are repeated on the bus when not all receivers have acknowledged them. A telegram with
+// the same sequence number and MAC as the last one from its source is such a repetition, not a
+// replay, and is ignored.
+type PassiveDecoder struct {
+	keys KeyStore
+
+	mu       sync.Mutex
+	received map[cemi.IndividualAddr]passiveReceived
+}
+
+// NewPassiveDecoder creates a decoder which takes the group keys from the given key store.
+func NewPassiveDecoder(keys KeyStore) *PassiveDecoder {
+	return &PassiveDecoder{
+		keys:     keys,
+		received: make(map[cemi.IndividualAddr]passiveReceived),
+	}
+}
+
+// Decode translates the frame into a group event. Secure telegrams are authenticated and decrypted,
+// failures are reported as *VerifyError. The second result is false if the frame does not contain
+// group communication or repeats a secure telegram that has been decoded already.
+func (dec *PassiveDecoder) Decode(msg cemi.Message) (knx.GroupEvent, bool, error) {
+	var ldata cemi.LData
+
+	switch msg := msg.(type) {
+	case *cemi.LDataInd:
+		ldata = msg.LData
+
+	case *cemi.LDataReq:
+		ldata = msg.LData
+
+	case *cemi.LDataCon:
+		ldata = msg.LData
+
+	default:
+		return knx.GroupEvent{}, false, nil
+	}
+
+	if !ldata.Control2.IsGroupAddr() {
+		return knx.GroupEvent{}, false, nil
+	}
+
+	app, ok := ldata.Data.(*cemi.AppData)
+	if !ok {
+		return knx.GroupEvent{}, false, nil
+	}
+
+	secure := IsSecure(app)
+	if secure {
+		plain, repeated, err := dec.open(ldata, app)
+		if err != nil {
+			return knx.GroupEvent{}, true, err
+		} else if repeated {
+			return knx.GroupEvent{}, false, nil
+		}
+
+		ldata = plain
+		app = plain.Data.(*cemi.AppData)
+	}
+
+	if !app.Command.IsGroupCommand() {
+		return knx.GroupEvent{}, false, nil
+	}
+
+	return knx.GroupEvent{
+		Command:     knx.GroupCommand(app.Command),
+		Source:      ldata.Source,
+		Destination: cemi.GroupAddr(ldata.Destination),
+		Data:        app.Data,
+		Secure:      secure,
+	}, true, nil
+}
+
+// open verifies the secure telegram. The second result is true if the telegram repeats the last one
+// from its source.
+func (dec *PassiveDecoder) open(ldata cemi.LData, app *cemi.AppData) (cemi.LData, bool, error) {
+	verifyErr := &VerifyError{
+		Source:      ldata.Source,
+		Destination: cemi.GroupAddr(ldata.Destination),
+	}
+
+	apdu, err := ParseAPDU(app)
+	if err != nil {
+		verifyErr.Err = err
+		return ldata, false, verifyErr
+	}
+
+	verifyErr.SeqNumber = apdu.SeqNumber
+
+	key, ok := dec.keys.GroupKey(cemi.GroupAddr(ldata.Destination))
+	if !ok {
+		verifyErr.Err = ErrNoKey
+		return ldata, false, verifyErr
+	}
+
+	plain, _, err := Open(ldata, key)
+	if err != nil {
+		verifyErr.Err = err
+		return ldata, false, verifyErr
+	}
+
+	dec.mu.Lock()
+	defer dec.mu.Unlock()
+
+	current := passiveReceived{seqNumber: apdu.SeqNumber, mac: apdu.MAC}
+
+	if last, ok := dec.received[ldata.Source]; ok && current == last {
+		return ldata, true, nil
+	} else if ok && apdu.SeqNumber <= last.seqNumber {
+		verifyErr.Err = ErrReplay
+		return ldata, false, verifyErr
+	}
+
+	dec.received[ldata.Source] = current
+
+	return plain, false, nil
+}
+
+// Serve decodes the inbound frames and delivers the group events to the outbound channel, which is
+// closed once the inbound channel has been closed. Verification failures are delivered to the
+// failures channel, if one is given, and logged otherwise.
+func (dec *PassiveDecoder) Serve(
+	inbound <-chan cemi.Message,
+	outbound chan<- knx.GroupEvent,
+	failures chan<- *VerifyError,
+) {
+	util.Log(dec, "Started worker")
+	defer util.Log(dec, "Worker exited")
+
+	defer close(outbound)
+
+	for msg := range inbound {
+		event, ok, err := dec.Decode(msg)
+		if err != nil {
+			verifyErr := err.(*VerifyError)
+
+			if failures != nil {
+				failures <- verifyErr
+			} else {
+				util.Log(dec, "%v", verifyErr)
+			}
+
+			continue
+		}
+
+		if ok {
+			outbound <- event
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package secure
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+func TestPassiveDecoder(t *testing.T) {
+	keys := NewMemoryKeyStore()
+	keys.SetGroupKey(cemi.NewGroupAddr3(1, 2, 3), testKey)
+
+	scf := SecurityControl{Algorithm: AlgorithmAuthConf}
+
+	secured, err := Seal(makeGroupWrite(1), testKey, scf, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The repetition of a telegram on the bus is identical, apart from the repeat flag.
+	repeated := secured
+	repeated.Control1 &^= cemi.Control1NoRepeat
+
+	// A replay uses an old sequence number for a different telegram.
+	replayed, err := Seal(makeGroupWrite(0), testKey, scf, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := secured
+	tampered.Source++
+
+	unknown := secured
+	unknown.Destination++
+
+	inbound := make(chan cemi.Message, 8)
+	inbound <- &cemi.LDataInd{LData: makeGroupWrite(0)}
+	inbound <- &cemi.LDataInd{LData: secured}
+	inbound <- &cemi.LDataInd{LData: repeated}
+	inbound <- &cemi.LDataInd{LData: replayed}
+	inbound <- &cemi.LDataInd{LData: tampered}
+	inbound <- &cemi.LDataInd{LData: unknown}
+	inbound <- &cemi.LDataCon{LData: makeGroupWrite(0)}
+	close(inbound)
+
+	outbound := make(chan knx.GroupEvent, 8)
+	failures := make(chan *VerifyError, 8)
+
+	NewPassiveDecoder(keys).Serve(inbound, outbound, failures)
+	close(failures)
+
+	var events []knx.GroupEvent
+	for event := range outbound {
+		events = append(events, event)
+	}
+
+	if len(events) != 3 || events[0].Secure || !events[1].Secure || events[2].Secure {
+		t.Fatalf("Unexpected events: %+v", events)
+	}
+
+	if events[1].Command != knx.GroupWrite || !bytes.Equal(events[1].Data, []byte{1}) ||
+		events[1].Source != secured.Source {
+		t.Errorf("Unexpected secure event: %+v", events[1])
+	}
+
+	expected := []error{ErrReplay, ErrMAC, ErrNoKey}
+	for _, want := range expected {
+		failure, ok := <-failures
+		if !ok {
+			t.Fatal("Missing failure")
+		}
+
+		if failure.Err != want || failure.SeqNumber != 7 {
+			t.Errorf("Expected %v, got %v", want, failure)
+		}
+	}
+
+	if failure, ok := <-failures; ok {
+		t.Errorf("Unexpected failure: %v", failure)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package secure
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// SequenceState is the sequence number state of a secure participant.
+type SequenceState struct {
+	// Own is the sequence number that is used for the next secure APDU that is sent.
+	Own uint64
+
+	// Received contains the last valid sequence number that has been received from each device.
+	// Secure APDUs whose sequence number is not greater are replays and must be rejected.
+	Received map[cemi.IndividualAddr]uint64
+}
+
+// clone creates a deep copy of the state.
+func (state SequenceState) clone() SequenceState {
+	received := make(map[cemi.IndividualAddr]uint64, len(state.Received))
+	for addr, seq := range state.Received {
+		received[addr] = seq
+	}
+
+	state.Received = received
+
+	return state
+}
+
+// A SequenceStore persists the sequence number state across restarts. Losing the state lets
+// devices reject our secure APDUs as replays and exposes us to replays of old ones.
+type SequenceStore interface {
+	// Load retrieves the persisted state.
+	Load() (SequenceState, error)
+
+	// Save persists the state.
+	Save(state SequenceState) error
+}
+
+// MemorySequenceStore keeps the sequence number state in memory. It is useful for tests or when
+// the state is persisted by other means.
+type MemorySequenceStore struct {
+	mu    sync.Mutex
+	state SequenceState
+}
+
+// NewMemorySequenceStore creates a store with the given initial state.
+func NewMemorySequenceStore(state SequenceState) *MemorySequenceStore {
+	return &MemorySequenceStore{state: state.clone()}
+}
+
+// Load retrieves the state.
+func (store *MemorySequenceStore) Load() (SequenceState, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.state.clone(), nil
+}
+
+// Save stores the state.
+func (store *MemorySequenceStore) Save(state SequenceState) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.state = state.clone()
+
+	return nil
+}
+
+// FileSequenceStore persists the sequence number state in a JSON file.
+type FileSequenceStore struct {
+	// Path is the location of the file.
+	Path string
+
+	// Initial is the state that is used when the file does not exist yet.
+	Initial SequenceState
+}
+
+// Load reads the state from the file.
+func (store *FileSequenceStore) Load() (SequenceState, error) {
+	data, err := ioutil.ReadFile(store.Path)
+	if os.IsNotExist(err) {
+		return store.Initial.clone(), nil
+	} else if err != nil {
+		return SequenceState{}, err
+	}
+
+	var state SequenceState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return SequenceState{}, err
+	}
+
+	return state.clone(), nil
+}
+
+// Save writes the state to the file. The file is replaced atomically, so that a crash does not
+// leave a truncated file behind.
+func (store *FileSequenceStore) Save(state SequenceState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(store.Path), filepath.Base(store.Path)+".")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), store.Path)
+}
@@ -0,0 +1,395 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// ThrottleConfig allows you to configure the throttle's behaviour.
+type ThrottleConfig struct {
+	// MinInterval is the minimum time between two writes to the same group address. Writes that
+	// arrive earlier are coalesced, so that only the latest value is sent once the interval has
+	// elapsed. A negative interval disables coalescing.
+	MinInterval time.Duration
+
+	// Intervals overrides MinInterval for individual group addresses. A non-positive interval
+	// disables coalescing for the group address.
+	Intervals map[cemi.GroupAddr]time.Duration
+
+	// Rate is the average number of telegrams per second that may be sent. A TP1 line carries
+	// about 50 standard telegrams per second; the default leaves room for the other devices.
+	Rate float64
+
+	// Burst is the number of telegrams that may be sent at once, after the throttle has been idle.
+	Burst int
+
+	// QueueLength is the number of events that can be queued before sending blocks.
+	QueueLength int
+
+	// Clock is used for all intervals. Tests may use a util.FakeClock in order to control the
+	// passage of time.
+	Clock util.Clock
+}
+
+// DefaultThrottleConfig is a good default configuration for a GroupThrottle.
+var DefaultThrottleConfig = ThrottleConfig{
+	MinInterval: 300 * time.Millisecond,
+	Rate:        20,
+	Burst:       10,
+	QueueLength: 256,
+	Clock:       util.RealClock,
+}
+
+// checkThrottleConfig makes sure that the configuration is actually usable.
+func checkThrottleConfig(config ThrottleConfig) ThrottleConfig {
+	if config.MinInterval == 0 {
+		config.MinInterval = DefaultThrottleConfig.MinInterval
+	}
+
+	if config.Rate <= 0 {
+		config.Rate = DefaultThrottleConfig.Rate
+	}
+
+	if config.Burst <= 0 {
+		config.Burst = DefaultThrottleConfig.Burst
+	}
+
+	if config.QueueLength <= 0 {
+		config.QueueLength = DefaultThrottleConfig.QueueLength
+	}
+
+	if config.Clock == nil {
+		config.Clock = DefaultThrottleConfig.Clock
+	}
+
+	return config
+}
+
+// These are errors that can occur when using a GroupThrottle.
+var (
+	ErrSuperseded     = errors.New("Write has been superseded by a newer value")
+	ErrThrottleClosed = errors.New("Throttle has been closed")
+)
+
+// tokenBucket limits the rate at which telegrams are sent. Instead of counting tokens, it tracks
+// the time at which the bucket will be full again, which avoids rounding errors.
+type tokenBucket struct {
+	interval  time.Duration
+	tolerance time.Duration
+	full      time.Time
+}
+
+// newTokenBucket creates a full bucket.
+func newTokenBucket(rate float64, burst int, now time.Time) tokenBucket {
+	interval := time.Duration(float64(time.Second) / rate)
+
+	return tokenBucket{
+		interval:  interval,
+		tolerance: time.Duration(burst-1) * interval,
+		full:      now,
+	}
+}
+
+// take consumes a token. If none is available, it returns the time until the next one.
+func (bucket *tokenBucket) take(now time.Time) (time.Duration, bool) {
+	if bucket.full.Before(now) {
+		bucket.full = now
+	}
+
+	if wait := bucket.full.Sub(now) - bucket.tolerance; wait > 0 {
+		return wait, false
+	}
+
+	bucket.full = bucket.full.Add(bucket.interval)
+	return 0, true
+}
+
+// throttleJob is an event that waits to be sent.
+type throttleJob struct {
+	event  GroupEvent
+	result chan<- error
+
+	// due is the time at which a coalesced write may be sent.
+	due time.Time
+}
+
+// throttleJobsByDue sorts jobs by the time at which they are due and then by destination.
+type throttleJobsByDue []*throttleJob
+
+func (jobs throttleJobsByDue) Len() int { return len(jobs) }
+
+func (jobs throttleJobsByDue) Less(i, j int) bool {
+	if !jobs[i].due.Equal(jobs[j].due) {
+		return jobs[i].due.Before(jobs[j].due)
+	}
+
+	return jobs[i].event.Destination < jobs[j].event.Destination
+}
+
+func (jobs throttleJobsByDue) Swap(i, j int) { jobs[i], jobs[j] = jobs[j], jobs[i] }
+
+// A GroupThrottle is a group client which protects the bus from being overloaded by its user. It
+// coalesces rapid writes to the same group address, e.g. from a slider, and limits the overall rate
+// of telegrams using a token bucket. The events are sent through another group client, whose
+// inbound events are passed through unchanged.
+//
+// The first write to a group address is sent right away. Writes that follow within the minimum
+// interval are held back; each replaces the previous one, which fails with ErrSuperseded. Reads
+// and responses are never coalesced.
+type GroupThrottle struct {
+	client GroupClient
+	config ThrottleConfig
+	slots  chan struct{}
+	wake   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+	wait   sync.WaitGroup
+
+	mu       sync.Mutex
+	closed   bool
+	bucket   tokenBucket
+	ready    []*throttleJob
+	deferred map[cemi.GroupAddr]*throttleJob
+	pending  map[cemi.GroupAddr]*throttleJob
+	lastSent map[cemi.GroupAddr]time.Time
+}
+
+// NewGroupThrottle creates a throttle which sends through the given client. The throttle must be
+// the only sender of the client, but it does not take ownership; closing the throttle leaves the
+// client open.
+func NewGroupThrottle(client GroupClient, config ThrottleConfig) *GroupThrottle {
+	config = checkThrottleConfig(config)
+
+	throttle := &GroupThrottle{
+		client:   client,
+		config:   config,
+		slots:    make(chan struct{}, config.QueueLength),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		bucket:   newTokenBucket(config.Rate, config.Burst, config.Clock.Now()),
+		deferred: make(map[cemi.GroupAddr]*throttleJob),
+		pending:  make(map[cemi.GroupAddr]*throttleJob),
+		lastSent: make(map[cemi.GroupAddr]time.Time),
+	}
+
+	throttle.wait.Add(1)
+	go throttle.serve()
+
+	return throttle
+}
+
+// interval determines the minimum interval between writes to the group address.
+func (throttle *GroupThrottle) interval(addr cemi.GroupAddr) time.Duration {
+	if interval, ok := throttle.config.Intervals[addr]; ok {
+		return interval
+	}
+
+	return throttle.config.MinInterval
+}
+
+// finish delivers the result of a job and frees its slot in the queue.
+func (throttle *GroupThrottle) finish(job *throttleJob, err error) {
+	job.result <- err
+	<-throttle.slots
+}
+
+// notify wakes up the worker.
+func (throttle *GroupThrottle) notify() {
+	select {
+	case throttle.wake <- struct{}{}:
+	default:
+	}
+}
+
+// SendAsync queues a group event for sending. The returned channel delivers the result once the
+// event has been sent or superseded by a newer write.
+func (throttle *GroupThrottle) SendAsync(event GroupEvent) <-chan error {
+	result := make(chan error, 1)
+
+	select {
+	case throttle.slots <- struct{}{}:
+	case <-throttle.done:
+		result <- ErrThrottleClosed
+		return result
+	}
+
+	job := &throttleJob{event: event, result: result}
+
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+
+	if throttle.closed {
+		throttle.finish(job, ErrThrottleClosed)
+		return result
+	}
+
+	defer throttle.notify()
+
+	interval := throttle.interval(event.Destination)
+	if event.Command != GroupWrite || interval <= 0 {
+		throttle.ready = append(throttle.ready, job)
+		return result
+	}
+
+	// A write that has not been sent yet takes the new value.
+	if queued, ok := throttle.pending[event.Destination]; ok {
+		throttle.finish(&throttleJob{result: queued.result}, ErrSuperseded)
+
+		queued.event = event
+		queued.result = result
+
+		return result
+	}
+
+	throttle.pending[event.Destination] = job
+
+	now := throttle.config.Clock.Now()
+	if last, ok := throttle.lastSent[event.Destination]; ok && now.Sub(last) < interval {
+		job.due = last.Add(interval)
+		throttle.deferred[event.Destination] = job
+	} else {
+		throttle.ready = append(throttle.ready, job)
+	}
+
+	return result
+}
+
+// Send transmits a group event and waits until it has been sent. If the event is a write that
+// is superseded by a newer one, ErrSuperseded is returned.
+func (throttle *GroupThrottle) Send(event GroupEvent) error {
+	return <-throttle.SendAsync(event)
+}
+
+// Inbound returns the inbound channel of the underlying client.
+func (throttle *GroupThrottle) Inbound() <-chan GroupEvent {
+	return throttle.client.Inbound()
+}
+
+// Close stops the throttle. Events that are still queued fail with ErrThrottleClosed.
+func (throttle *GroupThrottle) Close() {
+	throttle.once.Do(func() { close(throttle.done) })
+	throttle.wait.Wait()
+}
+
+// next selects the next job that may be sent. If there is none, it returns the time until the
+// state changes, or 0 if only new events can change it.
+func (throttle *GroupThrottle) next(now time.Time) (*throttleJob, time.Duration) {
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+
+	// Release the coalesced writes that are due, in the order in which they became due.
+	var due []*throttleJob
+	for _, job := range throttle.deferred {
+		if !job.due.After(now) {
+			due = append(due, job)
+		}
+	}
+
+	sort.Sort(throttleJobsByDue(due))
+
+	for _, job := range due {
+		delete(throttle.deferred, job.event.Destination)
+		throttle.ready = append(throttle.ready, job)
+	}
+
+	var wait time.Duration
+
+	if len(throttle.ready) > 0 {
+		tokenWait, ok := throttle.bucket.take(now)
+		if ok {
+			job := throttle.ready[0]
+			throttle.ready = throttle.ready[1:]
+
+			if throttle.pending[job.event.Destination] == job {
+				delete(throttle.pending, job.event.Destination)
+				throttle.lastSent[job.event.Destination] = now
+			}
+
+			return job, 0
+		}
+
+		wait = tokenWait
+	}
+
+	for _, job := range throttle.deferred {
+		if until := job.due.Sub(now); wait == 0 || until < wait {
+			wait = until
+		}
+	}
+
+	return nil, wait
+}
+
+// serve sends the queued events until the throttle is closed.
+func (throttle *GroupThrottle) serve() {
+	util.Log(throttle, "Started worker")
+	defer util.Log(throttle, "Worker exited")
+
+	defer throttle.wait.Done()
+	defer throttle.fail()
+
+	for {
+		select {
+		case <-throttle.done:
+			return
+		default:
+		}
+
+		job, wait := throttle.next(throttle.config.Clock.Now())
+		if job != nil {
+			err := throttle.client.Send(job.event)
+			if err != nil {
+				util.Log(throttle, "Failed to send event for %v: %v", job.event.Destination, err)
+			}
+
+			throttle.finish(job, err)
+			continue
+		}
+
+		var timer util.Timer
+		var timeout <-chan time.Time
+
+		if wait > 0 {
+			timer = throttle.config.Clock.NewTimer(wait)
+			timeout = timer.C()
+		}
+
+		select {
+		case <-throttle.done:
+		case <-throttle.wake:
+		case <-timeout:
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// fail rejects all queued events.
+func (throttle *GroupThrottle) fail() {
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+
+	throttle.closed = true
+
+	for _, job := range throttle.ready {
+		throttle.finish(job, ErrThrottleClosed)
+	}
+
+	for _, job := range throttle.deferred {
+		throttle.finish(job, ErrThrottleClosed)
+	}
+
+	throttle.ready = nil
+	throttle.deferred = nil
+	throttle.pending = nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+func newTestThrottle(config ThrottleConfig) (*GroupThrottle, *blockingConn, *util.FakeClock) {
+	conn := newBlockingConn()
+	close(conn.release)
+
+	clock := util.NewFakeClock(time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC))
+	config.Clock = clock
+
+	return NewGroupThrottle(conn, config), conn, clock
+}
+
+func expectResult(t *testing.T, result <-chan error, expected error) {
+	select {
+	case err := <-result:
+		if err != expected {
+			t.Fatalf("Expected %v, got %v", expected, err)
+		}
+
+	case <-time.After(time.Second):
+		t.Fatal("No result")
+	}
+}
+
+func TestGroupThrottle(t *testing.T) {
+	addr := cemi.NewGroupAddr3(1, 2, 3)
+
+	t.Run("Coalesce", func(t *testing.T) {
+		config := DefaultThrottleConfig
+		config.MinInterval = time.Second
+		throttle, conn, clock := newTestThrottle(config)
+		defer throttle.Close()
+
+		write := func(value byte) <-chan error {
+			return throttle.SendAsync(GroupEvent{
+				Command:     GroupWrite,
+				Destination: addr,
+				Data:        []byte{value},
+			})
+		}
+
+		expectResult(t, write(1), nil)
+		if event := conn.expectSent(t); event.Data[0] != 1 {
+			t.Fatalf("Expected 1, got %v", event.Data)
+		}
+
+		second := write(2)
+		third := write(3)
+		expectResult(t, second, ErrSuperseded)
+
+		clock.BlockUntil(1)
+		if len(conn.sent) > 0 {
+			t.Fatal("Expected the write to be held back")
+		}
+
+		clock.Advance(time.Second)
+
+		expectResult(t, third, nil)
+		if event := conn.expectSent(t); event.Data[0] != 3 {
+			t.Fatalf("Expected 3, got %v", event.Data)
+		}
+	})
+
+	t.Run("Reads", func(t *testing.T) {
+		throttle, conn, _ := newTestThrottle(DefaultThrottleConfig)
+		defer throttle.Close()
+
+		first := throttle.SendAsync(GroupEvent{Command: GroupRead, Destination: addr})
+		second := throttle.SendAsync(GroupEvent{Command: GroupRead, Destination: addr})
+
+		expectResult(t, first, nil)
+		expectResult(t, second, nil)
+
+		conn.expectSent(t)
+		conn.expectSent(t)
+	})
+
+	t.Run("Rate", func(t *testing.T) {
+		config := DefaultThrottleConfig
+		config.MinInterval = -1
+		config.Rate = 10
+		config.Burst = 2
+		throttle, conn, clock := newTestThrottle(config)
+		defer throttle.Close()
+
+		var results []<-chan error
+		for i := 0; i < 4; i++ {
+			results = append(results, throttle.SendAsync(GroupEvent{
+				Command:     GroupWrite,
+				Destination: addr,
+				Data:        []byte{byte(i)},
+			}))
+		}
+
+		expectResult(t, results[0], nil)
+		expectResult(t, results[1], nil)
+
+		clock.BlockUntil(1)
+		if len(conn.sent) != 2 {
+			t.Fatalf("Expected 2 events to be sent, got %v", len(conn.sent))
+		}
+
+		for _, result := range results[2:] {
+			clock.BlockUntil(1)
+			clock.Advance(100 * time.Millisecond)
+			expectResult(t, result, nil)
+		}
+
+		for i := 0; i < 4; i++ {
+			if event := conn.expectSent(t); event.Data[0] != byte(i) {
+				t.Fatalf("Expected %v, got %v", i, event.Data)
+			}
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		config := DefaultThrottleConfig
+		config.MinInterval = time.Minute
+		throttle, conn, clock := newTestThrottle(config)
+
+		event := GroupEvent{Command: GroupWrite, Destination: addr, Data: []byte{1}}
+
+		expectResult(t, throttle.SendAsync(event), nil)
+		conn.expectSent(t)
+
+		held := throttle.SendAsync(event)
+		clock.BlockUntil(1)
+
+		throttle.Close()
+
+		expectResult(t, held, ErrThrottleClosed)
+		expectResult(t, throttle.SendAsync(event), ErrThrottleClosed)
+	})
+}
@@ -17,20 +17,39 @@ import (
 // TunnelConfig allows you to configure the tunnel client's behavior.
 type TunnelConfig struct {
 	// ResendInterval is the interval with which requests will be resend if no response is received.
+	// In adaptive mode, it is only used until the first round-trip time has been measured.
 	ResendInterval time.Duration
 
+	// AdaptiveResend enables adaptive retransmission timing. The resend interval is then derived
+	// from the round-trip times of requests that have been answered, similar to TCP's
+	// retransmission timeout. It is doubled whenever a request has to be resent.
+	AdaptiveResend bool
+
+	// MinResendInterval is the lower bound for the resend interval in adaptive mode.
+	MinResendInterval time.Duration
+
+	// MaxResendInterval is the upper bound for the resend interval in adaptive mode.
+	MaxResendInterval time.Duration
+
 	// HeartbeatInterval specifies the time interval which triggers a heartbeat check.
 	HeartbeatInterval time.Duration
 
 	// ResponseTimeout specifies how long to wait for a response.
 	ResponseTimeout time.Duration
+
+	// Clock is used for all timeouts and intervals. Tests may use a util.FakeClock in order to
+	// control the passage of time.
+	Clock util.Clock
 }
 
 // DefaultTunnelConfig is a good default configuration for a Tunnel client.
 var DefaultTunnelConfig = TunnelConfig{
 	ResendInterval:    500 * time.Millisecond,
+	MinResendInterval: 100 * time.Millisecond,
+	MaxResendInterval: 5 * time.Second,
 	HeartbeatInterval: 10 * time.Second,
 	ResponseTimeout:   10 * time.Second,
+	Clock:             util.RealClock,
 }
 
 // checkTunnelConfig makes sure that the configuration is actually usable.
@@ -39,6 +58,18 @@ func checkTunnelConfig(config TunnelConfig) TunnelConfig {
 		config.ResendInterval = DefaultTunnelConfig.ResendInterval
 	}
 
+	if config.MinResendInterval <= 0 {
+		config.MinResendInterval = DefaultTunnelConfig.MinResendInterval
+	}
+
+	if config.MaxResendInterval < config.MinResendInterval {
+		config.MaxResendInterval = DefaultTunnelConfig.MaxResendInterval
+
+		if config.MaxResendInterval < config.MinResendInterval {
+			config.MaxResendInterval = config.MinResendInterval
+		}
+	}
+
 	if config.HeartbeatInterval <= 0 {
 		config.HeartbeatInterval = DefaultTunnelConfig.HeartbeatInterval
 	}
@@ -47,6 +78,10 @@ func checkTunnelConfig(config TunnelConfig) TunnelConfig {
 		config.ResponseTimeout = DefaultTunnelConfig.ResponseTimeout
 	}
 
+	if config.Clock == nil {
+		config.Clock = DefaultTunnelConfig.Clock
+	}
+
 	return config
 }
 
@@ -59,9 +94,11 @@ type Tunnel struct {
 	// Communication methods
 	sock   knxnet.Socket
 	config TunnelConfig
+	rto    rtoEstimator
 
 	// Connection information
 	layer   knxnet.TunnelLayer
+	infoMu  sync.Mutex
 	channel uint8
 	control knxnet.HostInfo
 
@@ -81,43 +118,57 @@ type Tunnel struct {
 
 // requestConn repeatedly sends a connection request through the socket until the configured
 // reponse timeout is reached or a response is received. A response that renders the gateway as busy
-// will not stop requestConn.
+// will not stop requestConn. But if the gateway is still busy when the timeout is reached, its
+// status is returned.
 func (conn *Tunnel) requestConn() (err error) {
-	conn.control = knxnet.HostInfo{Protocol: knxnet.UDP4}
+	control := knxnet.HostInfo{Protocol: knxnet.UDP4}
 
 	req := &knxnet.ConnReq{
 		Layer:   conn.layer,
-		Control: conn.control,
-		Tunnel:  conn.control,
+		Control: control,
+		Tunnel:  control,
 	}
 
 	// Send the initial request.
+	sent := conn.config.Clock.Now()
+	resent := false
+
 	err = conn.sock.Send(req)
 	if err != nil {
 		return
 	}
 
 	// Create a resend timer.
-	ticker := time.NewTicker(conn.config.ResendInterval)
-	defer ticker.Stop()
+	resend := conn.config.Clock.NewTimer(conn.resendInterval())
+	defer resend.Stop()
 
 	// Setup timeout.
-	timeout := time.After(conn.config.ResponseTimeout)
+	timeout := conn.config.Clock.NewTimer(conn.config.ResponseTimeout)
+	defer timeout.Stop()
+
+	var busy error
 
 	// Cycle until a request gets a response.
 	for {
 		select {
 		// Timeout reached.
-		case <-timeout:
+		case <-timeout.C():
+			if busy != nil {
+				return busy
+			}
+
 			return errResponseTimeout
 
 		// Resend timer triggered.
-		case <-ticker.C:
+		case <-resend.C():
 			err = conn.sock.Send(req)
 			if err != nil {
 				return
 			}
 
+			resent = true
+			resend.Reset(conn.backoffResend())
+
 		// A message has been received or the channel has been closed.
 		case msg, open := <-conn.sock.Inbound():
 			if !open {
@@ -126,10 +177,17 @@ func (conn *Tunnel) requestConn() (err error) {
 
 			// We're only interested in connection responses.
 			if res, ok := msg.(*knxnet.ConnRes); ok {
+				if !resent {
+					conn.measureRTT(sent)
+				}
+
 				switch res.Status {
 				// Conection has been established.
 				case knxnet.NoError:
+					conn.infoMu.Lock()
 					conn.channel = res.Channel
+					conn.control = control
+					conn.infoMu.Unlock()
 
 					conn.seqMu.Lock()
 					conn.seqNumber = 0
@@ -139,6 +197,7 @@ func (conn *Tunnel) requestConn() (err error) {
 
 				// The gateway is busy, but we don't stop yet.
 				case knxnet.ErrNoMoreConnections, knxnet.ErrNoMoreUniqueConnections:
+					busy = res.Status
 					continue
 
 				// Connection request has been denied.
@@ -155,51 +214,104 @@ func (conn *Tunnel) requestConn() (err error) {
 func (conn *Tunnel) requestConnState(
 	heartbeat <-chan knxnet.ErrCode,
 ) (knxnet.ErrCode, error) {
-	req := &knxnet.ConnStateReq{Channel: conn.channel, Status: 0, Control: conn.control}
+	channel, control := conn.connInfo()
+	req := &knxnet.ConnStateReq{Channel: channel, Status: 0, Control: control}
 
 	// Send first connection state request
+	sent := conn.config.Clock.Now()
+	resent := false
+
 	err := conn.sock.Send(req)
 	if err != nil {
 		return knxnet.ErrConnectionID, err
 	}
 
 	// Start the resend timer.
-	ticker := time.NewTicker(conn.config.ResendInterval)
-	defer ticker.Stop()
+	resend := conn.config.Clock.NewTimer(conn.resendInterval())
+	defer resend.Stop()
 
 	// Setup timeout timer.
-	timeout := time.After(conn.config.ResponseTimeout)
+	timeout := conn.config.Clock.NewTimer(conn.config.ResponseTimeout)
+	defer timeout.Stop()
 
 	for {
 		select {
 		// Reached timeout
-		case <-timeout:
+		case <-timeout.C():
 			return knxnet.ErrConnectionID, errResponseTimeout
 
 		// Resend timer fired.
-		case <-ticker.C:
+		case <-resend.C():
 			err := conn.sock.Send(req)
 			if err != nil {
 				return knxnet.ErrConnectionID, err
 			}
 
+			resent = true
+			resend.Reset(conn.backoffResend())
+
 		// Received a connection state response.
 		case res, open := <-heartbeat:
 			if !open {
 				return knxnet.ErrConnectionID, errors.New("Connection server has terminated")
 			}
 
+			if !resent {
+				conn.measureRTT(sent)
+			}
+
 			return res, nil
 		}
 	}
 }
 
+// resendInterval determines how long to wait for a response before a request is resent.
+func (conn *Tunnel) resendInterval() time.Duration {
+	if !conn.config.AdaptiveResend {
+		return conn.config.ResendInterval
+	}
+
+	return conn.rto.timeout(conn.config.ResendInterval)
+}
+
+// backoffResend is called when a request has been resent. It returns the interval after which the
+// request shall be resent again.
+func (conn *Tunnel) backoffResend() time.Duration {
+	if conn.config.AdaptiveResend {
+		conn.rto.backoff(conn.config.ResendInterval, conn.config.MaxResendInterval)
+	}
+
+	return conn.resendInterval()
+}
+
+// measureRTT feeds the round-trip time of a request that has been sent at the given time into the
+// adaptive resend timing.
+func (conn *Tunnel) measureRTT(sent time.Time) {
+	if conn.config.AdaptiveResend {
+		conn.rto.sample(
+			conn.config.Clock.Now().Sub(sent),
+			conn.config.MinResendInterval,
+			conn.config.MaxResendInterval,
+		)
+	}
+}
+
+// connInfo returns the communication channel and the control endpoint of the current connection.
+func (conn *Tunnel) connInfo() (uint8, knxnet.HostInfo) {
+	conn.infoMu.Lock()
+	defer conn.infoMu.Unlock()
+
+	return conn.channel, conn.control
+}
+
 // requestDisc sends a disconnect request to the gateway.
 func (conn *Tunnel) requestDisc() error {
+	channel, control := conn.connInfo()
+
 	return conn.sock.Send(&knxnet.DiscReq{
-		Channel: conn.channel,
+		Channel: channel,
 		Status:  0,
-		Control: conn.control,
+		Control: control,
 	})
 }
 
@@ -209,38 +321,47 @@ func (conn *Tunnel) requestTunnel(data cemi.Message) error {
 	conn.seqMu.Lock()
 	defer conn.seqMu.Unlock()
 
+	channel, _ := conn.connInfo()
+
 	req := &knxnet.TunnelReq{
-		Channel:   conn.channel,
+		Channel:   channel,
 		SeqNumber: conn.seqNumber,
 		Payload:   data,
 	}
 
 	// Send initial request.
+	sent := conn.config.Clock.Now()
+	resent := false
+
 	err := conn.sock.Send(req)
 	if err != nil {
 		return err
 	}
 
 	// Start the resend timer.
-	ticker := time.NewTicker(conn.config.ResendInterval)
-	defer ticker.Stop()
+	resend := conn.config.Clock.NewTimer(conn.resendInterval())
+	defer resend.Stop()
 
 	// Setup timeout.
-	timeout := time.After(conn.config.ResponseTimeout)
+	timeout := conn.config.Clock.NewTimer(conn.config.ResponseTimeout)
+	defer timeout.Stop()
 
 	for {
 		select {
 		// Timeout reached.
-		case <-timeout:
+		case <-timeout.C():
 			return errResponseTimeout
 
 		// Resend timer fired.
-		case <-ticker.C:
+		case <-resend.C():
 			err := conn.sock.Send(req)
 			if err != nil {
 				return err
 			}
 
+			resent = true
+			resend.Reset(conn.backoffResend())
+
 		// Received a tunnel response.
 		case res, open := <-conn.ack:
 			if !open {
@@ -255,6 +376,10 @@ func (conn *Tunnel) requestTunnel(data cemi.Message) error {
 			// Gateway has received the request, therefore we can increase on our side.
 			conn.seqNumber++
 
+			if !resent {
+				conn.measureRTT(sent)
+			}
+
 			// Check if the response confirms the tunnel request.
 			if res.Status == 0 {
 				return nil
@@ -370,9 +495,12 @@ func (conn *Tunnel) handleTunnelRes(res *knxnet.TunnelRes) error {
 		// writing to a closed channel here, and be done with it.
 		defer func() { recover() }()
 
+		timeout := conn.config.Clock.NewTimer(conn.config.ResendInterval)
+		defer timeout.Stop()
+
 		select {
 		case <-conn.done:
-		case <-time.After(conn.config.ResendInterval):
+		case <-timeout.C():
 		case conn.ack <- res:
 		}
 	}()
@@ -397,9 +525,12 @@ func (conn *Tunnel) handleConnStateRes(
 		// when writing to a closed channel here, and be done with it.
 		defer func() { recover() }()
 
+		timeout := conn.config.Clock.NewTimer(conn.config.ResendInterval)
+		defer timeout.Stop()
+
 		select {
 		case <-conn.done:
-		case <-time.After(conn.config.ResendInterval):
+		case <-timeout.C():
 		case heartbeat <- res.Status:
 		}
 	}()
@@ -422,7 +553,7 @@ func (conn *Tunnel) process() error {
 
 	var seqNumber uint8
 
-	heartbeatInterval := time.NewTicker(conn.config.HeartbeatInterval)
+	heartbeatInterval := conn.config.Clock.NewTicker(conn.config.HeartbeatInterval)
 	defer heartbeatInterval.Stop()
 
 	for {
@@ -436,7 +567,7 @@ func (conn *Tunnel) process() error {
 			return errHeartbeatFailed
 
 		// Heartbeat check is due.
-		case <-heartbeatInterval.C:
+		case <-heartbeatInterval.C():
 			go conn.performHeartbeat(heartbeat, timeout)
 
 		// A message has been received or the channel is closed.
@@ -532,6 +663,17 @@ func NewTunnel(gatewayAddr string, layer knxnet.TunnelLayer, config TunnelConfig
 		return nil, err
 	}
 
+	return NewTunnelWithSocket(sock, layer, config)
+}
+
+// NewTunnelWithSocket establishes a connection to a gateway that is reachable through the given
+// socket. The Tunnel takes ownership of the socket; it is closed when the Tunnel is closed or when
+// the connection cannot be established.
+func NewTunnelWithSocket(
+	sock knxnet.Socket,
+	layer knxnet.TunnelLayer,
+	config TunnelConfig,
+) (*Tunnel, error) {
 	// Initialize the Client structure.
 	client := &Tunnel{
 		sock:    sock,
@@ -543,7 +685,7 @@ func NewTunnel(gatewayAddr string, layer knxnet.TunnelLayer, config TunnelConfig
 	}
 
 	// Connect to the gateway.
-	err = client.requestConn()
+	err := client.requestConn()
 	if err != nil {
 		sock.Close()
 		return nil, err
@@ -597,6 +739,19 @@ func NewGroupTunnel(gatewayAddr string, config TunnelConfig) (gt GroupTunnel, er
 	return
 }
 
+// NewGroupTunnelWithSocket creates a new Tunnel for group communication that communicates through
+// the given socket.
+func NewGroupTunnelWithSocket(sock knxnet.Socket, config TunnelConfig) (gt GroupTunnel, err error) {
+	gt.Tunnel, err = NewTunnelWithSocket(sock, knxnet.TunnelLayerData, config)
+
+	if err == nil {
+		gt.inbound = make(chan GroupEvent)
+		go serveGroupInbound(gt.Tunnel.Inbound(), gt.inbound)
+	}
+
+	return
+}
+
 // Send a group communication.
 func (gt *GroupTunnel) Send(event GroupEvent) error {
 	return gt.Tunnel.Send(&cemi.LDataReq{LData: buildGroupOutbound(event)})
@@ -5,9 +5,11 @@ package knx
 
 import (
 	"testing"
+	"time"
 
 	"github.com/vapourismo/knx-go/knx/cemi"
 	"github.com/vapourismo/knx-go/knx/knxnet"
+	"github.com/vapourismo/knx-go/knx/util"
 )
 
 func makeTunnelConn(
@@ -246,6 +248,42 @@ func TestTunnelConn_requestConn(t *testing.T) {
 		})
 	})
 
+	// Resends and the timeout are driven by a fake clock.
+	t.Run("FakeClock", func(t *testing.T) {
+		client, gateway := newDummySockets()
+		defer client.Close()
+		defer gateway.Close()
+
+		clock := util.NewFakeClock(time.Unix(0, 0))
+
+		config := DefaultTunnelConfig
+		config.Clock = clock
+
+		conn := Tunnel{
+			sock:   client,
+			config: config,
+		}
+
+		result := make(chan error)
+		go func() { result <- conn.requestConn() }()
+
+		<-gateway.Inbound()
+
+		// Wait for the resend ticker and the timeout timer.
+		clock.BlockUntil(2)
+		clock.Advance(config.ResendInterval)
+
+		if _, ok := (<-gateway.Inbound()).(*knxnet.ConnReq); !ok {
+			t.Error("Expected a repeated connection request")
+		}
+
+		clock.Advance(config.ResponseTimeout)
+
+		if err := <-result; err != errResponseTimeout {
+			t.Fatalf("Expected error %v, got %v", errResponseTimeout, err)
+		}
+	})
+
 	// The gateway doesn't supported the requested connection type.
 	t.Run("Unsupported", func(t *testing.T) {
 		client, gateway := newDummySockets()
@@ -756,6 +794,63 @@ func TestTunnelConn_requestTunnel(t *testing.T) {
 			}
 		})
 	})
+
+	t.Run("Adaptive", func(t *testing.T) {
+		client, gateway := newDummySockets()
+		defer client.Close()
+		defer gateway.Close()
+
+		clock := util.NewFakeClock(time.Unix(0, 0))
+
+		config := DefaultTunnelConfig
+		config.Clock = clock
+		config.AdaptiveResend = true
+		config.MinResendInterval = 10 * time.Millisecond
+
+		conn := makeTunnelConn(client, config, 1)
+
+		result := make(chan error)
+		send := func() {
+			go func() { result <- conn.requestTunnel(&cemi.UnsupportedMessage{}) }()
+			<-gateway.Inbound()
+
+			// Wait for the resend and timeout timers.
+			clock.BlockUntil(2)
+		}
+
+		// The first request is acknowledged after 20ms.
+		send()
+		clock.Advance(20 * time.Millisecond)
+		conn.ack <- &knxnet.TunnelRes{Channel: 1, SeqNumber: 0}
+
+		if err := <-result; err != nil {
+			t.Fatal(err)
+		}
+
+		// The resend interval is the smoothed RTT plus four times its variation.
+		if interval := conn.resendInterval(); interval != 60*time.Millisecond {
+			t.Fatalf("Unexpected resend interval %v", interval)
+		}
+
+		send()
+		clock.Advance(60 * time.Millisecond)
+
+		if _, ok := (<-gateway.Inbound()).(*knxnet.TunnelReq); !ok {
+			t.Fatal("Expected a repeated tunnel request")
+		}
+
+		clock.BlockUntil(2)
+		conn.ack <- &knxnet.TunnelRes{Channel: 1, SeqNumber: 1}
+
+		if err := <-result; err != nil {
+			t.Fatal(err)
+		}
+
+		// The resent request must not be measured. Instead the interval has been doubled.
+		if interval := conn.resendInterval(); interval != 120*time.Millisecond {
+			t.Fatalf("Unexpected resend interval %v", interval)
+		}
+	})
 }
 
 func TestTunnelConn_handleTunnelReq(t *testing.T) {
@@ -0,0 +1,63 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package util
+
+import "errors"
+
+// ErrNilPackable is returned when a nil value shall be packed.
+var ErrNilPackable = errors.New("Cannot pack a nil value")
+
+// Appendable is implemented by types that can append their packed representation to a byte slice.
+// Unlike Pack, AppendPack reports problems through an error instead of panicking.
+type Appendable interface {
+	AppendPack(dst []byte) ([]byte, error)
+}
+
+// AppendPack appends the packed representation of the value to dst and returns the extended slice.
+// Values that do not implement Appendable are packed using their Pack method.
+func AppendPack(dst []byte, input Packable) ([]byte, error) {
+	if input == nil {
+		return dst, ErrNilPackable
+	}
+
+	if app, ok := input.(Appendable); ok {
+		return app.AppendPack(dst)
+	}
+
+	dst, window := Grow(dst, input.Size())
+	input.Pack(window)
+
+	return dst, nil
+}
+
+// Grow extends dst by n zeroed bytes. It returns the extended slice and the window that contains
+// the new bytes.
+func Grow(dst []byte, n uint) ([]byte, []byte) {
+	offset := len(dst)
+
+	if uint(cap(dst)-offset) < n {
+		extended := make([]byte, offset, 2*cap(dst)+int(n))
+		copy(extended, dst)
+		dst = extended
+	}
+
+	dst = dst[:offset+int(n)]
+	window := dst[offset:]
+
+	for i := range window {
+		window[i] = 0
+	}
+
+	return dst, window
+}
+
+// AppendUint16 appends the big-endian representation of the value.
+func AppendUint16(dst []byte, value uint16) []byte {
+	return append(dst, byte(value>>8), byte(value))
+}
+
+// AppendUint32 appends the big-endian representation of the value.
+func AppendUint32(dst []byte, value uint32) []byte {
+	return append(dst, byte(value>>24), byte(value>>16), byte(value>>8), byte(value))
+}
@@ -0,0 +1,265 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// A Timer fires once after a given duration, unless it is stopped.
+type Timer interface {
+	// C returns the channel on which the time is delivered when the timer fires. Timers created
+	// by AfterFunc do not deliver to this channel.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing. It returns false if the timer has already fired or
+	// has been stopped before.
+	Stop() bool
+
+	// Reset changes the timer to fire after the given duration. It returns true if the timer had
+	// been active.
+	Reset(d time.Duration) bool
+}
+
+// A Ticker delivers the time periodically.
+type Ticker interface {
+	// C returns the channel on which the ticks are delivered.
+	C() <-chan time.Time
+
+	// Stop turns off the ticker.
+	Stop()
+}
+
+// A Clock provides the current time and the means to wait for time to pass.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// RealClock is the Clock backed by the time package.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (timer realTimer) C() <-chan time.Time {
+	return timer.Timer.C
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (ticker realTicker) C() <-chan time.Time {
+	return ticker.Ticker.C
+}
+
+// A FakeClock is a Clock whose time only passes when Advance is called. It allows timeouts and
+// periodic tasks to be tested deterministically.
+type FakeClock struct {
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// NewFakeClock creates a FakeClock which starts at the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{cond: sync.NewCond(&sync.Mutex{}), now: now}
+}
+
+// fakeWaiter is a pending timer or ticker.
+type fakeWaiter struct {
+	clock  *FakeClock
+	when   time.Time
+	period time.Duration
+	ch     chan time.Time
+	fn     func()
+}
+
+// Now returns the current fake time.
+func (clock *FakeClock) Now() time.Time {
+	clock.cond.L.Lock()
+	defer clock.cond.L.Unlock()
+
+	return clock.now
+}
+
+// add registers a waiter. The caller must hold the lock.
+func (clock *FakeClock) add(waiter *fakeWaiter) {
+	clock.waiters = append(clock.waiters, waiter)
+	clock.cond.Broadcast()
+}
+
+// remove unregisters a waiter. The caller must hold the lock.
+func (clock *FakeClock) remove(waiter *fakeWaiter) bool {
+	for i, other := range clock.waiters {
+		if other == waiter {
+			clock.waiters = append(clock.waiters[:i], clock.waiters[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// newWaiter creates and registers a waiter.
+func (clock *FakeClock) newWaiter(d, period time.Duration, fn func()) *fakeWaiter {
+	clock.cond.L.Lock()
+	defer clock.cond.L.Unlock()
+
+	waiter := &fakeWaiter{
+		clock:  clock,
+		when:   clock.now.Add(d),
+		period: period,
+		ch:     make(chan time.Time, 1),
+		fn:     fn,
+	}
+
+	clock.add(waiter)
+
+	return waiter
+}
+
+// After waits for the duration to elapse and then sends the current time on the returned channel.
+func (clock *FakeClock) After(d time.Duration) <-chan time.Time {
+	return clock.NewTimer(d).C()
+}
+
+// NewTimer creates a Timer which fires once the clock has been advanced by the given duration.
+func (clock *FakeClock) NewTimer(d time.Duration) Timer {
+	return clock.newWaiter(d, 0, nil)
+}
+
+// NewTicker creates a Ticker which ticks every time the clock has been advanced by the given
+// duration.
+func (clock *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("Non-positive interval for NewTicker")
+	}
+
+	return fakeTicker{clock.newWaiter(d, d, nil)}
+}
+
+// AfterFunc calls f in its own goroutine once the clock has been advanced by the given duration.
+func (clock *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	return clock.newWaiter(d, 0, f)
+}
+
+// Pending returns the number of active timers and tickers.
+func (clock *FakeClock) Pending() int {
+	clock.cond.L.Lock()
+	defer clock.cond.L.Unlock()
+
+	return len(clock.waiters)
+}
+
+// BlockUntil waits until at least n timers and tickers are active. Use it to make sure that the
+// code under test has started waiting before advancing the clock.
+func (clock *FakeClock) BlockUntil(n int) {
+	clock.cond.L.Lock()
+	defer clock.cond.L.Unlock()
+
+	for len(clock.waiters) < n {
+		clock.cond.Wait()
+	}
+}
+
+// Advance moves the clock forward. Timers and tickers that become due fire in chronological order.
+func (clock *FakeClock) Advance(d time.Duration) {
+	clock.cond.L.Lock()
+	defer clock.cond.L.Unlock()
+
+	target := clock.now.Add(d)
+
+	for {
+		var waiter *fakeWaiter
+
+		// Find the waiter that is due first. Ties are resolved in order of registration.
+		for _, other := range clock.waiters {
+			if waiter == nil || other.when.Before(waiter.when) {
+				waiter = other
+			}
+		}
+
+		if waiter == nil || waiter.when.After(target) {
+			break
+		}
+
+		clock.now = waiter.when
+
+		if waiter.period > 0 {
+			waiter.when = waiter.when.Add(waiter.period)
+		} else {
+			clock.remove(waiter)
+		}
+
+		if waiter.fn != nil {
+			go waiter.fn()
+		} else {
+			select {
+			case waiter.ch <- clock.now:
+			default:
+			}
+		}
+	}
+
+	clock.now = target
+}
+
+func (waiter *fakeWaiter) C() <-chan time.Time {
+	return waiter.ch
+}
+
+func (waiter *fakeWaiter) Stop() bool {
+	waiter.clock.cond.L.Lock()
+	defer waiter.clock.cond.L.Unlock()
+
+	return waiter.clock.remove(waiter)
+}
+
+func (waiter *fakeWaiter) Reset(d time.Duration) bool {
+	waiter.clock.cond.L.Lock()
+	defer waiter.clock.cond.L.Unlock()
+
+	active := waiter.clock.remove(waiter)
+	waiter.when = waiter.clock.now.Add(d)
+	waiter.clock.add(waiter)
+
+	return active
+}
+
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (ticker fakeTicker) Stop() {
+	ticker.fakeWaiter.Stop()
+}
@@ -0,0 +1,98 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+
+	t.Run("Timer", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		timer := clock.NewTimer(time.Second)
+
+		clock.Advance(999 * time.Millisecond)
+
+		select {
+		case <-timer.C():
+			t.Fatal("Timer fired too early")
+		default:
+		}
+
+		clock.Advance(time.Millisecond)
+
+		select {
+		case now := <-timer.C():
+			if !now.Equal(start.Add(time.Second)) {
+				t.Errorf("Unexpected time %v", now)
+			}
+
+		default:
+			t.Fatal("Timer did not fire")
+		}
+
+		if clock.Pending() != 0 {
+			t.Error("Timer is still pending")
+		}
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		timer := clock.NewTimer(time.Second)
+
+		if !timer.Stop() {
+			t.Error("Timer should have been active")
+		}
+
+		clock.Advance(time.Second)
+
+		select {
+		case <-timer.C():
+			t.Fatal("Stopped timer fired")
+		default:
+		}
+	})
+
+	t.Run("Ticker", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		ticker := clock.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for i := 1; i <= 3; i++ {
+			clock.Advance(time.Second)
+
+			if now := <-ticker.C(); !now.Equal(start.Add(time.Duration(i) * time.Second)) {
+				t.Errorf("Unexpected tick %v", now)
+			}
+		}
+	})
+
+	t.Run("AfterFunc", func(t *testing.T) {
+		clock := NewFakeClock(start)
+		called := make(chan struct{})
+
+		clock.AfterFunc(time.Minute, func() { close(called) })
+		clock.Advance(time.Hour)
+
+		select {
+		case <-called:
+		case <-time.After(time.Second):
+			t.Fatal("Function has not been called")
+		}
+
+		if !clock.Now().Equal(start.Add(time.Hour)) {
+			t.Errorf("Unexpected time %v", clock.Now())
+		}
+	})
+
+	t.Run("BlockUntil", func(t *testing.T) {
+		clock := NewFakeClock(start)
+
+		go clock.After(time.Second)
+		clock.BlockUntil(1)
+	})
+}
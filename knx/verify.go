@@ -0,0 +1,244 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// VerifyConfig allows you to configure how writes are verified.
+type VerifyConfig struct {
+	// ReadDelay is the time to wait for the status to be reported before it is read actively. A
+	// negative delay disables reading, so that the status is only awaited passively. Reading only
+	// happens if the delay is shorter than the timeout.
+	ReadDelay time.Duration
+
+	// Timeout is the time to wait for a matching status after each write.
+	Timeout time.Duration
+
+	// Retries is the number of times the write is repeated if no matching status arrives in time.
+	Retries int
+
+	// Match compares the status with the written value. If nil, both must be equal byte by byte.
+	// Provide it if the status object uses a different datapoint type or may deviate slightly from
+	// the written value, e.g. the position of a shutter.
+	Match func(written, status []byte) bool
+
+	// Clock is used for all delays and timeouts. Tests may use a util.FakeClock in order to control
+	// the passage of time.
+	Clock util.Clock
+}
+
+// DefaultVerifyConfig is a good default configuration for a Verifier.
+var DefaultVerifyConfig = VerifyConfig{
+	ReadDelay: 500 * time.Millisecond,
+	Timeout:   3 * time.Second,
+	Retries:   2,
+	Clock:     util.RealClock,
+}
+
+// checkVerifyConfig makes sure that the configuration is actually usable.
+func checkVerifyConfig(config VerifyConfig) VerifyConfig {
+	if config.ReadDelay == 0 {
+		config.ReadDelay = DefaultVerifyConfig.ReadDelay
+	}
+
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultVerifyConfig.Timeout
+	}
+
+	if config.Retries < 0 {
+		config.Retries = 0
+	}
+
+	if config.Match == nil {
+		config.Match = bytes.Equal
+	}
+
+	if config.Clock == nil {
+		config.Clock = DefaultVerifyConfig.Clock
+	}
+
+	return config
+}
+
+// ErrMuxClosed is returned when the inbound channel of the group mux has been closed while waiting
+// for the status.
+var ErrMuxClosed = errors.New("Inbound channel of the group mux has been closed")
+
+// VerifyTimeoutError is returned when no status has been received for a write.
+type VerifyTimeoutError struct {
+	Target   cemi.GroupAddr
+	Status   cemi.GroupAddr
+	Attempts int
+}
+
+// Error implements the error interface.
+func (err *VerifyTimeoutError) Error() string {
+	return fmt.Sprintf("No status for write to %v has been received on %v after %d attempts",
+		err.Target, err.Status, err.Attempts)
+}
+
+// VerifyMismatchError is returned when the status that has been received last does not match the
+// written value.
+type VerifyMismatchError struct {
+	Target   cemi.GroupAddr
+	Status   cemi.GroupAddr
+	Source   cemi.IndividualAddr
+	Expected []byte
+	Received []byte
+	Attempts int
+}
+
+// Error implements the error interface.
+func (err *VerifyMismatchError) Error() string {
+	return fmt.Sprintf("Status %x from %v on %v does not match %x written to %v after %d attempts",
+		err.Received, err.Source, err.Status, err.Expected, err.Target, err.Attempts)
+}
+
+// A Verifier writes to group addresses and checks that the status feedback of the actuator
+// confirms the new value. Actuators usually report their status on a separate group address, e.g.
+// "shutter closed", and often do so only after a while or not at all unless being asked.
+type Verifier struct {
+	mux    *GroupMux
+	config VerifyConfig
+}
+
+// NewVerifier creates a verifier which sends through the mux and receives the status from it.
+func NewVerifier(mux *GroupMux, config VerifyConfig) *Verifier {
+	return &Verifier{
+		mux:    mux,
+		config: checkVerifyConfig(config),
+	}
+}
+
+// verification is the state of a single WriteVerified call.
+type verification struct {
+	written []byte
+	status  cemi.GroupAddr
+	sub     *Subscription
+	last    *GroupEvent
+}
+
+// WriteVerified writes the value to the target group address and waits for a matching value on
+// the status group address. Unless the status arrives by itself, it is read after the configured
+// delay. If no matching status arrives in time, the write is repeated.
+//
+// A status that does not match is not an error by itself, because actuators often report
+// intermediate states, e.g. while a shutter is moving. Only if the last attempt times out, the
+// status that has been received last is reported as *VerifyMismatchError. If no status has been
+// received at all, *VerifyTimeoutError is returned. Cancelling the context aborts waiting and
+// returns the context's error. Nothing is written once the context has been cancelled.
+func (verifier *Verifier) WriteVerified(
+	ctx context.Context,
+	target cemi.GroupAddr,
+	value dpt.DatapointValue,
+	status cemi.GroupAddr,
+) error {
+	ver := &verification{
+		written: value.Pack(),
+		status:  status,
+	}
+
+	// Subscribe before writing, so that a quick status report is not missed.
+	ver.sub = verifier.mux.Subscribe(func(event GroupEvent) bool {
+		return event.Destination == status &&
+			(event.Command == GroupWrite || event.Command == GroupResponse)
+	}, 16)
+	defer ver.sub.Unsubscribe()
+
+	attempts := verifier.config.Retries + 1
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		// A cancelled context must not cause another write.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := verifier.mux.Send(GroupEvent{
+			Command:     GroupWrite,
+			Destination: target,
+			Data:        ver.written,
+		})
+		if err != nil {
+			return err
+		}
+
+		matched, err := verifier.await(ctx, ver)
+		if err != nil || matched {
+			return err
+		}
+
+		util.Log(verifier, "No matching status for write to %v on %v (attempt %d of %d)",
+			target, status, attempt+1, attempts)
+	}
+
+	if ver.last != nil {
+		return &VerifyMismatchError{
+			Target:   target,
+			Status:   status,
+			Source:   ver.last.Source,
+			Expected: ver.written,
+			Received: ver.last.Data,
+			Attempts: attempts,
+		}
+	}
+
+	return &VerifyTimeoutError{Target: target, Status: status, Attempts: attempts}
+}
+
+// await waits for a matching status until the timeout of an attempt has elapsed.
+func (verifier *Verifier) await(ctx context.Context, ver *verification) (bool, error) {
+	timeout := verifier.config.Clock.NewTimer(verifier.config.Timeout)
+	defer timeout.Stop()
+
+	var read <-chan time.Time
+	if verifier.config.ReadDelay > 0 && verifier.config.ReadDelay < verifier.config.Timeout {
+		timer := verifier.config.Clock.NewTimer(verifier.config.ReadDelay)
+		defer timer.Stop()
+
+		read = timer.C()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+
+		case event, open := <-ver.sub.Events():
+			if !open {
+				return false, ErrMuxClosed
+			}
+
+			if verifier.config.Match(ver.written, event.Data) {
+				return true, nil
+			}
+
+			ver.last = &event
+
+		case <-read:
+			read = nil
+
+			if err := ctx.Err(); err != nil {
+				return false, err
+			}
+
+			err := verifier.mux.Send(GroupEvent{Command: GroupRead, Destination: ver.status})
+			if err != nil {
+				return false, err
+			}
+
+		case <-timeout.C():
+			return false, nil
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+func TestVerifier(t *testing.T) {
+	target := cemi.NewGroupAddr3(2, 1, 0)
+	status := cemi.NewGroupAddr3(2, 1, 1)
+	value := dpt.DPT_1009(true)
+
+	start := func(config VerifyConfig) (*blockingConn, *util.FakeClock, <-chan error) {
+		conn := newBlockingConn()
+		close(conn.release)
+
+		clock := util.NewFakeClock(time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC))
+		config.Clock = clock
+
+		verifier := NewVerifier(NewGroupMux(conn), config)
+
+		result := make(chan error, 1)
+		go func() {
+			result <- verifier.WriteVerified(context.Background(), target, &value, status)
+		}()
+
+		return conn, clock, result
+	}
+
+	expectWrite := func(t *testing.T, conn *blockingConn) {
+		event := conn.expectSent(t)
+		if event.Command != GroupWrite || event.Destination != target || event.Data[0] != 1 {
+			t.Fatalf("Expected write to %v, got %+v", target, event)
+		}
+	}
+
+	expectRead := func(t *testing.T, conn *blockingConn) {
+		event := conn.expectSent(t)
+		if event.Command != GroupRead || event.Destination != status {
+			t.Fatalf("Expected read of %v, got %+v", status, event)
+		}
+	}
+
+	report := func(conn *blockingConn, command GroupCommand, data byte) {
+		conn.inbound <- GroupEvent{
+			Command:     command,
+			Source:      cemi.NewIndividualAddr3(1, 1, 5),
+			Destination: status,
+			Data:        []byte{data},
+		}
+	}
+
+	t.Run("Passive", func(t *testing.T) {
+		conn, _, result := start(DefaultVerifyConfig)
+		defer conn.Close()
+
+		expectWrite(t, conn)
+
+		// Other group addresses and intermediate states are ignored.
+		conn.inbound <- GroupEvent{Command: GroupWrite, Destination: target, Data: []byte{0}}
+		report(conn, GroupWrite, 0)
+		report(conn, GroupWrite, 1)
+
+		expectResult(t, result, nil)
+	})
+
+	t.Run("Read", func(t *testing.T) {
+		conn, clock, result := start(DefaultVerifyConfig)
+		defer conn.Close()
+
+		expectWrite(t, conn)
+
+		clock.BlockUntil(2)
+		clock.Advance(DefaultVerifyConfig.ReadDelay)
+
+		expectRead(t, conn)
+		report(conn, GroupResponse, 1)
+
+		expectResult(t, result, nil)
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		config := DefaultVerifyConfig
+		config.Retries = 1
+		conn, clock, result := start(config)
+		defer conn.Close()
+
+		for i := 0; i < 2; i++ {
+			expectWrite(t, conn)
+
+			clock.BlockUntil(2)
+			clock.Advance(config.ReadDelay)
+
+			expectRead(t, conn)
+			report(conn, GroupResponse, 0)
+
+			clock.Advance(config.Timeout - config.ReadDelay)
+		}
+
+		var err error
+		select {
+		case err = <-result:
+		case <-time.After(time.Second):
+			t.Fatal("No result")
+		}
+
+		mismatch, ok := err.(*VerifyMismatchError)
+		if !ok {
+			t.Fatalf("Expected *VerifyMismatchError, got %v", err)
+		}
+
+		if mismatch.Attempts != 2 || mismatch.Received[0] != 0 || mismatch.Expected[0] != 1 {
+			t.Fatalf("Expected mismatch after 2 attempts, got %+v", mismatch)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		config := DefaultVerifyConfig
+		config.ReadDelay = -1
+		config.Retries = 0
+		conn, clock, result := start(config)
+		defer conn.Close()
+
+		expectWrite(t, conn)
+
+		clock.BlockUntil(1)
+		clock.Advance(config.Timeout)
+
+		var err error
+		select {
+		case err = <-result:
+		case <-time.After(time.Second):
+			t.Fatal("No result")
+		}
+
+		timeout, ok := err.(*VerifyTimeoutError)
+		if !ok || timeout.Attempts != 1 {
+			t.Fatalf("Expected *VerifyTimeoutError after 1 attempt, got %v", err)
+		}
+
+		if len(conn.sent) > 0 {
+			t.Fatalf("Expected the status not to be read, got %+v", <-conn.sent)
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		conn := newBlockingConn()
+		close(conn.release)
+		defer conn.Close()
+
+		verifier := NewVerifier(NewGroupMux(conn), DefaultVerifyConfig)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		result := make(chan error, 1)
+		go func() {
+			result <- verifier.WriteVerified(ctx, target, &value, status)
+		}()
+
+		expectWrite(t, conn)
+		cancel()
+
+		expectResult(t, result, context.Canceled)
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		conn := newBlockingConn()
+		close(conn.release)
+		defer conn.Close()
+
+		verifier := NewVerifier(NewGroupMux(conn), DefaultVerifyConfig)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if err := verifier.WriteVerified(ctx, target, &value, status); err != context.Canceled {
+			t.Fatalf("Expected %v, got %v", context.Canceled, err)
+		}
+
+		if len(conn.sent) > 0 {
+			t.Fatalf("Expected nothing to be written, got %+v", <-conn.sent)
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		conn, _, result := start(DefaultVerifyConfig)
+
+		expectWrite(t, conn)
+		conn.Close()
+
+		expectResult(t, result, ErrMuxClosed)
+	})
+}
@@ -0,0 +1,422 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Package watchdog detects sensors which have stopped sending their values cyclically.
+package watchdog
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// Key identifies a cyclic value by its group address and sender. A zero source matches any
+// sender.
+type Key struct {
+	Destination cemi.GroupAddr
+	Source      cemi.IndividualAddr
+}
+
+// keyLess orders keys by group address and source.
+func keyLess(a, b Key) bool {
+	if a.Destination != b.Destination {
+		return a.Destination < b.Destination
+	}
+
+	return a.Source < b.Source
+}
+
+// keySlice sorts keys with keyLess.
+type keySlice []Key
+
+func (keys keySlice) Len() int           { return len(keys) }
+func (keys keySlice) Less(i, j int) bool { return keyLess(keys[i], keys[j]) }
+func (keys keySlice) Swap(i, j int)      { keys[i], keys[j] = keys[j], keys[i] }
+
+// statusSlice sorts states by their key.
+type statusSlice []Status
+
+func (status statusSlice) Len() int           { return len(status) }
+func (status statusSlice) Less(i, j int) bool { return keyLess(status[i].Key, status[j].Key) }
+func (status statusSlice) Swap(i, j int)      { status[i], status[j] = status[j], status[i] }
+
+// Config allows you to configure the watchdog.
+type Config struct {
+	// Intervals contains the expected sending interval of the configured keys.
+	Intervals map[Key]time.Duration
+
+	// Learn enables learning the intervals of values which have not been configured.
+	Learn bool
+
+	// LearnSamples is the number of consecutive intervals that must be similar, before the longest
+	// of them is accepted as the sending interval. Values which are sent on change as well as
+	// cyclically therefore take longer to learn.
+	LearnSamples int
+
+	// Tolerance is the factor by which a value may be overdue before it is considered stale. It
+	// also limits how much the samples may differ while learning.
+	Tolerance float64
+
+	// CheckInterval specifies how often the values are checked.
+	CheckInterval time.Duration
+
+	// BufferSize is the size of the subscription buffer.
+	BufferSize int
+
+	// Clock is used for all timestamps and intervals. Tests may use a util.FakeClock in order to
+	// control the passage of time.
+	Clock util.Clock
+}
+
+// DefaultConfig is a good default configuration for a watchdog.
+var DefaultConfig = Config{
+	LearnSamples:  3,
+	Tolerance:     1.5,
+	CheckInterval: 10 * time.Second,
+	BufferSize:    64,
+	Clock:         util.RealClock,
+}
+
+// checkConfig makes sure that the configuration is actually usable.
+func checkConfig(config Config) Config {
+	if config.LearnSamples <= 0 {
+		config.LearnSamples = DefaultConfig.LearnSamples
+	}
+
+	if config.Tolerance < 1 {
+		config.Tolerance = DefaultConfig.Tolerance
+	}
+
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = DefaultConfig.CheckInterval
+	}
+
+	if config.BufferSize <= 0 {
+		config.BufferSize = DefaultConfig.BufferSize
+	}
+
+	if config.Clock == nil {
+		config.Clock = DefaultConfig.Clock
+	}
+
+	return config
+}
+
+// EventKind distinguishes watchdog events.
+type EventKind uint8
+
+// These are the kinds of watchdog events.
+const (
+	// Stale means that a value has not arrived in time.
+	Stale EventKind = iota
+
+	// Recovered means that a stale value has arrived again.
+	Recovered
+)
+
+// String generates a string representation.
+func (kind EventKind) String() string {
+	if kind == Recovered {
+		return "Recovered"
+	}
+
+	return "Stale"
+}
+
+// Event reports that a value has become stale or has recovered.
+type Event struct {
+	Key      Key
+	Kind     EventKind
+	Interval time.Duration
+	LastSeen time.Time
+	Time     time.Time
+}
+
+// Status describes the state of a watched value.
+type Status struct {
+	Key Key
+
+	// Interval is the expected sending interval. It is 0 while it is being learned.
+	Interval time.Duration
+	Learned  bool
+
+	// LastSeen is the time at which the value has been received last. It is zero if the value has
+	// not been received yet.
+	LastSeen   time.Time
+	Received   int
+	Stale      bool
+	StaleSince time.Time
+}
+
+// entry is the state of a watched value.
+type entry struct {
+	Status
+
+	// since is the time from which the arrival of the first value is expected.
+	since   time.Time
+	samples []time.Duration
+}
+
+// learn takes the interval since the previous value into account.
+func (ent *entry) learn(interval time.Duration, config Config) {
+	ent.samples = append(ent.samples, interval)
+	if len(ent.samples) > config.LearnSamples {
+		ent.samples = ent.samples[1:]
+	}
+
+	if len(ent.samples) < config.LearnSamples {
+		return
+	}
+
+	shortest, longest := ent.samples[0], ent.samples[0]
+	for _, sample := range ent.samples[1:] {
+		if sample < shortest {
+			shortest = sample
+		}
+
+		if sample > longest {
+			longest = sample
+		}
+	}
+
+	if shortest > 0 && float64(longest) <= float64(shortest)*config.Tolerance {
+		ent.Interval = longest
+		ent.Learned = true
+		ent.samples = nil
+	}
+}
+
+// A Watchdog watches values which are expected to be sent cyclically and reports when one of them
+// is overdue. It receives the group communication through a subscription.
+type Watchdog struct {
+	config Config
+	sub    *knx.Subscription
+	events chan Event
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+
+	mu      sync.Mutex
+	entries map[Key]*entry
+	pending []Event
+}
+
+// New starts a watchdog which subscribes to the group communication of the mux.
+func New(mux *knx.GroupMux, config Config) *Watchdog {
+	config = checkConfig(config)
+	now := config.Clock.Now()
+
+	dog := &Watchdog{
+		config:  config,
+		events:  make(chan Event, config.BufferSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		entries: make(map[Key]*entry, len(config.Intervals)),
+	}
+
+	var addrs []cemi.GroupAddr
+	for key, interval := range config.Intervals {
+		dog.entries[key] = &entry{Status: Status{Key: key, Interval: interval}, since: now}
+		addrs = append(addrs, key.Destination)
+	}
+
+	// Values are carried by writes and responses. Unless intervals are learned, only the
+	// configured group addresses are relevant.
+	filter := knx.FilterCommands(knx.GroupWrite, knx.GroupResponse)
+	if !config.Learn {
+		byDest := knx.FilterDestinations(addrs...)
+		byCommand := filter
+
+		filter = func(event knx.GroupEvent) bool {
+			return byCommand(event) && byDest(event)
+		}
+	}
+
+	dog.sub = mux.Subscribe(filter, config.BufferSize)
+
+	ticker := config.Clock.NewTicker(config.CheckInterval)
+	go dog.serve(ticker)
+
+	return dog
+}
+
+// Events returns the channel through which stale and recovered values are reported. It is closed
+// when the watchdog is closed or the group communication ends.
+func (dog *Watchdog) Events() <-chan Event {
+	return dog.events
+}
+
+// Status returns a snapshot of all watched values, ordered by group address and source.
+func (dog *Watchdog) Status() []Status {
+	dog.mu.Lock()
+	defer dog.mu.Unlock()
+
+	status := make([]Status, 0, len(dog.entries))
+	for _, ent := range dog.entries {
+		status = append(status, ent.Status)
+	}
+
+	sort.Sort(statusSlice(status))
+
+	return status
+}
+
+// Close stops the watchdog and cancels its subscription.
+func (dog *Watchdog) Close() {
+	dog.once.Do(func() { close(dog.stop) })
+	<-dog.done
+}
+
+// serve processes the group events and checks the values until the watchdog is closed.
+func (dog *Watchdog) serve(ticker util.Ticker) {
+	util.Log(dog, "Started worker")
+	defer util.Log(dog, "Worker exited")
+
+	defer close(dog.done)
+	defer close(dog.events)
+	defer dog.sub.Unsubscribe()
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-dog.stop:
+			return
+
+		case event, open := <-dog.sub.Events():
+			if !open {
+				return
+			}
+
+			dog.observe(event)
+
+		case <-ticker.C():
+			dog.check()
+		}
+
+		if !dog.flush() {
+			return
+		}
+	}
+}
+
+// flush delivers the pending events. It returns false if the watchdog has been closed meanwhile.
+func (dog *Watchdog) flush() bool {
+	dog.mu.Lock()
+	pending := dog.pending
+	dog.pending = nil
+	dog.mu.Unlock()
+
+	for _, event := range pending {
+		select {
+		case dog.events <- event:
+		case <-dog.stop:
+			return false
+		}
+	}
+
+	return true
+}
+
+// lookup finds the entry that the group event belongs to. Entries for a specific source take
+// precedence over those for any source. New entries are created when learning.
+func (dog *Watchdog) lookup(event knx.GroupEvent, now time.Time) *entry {
+	key := Key{Destination: event.Destination, Source: event.Source}
+	if ent, ok := dog.entries[key]; ok {
+		return ent
+	}
+
+	if ent, ok := dog.entries[Key{Destination: event.Destination}]; ok {
+		return ent
+	}
+
+	if !dog.config.Learn {
+		return nil
+	}
+
+	ent := &entry{Status: Status{Key: key}, since: now}
+	dog.entries[key] = ent
+
+	return ent
+}
+
+// observe records the arrival of a value.
+func (dog *Watchdog) observe(event knx.GroupEvent) {
+	now := dog.config.Clock.Now()
+
+	dog.mu.Lock()
+	defer dog.mu.Unlock()
+
+	ent := dog.lookup(event, now)
+	if ent == nil {
+		return
+	}
+
+	if ent.Received > 0 && !ent.Learned && ent.Interval == 0 {
+		ent.learn(now.Sub(ent.LastSeen), dog.config)
+	}
+
+	if ent.Stale {
+		util.Log(dog, "Value of %v from %v has recovered", ent.Key.Destination, event.Source)
+
+		ent.Stale = false
+		dog.pending = append(dog.pending, Event{
+			Key:      ent.Key,
+			Kind:     Recovered,
+			Interval: ent.Interval,
+			LastSeen: ent.LastSeen,
+			Time:     now,
+		})
+	}
+
+	ent.Received++
+	ent.LastSeen = now
+}
+
+// check looks for overdue values.
+func (dog *Watchdog) check() {
+	now := dog.config.Clock.Now()
+
+	dog.mu.Lock()
+	defer dog.mu.Unlock()
+
+	keys := make([]Key, 0, len(dog.entries))
+	for key := range dog.entries {
+		keys = append(keys, key)
+	}
+
+	// Report simultaneously stale values in a deterministic order.
+	sort.Sort(keySlice(keys))
+
+	for _, key := range keys {
+		ent := dog.entries[key]
+		if ent.Stale || ent.Interval <= 0 {
+			continue
+		}
+
+		last := ent.since
+		if ent.Received > 0 {
+			last = ent.LastSeen
+		}
+
+		deadline := last.Add(time.Duration(float64(ent.Interval) * dog.config.Tolerance))
+		if !now.After(deadline) {
+			continue
+		}
+
+		util.Log(dog, "Value of %v is stale", ent.Key.Destination)
+
+		ent.Stale = true
+		ent.StaleSince = now
+		dog.pending = append(dog.pending, Event{
+			Key:      ent.Key,
+			Kind:     Stale,
+			Interval: ent.Interval,
+			LastSeen: ent.LastSeen,
+			Time:     now,
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2017 Ole Krüger.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package watchdog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/util"
+)
+
+// testClient is a group client whose inbound events are injected by the test.
+type testClient struct {
+	inbound chan knx.GroupEvent
+}
+
+func (client *testClient) Send(event knx.GroupEvent) error {
+	return nil
+}
+
+func (client *testClient) Inbound() <-chan knx.GroupEvent {
+	return client.inbound
+}
+
+// waitReceived waits until the watchdog has processed n values of the key.
+func waitReceived(t *testing.T, dog *Watchdog, key Key, n int) {
+	deadline := time.Now().Add(time.Second)
+
+	for time.Now().Before(deadline) {
+		for _, status := range dog.Status() {
+			if status.Key == key && status.Received == n {
+				return
+			}
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatalf("Watchdog has not received %d values of %v", n, key)
+}
+
+func expectEvent(t *testing.T, dog *Watchdog, expected Event) {
+	select {
+	case event := <-dog.Events():
+		if event.Key != expected.Key || event.Kind != expected.Kind ||
+			event.Interval != expected.Interval || !event.Time.Equal(expected.Time) {
+			t.Fatalf("Unexpected event: %+v", event)
+		}
+
+	case <-time.After(time.Second):
+		t.Fatalf("Expected %v event for %v", expected.Kind, expected.Key.Destination)
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := util.NewFakeClock(start)
+
+	client := &testClient{inbound: make(chan knx.GroupEvent)}
+	mux := knx.NewGroupMux(client)
+
+	sensor := cemi.NewIndividualAddr3(1, 1, 5)
+	temperature := Key{Destination: cemi.NewGroupAddr3(1, 2, 3)}
+	humidity := Key{Destination: cemi.NewGroupAddr3(1, 2, 4), Source: sensor}
+
+	dog := New(mux, Config{
+		Intervals:     map[Key]time.Duration{temperature: 10 * time.Minute},
+		Learn:         true,
+		LearnSamples:  2,
+		Tolerance:     1.5,
+		CheckInterval: time.Minute,
+		Clock:         clock,
+	})
+
+	send := func(key Key, command knx.GroupCommand) {
+		client.inbound <- knx.GroupEvent{
+			Command:     command,
+			Source:      sensor,
+			Destination: key.Destination,
+		}
+	}
+
+	send(temperature, knx.GroupWrite)
+	waitReceived(t, dog, temperature, 1)
+
+	// Reads do not carry values.
+	send(temperature, knx.GroupRead)
+
+	// The humidity interval is learned from the first values.
+	for i := 1; i <= 3; i++ {
+		send(humidity, knx.GroupResponse)
+		waitReceived(t, dog, humidity, i)
+
+		if i < 3 {
+			clock.Advance(5 * time.Minute)
+		}
+	}
+
+	status := dog.Status()
+	if len(status) != 2 || status[0].Received != 1 || !status[1].Learned ||
+		status[1].Interval != 5*time.Minute {
+		t.Fatalf("Unexpected status: %+v", status)
+	}
+
+	// The temperature is overdue after 15 minutes, the humidity after 17.5 minutes.
+	clock.Advance(4 * time.Minute)
+	clock.Advance(2 * time.Minute)
+	expectEvent(t, dog, Event{
+		Key:      temperature,
+		Kind:     Stale,
+		Interval: 10 * time.Minute,
+		Time:     start.Add(16 * time.Minute),
+	})
+
+	clock.Advance(2 * time.Minute)
+	expectEvent(t, dog, Event{
+		Key:      humidity,
+		Kind:     Stale,
+		Interval: 5 * time.Minute,
+		Time:     start.Add(18 * time.Minute),
+	})
+
+	send(temperature, knx.GroupWrite)
+	expectEvent(t, dog, Event{
+		Key:      temperature,
+		Kind:     Recovered,
+		Interval: 10 * time.Minute,
+		Time:     start.Add(18 * time.Minute),
+	})
+
+	if status := dog.Status(); status[0].Stale || !status[1].Stale {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+
+	dog.Close()
+
+	if _, ok := <-dog.Events(); ok {
+		t.Error("Events should be closed")
+	}
+}
+
+func TestWatchdog_Unseen(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := util.NewFakeClock(start)
+
+	client := &testClient{inbound: make(chan knx.GroupEvent)}
+	key := Key{Destination: cemi.NewGroupAddr3(1, 2, 3)}
+
+	dog := New(knx.NewGroupMux(client), Config{
+		Intervals:     map[Key]time.Duration{key: time.Minute},
+		CheckInterval: time.Minute,
+		Clock:         clock,
+	})
+
+	// Values that never arrive become stale as well.
+	clock.Advance(2 * time.Minute)
+	expectEvent(t, dog, Event{
+		Key:      key,
+		Kind:     Stale,
+		Interval: time.Minute,
+		Time:     start.Add(2 * time.Minute),
+	})
+
+	// The watchdog stops when the group communication ends.
+	close(client.inbound)
+
+	if _, ok := <-dog.Events(); ok {
+		t.Error("Events should be closed")
+	}
+}